@@ -1,18 +1,116 @@
 package main
 
 import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/kemsta/go-easyrsa/pkg/enroll"
+	"github.com/kemsta/go-easyrsa/pkg/pair"
 	"github.com/kemsta/go-easyrsa/pkg/pki"
+	"github.com/kemsta/go-easyrsa/pkg/signer/awskms"
+	"github.com/kemsta/go-easyrsa/pkg/signer/azurekv"
+	"github.com/kemsta/go-easyrsa/pkg/signer/gcpkms"
+	"github.com/kemsta/go-easyrsa/pkg/signer/piv"
+	"github.com/kemsta/go-easyrsa/pkg/signer/pkcs11"
 	"github.com/spf13/cobra"
 	"log"
+	"math/big"
 	"net"
+	"net/http"
 	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 )
 
 var keyDir string
+var indexFile string
+var postIssueHooks []string
+var postRevokeHooks []string
+var logLockWaits bool
+var logScanWarnings bool
+var scanWarnings chan pki.ScanWarning
+var scanWarningsLogged chan struct{}
+var olderThan time.Duration
+var publishDirs []string
+var publishS3 []string
+var ageRecipients []string
+var gpgRecipients []string
+var outFile string
+var taKeyFile string
+var opensslSerial bool
+var serialWidth int
+var serialUpper bool
+var crlURLs []string
+var listenAddr string
+var enrollProfile string
+var enrollDir string
+var enrollToken string
+var reqDir string
+var fixturesDir string
+var fixturesSeed int64
+var submitProfile string
+var apiTokensFile string
+var ouRoles []string
+var tlsCert string
+var tlsKey string
+var logRequests bool
+var replayWindow time.Duration
+var tokenTTL time.Duration
+var anyCN bool
+var pkcs11Module string
+var pkcs11Slot string
+var pkcs11KeyID string
+var awsKMSKey string
+var gcpKMSKey string
+var azureKVKey string
+var pivSlot string
+var caPass string
+var storePass string
+var machineStore bool
+var keyAlgo string
+var keySize int
+var crlPruneAfter time.Duration
+var crlDays int
+var listJSON bool
+var strict bool
+var rebuildIndex bool
+var validDays int
+var leafDefaults pki.LeafDefaults
+var dryRun bool
+var maxValidities []string
+var rejectLongValidity bool
+var auditFile string
+var issuedBefore string
+var filterOU string
+var cnPattern string
+var labelArgs []string
+var reportFormat string
+var rotateOverlap time.Duration
+var caPathLen int
+var rotateCrossSign bool
+var rotateReissue bool
+var defaultDNSSuffixes []string
+var dnsZones []string
+var dnsSubnets []string
+var dnsGuard bool
+var ctLogURLs []string
+var caName string
+var stapleChain string
 var pkiI *pki.PKI
 var serverDnsNames []string
 var serverIPs []net.IP
+var serverName string
+var issuerSerial string
+var issuerCA string
+var revokeReason string
+var compromisedAt string
 
 var rootCmd = &cobra.Command{
 	Use: "easyrsa",
@@ -23,6 +121,12 @@ var rootCmd = &cobra.Command{
 			log.Fatal(err)
 		}
 	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		if scanWarnings != nil {
+			close(scanWarnings)
+			<-scanWarningsLogged
+		}
+	},
 }
 
 func Execute() {
@@ -36,7 +140,7 @@ var buildCa = &cobra.Command{
 	Use:   "build-ca [CN]",
 	Short: "build ca cert/key with optional CN",
 	Run: func(cmd *cobra.Command, args []string) {
-		var options []pki.Option
+		options := []pki.Option{pki.MaxPathLen(caPathLen)}
 		if len(args) > 0 {
 			options = append(options, pki.CN(args[0]))
 		}
@@ -48,18 +152,44 @@ var buildCa = &cobra.Command{
 }
 
 var buildServerKey = &cobra.Command{
-	Use:   "build-server-key CN",
-	Short: "build server cert/key with CN",
-	Args:  cobra.MinimumNArgs(1),
+	Use:   "build-server-key [CN]",
+	Short: "build server cert/key with CN or SAN-only cert with --dns/--ip",
+	Args:  cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		options := []pki.Option{pki.Server()}
-		if serverDnsNames != nil {
-			options = append(options, pki.DNSNames(serverDnsNames))
+		id := pki.Identity{
+			Name:        serverName,
+			DNSNames:    serverDnsNames,
+			IPAddresses: serverIPs,
+			Profile:     pki.ProfileServer,
+		}
+		if len(args) > 0 {
+			id.CommonName = args[0]
+		}
+		options, err := issuerOptions()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		options = append(options, validityOptions()...)
+		if len(labelArgs) > 0 {
+			labels, err := pki.ParseLabels(labelArgs...)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			options = append(options, pki.Labeled(labels))
 		}
-		if serverIPs != nil {
-			options = append(options, pki.IPAddresses(serverIPs))
+		checkIssuerExpiry(id, options)
+		if dryRun {
+			preview, err := pkiI.PreviewIdentity(id, options...)
+			if err != nil {
+				fmt.Println(fmt.Errorf("can`t preview server pair: %s", err))
+				return
+			}
+			printPreview(preview)
+			return
 		}
-		if _, err := pkiI.NewCert(args[0], options...); err != nil {
+		if _, err := pkiI.Issue(id, options...); err != nil {
 			fmt.Println(fmt.Errorf("can`t build server pair: %s", err))
 		}
 	},
@@ -70,7 +200,32 @@ var buildKey = &cobra.Command{
 	Short: "build client cert/key with CN",
 	Args:  cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		_, err := pkiI.NewCert(args[0], pki.Client())
+		options, err := issuerOptions()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		options = append(options, validityOptions()...)
+		if len(labelArgs) > 0 {
+			labels, err := pki.ParseLabels(labelArgs...)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			options = append(options, pki.Labeled(labels))
+		}
+		id := pki.Identity{CommonName: args[0], Profile: pki.ProfileClient}
+		checkIssuerExpiry(id, options)
+		if dryRun {
+			preview, err := pkiI.PreviewIdentity(id, options...)
+			if err != nil {
+				fmt.Println(fmt.Errorf("can`t preview client pair: %s", err))
+				return
+			}
+			printPreview(preview)
+			return
+		}
+		_, err = pkiI.NewClientCert(args[0], options...)
 		if err != nil {
 			fmt.Println(fmt.Errorf("can`t build client pair: %s", err))
 		}
@@ -82,23 +237,1923 @@ var revokeFull = &cobra.Command{
 	Short: "revoke cert with CN",
 	Args:  cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		err := pkiI.RevokeAllByCN(args[0])
+		options, err := revokeOptions()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		err = pkiI.RevokeAllByCN(args[0], options...)
 		if err != nil {
 			fmt.Println(fmt.Errorf("can`t revoke cert: %s", err))
 		}
 	},
 }
 
-func init() {
-	rootCmd.PersistentFlags().StringVarP(&keyDir, "key-dir", "k", "keys", "")
-	buildServerKey.Flags().StringArrayVarP(&serverDnsNames, "dns", "n", nil, "server dns names")
-	buildServerKey.Flags().IPSliceVarP(&serverIPs, "ip", "i", nil, "server ip addresses")
-	rootCmd.AddCommand(buildCa)
-	rootCmd.AddCommand(buildServerKey)
-	rootCmd.AddCommand(buildKey)
-	rootCmd.AddCommand(revokeFull)
+var revokeWhere = &cobra.Command{
+	Use:   "revoke-where",
+	Short: "revoke all certs matching --issued-before, --ou, --cn and --label filters with one crl update",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		filters := make([]pki.CertFilter, 0)
+		if issuedBefore != "" {
+			date, err := time.Parse(time.RFC3339, issuedBefore)
+			if err != nil {
+				fmt.Println(fmt.Errorf("bad issued before date: %s", err))
+				return
+			}
+			filters = append(filters, pki.IssuedBefore(date))
+		}
+		if filterOU != "" {
+			filters = append(filters, pki.OrganizationalUnit(filterOU))
+		}
+		if cnPattern != "" {
+			if _, err := path.Match(cnPattern, ""); err != nil {
+				fmt.Println(fmt.Errorf("bad cn pattern: %s", err))
+				return
+			}
+			filters = append(filters, pki.CNPattern(cnPattern))
+		}
+		if len(labelArgs) > 0 {
+			labels, err := pki.ParseLabels(labelArgs...)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			filters = append(filters, pkiI.HasLabels(labels))
+		}
+		if len(filters) == 0 {
+			fmt.Println("at least one filter is required")
+			return
+		}
+		options, err := revokeOptions()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		revoked, err := pkiI.RevokeWhere(pki.AllOf(filters...), options...)
+		for _, certPair := range revoked {
+			fmt.Printf("%v\t%v\n", certPair.Serial.Text(16), certPair.CN)
+		}
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t revoke certs: %s", err))
+		}
+	},
 }
 
-func getPki() (*pki.PKI, error) {
-	return pki.InitPKI(keyDir, nil)
+var reissueAll = &cobra.Command{
+	Use:   "reissue-all",
+	Short: "re-sign all valid certs with the last ca or with ca from --issuer",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		var newCA *pair.X509Pair
+		var err error
+		if issuerSerial != "" {
+			serial, ok := new(big.Int).SetString(issuerSerial, 16)
+			if !ok {
+				fmt.Println(fmt.Errorf("bad issuer serial %q", issuerSerial))
+				return
+			}
+			newCA, err = pkiI.Storage.GetBySerial(serial)
+		} else {
+			newCA, err = pkiI.GetLastCA()
+		}
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t get ca: %s", err))
+			return
+		}
+		reissued, err := pkiI.ReissueAll(newCA)
+		for _, certPair := range reissued {
+			fmt.Printf("%v\t%v\n", certPair.Serial.Text(16), certPair.CN)
+		}
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t reissue certs: %s", err))
+		}
+	},
+}
+
+var rotateCA = &cobra.Command{
+	Use:   "rotate-ca [CN]",
+	Short: "replace the last ca with new one, both of them are trusted until --overlap ends",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		options := []pki.RotationOption{pki.RotationOverlap(rotateOverlap)}
+		caOptions := append(validityOptions(), pki.MaxPathLen(caPathLen))
+		if len(args) > 0 {
+			caOptions = append(caOptions, pki.CN(args[0]))
+		}
+		options = append(options, pki.RotationCAOptions(caOptions...))
+		if rotateCrossSign {
+			options = append(options, pki.RotationCrossSign())
+		}
+		if rotateReissue {
+			options = append(options, pki.RotationReissue())
+		}
+		newCA, err := pkiI.RotateCA(options...)
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t rotate ca: %s", err))
+			return
+		}
+		fmt.Printf("%v\t%v\n", newCA.Serial.Text(16), newCA.CN)
+	},
+}
+
+var renewCA = &cobra.Command{
+	Use:   "renew-ca",
+	Short: "re-sign the last ca with the same key and subject and new validity, issued certs stay valid",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		renewed, err := pkiI.RenewCa(validityOptions()...)
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t renew ca: %s", err))
+			return
+		}
+		fmt.Printf("%v\t%v\n", renewed.Serial.Text(16), renewed.CN)
+	},
+}
+
+var exportCCD = &cobra.Command{
+	Use:   "export-ccd DIR",
+	Short: "write openvpn client-config-dir with client settings from metadata",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if _, err := pkiI.ExportCCD(args[0]); err != nil {
+			fmt.Println(fmt.Errorf("can`t export ccd: %s", err))
+		}
+	},
+}
+
+var importCCD = &cobra.Command{
+	Use:   "import-ccd DIR",
+	Short: "read openvpn client-config-dir into client metadata",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		imported, err := pkiI.ImportCCD(args[0])
+		for _, name := range imported {
+			fmt.Println(name)
+		}
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t import ccd: %s", err))
+		}
+	},
+}
+
+var cleanTemp = &cobra.Command{
+	Use:   "clean-temp",
+	Short: "remove temp files left in key dir by interrupted writes",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		var removed []string
+		var err error
+		if olderThan > 0 {
+			removed, err = pkiI.CleanStaleTemp(olderThan)
+		} else {
+			removed, err = pkiI.CleanTemp()
+		}
+		for _, path := range removed {
+			fmt.Println(path)
+		}
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t clean temp files: %s", err))
+		}
+	},
+}
+
+var publishCmd = &cobra.Command{
+	Use:   "publish",
+	Short: "write ca.crt, crl.pem and chain.pem to --publish-dir and --publish-s3 destinations",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		publishers := getPublishers()
+		if len(publishers) == 0 {
+			fmt.Println("no publish destinations, set --publish-dir or --publish-s3")
+			return
+		}
+		for _, publisher := range publishers {
+			if err := pkiI.Publish(publisher); err != nil {
+				fmt.Println(fmt.Errorf("can`t publish: %s", err))
+			}
+		}
+	},
+}
+
+var checkCRL = &cobra.Command{
+	Use:   "check-crl URL...",
+	Short: "compare crl served by distribution urls with local one",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		statuses, err := pkiI.CheckCRLPropagation(args, nil)
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t check crl: %s", err))
+			return
+		}
+		for _, status := range statuses {
+			switch {
+			case status.Err != nil:
+				fmt.Printf("%v\terror: %v\n", status.URL, status.Err)
+			case status.Lagging():
+				fmt.Printf("%v\tlagging: issued %v, missing %v, extra %v\n",
+					status.URL, status.ThisUpdate.Format(time.RFC3339), status.Missing, status.Extra)
+			default:
+				fmt.Printf("%v\tok\n", status.URL)
+			}
+		}
+	},
+}
+
+var exportEncrypted = &cobra.Command{
+	Use:   "export-encrypted NAME",
+	Short: "export key, cert and ca chain encrypted to --age or --gpg recipients",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		encrypter, err := getEncrypter()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		content, err := pkiI.ExportEncrypted(args[0], encrypter)
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t export %v: %s", args[0], err))
+			return
+		}
+		writeOutput(content)
+	},
+}
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "write tar.gz of all pairs, crl and index encrypted to --age or --gpg recipients",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		encrypter, err := getEncrypter()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		content, err := pkiI.BackupEncrypted(encrypter)
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t backup: %s", err))
+			return
+		}
+		writeOutput(content)
+	},
+}
+
+var exportZip = &cobra.Command{
+	Use:   "export-zip CN",
+	Short: "write zip with cert, key, ca.crt, optional ta.key and README for end user",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var taKey []byte
+		if taKeyFile != "" {
+			var err error
+			if taKey, err = os.ReadFile(taKeyFile); err != nil {
+				fmt.Println(fmt.Errorf("can`t read ta key: %s", err))
+				return
+			}
+		}
+		content, err := pkiI.ExportZip(args[0], taKey)
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t export %v: %s", args[0], err))
+			return
+		}
+		if outFile == "" {
+			outFile = args[0] + ".zip"
+		}
+		writeOutput(content)
+	},
+}
+
+var exportTruststore = &cobra.Command{
+	Use:   "export-truststore",
+	Short: "write java truststore in JKS format with valid ca certs",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		password, err := storePassword("keystore", "changeit")
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		content, err := pkiI.ExportTruststore(password)
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t export truststore: %s", err))
+			return
+		}
+		if outFile == "" {
+			outFile = "truststore.jks"
+		}
+		writeOutput(content)
+	},
+}
+
+var exportKeystore = &cobra.Command{
+	Use:   "export-keystore CN",
+	Short: "write java keystore in JKS format with key and cert chain of CN",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		password, err := storePassword("keystore", "")
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		content, err := pkiI.ExportKeystore(args[0], password)
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t export %v: %s", args[0], err))
+			return
+		}
+		if outFile == "" {
+			outFile = args[0] + ".jks"
+		}
+		writeOutput(content)
+	},
+}
+
+var exportPFX = &cobra.Command{
+	Use:   "export-pfx CN",
+	Short: "write PKCS#12 file with key and cert chain of CN",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		password, err := storePassword("pfx", "")
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		content, err := pkiI.ExportPFX(args[0], password)
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t export %v: %s", args[0], err))
+			return
+		}
+		if outFile == "" {
+			outFile = args[0] + ".pfx"
+		}
+		writeOutput(content)
+	},
+}
+
+var exportWindows = &cobra.Command{
+	Use:   "export-windows CN",
+	Short: "write zip with pfx, ca certs and PowerShell and certutil installers for windows endpoints",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		password, err := storePassword("pfx", "")
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		store := pki.WindowsCurrentUser
+		if machineStore {
+			store = pki.WindowsLocalMachine
+		}
+		content, err := pkiI.ExportWindows(args[0], password, store)
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t export %v: %s", args[0], err))
+			return
+		}
+		if outFile == "" {
+			outFile = args[0] + "-windows.zip"
+		}
+		writeOutput(content)
+	},
+}
+
+var setLeafDefaults = &cobra.Command{
+	Use:   "set-leaf-defaults",
+	Short: "set crl, ocsp, ca issuers urls and policies inherited by every cert signed by ca",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := pkiI.SetLeafDefaults(pkiI.CAName(), leafDefaults); err != nil {
+			fmt.Println(fmt.Errorf("can`t set leaf defaults: %s", err))
+		}
+	},
+}
+
+var importCa = &cobra.Command{
+	Use:   "import-ca KEY_FILE CERT_FILE",
+	Short: "import existing ca, e.g. root generated by openssl, to sign next certs with it",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		keyPEM, err := os.ReadFile(args[0])
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t read %v: %s", args[0], err))
+			return
+		}
+		certPEM, err := os.ReadFile(args[1])
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t read %v: %s", args[1], err))
+			return
+		}
+		ca, err := pkiI.ImportCA(keyPEM, certPEM)
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t import ca: %s", err))
+			return
+		}
+		fmt.Printf("imported ca with serial %x\n", ca.Serial)
+		warnNotLastCA(ca)
+	},
+}
+
+var importCaCert = &cobra.Command{
+	Use:   "import-ca-cert CERT_FILE",
+	Short: "import ca certificate whose key is kept in PKCS#11 token, PIV slot or cloud KMS",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		content, err := os.ReadFile(args[0])
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t read %v: %s", args[0], err))
+			return
+		}
+		ca, err := pkiI.ImportCaCert(content)
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t import ca cert: %s", err))
+			return
+		}
+		fmt.Printf("imported ca with serial %x\n", ca.Serial)
+		warnNotLastCA(ca)
+	},
+}
+
+var importCRL = &cobra.Command{
+	Use:   "import-crl CRL_FILE",
+	Short: "merge revocations from crl signed by ca elsewhere, e.g. by shell easy-rsa",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		content, err := os.ReadFile(args[0])
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t read %v: %s", args[0], err))
+			return
+		}
+		entries, err := pkiI.ImportCRL(content)
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t import crl: %s", err))
+			return
+		}
+		for _, entry := range entries {
+			fmt.Printf("%x\n", entry.SerialNumber)
+		}
+	},
+}
+
+var syncCmd = &cobra.Command{
+	Use:   "sync DIR",
+	Short: "copy new and changed pairs and crl to standby key dir",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		standby, err := pki.InitPKI(args[0], nil)
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t init standby pki: %s", err))
+			return
+		}
+		res, err := pki.Sync(pkiI, standby)
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t sync: %s", err))
+			return
+		}
+		fmt.Printf("added %v, updated %v pairs, crl updated: %v\n", len(res.Added), len(res.Updated), res.CRL)
+	},
+}
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "serve ca.crt, ca.der, chain.pem and crl.pem over http",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		mux := http.NewServeMux()
+		mux.Handle("/", pki.NewHandler(pkiI))
+		auth, err := getAuthenticator()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if cmd.Flags().Changed("enroll") {
+			var opts []pki.EnrollOption
+			if replayWindow > 0 {
+				opts = append(opts, pki.EnrollReplayWindow(replayWindow))
+			}
+			if auth != nil {
+				opts = append(opts, pki.EnrollRequireIssuer())
+			}
+			enrollHandler, err := pki.NewEnrollHandler(pkiI, pki.Profile(enrollProfile), opts...)
+			if err != nil {
+				fmt.Println(fmt.Errorf("can`t serve enrollment: %s", err))
+				os.Exit(1)
+			}
+			mux.Handle(pki.EnrollPath, enrollHandler)
+		}
+		if auth != nil {
+			mux.Handle(pki.RevokePath, pki.RequireRole(pki.RoleRevoker, pki.NewRevokeHandler(pkiI)))
+			mux.Handle(pki.CertsPath, pki.RequireRole(pki.RoleAuditor, pki.NewCertsHandler(pkiI)))
+		}
+		var handler http.Handler = mux
+		if logRequests {
+			encoder := json.NewEncoder(os.Stderr)
+			handler = pki.WithRequestLog(func(record pki.RequestLog) {
+				_ = encoder.Encode(record)
+			}, handler)
+		}
+		server := &http.Server{Addr: listenAddr, Handler: pki.WithRoles(auth, handler)}
+		log.Printf("serving %v on %v", keyDir, listenAddr)
+		if tlsCert != "" {
+			pool, err := pkiI.CertPool()
+			if err != nil {
+				fmt.Println(fmt.Errorf("can`t get client cas: %s", err))
+				os.Exit(1)
+			}
+			server.TLSConfig = &tls.Config{ClientCAs: pool, ClientAuth: tls.VerifyClientCertIfGiven}
+			err = server.ListenAndServeTLS(tlsCert, tlsKey)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t serve: %s", err))
+			os.Exit(1)
+		}
+	},
+}
+
+var enrollCmd = &cobra.Command{
+	Use:   "enroll URL NAME",
+	Short: "generate key locally and get its cert from ca served with serve --enroll",
+	Args:  cobra.ExactArgs(2),
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		// enrollment client doesn`t use local pki
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		client := &enroll.Client{URL: args[0], Token: enrollToken}
+		certPair, err := client.Enroll(enroll.Request{CommonName: args[1], DNSNames: serverDnsNames, IPAddresses: serverIPs})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		certPath, keyPath := filepath.Join(enrollDir, args[1]+".crt"), filepath.Join(enrollDir, args[1]+".key")
+		if err := enroll.WriteFiles(certPair, certPath, keyPath); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("enrolled %v with serial %x\n", certPath, certPair.Serial)
+	},
+}
+
+var genReq = &cobra.Command{
+	Use:   "gen-req CN",
+	Short: "generate key and csr for signing by external ca into CN.key and CN.req",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := pki.CheckName(args[0]); err != nil {
+			fmt.Println(fmt.Errorf("can`t generate request: %s", err))
+			return
+		}
+		csr, keyPair, err := pkiI.NewRequest(args[0], keySize,
+			pki.RequestDNSNames(serverDnsNames...), pki.RequestIPAddresses(serverIPs...))
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t generate request: %s", err))
+			return
+		}
+		keyPath := filepath.Join(reqDir, args[0]+".key")
+		if err := os.WriteFile(keyPath, keyPair.KeyPemBytes, 0600); err != nil {
+			fmt.Println(fmt.Errorf("can`t write %v: %s", keyPath, err))
+			return
+		}
+		reqPath := filepath.Join(reqDir, args[0]+".req")
+		if err := os.WriteFile(reqPath, csr, 0644); err != nil {
+			fmt.Println(fmt.Errorf("can`t write %v: %s", reqPath, err))
+			return
+		}
+		fmt.Printf("generated %v and %v\n", keyPath, reqPath)
+	},
+}
+
+var genIntermediateReq = &cobra.Command{
+	Use:   "gen-intermediate-req CN",
+	Short: "generate intermediate ca key kept in keydir and its csr CN.req for signing by offline root",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := pki.CheckName(args[0]); err != nil {
+			fmt.Println(fmt.Errorf("can`t generate intermediate request: %s", err))
+			return
+		}
+		csr, _, err := pkiI.NewIntermediateRequest(args[0])
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t generate intermediate request: %s", err))
+			return
+		}
+		reqPath := filepath.Join(reqDir, args[0]+".req")
+		if err := os.WriteFile(reqPath, csr, 0644); err != nil {
+			fmt.Println(fmt.Errorf("can`t write %v: %s", reqPath, err))
+			return
+		}
+		fmt.Printf("generated %v\n", reqPath)
+	},
+}
+
+var signIntermediate = &cobra.Command{
+	Use:   "sign-intermediate CSR_FILE",
+	Short: "sign csr of intermediate ca with offline root and print its cert followed by root cert",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		csr, err := os.ReadFile(args[0])
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t read %v: %s", args[0], err))
+			return
+		}
+		root, err := pkiI.GetLastCA()
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t get root: %s", err))
+			return
+		}
+		intermediate, err := pkiI.SignIntermediate(csr, validityOptions()...)
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t sign intermediate: %s", err))
+			return
+		}
+		fmt.Print(string(intermediate.CertPemBytes) + string(root.CertPemBytes))
+	},
+}
+
+var importIntermediate = &cobra.Command{
+	Use:   "import-intermediate CHAIN_FILE [KEY_FILE]",
+	Short: "import intermediate ca signed by offline root, key from gen-intermediate-req or token is used without KEY_FILE",
+	Args:  cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		chain, err := os.ReadFile(args[0])
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t read %v: %s", args[0], err))
+			return
+		}
+		var keyPEM []byte
+		if len(args) > 1 {
+			if keyPEM, err = os.ReadFile(args[1]); err != nil {
+				fmt.Println(fmt.Errorf("can`t read %v: %s", args[1], err))
+				return
+			}
+		}
+		ca, err := pkiI.ImportIntermediate(keyPEM, chain)
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t import intermediate: %s", err))
+			return
+		}
+		fmt.Printf("imported intermediate with serial %x\n", ca.Serial)
+	},
+}
+
+var genFixtures = &cobra.Command{
+	Use:   "gen-fixtures",
+	Short: "generate reproducible pki with valid, expired and revoked certs for integration tests",
+	Args:  cobra.NoArgs,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		// fixtures are generated in --out instead of keydir
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		var options []pki.PKIOption
+		if keySize != 0 {
+			options = append(options, pki.WithKeySize(keySize))
+		}
+		if _, err := pki.GenerateFixtures(fixturesDir, fixturesSeed, options...); err != nil {
+			fmt.Println(fmt.Errorf("can`t generate fixtures: %s", err))
+			os.Exit(1)
+		}
+		fmt.Printf("generated fixtures in %v: ca, %v, %v, %v and %v\n", fixturesDir,
+			pki.FixtureServer, pki.FixtureClient, pki.FixtureExpired, pki.FixtureRevoked)
+	},
+}
+
+var submitReq = &cobra.Command{
+	Use:   "submit CSR_FILE",
+	Short: "put pem or der csr into approval queue and print its serial",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		csr, err := os.ReadFile(args[0])
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t read %v: %s", args[0], err))
+			return
+		}
+		serial, err := pkiI.Submit(csr, pki.Profile(submitProfile))
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t submit request: %s", err))
+			return
+		}
+		fmt.Println(serial.Text(16))
+	},
+}
+
+var pendingCmd = &cobra.Command{
+	Use:   "pending",
+	Short: "print serial, profile, submission time and name of requests waiting for approval",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		requests, err := pkiI.Pending()
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t list requests: %s", err))
+			return
+		}
+		for _, req := range requests {
+			name := ""
+			if csr, err := pki.ParseRequest(req.CSR); err == nil {
+				name = csr.Subject.CommonName
+			}
+			fmt.Printf("%v\t%v\t%v\t%v\n", req.Serial.Text(16), req.Profile, req.Submitted.Format(time.RFC3339), name)
+		}
+	},
+}
+
+var approveCmd = &cobra.Command{
+	Use:   "approve SERIAL",
+	Short: "sign pending request with hex serial",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		serial, ok := new(big.Int).SetString(args[0], 16)
+		if !ok {
+			fmt.Println(fmt.Errorf("can`t parse serial %q", args[0]))
+			return
+		}
+		certPair, err := pkiI.Approve(serial)
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t approve request: %s", err))
+			return
+		}
+		fmt.Printf("issued %v with serial %x\n", certPair.CN, certPair.Serial)
+	},
+}
+
+var denyCmd = &cobra.Command{
+	Use:   "deny SERIAL",
+	Short: "drop pending request with hex serial",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		serial, ok := new(big.Int).SetString(args[0], 16)
+		if !ok {
+			fmt.Println(fmt.Errorf("can`t parse serial %q", args[0]))
+			return
+		}
+		if err := pkiI.Deny(serial); err != nil {
+			fmt.Println(fmt.Errorf("can`t deny request: %s", err))
+		}
+	},
+}
+
+var mintToken = &cobra.Command{
+	Use:   "mint-token CN",
+	Short: "print single-use token for enroll bound to CN, or to any CN with --any-cn, and to --dns and --ip SANs",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if anyCN == (len(args) > 0) {
+			fmt.Println("can`t mint token: set either CN or --any-cn")
+			os.Exit(1)
+		}
+		sans := []pki.RequestOption{pki.RequestDNSNames(serverDnsNames...), pki.RequestIPAddresses(serverIPs...)}
+		var token string
+		var err error
+		if anyCN {
+			token, err = pkiI.MintAnyCNToken(tokenTTL, sans...)
+		} else {
+			token, err = pkiI.MintToken(args[0], tokenTTL, sans...)
+		}
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t mint token: %s", err))
+			return
+		}
+		fmt.Println(token)
+	},
+}
+
+var genKey = &cobra.Command{
+	Use:   "gen-key",
+	Short: "generate private key with --key-algo and --key-size without cert",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		key, err := pkiI.GenerateKey()
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t generate key: %s", err))
+			return
+		}
+		keyPEM, err := pki.EncodeKey(key)
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t encode key: %s", err))
+			return
+		}
+		writeOutput(keyPEM)
+	},
+}
+
+var renewCmd = &cobra.Command{
+	Use:   "renew CN",
+	Short: "issue new cert for the existing key of the last cert with CN",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		last, err := pkiI.Storage.GetLastByCn(args[0])
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t get cert: %s", err))
+			return
+		}
+		renewed, err := pkiI.Renew(last.Serial, validityOptions()...)
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t renew cert: %s", err))
+			return
+		}
+		fmt.Printf("renewed %v with serial %v\n", renewed.CN, renewed.Serial.Text(16))
+	},
+}
+
+var reKeyCmd = &cobra.Command{
+	Use:   "rekey CN",
+	Short: "issue cert with new key copying subject, sans and usages of the last cert with CN",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		rekeyed, err := pkiI.ReKey(args[0], validityOptions()...)
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t rekey cert: %s", err))
+			return
+		}
+		fmt.Printf("rekeyed %v with serial %v\n", rekeyed.CN, rekeyed.Serial.Text(16))
+	},
+}
+
+var genCRL = &cobra.Command{
+	Use:   "gen-crl",
+	Short: "sign crl again with the same entries and new next update, e.g. from cron with --crl-days",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := pkiI.RefreshCRL(); err != nil {
+			fmt.Println(fmt.Errorf("can`t generate crl: %s", err))
+		}
+	},
+}
+
+var pruneCRL = &cobra.Command{
+	Use:   "prune-crl",
+	Short: "remove crl entries of expired certs",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		pruned, err := pkiI.PruneCRL(olderThan)
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t prune crl: %s", err))
+			return
+		}
+		for _, serial := range pruned {
+			fmt.Println(serial.Text(16))
+		}
+	},
+}
+
+var healthCmd = &cobra.Command{
+	Use:   "health",
+	Short: "print crl size and entry count",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		report, err := pkiI.Health()
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t get health: %s", err))
+			return
+		}
+		fmt.Printf("crl size\t%v\n", report.CRLSize)
+		fmt.Printf("crl entries\t%v\n", report.CRLEntries)
+		fmt.Printf("crl expired entries\t%v\n", report.CRLExpiredEntries)
+		fmt.Printf("crl next update\t%v\n", report.CRLNextUpdate.Format(time.RFC3339))
+	},
+}
+
+var holdCmd = &cobra.Command{
+	Use:   "hold CN",
+	Short: "suspend all certs with CN until release",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		pairs, err := pkiI.Storage.GetByCN(args[0])
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t hold cert: %s", err))
+			return
+		}
+		for _, certPair := range pairs {
+			if err := pkiI.Hold(certPair.Serial); err != nil {
+				fmt.Println(fmt.Errorf("can`t hold cert: %s", err))
+			}
+		}
+	},
+}
+
+var releaseCmd = &cobra.Command{
+	Use:   "release CN",
+	Short: "release suspended certs with CN",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		pairs, err := pkiI.Storage.GetByCN(args[0])
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t release cert: %s", err))
+			return
+		}
+		for _, certPair := range pairs {
+			if status, err := pkiI.Status(certPair.Serial); err != nil || status != pki.CertStatusSuspended {
+				continue
+			}
+			if err := pkiI.RemoveFromCRL(certPair.Serial); err != nil {
+				fmt.Println(fmt.Errorf("can`t release cert: %s", err))
+			}
+		}
+	},
+}
+
+var unrevokeCmd = &cobra.Command{
+	Use:   "unrevoke SERIAL",
+	Short: "remove cert with hex serial revoked with certificateHold reason from crl",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		serial, ok := new(big.Int).SetString(args[0], 16)
+		if !ok {
+			fmt.Println(fmt.Errorf("can`t parse serial %q", args[0]))
+			return
+		}
+		if err := pkiI.Unrevoke(serial); err != nil {
+			fmt.Println(fmt.Errorf("can`t unrevoke cert: %s", err))
+		}
+	},
+}
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "print issuance and expiry summary of leaf certs by month, profile and status",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		report, err := pkiI.Report()
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t build report: %s", err))
+			return
+		}
+		switch reportFormat {
+		case "json":
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			err = encoder.Encode(report)
+		case "csv":
+			writer := csv.NewWriter(os.Stdout)
+			_ = writer.Write([]string{"section", "key", "value"})
+			_ = writer.WriteAll(reportRows(report))
+			err = writer.Error()
+		case "text":
+			for _, row := range reportRows(report) {
+				fmt.Println(strings.Join(row, "\t"))
+			}
+		default:
+			err = fmt.Errorf("unknown format %q", reportFormat)
+		}
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t print report: %s", err))
+		}
+	},
+}
+
+var labelCmd = &cobra.Command{
+	Use:   "label NAME [KEY=VALUE...]",
+	Short: "add labels to identity, KEY= removes label. Print its labels",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		labels, err := pki.ParseLabels(args[1:]...)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if len(labels) > 0 {
+			if err := pkiI.Label(args[0], labels); err != nil {
+				fmt.Println(fmt.Errorf("can`t label %v: %s", args[0], err))
+				return
+			}
+		}
+		if labels, err = pkiI.Labels(args[0]); err != nil {
+			fmt.Println(fmt.Errorf("can`t get labels of %v: %s", args[0], err))
+			return
+		}
+		fmt.Println(labels)
+	},
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status CN",
+	Short: "print status of all certs with CN",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		infos, err := pkiI.Find(pki.Named(args[0]))
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t get certs: %s", err))
+			return
+		}
+		for _, info := range infos {
+			fmt.Printf("%v\t%v\n", info.Serial.Text(16), info.Status)
+		}
+	},
+}
+
+var fingerprintCmd = &cobra.Command{
+	Use:   "fingerprint FINGERPRINT",
+	Short: "print name and serial of cert with sha256 fingerprint, colons are allowed like in openssl output",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if rebuildIndex {
+			if err := pkiI.RebuildFingerprintIndex(); err != nil {
+				fmt.Println(fmt.Errorf("can`t rebuild fingerprint index: %s", err))
+				return
+			}
+		}
+		certPair, err := pkiI.GetByFingerprint(args[0])
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t find cert: %s", err))
+			return
+		}
+		fmt.Printf("%v\t%v\n", certPair.CN, certPair.Serial.Text(16))
+	},
+}
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "print serial, status, expiration and name of all certs",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		filters := make([]pki.CertFilter, 0)
+		if cnPattern != "" {
+			if _, err := path.Match(cnPattern, ""); err != nil {
+				fmt.Println(fmt.Errorf("bad cn pattern: %s", err))
+				return
+			}
+			filters = append(filters, pki.CNPattern(cnPattern))
+		}
+		if len(labelArgs) > 0 {
+			labels, err := pki.ParseLabels(labelArgs...)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			filters = append(filters, pkiI.HasLabels(labels))
+		}
+		infos, err := pkiI.Find(pki.AllOf(filters...))
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t list certs: %s", err))
+			return
+		}
+		if listJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(infos); err != nil {
+				fmt.Println(fmt.Errorf("can`t print certs: %s", err))
+			}
+			return
+		}
+		for _, info := range infos {
+			fmt.Printf("%v\t%v\t%v\t%v\n", info.Serial.Text(16), info.Status,
+				info.NotAfter.Format(time.RFC3339), info.Name)
+		}
+	},
+}
+
+var recoverCmd = &cobra.Command{
+	Use:   "recover",
+	Short: "finish revocations interrupted by crash",
+	Run: func(cmd *cobra.Command, args []string) {
+		recovered, err := pkiI.Recover()
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t recover: %s", err))
+		}
+		fmt.Printf("%v interrupted revocations are finished\n", recovered)
+	},
+}
+
+var trustCa = &cobra.Command{
+	Use:   "trust-ca BUNDLE_FILE",
+	Short: "trust partner ca certificates from pem bundle for verification",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		bundle, err := os.ReadFile(args[0])
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t read bundle: %s", err))
+			return
+		}
+		fingerprints, err := pkiI.ImportTrustedCA(bundle)
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t trust ca: %s", err))
+			return
+		}
+		for _, fingerprint := range fingerprints {
+			fmt.Println(fingerprint)
+		}
+	},
+}
+
+var verifyCert = &cobra.Command{
+	Use:   "verify CERT_FILE",
+	Short: "verify pem certificate against issuing and trusted ca certificates",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		certPEM, err := os.ReadFile(args[0])
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t read cert: %s", err))
+			os.Exit(1)
+		}
+		if _, err := pkiI.Verify(certPEM); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println("ok")
+	},
+}
+
+var showIndex = &cobra.Command{
+	Use:   "index",
+	Short: "print openssl compatible index of all certificates",
+	Run: func(cmd *cobra.Command, args []string) {
+		index, err := pkiI.Index()
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t build index: %s", err))
+			return
+		}
+		if err := index.Encode(os.Stdout); err != nil {
+			fmt.Println(fmt.Errorf("can`t print index: %s", err))
+		}
+	},
+}
+
+var showJWKS = &cobra.Command{
+	Use:   "jwks",
+	Short: "print public keys of all valid ca certificates as json web key set",
+	Run: func(cmd *cobra.Command, args []string) {
+		jwks, err := pkiI.JWKS()
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t get jwks: %s", err))
+			return
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(jwks); err != nil {
+			fmt.Println(fmt.Errorf("can`t print jwks: %s", err))
+		}
+	},
+}
+
+var snapshot = &cobra.Command{
+	Use:   "snapshot DIR",
+	Short: "write immutable hash-addressed snapshot of pki into DIR",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		snapshotDir, err := pkiI.Snapshot(args[0])
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t create snapshot: %s", err))
+			return
+		}
+		fmt.Println(snapshotDir)
+	},
+}
+
+var verifySnapshot = &cobra.Command{
+	Use:   "verify-snapshot SNAPSHOT_DIR",
+	Short: "verify snapshot content against its manifest",
+	Args:  cobra.ExactArgs(1),
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		// snapshot is verified without pki
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		manifest, err := pki.VerifySnapshot(args[0])
+		if err != nil {
+			fmt.Println(fmt.Errorf("snapshot is broken: %s", err))
+			os.Exit(1)
+		}
+		fmt.Printf("snapshot created at %v with %v files is ok\n", manifest.Created, len(manifest.Files))
+	},
+}
+
+var exportCounters = &cobra.Command{
+	Use:   "export-counters",
+	Short: "print serial and crl number counters as json to restore them with import-counters",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		counters, err := pkiI.Counters()
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t get counters: %s", err))
+			return
+		}
+		content, err := json.Marshal(counters)
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t marshal counters: %s", err))
+			return
+		}
+		fmt.Println(string(content))
+	},
+}
+
+var importCounters = &cobra.Command{
+	Use:   "import-counters FILE",
+	Short: "advance serial and crl number counters to state printed by export-counters",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		content, err := os.ReadFile(args[0])
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t read %v: %s", args[0], err))
+			return
+		}
+		counters := pki.Counters{}
+		if err := json.Unmarshal(content, &counters); err != nil {
+			fmt.Println(fmt.Errorf("can`t parse counters: %s", err))
+			return
+		}
+		if err := pkiI.RestoreCounters(counters); err != nil {
+			fmt.Println(fmt.Errorf("can`t import counters: %s", err))
+		}
+	},
+}
+
+var caBundle = &cobra.Command{
+	Use:   "ca-bundle",
+	Short: "print all valid ca certificates as pem bundle",
+	Run: func(cmd *cobra.Command, args []string) {
+		bundle, err := pkiI.GetTrustBundle()
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t get ca bundle: %s", err))
+			return
+		}
+		fmt.Print(string(bundle))
+	},
+}
+
+var selfTest = &cobra.Command{
+	Use:   "self-test",
+	Short: "build ca, issue certs, do tls handshake, revoke and check rejection in temp dir",
+	Args:  cobra.NoArgs,
+	// key dir isn`t touched
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+	Run: func(cmd *cobra.Command, args []string) {
+		dir, err := os.MkdirTemp("", "easyrsa-self-test")
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t create temp dir: %s", err))
+			os.Exit(1)
+		}
+		defer os.RemoveAll(dir)
+		steps, err := pki.SelfTest(cmd.Context(), filepath.Join(dir, "pki"),
+			pki.WithKeyAlgorithm(pki.KeyAlgorithm(keyAlgo)), pki.WithKeySize(keySize))
+		for _, step := range steps {
+			status := "ok"
+			if step.Err != nil {
+				status = "FAIL"
+			}
+			fmt.Printf("%-24v%v\t%v\n", step.Name, status, step.Duration.Round(time.Millisecond))
+		}
+		if err != nil {
+			fmt.Println(fmt.Errorf("self test failed: %s", err))
+			_ = os.RemoveAll(dir)
+			os.Exit(1)
+		}
+	},
+}
+
+var caNames = &cobra.Command{
+	Use:   "ca-names",
+	Short: "print names of all roots in key dir, see --ca-name",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		names, err := pkiI.CANames()
+		if err != nil {
+			fmt.Println(fmt.Errorf("can`t get ca names: %s", err))
+			return
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+	},
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVarP(&keyDir, "key-dir", "k", "keys", "")
+	rootCmd.PersistentFlags().StringVar(&caName, "ca-name", pki.DefaultCAName,
+		"name of ca to work with, several roots with different names can share key dir")
+	rootCmd.PersistentFlags().StringArrayVar(&postIssueHooks, "post-issue-hook", nil,
+		"command to run after issue, e.g. \"systemctl reload nginx\". {{.CN}}, {{.CertPath}}, {{.KeyPath}} are substituted")
+	rootCmd.PersistentFlags().StringArrayVar(&postRevokeHooks, "post-revoke-hook", nil,
+		"command to run after revoke, hold or release, e.g. \"cp {{.CRLPath}} /etc/openvpn/crl.pem\"")
+	rootCmd.PersistentFlags().StringVar(&stapleChain, "staple-chain", "none",
+		"keep ca chain with new certs: cert appends it to .crt file, fullchain writes SERIAL.fullchain.crt next to it")
+	rootCmd.PersistentFlags().StringVar(&indexFile, "index-file", "", "keep openssl compatible index in file up to date")
+	rootCmd.PersistentFlags().StringArrayVar(&defaultDNSSuffixes, "default-dns-suffix", nil,
+		"add \"<cn>.<suffix>\" dns name to every issued cert with hostname cn")
+	rootCmd.PersistentFlags().StringArrayVar(&maxValidities, "max-validity", nil,
+		"maximum validity of profile certs, e.g. server=8760h or client=2160h")
+	rootCmd.PersistentFlags().BoolVar(&rejectLongValidity, "reject-long-validity", false,
+		"reject certs longer than --max-validity instead of clamping them")
+	rootCmd.PersistentFlags().BoolVar(&dnsGuard, "dns-guard", false,
+		"refuse server certs with dns names which don`t resolve")
+	rootCmd.PersistentFlags().StringArrayVar(&dnsZones, "dns-zone", nil,
+		"refuse server certs with dns names out of zone, e.g. corp.example.com. Implies --dns-guard")
+	rootCmd.PersistentFlags().StringArrayVar(&dnsSubnets, "dns-subnet", nil,
+		"refuse server certs with dns names resolving out of subnet, e.g. 10.0.0.0/8. Implies --dns-guard")
+	rootCmd.PersistentFlags().StringArrayVar(&ctLogURLs, "ct-log", nil,
+		"submit leaf certs to certificate transparency log with url and embed returned scts, e.g. https://ct.example.com/2024")
+	rootCmd.PersistentFlags().StringVar(&auditFile, "audit-file", "", "append policy decisions to audit file")
+	rootCmd.PersistentFlags().BoolVar(&logLockWaits, "log-lock-waits", false, "log lock wait durations to stderr")
+	rootCmd.PersistentFlags().StringArrayVar(&publishDirs, "publish-dir", nil,
+		"write ca.crt, crl.pem and chain.pem to dir after every change, e.g. web root")
+	rootCmd.PersistentFlags().StringArrayVar(&publishS3, "publish-s3", nil,
+		"put ca.crt, crl.pem and chain.pem to BUCKET[/PREFIX] after every change. "+
+			"Credentials, region and endpoint are taken from AWS_* environment variables")
+	rootCmd.PersistentFlags().BoolVar(&opensslSerial, "openssl-serial", false,
+		"keep serial file in openssl format to share it with openssl ca and easy-rsa")
+	rootCmd.PersistentFlags().IntVar(&serialWidth, "serial-width", 0,
+		"zero pad serials in names of new cert and key files to this number of hex digits")
+	rootCmd.PersistentFlags().BoolVar(&serialUpper, "serial-upper", false,
+		"use upper case hex serials in names of new cert and key files")
+	rootCmd.PersistentFlags().StringArrayVar(&crlURLs, "crl-distribution-point", nil,
+		"crl url put into every cert signed by ca unless ca has leaf defaults")
+	rootCmd.PersistentFlags().BoolVar(&logScanWarnings, "log-scan-warnings", false,
+		"log files in key dir which don`t belong to it to stderr")
+	rootCmd.PersistentFlags().StringVar(&pkcs11Module, "pkcs11-module", "",
+		"sign with ca key kept in PKCS#11 token with module, pin is taken from EASYRSA_PKCS11_PIN. pkcs11-tool is required")
+	rootCmd.PersistentFlags().StringVar(&pkcs11Slot, "pkcs11-slot", "", "PKCS#11 slot id, the first token by default")
+	rootCmd.PersistentFlags().StringVar(&pkcs11KeyID, "pkcs11-id", "", "hex id of ca key object in PKCS#11 token")
+	rootCmd.PersistentFlags().StringVar(&keyAlgo, "key-algo", string(pki.KeyRSA), "algorithm of new keys, rsa or ecdsa")
+	rootCmd.PersistentFlags().IntVar(&keySize, "key-size", 0,
+		"size of new keys, 2048 for rsa and 256 for ecdsa by default. rsa keys are at least 2048, ecdsa keys are 256, 384 or 521")
+	rootCmd.PersistentFlags().IntVar(&crlDays, "crl-days", 0,
+		"days until next update of signed crls, clients reject crl after it. Practically unlimited by default")
+	rootCmd.PersistentFlags().DurationVar(&crlPruneAfter, "crl-prune-after", 0,
+		"drop crl entries of certs expired for duration on every crl update, e.g. 720h. Disabled by default")
+	rootCmd.PersistentFlags().StringVar(&caPass, "ca-pass", "",
+		"encrypt new ca keys and decrypt encrypted ones with passphrase from env:NAME, file:PATH, exec:COMMAND or stdin")
+	rootCmd.PersistentFlags().StringVar(&awsKMSKey, "aws-kms-key", "",
+		"sign with ca key kept in AWS KMS with key id, arn or alias. "+
+			"Credentials, region and endpoint are taken from AWS_* environment variables")
+	rootCmd.PersistentFlags().StringVar(&gcpKMSKey, "gcp-kms-key", "",
+		"sign with ca key kept in Google Cloud KMS with key version resource name. "+
+			"Access token is taken from GOOGLE_OAUTH_ACCESS_TOKEN, metadata server or gcloud")
+	rootCmd.PersistentFlags().StringVar(&azureKVKey, "azure-kv-key", "",
+		"sign with ca key kept in Azure Key Vault with key identifier. "+
+			"Access token is taken from AZURE_ACCESS_TOKEN, managed identity or az")
+	rootCmd.PersistentFlags().StringVar(&pivSlot, "piv-slot", "",
+		"sign with ca key kept in YubiKey PIV slot, e.g. 9c, pin is taken from EASYRSA_PIV_PIN. "+
+			"pkcs11-tool and ykcs11 are required")
+	buildServerKey.Flags().StringArrayVarP(&serverDnsNames, "dns", "n", nil, "server dns names")
+	buildServerKey.Flags().IPSliceVarP(&serverIPs, "ip", "i", nil, "server ip addresses")
+	fingerprintCmd.Flags().BoolVar(&rebuildIndex, "rebuild-index", false,
+		"rebuild fingerprint index first, e.g. after pairs were copied into key dir by hand")
+	buildServerKey.Flags().StringVar(&issuerSerial, "issuer", "", "hex serial of signing ca, the last ca by default")
+	buildServerKey.Flags().StringVar(&issuerCA, "issuer-ca", "", "name of signing ca, --ca-name by default")
+	reissueAll.Flags().StringVar(&issuerSerial, "issuer", "", "hex serial of new ca, the last ca by default")
+	buildKey.Flags().StringVar(&issuerSerial, "issuer", "", "hex serial of signing ca, the last ca by default")
+	buildKey.Flags().StringVar(&issuerCA, "issuer-ca", "", "name of signing ca, --ca-name by default")
+	for _, cmd := range []*cobra.Command{buildServerKey, buildKey} {
+		cmd.Flags().IntVar(&validDays, "days", 0, "cert validity in days, until signing ca expiration by default")
+		cmd.Flags().BoolVar(&strict, "strict", false, "fail if cert would outlive signing ca")
+	}
+	buildServerKey.Flags().BoolVar(&dryRun, "dry-run", false, "print certificate which would be signed without issuing it")
+	buildKey.Flags().BoolVar(&dryRun, "dry-run", false, "print certificate which would be signed without issuing it")
+	buildServerKey.Flags().StringVar(&serverName, "name", "", "storage name, CN or the first SAN by default")
+	revokeFull.Flags().StringVar(&revokeReason, "reason", "", "revocation reason, e.g. keyCompromise or superseded")
+	revokeFull.Flags().StringVar(&compromisedAt, "compromised-at", "", "key compromise date in RFC3339 format")
+	revokeWhere.Flags().StringVar(&revokeReason, "reason", "", "revocation reason, e.g. keyCompromise or superseded")
+	revokeWhere.Flags().StringVar(&compromisedAt, "compromised-at", "", "key compromise date in RFC3339 format")
+	revokeWhere.Flags().StringVar(&issuedBefore, "issued-before", "", "select certs issued before date in RFC3339 format")
+	cleanTemp.Flags().DurationVar(&olderThan, "older-than", 0,
+		"remove only temp files not modified for duration, e.g. 1h. All of them by default")
+	for _, cmd := range []*cobra.Command{exportEncrypted, backupCmd} {
+		cmd.Flags().StringArrayVar(&ageRecipients, "age", nil, "age recipient, e.g. age1... or ssh public key")
+		cmd.Flags().StringArrayVar(&gpgRecipients, "gpg", nil, "gpg recipient key id or email from keyring")
+		cmd.Flags().StringVarP(&outFile, "out", "o", "", "output file, stdout by default")
+	}
+	genKey.Flags().StringVarP(&outFile, "out", "o", "", "output file, stdout by default")
+	exportZip.Flags().StringVar(&taKeyFile, "ta-key", "", "openvpn tls-auth key to include as ta.key")
+	exportZip.Flags().StringVarP(&outFile, "out", "o", "", "output file, <CN>.zip by default")
+	exportTruststore.Flags().StringVar(&storePass, "store-pass", "",
+		"truststore password from env:NAME, file:PATH, exec:COMMAND or stdin, \"changeit\" by default")
+	exportKeystore.Flags().StringVar(&storePass, "store-pass", "stdin",
+		"keystore password from env:NAME, file:PATH, exec:COMMAND or stdin")
+	for _, cmd := range []*cobra.Command{exportPFX, exportWindows} {
+		cmd.Flags().StringVar(&storePass, "store-pass", "stdin",
+			"pfx password from env:NAME, file:PATH, exec:COMMAND or stdin")
+	}
+	exportPFX.Flags().StringVarP(&outFile, "out", "o", "", "output file, <CN>.pfx by default")
+	exportWindows.Flags().StringVarP(&outFile, "out", "o", "", "output file, <CN>-windows.zip by default")
+	exportWindows.Flags().BoolVar(&machineStore, "machine", false,
+		"install to LocalMachine stores for all users instead of CurrentUser ones, requires administrator rights")
+	exportTruststore.Flags().StringVarP(&outFile, "out", "o", "", "output file, truststore.jks by default")
+	exportKeystore.Flags().StringVarP(&outFile, "out", "o", "", "output file, <CN>.jks by default")
+	setLeafDefaults.Flags().StringArrayVar(&leafDefaults.CRLDistributionPoints, "crl-url", nil, "crl distribution point")
+	setLeafDefaults.Flags().StringArrayVar(&leafDefaults.OCSPServers, "ocsp-url", nil, "ocsp responder url")
+	setLeafDefaults.Flags().StringArrayVar(&leafDefaults.IssuingCertificateURLs, "ca-issuers-url", nil, "ca certificate url")
+	setLeafDefaults.Flags().StringArrayVar(&leafDefaults.Policies, "policy", nil, "certificate policy oid")
+	revokeWhere.Flags().StringVar(&filterOU, "ou", "", "select certs with organizational unit")
+	serveCmd.Flags().StringVar(&listenAddr, "listen", ":8080", "http listen address")
+	serveCmd.Flags().StringVar(&enrollProfile, "enroll", "client",
+		"sign csrs with tokens from mint-token posted to "+pki.EnrollPath+" with profile, "+
+			"enrollment is disabled without the flag")
+	serveCmd.Flags().StringVar(&apiTokensFile, "api-tokens", "",
+		"file with \"TOKEN ROLE[,ROLE...]\" lines, roles are issuer, revoker and auditor. "+
+			"Enables "+pki.RevokePath+" and "+pki.CertsPath)
+	serveCmd.Flags().StringArrayVar(&ouRoles, "ou-role", nil,
+		"grant role to tls client certs with organizational unit, e.g. ops=revoker. "+
+			"Enables "+pki.RevokePath+" and "+pki.CertsPath)
+	serveCmd.Flags().StringVar(&tlsCert, "tls-cert", "", "serve https with pem cert, client certs are verified by pki cas")
+	serveCmd.Flags().StringVar(&tlsKey, "tls-key", "", "pem key of --tls-cert")
+	serveCmd.Flags().BoolVar(&logRequests, "log-requests", false, "log requests as json lines to stderr")
+	serveCmd.Flags().DurationVar(&replayWindow, "replay-window", 0,
+		"require enrollment requests signed with nonce and time within window, e.g. 5m. Disabled by default")
+	enrollCmd.Flags().StringArrayVarP(&serverDnsNames, "dns", "n", nil, "dns names")
+	enrollCmd.Flags().IPSliceVarP(&serverIPs, "ip", "i", nil, "ip addresses")
+	enrollCmd.Flags().StringVarP(&enrollDir, "out", "o", ".", "output dir for NAME.crt and NAME.key")
+	enrollCmd.Flags().StringVar(&enrollToken, "token", "", "enrollment token from mint-token")
+	genReq.Flags().StringArrayVarP(&serverDnsNames, "dns", "n", nil, "dns names")
+	genReq.Flags().IPSliceVarP(&serverIPs, "ip", "i", nil, "ip addresses")
+	genReq.Flags().StringVarP(&reqDir, "out", "o", ".", "output dir for CN.key and CN.req")
+	genIntermediateReq.Flags().StringVarP(&reqDir, "out", "o", ".", "output dir for CN.req")
+	signIntermediate.Flags().IntVar(&validDays, "days", 0, "intermediate validity in days, until root expiration by default")
+	genFixtures.Flags().StringVarP(&fixturesDir, "out", "o", "fixtures", "output dir, must be empty or missing")
+	genFixtures.Flags().Int64Var(&fixturesSeed, "seed", 1, "seed of keys, the same seed produces the same files")
+	submitReq.Flags().StringVar(&submitProfile, "profile", "client", "profile of certificate, client or server")
+	mintToken.Flags().DurationVar(&tokenTTL, "ttl", 24*time.Hour, "token validity")
+	mintToken.Flags().BoolVar(&anyCN, "any-cn", false, "let token holder choose any CN except ca names")
+	mintToken.Flags().StringArrayVarP(&serverDnsNames, "dns", "n", nil, "dns names csr can have")
+	mintToken.Flags().IPSliceVarP(&serverIPs, "ip", "i", nil, "ip addresses csr can have")
+	for _, cmd := range []*cobra.Command{renewCmd, reKeyCmd} {
+		cmd.Flags().IntVar(&validDays, "days", 0, "cert validity in days, until signing ca expiration by default")
+	}
+	listCmd.Flags().BoolVar(&listJSON, "json", false, "print certs as json")
+	for _, cmd := range []*cobra.Command{listCmd, revokeWhere} {
+		cmd.Flags().StringVar(&cnPattern, "cn", "", "select certs with name matching glob pattern, e.g. vpn-client-*")
+		cmd.Flags().StringArrayVar(&labelArgs, "label", nil, "select certs of identities with label KEY=VALUE")
+	}
+	rotateCA.Flags().DurationVar(&rotateOverlap, "overlap", pki.DefaultRotationOverlap,
+		"period previous ca stays trusted, e.g. 168h")
+	rotateCA.Flags().BoolVar(&rotateCrossSign, "cross-sign", false,
+		"sign new ca with previous one as well for peers trusting only previous ca")
+	rotateCA.Flags().BoolVar(&rotateReissue, "reissue", false, "re-sign all valid certs with new ca")
+	rotateCA.Flags().IntVar(&validDays, "days", 0, "new ca validity in days")
+	renewCA.Flags().IntVar(&validDays, "days", 0, "renewed ca validity in days")
+	for _, cmd := range []*cobra.Command{buildCa, rotateCA} {
+		cmd.Flags().IntVar(&caPathLen, "path-len", 0,
+			"levels of intermediate cas allowed below ca, -1 removes constraint. Only leaf certs by default")
+	}
+	reportCmd.Flags().StringVar(&reportFormat, "format", "text", "output format: text, json or csv")
+	for _, cmd := range []*cobra.Command{buildKey, buildServerKey} {
+		cmd.Flags().StringArrayVar(&labelArgs, "label", nil, "label identity with KEY=VALUE, e.g. team=infra")
+	}
+	pruneCRL.Flags().DurationVar(&olderThan, "older-than", 0,
+		"remove only entries of certs expired for duration, e.g. 720h. All of them by default")
+	rootCmd.AddCommand(buildCa)
+	rootCmd.AddCommand(buildServerKey)
+	rootCmd.AddCommand(buildKey)
+	rootCmd.AddCommand(revokeFull)
+	rootCmd.AddCommand(revokeWhere)
+	rootCmd.AddCommand(reissueAll)
+	rootCmd.AddCommand(rotateCA)
+	rootCmd.AddCommand(renewCA)
+	rootCmd.AddCommand(exportCCD)
+	rootCmd.AddCommand(importCCD)
+	rootCmd.AddCommand(cleanTemp)
+	rootCmd.AddCommand(publishCmd)
+	rootCmd.AddCommand(checkCRL)
+	rootCmd.AddCommand(exportEncrypted)
+	rootCmd.AddCommand(backupCmd)
+	rootCmd.AddCommand(exportZip)
+	rootCmd.AddCommand(exportTruststore)
+	rootCmd.AddCommand(exportKeystore)
+	rootCmd.AddCommand(exportPFX)
+	rootCmd.AddCommand(exportWindows)
+	rootCmd.AddCommand(setLeafDefaults)
+	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(importCa)
+	rootCmd.AddCommand(importCaCert)
+	rootCmd.AddCommand(importCRL)
+	rootCmd.AddCommand(syncCmd)
+	rootCmd.AddCommand(enrollCmd)
+	rootCmd.AddCommand(genKey)
+	rootCmd.AddCommand(genReq)
+	rootCmd.AddCommand(genIntermediateReq)
+	rootCmd.AddCommand(signIntermediate)
+	rootCmd.AddCommand(importIntermediate)
+	rootCmd.AddCommand(genFixtures)
+	rootCmd.AddCommand(mintToken)
+	rootCmd.AddCommand(submitReq)
+	rootCmd.AddCommand(pendingCmd)
+	rootCmd.AddCommand(approveCmd)
+	rootCmd.AddCommand(denyCmd)
+	rootCmd.AddCommand(genCRL)
+	rootCmd.AddCommand(pruneCRL)
+	rootCmd.AddCommand(renewCmd)
+	rootCmd.AddCommand(reKeyCmd)
+	rootCmd.AddCommand(listCmd)
+	rootCmd.AddCommand(healthCmd)
+	rootCmd.AddCommand(reportCmd)
+	rootCmd.AddCommand(holdCmd)
+	rootCmd.AddCommand(releaseCmd)
+	rootCmd.AddCommand(unrevokeCmd)
+	rootCmd.AddCommand(statusCmd)
+	rootCmd.AddCommand(labelCmd)
+	rootCmd.AddCommand(recoverCmd)
+	rootCmd.AddCommand(caBundle)
+	rootCmd.AddCommand(caNames)
+	rootCmd.AddCommand(selfTest)
+	rootCmd.AddCommand(trustCa)
+	rootCmd.AddCommand(verifyCert)
+	rootCmd.AddCommand(showIndex)
+	rootCmd.AddCommand(showJWKS)
+	rootCmd.AddCommand(snapshot)
+	rootCmd.AddCommand(verifySnapshot)
+	rootCmd.AddCommand(exportCounters)
+	rootCmd.AddCommand(fingerprintCmd)
+	rootCmd.AddCommand(importCounters)
+}
+
+// getAuthenticator return authenticator of serve callers by --api-tokens and --ou-role, nil if there are none
+func getAuthenticator() (pki.Authenticator, error) {
+	auths := make([]pki.Authenticator, 0)
+	if apiTokensFile != "" {
+		if tlsCert == "" {
+			return nil, errors.New("--api-tokens requires --tls-cert and --tls-key")
+		}
+		content, err := os.ReadFile(apiTokensFile)
+		if err != nil {
+			return nil, fmt.Errorf("can`t read api tokens: %w", err)
+		}
+		tokens, err := pki.ParseAPITokens(content)
+		if err != nil {
+			return nil, err
+		}
+		auths = append(auths, pki.APITokens(tokens))
+	}
+	if len(ouRoles) > 0 {
+		if tlsCert == "" {
+			return nil, errors.New("--ou-role requires --tls-cert and --tls-key")
+		}
+		byOU := map[string][]pki.Role{}
+		for _, ouRole := range ouRoles {
+			ou, name, ok := strings.Cut(ouRole, "=")
+			if !ok {
+				return nil, fmt.Errorf("bad ou role %q, expected OU=ROLE", ouRole)
+			}
+			role, err := pki.ParseRole(name)
+			if err != nil {
+				return nil, fmt.Errorf("bad ou role %q: %w", ouRole, err)
+			}
+			byOU[ou] = append(byOU[ou], role)
+		}
+		auths = append(auths, pkiI.ClientCertRoles(byOU))
+	}
+	if len(auths) == 0 {
+		return nil, nil
+	}
+	return pki.AnyAuthenticator(auths...), nil
+}
+
+// logWarnings log scan warnings until warnings is closed and then close logged
+func logWarnings(warnings <-chan pki.ScanWarning, logged chan<- struct{}) {
+	for warning := range warnings {
+		log.Printf("skip %v %v", warning.Kind, warning.Path)
+	}
+	close(logged)
+}
+
+// warnNotLastCA tell that imported ca doesn`t sign next certs because stored ca has greater serial
+func warnNotLastCA(ca *pair.X509Pair) {
+	last, err := pkiI.GetLastCA()
+	if err != nil || last.Serial.Cmp(ca.Serial) == 0 {
+		return
+	}
+	log.Printf("warning: ca with serial %x still signs next certs, use --issuer %x to sign with imported one", last.Serial, ca.Serial)
+}
+
+// checkIssuerExpiry warn about cert outliving its ca or exit with --strict
+func checkIssuerExpiry(id pki.Identity, options []pki.CertificateOption) {
+	err := pkiI.CheckIssuerExpiry(id, options...)
+	var outlives *pki.OutlivesIssuerError
+	if !errors.As(err, &outlives) {
+		return
+	}
+	if strict {
+		fmt.Println(fmt.Errorf("refuse to issue: %s", err))
+		os.Exit(1)
+	}
+	log.Printf("warning: %s", err)
+}
+
+func validityOptions() []pki.CertificateOption {
+	if validDays <= 0 {
+		return nil
+	}
+	return []pki.CertificateOption{pki.NotAfter(time.Now().AddDate(0, 0, validDays))}
+}
+
+func issuerOptions() ([]pki.CertificateOption, error) {
+	if issuerSerial == "" && issuerCA != "" {
+		return []pki.CertificateOption{pki.IssuedByCA(issuerCA)}, nil
+	}
+	if issuerSerial == "" {
+		return nil, nil
+	}
+	serial, ok := new(big.Int).SetString(issuerSerial, 16)
+	if !ok {
+		return nil, fmt.Errorf("bad issuer serial %q", issuerSerial)
+	}
+	return []pki.CertificateOption{pki.IssuedBy(serial)}, nil
+}
+
+func revokeOptions() ([]pki.RevokeOption, error) {
+	var options []pki.RevokeOption
+	if revokeReason != "" {
+		reason, err := pki.ParseRevocationReason(revokeReason)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, pki.Reason(reason))
+	}
+	if compromisedAt != "" {
+		date, err := time.Parse(time.RFC3339, compromisedAt)
+		if err != nil {
+			return nil, fmt.Errorf("bad compromise date %q: %w", compromisedAt, err)
+		}
+		options = append(options, pki.InvalidityDate(date))
+	}
+	return options, nil
+}
+
+func getPki() (*pki.PKI, error) {
+	options := []pki.PKIOption{
+		pki.WithJournalDir(filepath.Join(keyDir, ".journal")),
+		pki.WithTokenDir(filepath.Join(keyDir, ".tokens")),
+		pki.WithRequestDir(filepath.Join(keyDir, ".reqs")),
+		pki.WithTempCleanup(pki.TempMaxAge),
+		pki.WithCAName(caName),
+	}
+	if indexFile != "" {
+		options = append(options, pki.WithIndexFile(indexFile))
+	}
+	for _, suffix := range defaultDNSSuffixes {
+		options = append(options, pki.WithDefaultSANs(pki.DNSSuffix(suffix)))
+	}
+	for _, maxValidity := range maxValidities {
+		profile, duration, ok := strings.Cut(maxValidity, "=")
+		if !ok {
+			return nil, fmt.Errorf("bad max validity %q, expected profile=duration", maxValidity)
+		}
+		max, err := time.ParseDuration(duration)
+		if err != nil {
+			return nil, fmt.Errorf("bad max validity %q: %w", maxValidity, err)
+		}
+		options = append(options, pki.WithValidityPolicy(pki.ValidityPolicy{
+			Profile: pki.Profile(profile),
+			Max:     max,
+			Reject:  rejectLongValidity,
+		}))
+	}
+	if dnsGuard || len(dnsZones) > 0 || len(dnsSubnets) > 0 {
+		guard := pki.DNSGuard{Zones: dnsZones}
+		for _, subnet := range dnsSubnets {
+			_, ipNet, err := net.ParseCIDR(subnet)
+			if err != nil {
+				return nil, fmt.Errorf("bad dns subnet %q: %w", subnet, err)
+			}
+			guard.Subnets = append(guard.Subnets, ipNet)
+		}
+		options = append(options, pki.WithDNSGuard(guard))
+	}
+	stapling, err := pki.ParseChainStapling(stapleChain)
+	if err != nil {
+		return nil, err
+	}
+	options = append(options, pki.WithChainStapling(stapling))
+	for _, ctLogURL := range ctLogURLs {
+		options = append(options, pki.WithCTLogs(&pki.CTLogClient{URL: ctLogURL}))
+	}
+	if auditFile != "" {
+		options = append(options, pki.WithAuditFile(auditFile))
+	}
+	if logLockWaits {
+		options = append(options, pki.WithLockObserver(func(wait pki.LockWait) {
+			if wait.Acquired {
+				log.Printf("lock %v acquired in %v", wait.Path, wait.Wait)
+			} else {
+				log.Printf("lock %v held by %v isn`t acquired in %v", wait.Path, wait.Holder, wait.Wait)
+			}
+		}))
+	}
+	for _, publisher := range getPublishers() {
+		options = append(options, pki.WithPublisher(publisher))
+	}
+	if opensslSerial {
+		options = append(options, pki.WithOpenSSLSerial())
+	}
+	if serialWidth > 0 || serialUpper {
+		options = append(options, pki.WithSerialFileNames(serialWidth, serialUpper))
+	}
+	if len(crlURLs) > 0 {
+		options = append(options, pki.WithCRLDistributionPoints(crlURLs...))
+	}
+	if logScanWarnings {
+		scanWarnings, scanWarningsLogged = make(chan pki.ScanWarning), make(chan struct{})
+		go logWarnings(scanWarnings, scanWarningsLogged)
+		options = append(options, pki.WithScanWarnings(scanWarnings))
+	}
+	if err := pki.CheckKeySize(pki.KeyAlgorithm(keyAlgo), keySize); err != nil {
+		return nil, err
+	}
+	options = append(options, pki.WithKeyAlgorithm(pki.KeyAlgorithm(keyAlgo)), pki.WithKeySize(keySize))
+	if crlDays > 0 {
+		options = append(options, pki.WithCRLValidity(time.Duration(crlDays)*24*time.Hour))
+	}
+	if crlPruneAfter > 0 {
+		options = append(options, pki.WithCRLPruning(crlPruneAfter))
+	}
+	if caPass != "" {
+		provider, err := pki.ParsePassphrase(caPass)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, pki.WithCAPassphrase(provider))
+	}
+	signer, err := getSigner()
+	if err != nil {
+		return nil, err
+	}
+	if signer != nil {
+		options = append(options, pki.WithSigner(signer))
+	}
+	for eventType, commands := range map[pki.EventType][]string{
+		pki.EventIssue:   postIssueHooks,
+		pki.EventRevoke:  postRevokeHooks,
+		pki.EventRelease: postRevokeHooks,
+	} {
+		for _, command := range commands {
+			hook, err := pki.ExecHook(command)
+			if err != nil {
+				return nil, err
+			}
+			options = append(options, pki.WithHooks(eventType, hook))
+		}
+	}
+	return pki.InitPKI(keyDir, nil, options...)
+}
+
+// getSigner return signer of external ca key from --pkcs11-module, --piv-slot, --aws-kms-key, --gcp-kms-key
+// or --azure-kv-key, nil without them
+func getSigner() (crypto.Signer, error) {
+	configured := 0
+	for _, flag := range []string{pkcs11Module, pivSlot, awsKMSKey, gcpKMSKey, azureKVKey} {
+		if flag != "" {
+			configured++
+		}
+	}
+	switch {
+	case configured > 1:
+		return nil, errors.New("only one of --pkcs11-module, --piv-slot, --aws-kms-key, --gcp-kms-key and --azure-kv-key can be used")
+	case pkcs11Module != "":
+		signer, err := pkcs11.New(pkcs11Module, pkcs11Slot, pkcs11KeyID, os.Getenv("EASYRSA_PKCS11_PIN"))
+		if err != nil {
+			return nil, fmt.Errorf("can`t open PKCS#11 key: %w", err)
+		}
+		return signer, nil
+	case pivSlot != "":
+		signer, err := piv.New(pivSlot, os.Getenv("EASYRSA_PIV_PIN"))
+		if err != nil {
+			return nil, err
+		}
+		return signer, nil
+	case awsKMSKey != "":
+		signer, err := awskms.New(awsKMSKey)
+		if err != nil {
+			return nil, fmt.Errorf("can`t open AWS KMS key: %w", err)
+		}
+		return signer, nil
+	case gcpKMSKey != "":
+		signer, err := gcpkms.New(gcpKMSKey)
+		if err != nil {
+			return nil, fmt.Errorf("can`t open Cloud KMS key: %w", err)
+		}
+		return signer, nil
+	case azureKVKey != "":
+		signer, err := azurekv.New(azureKVKey)
+		if err != nil {
+			return nil, fmt.Errorf("can`t open Key Vault key: %w", err)
+		}
+		return signer, nil
+	}
+	return nil, nil
+}
+
+func getEncrypter() (pki.Encrypter, error) {
+	switch {
+	case len(ageRecipients) != 0 && len(gpgRecipients) != 0:
+		return nil, fmt.Errorf("--age and --gpg can`t be used together")
+	case len(ageRecipients) != 0:
+		return pki.AgeRecipients(ageRecipients...)
+	case len(gpgRecipients) != 0:
+		return pki.GPGRecipients(gpgRecipients...)
+	}
+	return nil, fmt.Errorf("set --age or --gpg recipients")
+}
+
+// storePassword return password of keystore kind by --store-pass, fallback if it isn`t set
+func storePassword(kind, fallback string) (string, error) {
+	if storePass == "" {
+		return fallback, nil
+	}
+	provider := pki.PassphraseFromPrompt("Enter "+kind+" password: ", os.Stdin, os.Stderr)
+	if storePass != "stdin" {
+		var err error
+		if provider, err = pki.ParsePassphrase(storePass); err != nil {
+			return "", err
+		}
+	}
+	password, err := provider()
+	if err != nil {
+		return "", fmt.Errorf("can`t get %v password: %w", kind, err)
+	}
+	return string(password), nil
+}
+
+func writeOutput(content []byte) {
+	if outFile == "" {
+		_, _ = os.Stdout.Write(content)
+		return
+	}
+	if err := os.WriteFile(outFile, content, 0600); err != nil {
+		fmt.Println(fmt.Errorf("can`t write %v: %s", outFile, err))
+	}
+}
+
+func getPublishers() []pki.Publisher {
+	publishers := make([]pki.Publisher, 0, len(publishDirs)+len(publishS3))
+	for _, dir := range publishDirs {
+		publishers = append(publishers, pki.NewDirPublisher(dir))
+	}
+	for _, location := range publishS3 {
+		bucket, prefix, _ := strings.Cut(location, "/")
+		if prefix != "" && !strings.HasSuffix(prefix, "/") {
+			prefix += "/"
+		}
+		region := os.Getenv("AWS_REGION")
+		if region == "" {
+			region = os.Getenv("AWS_DEFAULT_REGION")
+		}
+		publishers = append(publishers, &pki.S3Publisher{
+			Endpoint:        os.Getenv("AWS_ENDPOINT_URL"),
+			Region:          region,
+			Bucket:          bucket,
+			Prefix:          prefix,
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		})
+	}
+	return publishers
+}
+
+var extKeyUsageNames = map[x509.ExtKeyUsage]string{
+	x509.ExtKeyUsageAny:        "any",
+	x509.ExtKeyUsageServerAuth: "serverAuth",
+	x509.ExtKeyUsageClientAuth: "clientAuth",
+}
+
+// printPreview print resolved certificate template of dry run
+func printPreview(cert *x509.Certificate) {
+	fmt.Printf("subject: %v\n", cert.Subject)
+	fmt.Printf("issuer: %v\n", cert.Issuer)
+	fmt.Printf("not before: %v\n", cert.NotBefore.Format(time.RFC3339))
+	fmt.Printf("not after: %v\n", cert.NotAfter.Format(time.RFC3339))
+	if len(cert.DNSNames) > 0 {
+		fmt.Printf("dns names: %v\n", strings.Join(cert.DNSNames, ", "))
+	}
+	for _, ip := range cert.IPAddresses {
+		fmt.Printf("ip address: %v\n", ip)
+	}
+	for _, usage := range cert.ExtKeyUsage {
+		name, ok := extKeyUsageNames[usage]
+		if !ok {
+			name = fmt.Sprintf("%d", usage)
+		}
+		fmt.Printf("extended key usage: %v\n", name)
+	}
+}
+
+// reportRows return report as section, key and value rows for text and csv output
+func reportRows(report *pki.Report) [][]string {
+	rows := [][]string{
+		{"total", "cas", fmt.Sprint(report.CAs)},
+		{"total", "leaves", fmt.Sprint(report.Leaves)},
+		{"total", "revocation rate", fmt.Sprintf("%.4f", report.RevocationRate)},
+	}
+	statuses := make([]string, 0, len(report.Statuses))
+	counts := map[string]int{}
+	for status, count := range report.Statuses {
+		statuses = append(statuses, status.String())
+		counts[status.String()] = count
+	}
+	sort.Strings(statuses)
+	for _, status := range statuses {
+		rows = append(rows, []string{"status", status, fmt.Sprint(counts[status])})
+	}
+	profiles := make([]string, 0, len(report.Profiles))
+	for profile := range report.Profiles {
+		profiles = append(profiles, string(profile))
+	}
+	sort.Strings(profiles)
+	for _, profile := range profiles {
+		name := profile
+		if name == "" {
+			name = "none"
+		}
+		rows = append(rows, []string{"profile", name, fmt.Sprint(report.Profiles[pki.Profile(profile)])})
+	}
+	for _, month := range report.Issued {
+		rows = append(rows, []string{"issued", month.Month, fmt.Sprint(month.Count)})
+	}
+	for _, month := range report.Expiring {
+		rows = append(rows, []string{"expiring", month.Month, fmt.Sprint(month.Count)})
+	}
+	return rows
 }
@@ -5,6 +5,7 @@ go 1.18
 require (
 	github.com/gofrs/flock v0.8.1
 	github.com/stretchr/testify v1.7.1
+	golang.org/x/crypto v0.0.0-20220525230936-793ad666bf5e
 )
 
 require (
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package fsStorage
+
+// restrictAccess leave access to path only to the current user. File mode is enough here.
+func restrictAccess(path string) error {
+	return nil
+}
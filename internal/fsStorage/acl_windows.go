@@ -0,0 +1,24 @@
+//go:build windows
+
+package fsStorage
+
+import (
+	"fmt"
+	"os/exec"
+	"os/user"
+)
+
+// restrictAccess leave access to path only to the current user. Chmod can`t do it on windows,
+// so inherited ACL entries are dropped with icacls.
+func restrictAccess(path string) error {
+	current, err := user.Current()
+	if err != nil {
+		return fmt.Errorf("can`t get current user: %w", err)
+	}
+	// Uid is a SID on windows, "*" makes icacls use it instead of account name
+	out, err := exec.Command("icacls", path, "/inheritance:r", "/grant:r", fmt.Sprintf("*%s:F", current.Uid)).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("can`t set acl on %v: %w: %s", path, err, out)
+	}
+	return nil
+}
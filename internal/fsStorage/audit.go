@@ -0,0 +1,42 @@
+package fsStorage
+
+import (
+	"fmt"
+	"github.com/gofrs/flock"
+	"os"
+)
+
+// FileAuditLog implement AuditLog interface with appending records as lines to file
+type FileAuditLog struct {
+	lockOptions
+	locker *flock.Flock
+	path   string
+}
+
+func NewFileAuditLog(path string) *FileAuditLog {
+	return &FileAuditLog{locker: flock.New(fmt.Sprintf("%v.lock", path)), path: path}
+}
+
+// Append record as a line to the end of file
+func (l *FileAuditLog) Append(record []byte) error {
+	unlock, err := l.acquire(l.locker)
+	if err != nil {
+		return fmt.Errorf("can`t lock audit log %v: %w", l.path, err)
+	}
+	defer func() {
+		_ = unlock()
+	}()
+	fd, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("can`t open audit log %v: %w", l.path, err)
+	}
+	if _, err := fd.Write(append(record, '\n')); err != nil {
+		_ = fd.Close()
+		return fmt.Errorf("can`t write audit log %v: %w", l.path, err)
+	}
+	if err := fd.Sync(); err != nil {
+		_ = fd.Close()
+		return fmt.Errorf("can`t flush audit log %v: %w", l.path, err)
+	}
+	return fd.Close()
+}
@@ -0,0 +1,189 @@
+package fsStorage
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	_ "crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/gofrs/flock"
+	"github.com/kemsta/go-easyrsa/pkg/pair"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fingerprintIndexName is a file in keydir mapping fingerprints of certificates to their serials. The first line is
+// the hash name, every next one is a hex fingerprint and a hex serial. Entries are appended on Put, so entries of
+// overwritten and deleted pairs stay until rebuild and are skipped on lookup.
+const fingerprintIndexName = ".fingerprints"
+
+// FingerprintHash set hash of fingerprint index, sha256 by default. Index built with other hash is rebuilt on lookup.
+func (s *DirKeyStorage) FingerprintHash(hash crypto.Hash) {
+	s.fingerprintHash = hash
+}
+
+func (s *DirKeyStorage) hash() crypto.Hash {
+	if s.fingerprintHash == 0 {
+		return crypto.SHA256
+	}
+	return s.fingerprintHash
+}
+
+// fingerprint return hex encoded hash of DER certificate from pem
+func (s *DirKeyStorage) fingerprint(certPEM []byte) (string, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return "", errors.New("no certificate pem block")
+	}
+	h := s.hash().New()
+	_, _ = h.Write(block.Bytes)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (s *DirKeyStorage) lockFingerprints() (func() error, error) {
+	if err := os.MkdirAll(s.keydir, 0755); err != nil {
+		return nil, fmt.Errorf("can`t create keydir %v: %w", s.keydir, err)
+	}
+	path := filepath.Join(s.keydir, fingerprintIndexName)
+	unlock, err := s.acquire(flock.New(fmt.Sprintf("%v.lock", path)))
+	if err != nil {
+		return nil, fmt.Errorf("can`t lock fingerprint index %v: %w", path, err)
+	}
+	return unlock, nil
+}
+
+// addFingerprint append entry of pair to fingerprint index. Index which doesn`t exist yet or is built with
+// other hash is left as is, it`s rebuilt on lookup. Pairs without readable certificate aren`t indexed.
+func (s *DirKeyStorage) addFingerprint(pair *pair.X509Pair) error {
+	fingerprint, err := s.fingerprint(pair.CertPemBytes)
+	if err != nil {
+		return nil
+	}
+	path := filepath.Join(s.keydir, fingerprintIndexName)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	unlock, err := s.lockFingerprints()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = unlock()
+	}()
+	fd, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND, 0644)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("can`t open fingerprint index %v: %w", path, err)
+	}
+	defer func() {
+		_ = fd.Close()
+	}()
+	header, _ := bufio.NewReader(fd).ReadString('\n')
+	if strings.TrimSuffix(header, "\n") != s.hash().String() {
+		return nil
+	}
+	if _, err := fmt.Fprintf(fd, "%s %s\n", fingerprint, pair.Serial.Text(16)); err != nil {
+		return fmt.Errorf("can`t write fingerprint index %v: %w", path, err)
+	}
+	return nil
+}
+
+// GetByFingerprint return pair whose certificate has hex encoded fingerprint. Colons and case are ignored, so
+// fingerprints printed by openssl x509 -fingerprint are accepted. Index is built if it doesn`t exist yet or
+// was built with other hash.
+func (s *DirKeyStorage) GetByFingerprint(fingerprint string) (*pair.X509Pair, error) {
+	fingerprint = strings.ToLower(strings.ReplaceAll(fingerprint, ":", ""))
+	serials, err := s.readFingerprints()
+	if err != nil {
+		return nil, err
+	}
+	serial, ok := serials[fingerprint]
+	if !ok {
+		return nil, fmt.Errorf("fingerprint %v %w", fingerprint, ErrNotFound)
+	}
+	res, err := s.GetBySerial(serial)
+	if err != nil {
+		return nil, err
+	}
+	if actual, err := s.fingerprint(res.CertPemBytes); err != nil || actual != fingerprint {
+		return nil, fmt.Errorf("fingerprint %v %w", fingerprint, ErrNotFound)
+	}
+	return res, nil
+}
+
+// RebuildFingerprints build fingerprint index from all pairs of keydir dropping stale entries.
+// Use it after pairs were copied into keydir bypassing storage.
+func (s *DirKeyStorage) RebuildFingerprints() error {
+	unlock, err := s.lockFingerprints()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = unlock()
+	}()
+	_, err = s.buildFingerprints()
+	return err
+}
+
+// readFingerprints return serials by fingerprints from index, the last entry wins
+func (s *DirKeyStorage) readFingerprints() (map[string]*big.Int, error) {
+	unlock, err := s.lockFingerprints()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = unlock()
+	}()
+	path := filepath.Join(s.keydir, fingerprintIndexName)
+	content, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("can`t read fingerprint index %v: %w", path, err)
+	}
+	lines := strings.Split(string(content), "\n")
+	if lines[0] != s.hash().String() {
+		return s.buildFingerprints()
+	}
+	res := make(map[string]*big.Int, len(lines)-1)
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if serial, ok := new(big.Int).SetString(fields[1], 16); ok {
+			res[fields[0]] = serial
+		}
+	}
+	return res, nil
+}
+
+// buildFingerprints write fingerprint index of all pairs and return it, index should be locked
+func (s *DirKeyStorage) buildFingerprints() (map[string]*big.Int, error) {
+	pairs, err := s.GetAllCertOnly()
+	if err != nil {
+		return nil, fmt.Errorf("can`t get pairs for fingerprint index: %w", err)
+	}
+	res := make(map[string]*big.Int, len(pairs))
+	var content bytes.Buffer
+	content.WriteString(s.hash().String() + "\n")
+	for _, p := range pairs {
+		fingerprint, err := s.fingerprint(p.CertPemBytes)
+		if err != nil {
+			continue
+		}
+		res[fingerprint] = p.Serial
+		_, _ = fmt.Fprintf(&content, "%s %s\n", fingerprint, p.Serial.Text(16))
+	}
+	path := filepath.Join(s.keydir, fingerprintIndexName)
+	if err := writeFileAtomic(path, &content, 0644); err != nil {
+		return nil, fmt.Errorf("can`t write fingerprint index %v: %w", path, err)
+	}
+	return res, nil
+}
@@ -0,0 +1,74 @@
+package fsStorage
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const intentFileExtension = ".intent" // journal intent file extension
+
+// FileJournal implement Journal interface with storing every intent in separate file in dir
+type FileJournal struct {
+	dir string
+}
+
+func NewFileJournal(dir string) *FileJournal {
+	return &FileJournal{dir: dir}
+}
+
+// Begin persist intent and return its id. Ids are ordered by creation time.
+func (j *FileJournal) Begin(intent []byte) (string, error) {
+	if err := os.MkdirAll(j.dir, 0700); err != nil {
+		return "", fmt.Errorf("can`t create journal dir %v: %w", j.dir, err)
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("can`t generate intent id: %w", err)
+	}
+	id := fmt.Sprintf("%020d-%s", time.Now().UnixNano(), hex.EncodeToString(suffix))
+	path := filepath.Join(j.dir, id+intentFileExtension)
+	if err := writeFileAtomic(path, bytes.NewReader(intent), 0600); err != nil {
+		return "", fmt.Errorf("can`t write intent %v: %w", path, err)
+	}
+	return id, nil
+}
+
+// Commit remove intent with id
+func (j *FileJournal) Commit(id string) error {
+	path := filepath.Join(j.dir, id+intentFileExtension)
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("can`t remove intent %v: %w", path, err)
+	}
+	return nil
+}
+
+// Pending return not committed intents by id
+func (j *FileJournal) Pending() (map[string][]byte, error) {
+	res := map[string][]byte{}
+	entries, err := os.ReadDir(j.dir)
+	if os.IsNotExist(err) {
+		return res, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can`t read journal dir %v: %w", j.dir, err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != intentFileExtension {
+			continue
+		}
+		path := filepath.Join(j.dir, entry.Name())
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("can`t read intent %v: %w", path, err)
+		}
+		res[strings.TrimSuffix(entry.Name(), intentFileExtension)] = content
+	}
+	return res, nil
+}
@@ -0,0 +1,150 @@
+package fsStorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gofrs/flock"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LockWait describe one attempt to acquire lock file
+type LockWait struct {
+	Path     string        // lock file path
+	Wait     time.Duration // time spent on waiting
+	Acquired bool          // false on timeout or error
+	Holder   string        // holder written into lock file, only when lock isn`t acquired
+}
+
+// ErrLocked is matched by errors of locks which weren`t acquired in lock timeout
+var ErrLocked = errors.New("lock is busy")
+
+// lockError is an error of lock which wasn`t acquired, it matches ErrLocked and wraps the cause
+type lockError struct {
+	err error
+}
+
+func (e lockError) Error() string {
+	return e.err.Error()
+}
+
+func (e lockError) Unwrap() error {
+	return e.err
+}
+
+func (e lockError) Is(target error) bool {
+	return target == ErrLocked
+}
+
+// lockOptions keep lock observer and lock timing of fs holders
+type lockOptions struct {
+	observer func(LockWait)
+	timeout  time.Duration
+	period   time.Duration
+}
+
+// ObserveLocks call fn after every attempt to acquire lock, e.g. for contention metrics or logging
+func (o *lockOptions) ObserveLocks(fn func(LockWait)) {
+	o.observer = fn
+}
+
+// LockTiming wait for locks up to timeout retrying every period instead of LockTimeout and LockPeriod.
+// Zero value keeps the default.
+func (o *lockOptions) LockTiming(timeout, period time.Duration) {
+	o.timeout, o.period = timeout, period
+}
+
+// lockTiming return lock timeout and retry period with defaults for zero values
+func (o *lockOptions) lockTiming() (timeout, period time.Duration) {
+	timeout, period = LockTimeout, LockPeriod
+	if o.timeout > 0 {
+		timeout = o.timeout
+	}
+	if o.period > 0 {
+		period = o.period
+	}
+	return timeout, period
+}
+
+// processLocks serialize goroutines of this process on lock file path. flock doesn`t exclude goroutines sharing
+// one Flock, and goroutines with own Flocks would poll with lock period instead of taking turns.
+// They aren`t reentrant: goroutine acquiring the path it already holds waits for lock timeout and gets ErrLocked.
+var processLocks sync.Map
+
+// processLock return in-process lock of path as channel with one slot
+func processLock(path string) chan struct{} {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	res, _ := processLocks.LoadOrStore(path, make(chan struct{}, 1))
+	return res.(chan struct{})
+}
+
+// acquire exclusive lock waiting for lock timeout. Holder of acquired lock writes its pid and host into lock file,
+// so on timeout the error tells who holds the lock. The lock isn`t reentrant, see processLocks.
+func (o *lockOptions) acquire(locker *flock.Flock) (unlock func() error, err error) {
+	start := time.Now()
+	timeout, period := o.lockTiming()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	local := processLock(locker.Path())
+	locked := false
+	select {
+	case local <- struct{}{}:
+		locked, err = locker.TryLockContext(ctx, period)
+		if err != nil || !locked {
+			<-local
+		}
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	wait := LockWait{Path: locker.Path(), Wait: time.Since(start), Acquired: err == nil && locked}
+	if !wait.Acquired {
+		wait.Holder = lockHolder(locker.Path())
+		o.observe(wait)
+		if err == nil {
+			err = ErrLocked
+		}
+		return nil, fmt.Errorf("held by %s, waited %v: %w", wait.Holder, wait.Wait.Round(time.Millisecond), lockError{err})
+	}
+	// best effort, lock files can`t be written while they are locked on some platforms
+	_ = ioutil.WriteFile(locker.Path(), []byte(holderInfo()), 0600)
+	o.observe(wait)
+	return func() error {
+		defer func() {
+			<-local
+		}()
+		_ = os.Truncate(locker.Path(), 0)
+		return locker.Unlock()
+	}, nil
+}
+
+func (o *lockOptions) observe(wait LockWait) {
+	if o.observer != nil {
+		o.observer(wait)
+	}
+}
+
+// holderInfo describe current process for lock file
+func holderInfo() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("pid=%d host=%s since=%s\n", os.Getpid(), host, time.Now().UTC().Format(time.RFC3339))
+}
+
+// lockHolder return holder info from lock file or "unknown holder"
+func lockHolder(path string) string {
+	content, err := ioutil.ReadFile(path)
+	holder := strings.TrimSpace(string(content))
+	if err != nil || holder == "" {
+		return "unknown holder"
+	}
+	return holder
+}
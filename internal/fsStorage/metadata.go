@@ -0,0 +1,43 @@
+package fsStorage
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const metadataFileName = "metadata.json" // sidecar metadata file name in pair directory
+
+// PutMetadata save sidecar metadata of pairs with name next to them
+func (s *DirKeyStorage) PutMetadata(name string, content []byte) error {
+	if err := checkNewName(name); err != nil {
+		return err
+	}
+	dir := filepath.Join(s.keydir, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("can`t create dir for metadata of %v: %w", name, err)
+	}
+	path := filepath.Join(dir, metadataFileName)
+	if err := writeFileAtomic(path, bytes.NewReader(content), 0644); err != nil {
+		return fmt.Errorf("can`t write metadata %v: %w", path, err)
+	}
+	return nil
+}
+
+// GetMetadata return sidecar metadata of pairs with name. Empty content without metadata.
+func (s *DirKeyStorage) GetMetadata(name string) ([]byte, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(s.keydir, name, metadataFileName)
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return []byte{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can`t read metadata %v: %w", path, err)
+	}
+	return content, nil
+}
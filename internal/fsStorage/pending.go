@@ -0,0 +1,55 @@
+package fsStorage
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const pendingKeyFileName = "pending.key" // key file name in pair directory for key whose certificate isn`t issued yet
+
+// PutPendingKey save key of pairs with name whose certificate isn`t issued yet next to them
+func (s *DirKeyStorage) PutPendingKey(name string, keyPEM []byte) error {
+	if err := checkNewName(name); err != nil {
+		return err
+	}
+	dir := filepath.Join(s.keydir, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("can`t create dir for pending key of %v: %w", name, err)
+	}
+	path := filepath.Join(dir, pendingKeyFileName)
+	if err := writeFileAtomic(path, bytes.NewReader(keyPEM), 0600); err != nil {
+		return fmt.Errorf("can`t write pending key %v: %w", path, err)
+	}
+	return nil
+}
+
+// GetPendingKey return pending key of pairs with name. Empty content without pending key.
+func (s *DirKeyStorage) GetPendingKey(name string) ([]byte, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(s.keydir, name, pendingKeyFileName)
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return []byte{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can`t read pending key %v: %w", path, err)
+	}
+	return content, nil
+}
+
+// DeletePendingKey remove pending key of pairs with name. Missing pending key isn`t an error.
+func (s *DirKeyStorage) DeletePendingKey(name string) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+	path := filepath.Join(s.keydir, name, pendingKeyFileName)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("can`t delete pending key %v: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package fsStorage
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// DirPublisher write published files into dir, e.g. web root of static server
+type DirPublisher struct {
+	dir string
+}
+
+func NewDirPublisher(dir string) *DirPublisher {
+	return &DirPublisher{dir: dir}
+}
+
+// Publish replace file with name in dir with content atomically, so web server never serves partial file
+func (p *DirPublisher) Publish(name string, content []byte) error {
+	if err := checkNewName(name); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(p.dir, 0755); err != nil {
+		return fmt.Errorf("can`t create publish dir %v: %w", p.dir, err)
+	}
+	path := filepath.Join(p.dir, name)
+	if err := writeFileAtomic(path, bytes.NewReader(content), 0644); err != nil {
+		return fmt.Errorf("can`t publish %v: %w", path, err)
+	}
+	return nil
+}
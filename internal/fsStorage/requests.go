@@ -0,0 +1,86 @@
+package fsStorage
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const requestFileExtension = ".pending" // pending request record file extension
+
+// DirRequestStore implement RequestStore interface with storing every pending request record as file in dir
+type DirRequestStore struct {
+	dir string
+}
+
+func NewDirRequestStore(dir string) *DirRequestStore {
+	return &DirRequestStore{dir: dir}
+}
+
+// Put request record with id. Overwrite if already exist.
+func (s *DirRequestStore) Put(id string, content []byte) error {
+	if err := checkNewName(id); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0750); err != nil {
+		return fmt.Errorf("can`t create request dir %v: %w", s.dir, err)
+	}
+	path := filepath.Join(s.dir, id+requestFileExtension)
+	if err := writeFileAtomic(path, bytes.NewReader(content), 0640); err != nil {
+		return fmt.Errorf("can`t write request %v: %w", path, err)
+	}
+	return nil
+}
+
+// Take remove request record with id and return its content. Record is renamed before reading, so only one
+// of concurrent approvers and deniers gets it, even from different processes.
+func (s *DirRequestStore) Take(id string) ([]byte, error) {
+	if err := checkName(id); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(s.dir, id+requestFileExtension)
+	// taken record looks like temp file, so it`s cleaned if process crashes before removing it
+	taken := filepath.Join(s.dir, "."+id+requestFileExtension+tempSuffix+"0")
+	if err := os.Rename(path, taken); err != nil {
+		return nil, fmt.Errorf("can`t take request %v: %w", id, err)
+	}
+	defer func() {
+		_ = os.Remove(taken)
+	}()
+	content, err := ioutil.ReadFile(taken)
+	if err != nil {
+		return nil, fmt.Errorf("can`t read request %v: %w", id, err)
+	}
+	return content, nil
+}
+
+// GetAll return contents of all request records by id
+func (s *DirRequestStore) GetAll() (map[string][]byte, error) {
+	entries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return map[string][]byte{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can`t read request dir %v: %w", s.dir, err)
+	}
+	res := make(map[string][]byte, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, requestFileExtension) {
+			continue
+		}
+		content, err := ioutil.ReadFile(filepath.Join(s.dir, name))
+		if os.IsNotExist(err) {
+			// taken concurrently
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("can`t read request %v: %w", name, err)
+		}
+		res[strings.TrimSuffix(name, requestFileExtension)] = content
+	}
+	return res, nil
+}
@@ -0,0 +1,285 @@
+package fsStorage
+
+import (
+	"encoding/pem"
+	"fmt"
+	"github.com/kemsta/go-easyrsa/pkg/pair"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// ScanWorkers is a maximum number of goroutines reading keydir at once. Zero means twice the number of CPUs.
+var ScanWorkers = 0
+
+// certFile is a certificate file found in keydir
+type certFile struct {
+	cn     string
+	serial *big.Int
+	path   string
+}
+
+// keyPath return path of private key stored next to certificate
+func (f certFile) keyPath() string {
+	return strings.TrimSuffix(f.path, CertFileExtension) + ".key"
+}
+
+// fullChainPath return path of full chain stored next to certificate
+func (f certFile) fullChainPath() string {
+	return strings.TrimSuffix(f.path, CertFileExtension) + FullChainFileExtension
+}
+
+// csrPath return path of certificate request stored next to certificate
+func (f certFile) csrPath() string {
+	return strings.TrimSuffix(f.path, CertFileExtension) + CSRFileExtension
+}
+
+func scanWorkers(n int) int {
+	workers := ScanWorkers
+	if workers <= 0 {
+		workers = runtime.NumCPU() * 2
+	}
+	if workers > n {
+		workers = n
+	}
+	return workers
+}
+
+// parallel call fn for every index in [0, n) with a bounded number of workers
+func parallel(n int, fn func(i int)) {
+	workers := scanWorkers(n)
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			fn(i)
+		}
+		return
+	}
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// Kinds of scan warnings
+const (
+	WarningUnknownFile = "unknown file" // file or directory which isn`t a part of pair
+	WarningTempFile    = "temp file"    // temp file left by interrupted write, see CleanTemp
+	WarningLockFile    = "lock file"    // lock file in pair directory, storage never creates them there
+)
+
+// osJunkFiles are created by file managers in any directory
+var osJunkFiles = map[string]bool{".DS_Store": true, "Thumbs.db": true, "desktop.ini": true}
+
+// ScanWarning describe an entry of keydir which doesn`t belong to storage. Scans skip such entries.
+type ScanWarning struct {
+	Path string // path of entry
+	Kind string // one of Warning* kinds
+}
+
+// ReportWarnings send every unrelated entry met by scans to ch. Scans wait until warning is received,
+// so ch must be drained, e.g. by goroutine ranging over it. Parallel scans send from several goroutines.
+func (s *DirKeyStorage) ReportWarnings(ch chan<- ScanWarning) {
+	s.warnings = ch
+}
+
+func (s *DirKeyStorage) warning(path, kind string) {
+	if s.warnings != nil {
+		s.warnings <- ScanWarning{Path: path, Kind: kind}
+	}
+}
+
+// listNames return names of all pair directories in keydir.
+// Hidden directories keep service data like journal or trust store and are skipped. Files in keydir root
+// can belong to holders like serial or crl, so only temp and junk files are reported there.
+func (s *DirKeyStorage) listNames() ([]string, error) {
+	entries, err := os.ReadDir(s.keydir)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		switch {
+		case entry.IsDir() && !strings.HasPrefix(name, "."):
+			names = append(names, name)
+		case entry.IsDir():
+		case isTempFile(name):
+			s.warning(filepath.Join(s.keydir, name), WarningTempFile)
+		case osJunkFiles[name]:
+			s.warning(filepath.Join(s.keydir, name), WarningUnknownFile)
+		}
+	}
+	return names, nil
+}
+
+// listCertFiles return certificate files of pair directory cn. Entries which aren`t pair files or metadata
+// are skipped and reported as warnings.
+func (s *DirKeyStorage) listCertFiles(cn string) ([]certFile, error) {
+	dir := filepath.Join(s.keydir, cn)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]certFile, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		path := filepath.Join(dir, name)
+		switch {
+		case isTempFile(name):
+			s.warning(path, WarningTempFile)
+		case filepath.Ext(name) == ".lock":
+			s.warning(path, WarningLockFile)
+		case entry.IsDir() || !isPairFile(name):
+			s.warning(path, WarningUnknownFile)
+		case strings.HasSuffix(name, FullChainFileExtension):
+		case filepath.Ext(name) == CertFileExtension:
+			serial, _ := new(big.Int).SetString(strings.TrimSuffix(name, CertFileExtension), 16)
+			res = append(res, certFile{cn: cn, serial: serial, path: path})
+		}
+	}
+	return res, nil
+}
+
+// isPairFile check that name is a certificate, key, request, full chain, metadata or pending key file of pair directory
+func isPairFile(name string) bool {
+	if name == metadataFileName || name == pendingKeyFileName {
+		return true
+	}
+	if strings.HasSuffix(name, FullChainFileExtension) {
+		name = strings.TrimSuffix(name, FullChainFileExtension) + CertFileExtension
+	}
+	ext := filepath.Ext(name)
+	if ext != CertFileExtension && ext != ".key" && ext != CSRFileExtension {
+		return false
+	}
+	_, ok := new(big.Int).SetString(strings.TrimSuffix(name, ext), 16)
+	return ok
+}
+
+// ScanError is returned in error collection mode with every entry of keydir which can`t be read.
+// Pairs which were read successfully are returned together with it.
+type ScanError struct {
+	Errors []error
+}
+
+func (e *ScanError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("can`t read %d entries of keydir: %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+// Unwrap return all collected errors
+func (e *ScanError) Unwrap() []error {
+	return e.Errors
+}
+
+// scanError return ScanError with errs in error collection mode
+func (s *DirKeyStorage) scanError(errs []error) error {
+	if !s.collectErrors || len(errs) == 0 {
+		return nil
+	}
+	return &ScanError{Errors: errs}
+}
+
+// listAllCertFiles return certificate files of every pair directory in keydir and errors of unreadable directories.
+// Directories are read in parallel, the result is ordered by name like in a sequential scan.
+func (s *DirKeyStorage) listAllCertFiles() ([]certFile, []error, error) {
+	return s.listMatchingCertFiles(func(string) bool { return true })
+}
+
+// listMatchingCertFiles return certificate files of pair directories with names matching match,
+// other directories aren`t read
+func (s *DirKeyStorage) listMatchingCertFiles(match func(name string) bool) ([]certFile, []error, error) {
+	all, err := s.listNames()
+	if err != nil {
+		return nil, nil, err
+	}
+	names := make([]string, 0, len(all))
+	for _, name := range all {
+		if match(name) {
+			names = append(names, name)
+		}
+	}
+	perName := make([][]certFile, len(names))
+	perNameErrs := make([]error, len(names))
+	parallel(len(names), func(i int) {
+		perName[i], perNameErrs[i] = s.listCertFiles(names[i])
+	})
+	res := make([]certFile, 0, len(names))
+	errs := make([]error, 0)
+	for i, files := range perName {
+		res = append(res, files...)
+		if perNameErrs[i] != nil {
+			errs = append(errs, fmt.Errorf("can`t list %v: %w", names[i], perNameErrs[i]))
+		}
+	}
+	return res, errs, nil
+}
+
+// readPair read certificate and key of file
+func readPair(f certFile) (*pair.X509Pair, error) {
+	certBytes, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("can`t read cert %v: %w", f.path, err)
+	}
+	keyBytes, err := ioutil.ReadFile(f.keyPath())
+	if err != nil {
+		return nil, fmt.Errorf("can`t read key %v: %w", f.keyPath(), err)
+	}
+	return pair.NewX509Pair(keyBytes, certBytes, f.cn, f.serial), nil
+}
+
+// pairReader read pair of certificate file
+type pairReader func(certFile) (*pair.X509Pair, error)
+
+// readCert read certificate of file only, key file isn`t touched. Certificate without pem block is unreadable
+// like pair without key file for readPair.
+func readCert(f certFile) (*pair.X509Pair, error) {
+	certBytes, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("can`t read cert %v: %w", f.path, err)
+	}
+	if block, _ := pem.Decode(certBytes); block == nil {
+		return nil, fmt.Errorf("can`t read cert %v: no pem block", f.path)
+	}
+	return pair.NewX509Pair(nil, certBytes, f.cn, f.serial), nil
+}
+
+// readPairs read pairs of all files in parallel with read. Unreadable pairs are skipped and their errors
+// are returned, order of files is kept.
+func readPairs(files []certFile, read pairReader) ([]*pair.X509Pair, []error) {
+	pairs := make([]*pair.X509Pair, len(files))
+	pairErrs := make([]error, len(files))
+	parallel(len(files), func(i int) {
+		pairs[i], pairErrs[i] = read(files[i])
+	})
+	res := make([]*pair.X509Pair, 0, len(pairs))
+	errs := make([]error, 0)
+	for i, p := range pairs {
+		if p != nil {
+			res = append(res, p)
+		}
+		if pairErrs[i] != nil {
+			errs = append(errs, pairErrs[i])
+		}
+	}
+	return res, errs
+}
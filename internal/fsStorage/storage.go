@@ -2,7 +2,7 @@ package fsStorage
 
 import (
 	"bytes"
-	"context"
+	"crypto"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"errors"
@@ -10,24 +10,34 @@ import (
 	"github.com/gofrs/flock"
 	"github.com/kemsta/go-easyrsa/pkg/pair"
 	"io"
+	"io/fs"
 	"io/ioutil"
 	"math/big"
 	"os"
+	"path"
 	"path/filepath"
+	"runtime"
 	"sort"
-	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 const (
-	LockPeriod        = time.Millisecond * 100
-	LockTimeout       = time.Second * 10
-	CertFileExtension = ".crt" // certificate file extension
+	CertFileExtension      = ".crt"           // certificate file extension
+	FullChainFileExtension = ".fullchain.crt" // extension of certificate file followed by its issuers
+	CSRFileExtension       = ".csr"           // extension of certificate request file
+)
+
+const (
+	LockPeriod  = time.Millisecond * 100 // default retry period of lock acquisition, see LockTiming
+	LockTimeout = time.Second * 10       // default time after which lock acquisition gives up, see LockTiming
 )
 
 // Common CRLHolder implementation. It's saving file on fs
 type FileCRLHolder struct {
+	lockOptions
 	locker *flock.Flock
 	path   string
 }
@@ -36,19 +46,19 @@ func NewFileCRLHolder(path string) *FileCRLHolder {
 	return &FileCRLHolder{locker: flock.New(fmt.Sprintf("%v.lock", path)), path: path}
 }
 
+// Path of crl file
+func (h *FileCRLHolder) Path() string {
+	return h.path
+}
+
 // Save new crl content to storage
 func (h *FileCRLHolder) Put(content []byte) error {
-	ctx, cancel := context.WithTimeout(context.Background(), LockTimeout)
-	defer cancel()
-	locked, err := h.locker.TryLockContext(ctx, LockPeriod)
+	unlock, err := h.acquire(h.locker)
 	if err != nil {
-		return fmt.Errorf("there's error with saving crl to storage: %w", err)
-	}
-	if !locked {
-		return fmt.Errorf("can`t lock serial file %v", h.path)
+		return fmt.Errorf("can`t lock crl file %v: %w", h.path, err)
 	}
 	defer func() {
-		_ = h.locker.Unlock()
+		_ = unlock()
 	}()
 	if err = writeFileAtomic(h.path, bytes.NewReader(content), 0644); err != nil {
 		return fmt.Errorf("can't overwrite crl file %s with new content: %w", h.path, err)
@@ -75,48 +85,93 @@ func (h *FileCRLHolder) Get() (*pkix.CertificateList, error) {
 	}
 	list, err := x509.ParseCRL(fBytes)
 	if err != nil {
-		return nil, fmt.Errorf("can`t parse crl \n %v: %w", string(fBytes), err)
+		return nil, fmt.Errorf("can`t parse crl %v: %w", h.path, err)
 	}
 	return list, nil
 }
 
-// FileSerialProvider implement SerialProvider interface with storing serial in file on fs
-type FileSerialProvider struct {
+// FileIndexHolder implement IndexHolder interface with storing index in file on fs
+type FileIndexHolder struct {
+	lockOptions
 	locker *flock.Flock
 	path   string
 }
 
-// Get next serial and increment counter in storage
-func (p *FileSerialProvider) Next() (*big.Int, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), LockTimeout)
-	defer cancel()
-	locked, err := p.locker.TryLockContext(ctx, LockPeriod)
+func NewFileIndexHolder(path string) *FileIndexHolder {
+	return &FileIndexHolder{locker: flock.New(fmt.Sprintf("%v.lock", path)), path: path}
+}
+
+// Put new index content to storage
+func (h *FileIndexHolder) Put(content []byte) error {
+	unlock, err := h.acquire(h.locker)
 	if err != nil {
-		return nil, fmt.Errorf("can`t lock serial file %v: %w", p.path, err)
+		return fmt.Errorf("can`t lock index file %v: %w", h.path, err)
+	}
+	defer func() {
+		_ = unlock()
+	}()
+	if err = writeFileAtomic(h.path, bytes.NewReader(content), 0644); err != nil {
+		return fmt.Errorf("can't overwrite index file %s with new content: %w", h.path, err)
 	}
-	if !locked {
-		return nil, fmt.Errorf("can`t lock serial file %v", p.path)
+	return nil
+}
+
+// Get index content from storage. Empty content if there is no index file yet.
+func (h *FileIndexHolder) Get() ([]byte, error) {
+	err := h.locker.RLock()
+	if err != nil {
+		return nil, err
 	}
 	defer func() {
-		_ = p.locker.Unlock()
+		_ = h.locker.Unlock()
 	}()
-	res := big.NewInt(0)
-	sBytes, err := ioutil.ReadFile(p.path)
+	content, err := ioutil.ReadFile(h.path)
 	if os.IsNotExist(err) {
-		// nothing to do. New serial
-	} else if err != nil {
-		return nil, fmt.Errorf("can`t read serial file %v: %w", p.path, err)
+		return []byte{}, nil
 	}
+	if err != nil {
+		return nil, fmt.Errorf("can`t read index %v: %w", h.path, err)
+	}
+	return content, nil
+}
+
+// FileSerialProvider implement SerialProvider interface with storing serial in file on fs.
+// By default file keeps the last issued serial as lower case hex. In openssl mode it keeps the next serial
+// as upper case even length hex with trailing newline like openssl ca does, the previous content is kept
+// in <path>.old.
+type FileSerialProvider struct {
+	lockOptions
+	locker  *flock.Flock
+	path    string
+	openssl bool
+}
 
-	if len(sBytes) != 0 {
-		res.SetString(string(sBytes), 16)
+// Get next serial and increment counter in storage
+func (p *FileSerialProvider) Next() (*big.Int, error) {
+	unlock, err := p.acquire(p.locker)
+	if err != nil {
+		return nil, fmt.Errorf("can`t lock serial file %v: %w", p.path, err)
+	}
+	defer func() {
+		_ = unlock()
+	}()
+	res, err := p.read()
+	if err != nil {
+		return nil, err
+	}
+	if p.openssl {
+		if res.Sign() == 0 {
+			res.SetInt64(1)
+		}
+		if err := p.write(new(big.Int).Add(res, big.NewInt(1))); err != nil {
+			return nil, err
+		}
+		return res, nil
 	}
 	res.Add(big.NewInt(1), res)
-
-	if err := writeFileAtomic(p.path, strings.NewReader(res.Text(16)), 0644); err != nil {
-		return res, fmt.Errorf("can`t write cert %v: %w", p.path, err)
+	if err := p.write(res); err != nil {
+		return res, err
 	}
-
 	return res, nil
 }
 
@@ -127,188 +182,614 @@ func NewFileSerialProvider(path string) *FileSerialProvider {
 	}
 }
 
+// OpenSSLFormat switch openssl compatible mode, so serial file can be shared with openssl ca and easy-rsa
+func (p *FileSerialProvider) OpenSSLFormat(enabled bool) {
+	p.openssl = enabled
+}
+
+// AdvanceTo move counter forward to serial, so next serial is greater than it. Counter is never moved back.
+func (p *FileSerialProvider) AdvanceTo(serial *big.Int) error {
+	unlock, err := p.acquire(p.locker)
+	if err != nil {
+		return fmt.Errorf("can`t lock serial file %v: %w", p.path, err)
+	}
+	defer func() {
+		_ = unlock()
+	}()
+	current, err := p.read()
+	if err != nil {
+		return err
+	}
+	target := serial
+	if p.openssl {
+		target = new(big.Int).Add(serial, big.NewInt(1))
+	}
+	if current.Cmp(target) >= 0 {
+		return nil
+	}
+	return p.write(target)
+}
+
+// Last return the last issued serial, zero if nothing was issued yet
+func (p *FileSerialProvider) Last() (*big.Int, error) {
+	unlock, err := p.acquire(p.locker)
+	if err != nil {
+		return nil, fmt.Errorf("can`t lock serial file %v: %w", p.path, err)
+	}
+	defer func() {
+		_ = unlock()
+	}()
+	res, err := p.read()
+	if err != nil {
+		return nil, err
+	}
+	if p.openssl && res.Sign() > 0 {
+		res.Sub(res, big.NewInt(1))
+	}
+	return res, nil
+}
+
+// read stored value. It`s zero if file doesn`t exist. In openssl mode file which can`t be parsed is an error,
+// so a damaged file shared with openssl doesn`t restart serials from the beginning.
+func (p *FileSerialProvider) read() (*big.Int, error) {
+	sBytes, err := ioutil.ReadFile(p.path)
+	if os.IsNotExist(err) {
+		return big.NewInt(0), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can`t read serial file %v: %w", p.path, err)
+	}
+	res, ok := new(big.Int).SetString(strings.TrimSpace(string(sBytes)), 16)
+	if !ok && p.openssl {
+		return nil, fmt.Errorf("can`t parse serial file %v: bad hex serial %q", p.path, bytes.TrimSpace(sBytes))
+	}
+	if !ok {
+		return big.NewInt(0), nil
+	}
+	return res, nil
+}
+
+// write value in provider format
+func (p *FileSerialProvider) write(value *big.Int) error {
+	content := value.Text(16)
+	if p.openssl {
+		content = strings.ToUpper(content)
+		if len(content)%2 != 0 {
+			content = "0" + content
+		}
+		content += "\n"
+		if old, err := ioutil.ReadFile(p.path); err == nil {
+			if err := writeFileAtomic(p.path+".old", bytes.NewReader(old), 0644); err != nil {
+				return fmt.Errorf("can`t write serial file %v.old: %w", p.path, err)
+			}
+		}
+	}
+	if err := writeFileAtomic(p.path, strings.NewReader(content), 0644); err != nil {
+		return fmt.Errorf("can`t write serial file %v: %w", p.path, err)
+	}
+	return nil
+}
+
 // DirKeyStorage is a Storage interface implementation with storing pairs on fs
 type DirKeyStorage struct {
-	keydir string
+	lockOptions
+	keydir            string
+	collectErrors     bool
+	warnings          chan<- ScanWarning
+	confirmCADeletion bool
+	serialWidth       int
+	serialUpper       bool
+	fingerprintHash   crypto.Hash
 }
 
+// ErrCAMaterial is returned on attempt to delete CA pairs without confirmation
+var ErrCAMaterial = errors.New("refuse to delete CA material without confirmation")
+
+// ErrOutsideKeydir is returned when path of pair resolves outside keydir
+var ErrOutsideKeydir = errors.New("path is outside keydir")
+
+// ErrNotFound is returned when there is no pair with requested name or serial
+var ErrNotFound = errors.New("not found")
+
 func NewDirKeyStorage(keydir string) *DirKeyStorage {
 	return &DirKeyStorage{keydir: keydir}
 }
 
-// Put keypair in dir as /keydir/cn/serial.[crt,key]
+// CollectErrors switch error collection mode. In this mode scans return ScanError with all entries
+// of keydir which can`t be read instead of silently skipping them.
+func (s *DirKeyStorage) CollectErrors(collect bool) {
+	s.collectErrors = collect
+}
+
+// SerialFormat set format of serials in names of new pair files. Serial is zero padded to width hex digits,
+// 0 means no padding, and upper switch upper case hex like in openssl newcerts. Pairs are found by parsed serial,
+// so files named in other formats are still read.
+func (s *DirKeyStorage) SerialFormat(width int, upper bool) {
+	s.serialWidth = width
+	s.serialUpper = upper
+}
+
+// ConfirmCADeletion return a view of storage which is allowed to delete CA pairs.
+// Storage itself keeps refusing it, so confirmation is given per operation:
+//
+//	storage.ConfirmCADeletion().DeleteByCn("ca")
+func (s *DirKeyStorage) ConfirmCADeletion() *DirKeyStorage {
+	confirmed := *s
+	confirmed.confirmCADeletion = true
+	return &confirmed
+}
+
+// Lock operation with name across processes with lock file in keydir. The lock isn`t reentrant, nested Lock
+// with the same name before unlock fails with ErrLocked after lock timeout.
+func (s *DirKeyStorage) Lock(name string) (unlock func() error, err error) {
+	if err := os.MkdirAll(s.keydir, 0755); err != nil {
+		return nil, fmt.Errorf("can`t create keydir %v: %w", s.keydir, err)
+	}
+	lockPath := filepath.Join(s.keydir, fmt.Sprintf(".%s.lock", name))
+	unlock, err = s.acquire(flock.New(lockPath))
+	if err != nil {
+		return nil, fmt.Errorf("can`t lock %v: %w", lockPath, err)
+	}
+	return unlock, nil
+}
+
+// Put keypair in dir as /keydir/cn/serial.[crt,key] and add its certificate to fingerprint index
 func (s *DirKeyStorage) Put(pair *pair.X509Pair) error {
 	certPath, keyPath, err := s.makePath(pair)
 	if err != nil {
-		return fmt.Errorf("can`t make path %v: %w", pair, err)
+		return fmt.Errorf("can`t make path for %v with serial %v: %w", pair.CN, pair.Serial, err)
 	}
 	if err := writeFileAtomic(certPath, bytes.NewReader(pair.CertPemBytes), 0644); err != nil {
 		return fmt.Errorf("can`t write cert %v: %w", certPath, err)
 	}
 
-	if err := writeFileAtomic(keyPath, bytes.NewReader(pair.KeyPemBytes), 0644); err != nil {
-		return fmt.Errorf("can`t write cert %v: %w", certPath, err)
+	if err := writeFileAtomic(keyPath, bytes.NewReader(pair.KeyPemBytes), 0600); err != nil {
+		return fmt.Errorf("can`t write key %v: %w", keyPath, err)
 	}
-	return nil
+	return s.addFingerprint(pair)
 }
 
-// DeleteByCn delete all pair with cn
+// DeleteByCn delete directory of cn with all pairs in it.
+// Directories resolving outside keydir and CA pairs without confirmation are refused.
 func (s *DirKeyStorage) DeleteByCn(cn string) error {
-	err := os.Remove(filepath.Join(s.keydir, cn))
+	paths, err := s.DeleteByCnDryRun(cn)
 	if err != nil {
 		return fmt.Errorf("can`t delete by cn %v in %v: %w", cn, s.keydir, err)
 	}
+	if err := s.checkInside(paths[0]); err != nil {
+		return fmt.Errorf("can`t delete by cn %v in %v: %w", cn, s.keydir, err)
+	}
+	files, err := s.listCertFiles(cn)
+	if err != nil {
+		return fmt.Errorf("can`t delete by cn %v in %v: %w", cn, s.keydir, err)
+	}
+	for _, f := range files {
+		if err := s.checkCAMaterial(f); err != nil {
+			return fmt.Errorf("can`t delete by cn %v in %v: %w", cn, s.keydir, err)
+		}
+	}
+	if err := os.RemoveAll(paths[0]); err != nil {
+		return fmt.Errorf("can`t delete by cn %v in %v: %w", cn, s.keydir, err)
+	}
 	return nil
 }
 
-// Delete only one pair with serial
+// DeleteByCnDryRun return paths which DeleteByCn would delete starting from the cn directory itself
+func (s *DirKeyStorage) DeleteByCnDryRun(cn string) ([]string, error) {
+	if err := checkName(cn); err != nil {
+		return nil, err
+	}
+	res := make([]string, 0)
+	err := filepath.WalkDir(filepath.Join(s.keydir, cn), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		res = append(res, path)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Delete only one pair with serial.
+// Files resolving outside keydir and CA pairs without confirmation are refused.
 func (s *DirKeyStorage) DeleteBySerial(serial *big.Int) error {
-	p, err := s.GetBySerial(serial)
+	f, _, err := s.findBySerial(serial, readPair)
 	if err != nil {
 		return fmt.Errorf("can`t find pair by serial %v: %w", serial, err)
 	}
-	certPath := filepath.Join(s.keydir, p.CN, fmt.Sprintf("%s.crt", p.Serial.Text(16)))
-	keyPath := filepath.Join(s.keydir, p.CN, fmt.Sprintf("%s.key", p.Serial.Text(16)))
-	err = os.Remove(certPath)
+	for _, path := range []string{f.path, f.keyPath()} {
+		if err := s.checkInside(path); err != nil {
+			return fmt.Errorf("can`t delete pair with serial %v: %w", serial, err)
+		}
+	}
+	if err := s.checkCAMaterial(f); err != nil {
+		return fmt.Errorf("can`t delete pair with serial %v: %w", serial, err)
+	}
+	if err := os.Remove(f.path); err != nil {
+		return fmt.Errorf("can`t delete cert %v: %w", f.path, err)
+	}
+	if err := os.Remove(f.keyPath()); err != nil {
+		return fmt.Errorf("can`t delete key %v: %w", f.keyPath(), err)
+	}
+	if err := os.Remove(f.fullChainPath()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("can`t delete full chain %v: %w", f.fullChainPath(), err)
+	}
+	if err := os.Remove(f.csrPath()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("can`t delete csr %v: %w", f.csrPath(), err)
+	}
+	return nil
+}
+
+// checkInside resolve path with symlinks and verify that it stays strictly inside keydir
+func (s *DirKeyStorage) checkInside(path string) error {
+	root, err := resolvePath(s.keydir)
 	if err != nil {
-		return fmt.Errorf("can`t delete cert %v: %w", certPath, err)
+		return err
 	}
-	err = os.Remove(keyPath)
+	target, err := resolvePath(path)
 	if err != nil {
-		return fmt.Errorf("can`t delete key %v: %w", keyPath, err)
+		return err
+	}
+	rel, err := filepath.Rel(root, target)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%v: %w", path, ErrOutsideKeydir)
 	}
 	return nil
 }
 
+// checkCAMaterial refuse CA certificate of file until deletion is confirmed. Not parsable certificates aren`t CA.
+func (s *DirKeyStorage) checkCAMaterial(f certFile) error {
+	if s.confirmCADeletion {
+		return nil
+	}
+	certBytes, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return nil
+	}
+	cert, err := pair.NewX509Pair(nil, certBytes, f.cn, f.serial).DecodeCert()
+	if err != nil || !cert.IsCA {
+		return nil
+	}
+	return fmt.Errorf("%v with serial %v: %w", f.cn, f.serial, ErrCAMaterial)
+}
+
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("can`t resolve %v: %w", path, err)
+	}
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return "", fmt.Errorf("can`t resolve %v: %w", path, err)
+	}
+	return resolved, nil
+}
+
 // GetByCN return all pairs with cn
 func (s *DirKeyStorage) GetByCN(cn string) ([]*pair.X509Pair, error) {
-	res := make([]*pair.X509Pair, 0)
-	err := filepath.Walk(filepath.Join(s.keydir, cn), func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if filepath.Ext(path) == CertFileExtension {
-			fileName := filepath.Base(path)
-			serial, err := strconv.ParseInt(fileName[0:len(fileName)-len(filepath.Ext(fileName))], 16, 64)
-			if err != nil {
-				return nil
-			}
-			certBytes, err := ioutil.ReadFile(path)
-			if err != nil {
-				return nil
-			}
-			keyBytes, err := ioutil.ReadFile(fmt.Sprintf("%s.key", path[0:len(path)-len(filepath.Ext(path))]))
-			if err != nil {
-				return nil
-			}
-			res = append(res, pair.NewX509Pair(keyBytes, certBytes, cn, big.NewInt(serial)))
-		}
-		return nil
-	})
+	if err := checkName(cn); err != nil {
+		return nil, err
+	}
+	files, err := s.listCertFiles(cn)
+	res, errs := readPairs(files, readPair)
+	if err != nil && !os.IsNotExist(err) {
+		errs = append(errs, fmt.Errorf("can`t list %v: %w", cn, err))
+	}
+	if scanErr := s.scanError(errs); scanErr != nil {
+		return res, scanErr
+	}
 	if len(res) == 0 {
-		return nil, fmt.Errorf("%v not found", cn)
+		return nil, fmt.Errorf("%v %w", cn, ErrNotFound)
 	}
-	return res, err
+	return res, nil
 }
 
-// GetLastByCn return only last pair with cn
+// GetLastByCn return only last pair with cn.
+// Pairs are read from the greatest serial until the first readable one, so older ones aren`t read.
 func (s *DirKeyStorage) GetLastByCn(cn string) (*pair.X509Pair, error) {
-	pairs, err := s.GetByCN(cn)
-	if err != nil || len(pairs) == 0 {
+	return s.lastByCn(cn, readPair)
+}
+
+// GetCertOnly return last certificate with cn without key. Key files aren`t read,
+// so it works under account which has no permission to read them.
+func (s *DirKeyStorage) GetCertOnly(cn string) (*pair.X509Pair, error) {
+	return s.lastByCn(cn, readCert)
+}
+
+// lastByCn return the first pair with cn readable by read from the greatest serial
+func (s *DirKeyStorage) lastByCn(cn string, read pairReader) (*pair.X509Pair, error) {
+	if err := checkName(cn); err != nil {
 		return nil, fmt.Errorf("can`t get cert %v: %w", cn, err)
 	}
-	sort.Slice(pairs, func(i, j int) bool {
-		return pairs[i].Serial.Cmp(pairs[j].Serial) == 1
+	files, err := s.listCertFiles(cn)
+	errs := make([]error, 0)
+	if err != nil && !os.IsNotExist(err) {
+		errs = append(errs, fmt.Errorf("can`t list %v: %w", cn, err))
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].serial.Cmp(files[j].serial) == 1
 	})
-	return pairs[0], nil
+	for _, f := range files {
+		res, err := read(f)
+		if err == nil {
+			return res, nil
+		}
+		errs = append(errs, err)
+	}
+	if scanErr := s.scanError(errs); scanErr != nil {
+		return nil, fmt.Errorf("can`t get cert %v: %w", cn, scanErr)
+	}
+	return nil, fmt.Errorf("can`t get cert %v: %v %w", cn, cn, ErrNotFound)
 }
 
-// GetBySerial return only one pair with serial
+// GetBySerial return only one pair with serial.
+// Only directory listings are scanned, just the matched pair is read.
 func (s *DirKeyStorage) GetBySerial(serial *big.Int) (*pair.X509Pair, error) {
-	var res *pair.X509Pair
-	err := filepath.Walk(s.keydir, func(path string, info os.FileInfo, err error) error {
+	_, res, err := s.findBySerial(serial, readPair)
+	return res, err
+}
+
+// GetCertOnlyBySerial return certificate with serial without key
+func (s *DirKeyStorage) GetCertOnlyBySerial(serial *big.Int) (*pair.X509Pair, error) {
+	_, res, err := s.findBySerial(serial, readCert)
+	return res, err
+}
+
+// findBySerial return the first pair with serial readable by read and its file
+func (s *DirKeyStorage) findBySerial(serial *big.Int, read pairReader) (certFile, *pair.X509Pair, error) {
+	names, err := s.listNames()
+	errs := make([]error, 0)
+	if err != nil && !os.IsNotExist(err) {
+		errs = append(errs, fmt.Errorf("can`t list keydir %v: %w", s.keydir, err))
+	}
+	found := make([][]certFile, len(names))
+	listErrs := make([]error, len(names))
+	parallel(len(names), func(i int) {
+		files, err := s.listCertFiles(names[i])
 		if err != nil {
-			return nil
+			listErrs[i] = fmt.Errorf("can`t list %v: %w", names[i], err)
 		}
-		if filepath.Ext(path) == CertFileExtension {
-			fileName := filepath.Base(path)
-			ser, err := strconv.ParseInt(fileName[0:len(fileName)-len(filepath.Ext(fileName))], 16, 64)
-			if err != nil {
-				return nil
-			}
-			cn := filepath.Base(filepath.Dir(path))
-			if serial.Text(16) == big.NewInt(ser).Text(16) {
-				certBytes, err := ioutil.ReadFile(path)
-				if err != nil {
-					return nil
-				}
-				keyBytes, err := ioutil.ReadFile(fmt.Sprintf("%s.key", path[0:len(path)-len(filepath.Ext(path))]))
-				if err != nil {
-					return nil
-				}
-				res = pair.NewX509Pair(keyBytes, certBytes, cn, big.NewInt(ser))
-				return nil
+		for _, f := range files {
+			if f.serial.Cmp(serial) == 0 {
+				found[i] = append(found[i], f)
 			}
 		}
-		return nil
 	})
-	if res == nil {
-		return nil, fmt.Errorf("%v not found", serial)
+	for i, files := range found {
+		if listErrs[i] != nil {
+			errs = append(errs, listErrs[i])
+		}
+		for _, f := range files {
+			res, err := read(f)
+			if err == nil {
+				return f, res, nil
+			}
+			errs = append(errs, err)
+		}
 	}
-	return res, err
+	if scanErr := s.scanError(errs); scanErr != nil {
+		return certFile{}, nil, fmt.Errorf("%v not found: %w", serial, scanErr)
+	}
+	return certFile{}, nil, fmt.Errorf("%v %w", serial, ErrNotFound)
 }
 
-// GetAll return all pairs
+// GetAll return all pairs. In error collection mode pairs which were read are returned together with ScanError.
 func (s *DirKeyStorage) GetAll() ([]*pair.X509Pair, error) {
-	res := make([]*pair.X509Pair, 0)
-	err := filepath.Walk(s.keydir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil
+	return s.getAll(readPair)
+}
+
+// GetAllCertOnly return all certificates without keys
+func (s *DirKeyStorage) GetAllCertOnly() ([]*pair.X509Pair, error) {
+	return s.getAll(readCert)
+}
+
+func (s *DirKeyStorage) getAll(read pairReader) ([]*pair.X509Pair, error) {
+	files, errs, err := s.listAllCertFiles()
+	if os.IsNotExist(err) {
+		return make([]*pair.X509Pair, 0), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can`t get all pairs: %w", err)
+	}
+	res, readErrs := readPairs(files, read)
+	return res, s.scanError(append(errs, readErrs...))
+}
+
+// GetByCNPattern return all pairs with cn matching glob pattern of path.Match, e.g. "vpn-client-*".
+// Only matching pair directories are read. Nothing matched isn`t an error.
+func (s *DirKeyStorage) GetByCNPattern(pattern string) ([]*pair.X509Pair, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("bad pattern %q: %w", pattern, err)
+	}
+	files, errs, err := s.listMatchingCertFiles(func(name string) bool {
+		matched, _ := path.Match(pattern, name)
+		return matched
+	})
+	if os.IsNotExist(err) {
+		return make([]*pair.X509Pair, 0), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can`t get pairs matching %q: %w", pattern, err)
+	}
+	res, readErrs := readPairs(files, readPair)
+	return res, s.scanError(append(errs, readErrs...))
+}
+
+// PairPaths return paths of pair files in keydir. Files of existing pair are returned as they are named,
+// so pairs stored before SerialFormat was changed keep their paths. Paths of new pair follow the current format.
+func (s *DirKeyStorage) PairPaths(pair *pair.X509Pair) (certPath, keyPath string) {
+	if f, ok := s.existingCertFile(pair.CN, pair.Serial); ok {
+		return f.path, f.keyPath()
+	}
+	basePath := filepath.Join(s.keydir, pair.CN)
+	name := s.serialName(pair.Serial)
+	return filepath.Join(basePath, fmt.Sprintf("%s.crt", name)),
+		filepath.Join(basePath, fmt.Sprintf("%s.key", name))
+}
+
+// existingCertFile return certificate file with serial in pair directory cn whatever serial format it`s named in
+func (s *DirKeyStorage) existingCertFile(cn string, serial *big.Int) (certFile, bool) {
+	if checkName(cn) != nil || serial == nil {
+		return certFile{}, false
+	}
+	dir := filepath.Join(s.keydir, cn)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return certFile{}, false
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != CertFileExtension || strings.HasSuffix(name, FullChainFileExtension) {
+			continue
 		}
-		if filepath.Ext(path) == CertFileExtension {
-			fileName := filepath.Base(path)
-			ser, err := strconv.ParseInt(fileName[0:len(fileName)-len(filepath.Ext(fileName))], 16, 64)
-			if err != nil {
-				return nil
-			}
-			cn := filepath.Base(filepath.Dir(path))
-			certBytes, err := ioutil.ReadFile(path)
-			if err != nil {
-				return nil
-			}
-			keyBytes, err := ioutil.ReadFile(fmt.Sprintf("%s.key", path[0:len(path)-len(filepath.Ext(path))]))
-			if err != nil {
-				return nil
-			}
-			res = append(res, pair.NewX509Pair(keyBytes, certBytes, cn, big.NewInt(ser)))
+		if fileSerial, ok := new(big.Int).SetString(strings.TrimSuffix(name, CertFileExtension), 16); ok && fileSerial.Cmp(serial) == 0 {
+			return certFile{cn: cn, serial: fileSerial, path: filepath.Join(dir, name)}, true
 		}
-		return nil
-	})
+	}
+	return certFile{}, false
+}
+
+// serialName format serial for names of pair files
+func (s *DirKeyStorage) serialName(serial *big.Int) string {
+	name := serial.Text(16)
+	if s.serialUpper {
+		name = strings.ToUpper(name)
+	}
+	if len(name) < s.serialWidth {
+		name = strings.Repeat("0", s.serialWidth-len(name)) + name
+	}
+	return name
+}
+
+// PutFullChain write certificate of pair followed by its issuers as /keydir/cn/serial.fullchain.crt next to pair files
+func (s *DirKeyStorage) PutFullChain(pair *pair.X509Pair, content []byte) error {
+	certPath, _, err := s.makePath(pair)
 	if err != nil {
-		return nil, fmt.Errorf("can`t get all pairs: %w", err)
+		return fmt.Errorf("can`t make path for %v with serial %v: %w", pair.CN, pair.Serial, err)
 	}
-	return res, nil
+	path := certFile{path: certPath}.fullChainPath()
+	if err := writeFileAtomic(path, bytes.NewReader(content), 0644); err != nil {
+		return fmt.Errorf("can`t write full chain %v: %w", path, err)
+	}
+	return nil
+}
+
+// PutCSR write certificate request of pair as /keydir/cn/serial.csr next to pair files
+func (s *DirKeyStorage) PutCSR(pair *pair.X509Pair, csr []byte) error {
+	certPath, _, err := s.makePath(pair)
+	if err != nil {
+		return fmt.Errorf("can`t make path for %v with serial %v: %w", pair.CN, pair.Serial, err)
+	}
+	path := certFile{path: certPath}.csrPath()
+	if err := writeFileAtomic(path, bytes.NewReader(csr), 0644); err != nil {
+		return fmt.Errorf("can`t write csr %v: %w", path, err)
+	}
+	return nil
+}
+
+// GetCSR return certificate request of pair with serial. Empty content if pair has no request.
+func (s *DirKeyStorage) GetCSR(serial *big.Int) ([]byte, error) {
+	f, _, err := s.findBySerial(serial, readCert)
+	if err != nil {
+		return nil, fmt.Errorf("can`t find pair by serial %v: %w", serial, err)
+	}
+	content, err := ioutil.ReadFile(f.csrPath())
+	if os.IsNotExist(err) {
+		return []byte{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can`t read csr %v: %w", f.csrPath(), err)
+	}
+	return content, nil
 }
 
 func (s *DirKeyStorage) makePath(pair *pair.X509Pair) (certPath, keyPath string, err error) {
 	if pair.CN == "" || pair.Serial == nil {
 		return "", "", errors.New("empty cn or serial")
 	}
-	basePath := filepath.Join(s.keydir, pair.CN)
-	err = os.MkdirAll(basePath, 0755)
+	if err := checkNewName(pair.CN); err != nil {
+		return "", "", err
+	}
+	err = os.MkdirAll(filepath.Join(s.keydir, pair.CN), 0755)
 	if err != nil {
-		return "", "", fmt.Errorf("can`t create dir for key pair %v: %w", pair, err)
+		return "", "", fmt.Errorf("can`t create dir for key pair %v: %w", pair.CN, err)
+	}
+	certPath, keyPath = s.PairPaths(pair)
+	return certPath, keyPath, nil
+}
+
+// checkName verify that name is a single path element in keydir and isn`t reserved for service data.
+// Pairs are stored by caller-supplied name, which is not necessarily a common name.
+func checkName(name string) error {
+	if name == "" || name == "." || name == ".." {
+		return fmt.Errorf("bad name %q", name)
+	}
+	if i := strings.IndexAny(name, "/\\\x00"); i >= 0 {
+		return fmt.Errorf("bad name %q: forbidden character %q", name, name[i])
+	}
+	if strings.HasPrefix(name, ".") {
+		return fmt.Errorf("bad name %q: hidden names are reserved for service data", name)
+	}
+	return nil
+}
+
+// CheckName verify that name can be a name of new pair in keydir. Check names with it before naming
+// files outside of keydir after pairs, e.g. key and request files.
+func CheckName(name string) error {
+	return checkNewName(name)
+}
+
+// checkNewName verify name of new file or directory in keydir. On windows names which it can`t keep are refused
+// as well, names of existing pairs are checked with checkName only, so they stay readable.
+func checkNewName(name string) error {
+	if err := checkName(name); err != nil {
+		return err
 	}
-	return filepath.Join(basePath, fmt.Sprintf("%s.crt", pair.Serial.Text(16))),
-		filepath.Join(basePath, fmt.Sprintf("%s.key", pair.Serial.Text(16))), nil
+	if runtime.GOOS == "windows" {
+		return checkWindowsName(name)
+	}
+	return nil
 }
 
+// checkWindowsName verify that name can be used as a file name on windows. Non-ASCII names are fine as long as
+// they are valid UTF-8.
+func checkWindowsName(name string) error {
+	if !utf8.ValidString(name) {
+		return fmt.Errorf("bad name %q: invalid utf-8", name)
+	}
+	if i := strings.IndexAny(name, "<>:\"|?*"); i >= 0 {
+		return fmt.Errorf("bad name %q: forbidden character %q", name, name[i])
+	}
+	if strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		return fmt.Errorf("bad name %q: control character", name)
+	}
+	if strings.HasSuffix(name, ".") || strings.HasSuffix(name, " ") {
+		return fmt.Errorf("bad name %q: trailing dot or space is dropped on windows", name)
+	}
+	base := strings.ToUpper(strings.SplitN(name, ".", 2)[0])
+	if windowsReservedNames[base] {
+		return fmt.Errorf("bad name %q: reserved device name on windows", name)
+	}
+	return nil
+}
+
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// writeFileAtomic replace file at path with content of r. Files with mode private to owner, like keys,
+// are private on windows as well.
 func writeFileAtomic(path string, r io.Reader, mode os.FileMode) error {
 	dir, file := filepath.Split(path)
 	if dir == "" {
 		dir = "."
 	}
-	fd, err := ioutil.TempFile(dir, file)
+	fd, err := ioutil.TempFile(dir, "."+file+tempSuffix)
 	if err != nil {
 		return fmt.Errorf("cannot create temp file: %w", err)
 	}
@@ -318,6 +799,11 @@ func writeFileAtomic(path string, r io.Reader, mode os.FileMode) error {
 	defer func(fd *os.File) {
 		_ = fd.Close()
 	}(fd)
+	if mode&0077 == 0 {
+		if err := restrictAccess(fd.Name()); err != nil {
+			return fmt.Errorf("can't restrict access to tempfile %q: %w", fd.Name(), err)
+		}
+	}
 	if _, err := io.Copy(fd, r); err != nil {
 		return fmt.Errorf("cannot write data to tempfile %q: %w", fd.Name(), err)
 	}
@@ -2,7 +2,15 @@ package fsStorage
 
 import (
 	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
 	"fmt"
 	"github.com/kemsta/go-easyrsa/pkg/pair"
 	"io"
@@ -11,8 +19,11 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"runtime"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -71,6 +82,21 @@ func TestDirKeyStorage_makePath(t *testing.T) {
 			wantKeyPath:  "",
 			wantErr:      true,
 		},
+		{
+			name: "escaping name",
+			fields: fields{
+				keydir: filepath.Join(getTestDir(), "dir_keystorage"),
+			},
+			args: args{
+				pair: &pair.X509Pair{
+					CN:     "../escape",
+					Serial: big.NewInt(66),
+				},
+			},
+			wantCertPath: "",
+			wantKeyPath:  "",
+			wantErr:      true,
+		},
 		{
 			name: "can`t create dir",
 			fields: fields{
@@ -257,6 +283,78 @@ func TestDirKeyStorage_DeleteByCn(t *testing.T) {
 	}
 }
 
+func TestDirKeyStorage_DeleteByCnDryRun(t *testing.T) {
+	storPath := filepath.Join(getTestDir(), "delete_stor")
+	stor := NewDirKeyStorage(storPath)
+	defer func() {
+		_ = os.RemoveAll(storPath)
+	}()
+	_, err := stor.DeleteByCnDryRun("cn")
+	assert.Error(t, err)
+	assert.Error(t, stor.DeleteByCn("cn"))
+	assert.NoError(t, stor.Put(pair.NewX509Pair([]byte("key"), []byte("cert"), "cn", big.NewInt(1))))
+	assert.NoError(t, stor.Put(pair.NewX509Pair([]byte("key"), []byte("cert"), "cn", big.NewInt(2))))
+	got, err := stor.DeleteByCnDryRun("cn")
+	assert.NoError(t, err)
+	cnDir := filepath.Join(storPath, "cn")
+	assert.Equal(t, []string{
+		cnDir,
+		filepath.Join(cnDir, "1.crt"),
+		filepath.Join(cnDir, "1.key"),
+		filepath.Join(cnDir, "2.crt"),
+		filepath.Join(cnDir, "2.key"),
+	}, got)
+	assert.DirExists(t, cnDir)
+	assert.NoError(t, stor.DeleteByCn("cn"))
+	assert.NoDirExists(t, cnDir)
+}
+
+func caCertPem(t *testing.T) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestDirKeyStorage_DeleteGuards(t *testing.T) {
+	storPath := filepath.Join(getTestDir(), "guard_stor")
+	outside := filepath.Join(getTestDir(), "guard_outside")
+	stor := NewDirKeyStorage(storPath)
+	defer func() {
+		_ = os.RemoveAll(storPath)
+		_ = os.RemoveAll(outside)
+	}()
+	assert.NoError(t, stor.Put(pair.NewX509Pair([]byte("key"), caCertPem(t), "ca", big.NewInt(1))))
+	assert.ErrorIs(t, stor.DeleteByCn("ca"), ErrCAMaterial)
+	assert.ErrorIs(t, stor.DeleteBySerial(big.NewInt(1)), ErrCAMaterial)
+	assert.FileExists(t, filepath.Join(storPath, "ca", "1.crt"))
+	assert.NoError(t, stor.ConfirmCADeletion().DeleteBySerial(big.NewInt(1)))
+	assert.NoFileExists(t, filepath.Join(storPath, "ca", "1.crt"))
+	assert.NoError(t, stor.ConfirmCADeletion().DeleteByCn("ca"))
+
+	assert.NoError(t, os.MkdirAll(outside, 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(outside, "2.crt"), []byte("cert"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(outside, "2.key"), []byte("key"), 0644))
+	if err := os.Symlink(outside, filepath.Join(storPath, "link")); err != nil {
+		t.Skipf("symlinks are not supported: %v", err)
+	}
+	assert.NoError(t, os.MkdirAll(filepath.Join(storPath, "cn"), 0755))
+	assert.NoError(t, os.Symlink(filepath.Join(outside, "2.crt"), filepath.Join(storPath, "cn", "2.crt")))
+	assert.NoError(t, os.Symlink(filepath.Join(outside, "2.key"), filepath.Join(storPath, "cn", "2.key")))
+	assert.ErrorIs(t, stor.DeleteBySerial(big.NewInt(2)), ErrOutsideKeydir)
+	assert.ErrorIs(t, stor.DeleteByCn("link"), ErrOutsideKeydir)
+	assert.FileExists(t, filepath.Join(outside, "2.crt"))
+}
+
 func TestDirKeyStorage_GetByCN(t *testing.T) {
 	type fields struct {
 		keydir string
@@ -672,3 +770,743 @@ func Test_writeFileAtomic(t *testing.T) {
 		})
 	}
 }
+
+func TestDirKeyStorage_Lock(t *testing.T) {
+	storPath := filepath.Join(getTestDir(), "lock_stor")
+	stor := NewDirKeyStorage(storPath)
+	defer func() {
+		_ = os.RemoveAll(storPath)
+	}()
+	unlock, err := stor.Lock("ca")
+	assert.NoError(t, err)
+	assert.FileExists(t, filepath.Join(storPath, ".ca.lock"))
+	assert.NoError(t, unlock())
+	unlock, err = stor.Lock("ca")
+	assert.NoError(t, err)
+	assert.NoError(t, unlock())
+}
+
+func TestFileIndexHolder(t *testing.T) {
+	fileName := filepath.Join(getTestDir(), "dir_keystorage", "index.txt")
+	defer func() {
+		_ = os.Remove(fileName)
+		_ = os.Remove(fileName + ".lock")
+	}()
+	h := NewFileIndexHolder(fileName)
+	got, err := h.Get()
+	assert.NoError(t, err)
+	assert.Empty(t, got)
+	assert.NoError(t, h.Put([]byte("content")))
+	got, err = h.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("content"), got)
+}
+
+func TestDirKeyStorage_CollectErrors(t *testing.T) {
+	storPath := filepath.Join(getTestDir(), "collect_stor")
+	stor := NewDirKeyStorage(storPath)
+	defer func() {
+		_ = os.RemoveAll(storPath)
+	}()
+	assert.NoError(t, stor.Put(pair.NewX509Pair([]byte("key"), []byte("cert"), "good", big.NewInt(1))))
+	assert.NoError(t, os.MkdirAll(filepath.Join(storPath, "no_key"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(storPath, "no_key", "2.crt"), []byte("cert"), 0644))
+
+	all, err := stor.GetAll()
+	assert.NoError(t, err)
+	assert.Len(t, all, 1)
+
+	stor.CollectErrors(true)
+	all, err = stor.GetAll()
+	var scanErr *ScanError
+	assert.ErrorAs(t, err, &scanErr)
+	assert.Len(t, scanErr.Errors, 1)
+	assert.Len(t, all, 1)
+
+	_, err = stor.GetBySerial(big.NewInt(2))
+	assert.ErrorAs(t, err, &scanErr)
+	_, err = stor.GetByCN("no_key")
+	assert.ErrorAs(t, err, &scanErr)
+	got, err := stor.GetBySerial(big.NewInt(1))
+	assert.NoError(t, err)
+	assert.Equal(t, "good", got.CN)
+}
+
+func benchStorage(b *testing.B, cns, perCN int) *DirKeyStorage {
+	stor := NewDirKeyStorage(b.TempDir())
+	serial := int64(1)
+	for i := 0; i < cns; i++ {
+		for j := 0; j < perCN; j++ {
+			_ = stor.Put(pair.NewX509Pair([]byte("keybytes"), []byte("certbytes"), fmt.Sprintf("cn%d", i), big.NewInt(serial)))
+			serial++
+		}
+	}
+	return stor
+}
+
+func BenchmarkDirKeyStorage_GetAll(b *testing.B) {
+	stor := benchStorage(b, 500, 4)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		all, err := stor.GetAll()
+		if err != nil || len(all) != 2000 {
+			b.Fatalf("GetAll() = %v, %v", len(all), err)
+		}
+	}
+}
+
+func BenchmarkDirKeyStorage_GetBySerial(b *testing.B) {
+	stor := benchStorage(b, 500, 4)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := stor.GetBySerial(big.NewInt(1000)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestFileJournal(t *testing.T) {
+	dir := filepath.Join(getTestDir(), "journal")
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	j := NewFileJournal(dir)
+	pending, err := j.Pending()
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+	first, err := j.Begin([]byte("first"))
+	assert.NoError(t, err)
+	second, err := j.Begin([]byte("second"))
+	assert.NoError(t, err)
+	assert.Less(t, first, second)
+	pending, err = j.Pending()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{first: []byte("first"), second: []byte("second")}, pending)
+	assert.NoError(t, j.Commit(first))
+	pending, err = j.Pending()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{second: []byte("second")}, pending)
+	assert.Error(t, j.Commit(first))
+}
+
+func TestDirKeyStorage_LockHolder(t *testing.T) {
+	storPath := filepath.Join(getTestDir(), "lock_holder_stor")
+	defer func() {
+		_ = os.RemoveAll(storPath)
+	}()
+	holder := NewDirKeyStorage(storPath)
+	waiter := NewDirKeyStorage(storPath)
+	waiter.LockTiming(LockPeriod*3, 0)
+	waits := make([]LockWait, 0)
+	waiter.ObserveLocks(func(wait LockWait) {
+		waits = append(waits, wait)
+	})
+	unlock, err := holder.Lock("ca")
+	assert.NoError(t, err)
+	_, err = waiter.Lock("ca")
+	assert.ErrorContains(t, err, fmt.Sprintf("pid=%d", os.Getpid()))
+	assert.NoError(t, unlock())
+	unlock, err = waiter.Lock("ca")
+	assert.NoError(t, err)
+	assert.NoError(t, unlock())
+	assert.Len(t, waits, 2)
+	assert.False(t, waits[0].Acquired)
+	assert.GreaterOrEqual(t, waits[0].Wait, LockPeriod*3)
+	assert.Less(t, waits[0].Wait, LockTimeout)
+	assert.Contains(t, waits[0].Holder, "host=")
+	assert.True(t, waits[1].Acquired)
+}
+
+func TestDirKeyStorage_LockNotReentrant(t *testing.T) {
+	stor := NewDirKeyStorage(t.TempDir())
+	stor.LockTiming(LockPeriod*3, 0)
+	unlock, err := stor.Lock("ca")
+	assert.NoError(t, err)
+	_, err = stor.Lock("ca")
+	assert.ErrorIs(t, err, ErrLocked)
+	assert.NoError(t, unlock())
+	unlock, err = stor.Lock("ca")
+	assert.NoError(t, err)
+	assert.NoError(t, unlock())
+}
+
+func TestFileSerialProvider_AdvanceTo(t *testing.T) {
+	path := filepath.Join(getTestDir(), "advance_serial")
+	defer func() {
+		_ = os.Remove(path)
+		_ = os.Remove(path + ".lock")
+	}()
+	p := NewFileSerialProvider(path)
+	serial, _ := new(big.Int).SetString("4f1c2a9be0d3a5c7e9f10b2d4e6a8c0e1f3a5b7d", 16)
+	assert.NoError(t, p.AdvanceTo(serial))
+	assert.NoError(t, p.AdvanceTo(big.NewInt(1)))
+	last, err := p.Last()
+	assert.NoError(t, err)
+	assert.Equal(t, serial, last)
+	next, err := p.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, "4f1c2a9be0d3a5c7e9f10b2d4e6a8c0e1f3a5b7e", next.Text(16))
+}
+
+func TestDirTrustStore(t *testing.T) {
+	dir := filepath.Join(getTestDir(), "trusted")
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	s := NewDirTrustStore(dir)
+	got, err := s.GetAll()
+	assert.NoError(t, err)
+	assert.Empty(t, got)
+	assert.NoError(t, s.Put("b", []byte("second")))
+	assert.NoError(t, s.Put("a", []byte("first")))
+	assert.Error(t, s.Put("../a", []byte("escape")))
+	got, err = s.GetAll()
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("first"), []byte("second")}, got)
+	assert.NoError(t, s.Delete("a"))
+	assert.Error(t, s.Delete("a"))
+}
+
+func TestFileAuditLog(t *testing.T) {
+	path := filepath.Join(getTestDir(), "audit.log")
+	defer func() {
+		_ = os.Remove(path)
+		_ = os.Remove(path + ".lock")
+	}()
+	l := NewFileAuditLog(path)
+	assert.NoError(t, l.Append([]byte("first")))
+	assert.NoError(t, l.Append([]byte("second")))
+	content, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "first\nsecond\n", string(content))
+}
+
+func TestDirKeyStorage_Metadata(t *testing.T) {
+	storPath := filepath.Join(getTestDir(), "metadata_stor")
+	stor := NewDirKeyStorage(storPath)
+	defer func() {
+		_ = os.RemoveAll(storPath)
+	}()
+	got, err := stor.GetMetadata("cn")
+	assert.NoError(t, err)
+	assert.Empty(t, got)
+	assert.NoError(t, stor.PutMetadata("cn", []byte("{}")))
+	got, err = stor.GetMetadata("cn")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("{}"), got)
+	assert.Error(t, stor.PutMetadata("../cn", []byte("{}")))
+	assert.NoError(t, stor.Put(pair.NewX509Pair([]byte("key"), []byte("cert"), "cn", big.NewInt(1))))
+	pairs, err := stor.GetByCN("cn")
+	assert.NoError(t, err)
+	assert.Len(t, pairs, 1)
+}
+
+func TestDirKeyStorage_PutFullChain(t *testing.T) {
+	storPath := t.TempDir()
+	stor := NewDirKeyStorage(storPath)
+	warnings := make(chan ScanWarning, 100)
+	stor.ReportWarnings(warnings)
+	leaf := pair.NewX509Pair([]byte("key"), []byte("cert"), "web", big.NewInt(0x1a))
+	assert.NoError(t, stor.Put(leaf))
+	assert.NoError(t, stor.PutFullChain(leaf, []byte("cert\nca")))
+	content, err := ioutil.ReadFile(filepath.Join(storPath, "web", "1a.fullchain.crt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "cert\nca", string(content))
+
+	pairs, err := stor.GetByCN("web")
+	assert.NoError(t, err)
+	if assert.Len(t, pairs, 1) {
+		assert.Equal(t, []byte("cert"), pairs[0].CertPemBytes)
+	}
+	assert.Empty(t, receivedWarnings(warnings))
+	assert.NoError(t, stor.DeleteBySerial(big.NewInt(0x1a)))
+	assert.NoFileExists(t, filepath.Join(storPath, "web", "1a.fullchain.crt"))
+}
+
+func TestDirKeyStorage_PutCSR(t *testing.T) {
+	storPath := t.TempDir()
+	stor := NewDirKeyStorage(storPath)
+	warnings := make(chan ScanWarning, 100)
+	stor.ReportWarnings(warnings)
+	leaf := pair.NewX509Pair(nil, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE"}), "web", big.NewInt(0x1a))
+	assert.NoError(t, stor.Put(leaf))
+	content, err := stor.GetCSR(leaf.Serial)
+	assert.NoError(t, err)
+	assert.Empty(t, content)
+	assert.NoError(t, stor.PutCSR(leaf, []byte("csr")))
+	content, err = stor.GetCSR(leaf.Serial)
+	assert.NoError(t, err)
+	assert.Equal(t, "csr", string(content))
+	assert.FileExists(t, filepath.Join(storPath, "web", "1a.csr"))
+
+	pairs, err := stor.GetByCN("web")
+	assert.NoError(t, err)
+	assert.Len(t, pairs, 1)
+	assert.Empty(t, receivedWarnings(warnings))
+	_, err = stor.GetCSR(big.NewInt(0x2b))
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NoError(t, stor.DeleteBySerial(leaf.Serial))
+	assert.NoFileExists(t, filepath.Join(storPath, "web", "1a.csr"))
+}
+
+func TestDirKeyStorage_GetByFingerprint(t *testing.T) {
+	storPath := t.TempDir()
+	stor := NewDirKeyStorage(storPath)
+	certPair := func(content string, cn string, serial int64) (*pair.X509Pair, string) {
+		sum := sha256.Sum256([]byte(content))
+		certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte(content)})
+		return pair.NewX509Pair(nil, certPEM, cn, big.NewInt(serial)), hex.EncodeToString(sum[:])
+	}
+	first, firstFingerprint := certPair("first", "web", 1)
+	assert.NoError(t, stor.Put(first))
+	assert.NoFileExists(t, filepath.Join(storPath, ".fingerprints"))
+	found, err := stor.GetByFingerprint(firstFingerprint)
+	assert.NoError(t, err)
+	assert.Equal(t, first.CertPemBytes, found.CertPemBytes)
+
+	second, secondFingerprint := certPair("second", "api", 2)
+	assert.NoError(t, stor.Put(second))
+	found, err = stor.GetByFingerprint(strings.ToUpper(secondFingerprint[:2] + ":" + secondFingerprint[2:]))
+	assert.NoError(t, err)
+	assert.Equal(t, "api", found.CN)
+
+	replaced, replacedFingerprint := certPair("replaced", "web", 1)
+	assert.NoError(t, stor.Put(replaced))
+	_, err = stor.GetByFingerprint(firstFingerprint)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = stor.GetByFingerprint(replacedFingerprint)
+	assert.NoError(t, err)
+	assert.NoError(t, stor.DeleteBySerial(second.Serial))
+	_, err = stor.GetByFingerprint(secondFingerprint)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.NoError(t, stor.RebuildFingerprints())
+	content, err := ioutil.ReadFile(filepath.Join(storPath, ".fingerprints"))
+	assert.NoError(t, err)
+	assert.Equal(t, "SHA-256\n"+replacedFingerprint+" 1\n", string(content))
+
+	stor.FingerprintHash(crypto.SHA512)
+	_, err = stor.GetByFingerprint(replacedFingerprint)
+	assert.ErrorIs(t, err, ErrNotFound)
+	sum := crypto.SHA512.New()
+	sum.Write([]byte("replaced"))
+	_, err = stor.GetByFingerprint(hex.EncodeToString(sum.Sum(nil)))
+	assert.NoError(t, err)
+}
+
+func TestDirKeyStorage_ScanWarnings(t *testing.T) {
+	storPath := t.TempDir()
+	stor := NewDirKeyStorage(storPath)
+	assert.NoError(t, stor.Put(pair.NewX509Pair([]byte("key"), []byte("cert"), "good", big.NewInt(1))))
+	assert.NoError(t, stor.PutMetadata("good", []byte("{}")))
+	assert.NoError(t, stor.PutPendingKey("good", []byte("key")))
+	for _, name := range []string{"README", ".DS_Store", "1.crt.lock", ".1.crt.tmp123", "2.key4567"} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(storPath, "good", name), []byte("junk"), 0644))
+	}
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(storPath, ".DS_Store"), []byte("junk"), 0644))
+	for _, name := range []string{".serial.tmp42", "serial123", "crl.pem456", "index.txt789"} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(storPath, name), []byte("1"), 0644))
+	}
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(storPath, "serial"), []byte("1"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(storPath, "crl.pem"), []byte("1"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(storPath, "index.txt"), []byte("1"), 0644))
+
+	warnings := make(chan ScanWarning, 100)
+	stor.ReportWarnings(warnings)
+	all, err := stor.GetAll()
+	assert.NoError(t, err)
+	assert.Len(t, all, 1)
+	got := map[string]string{}
+	for _, warning := range receivedWarnings(warnings) {
+		rel, _ := filepath.Rel(storPath, warning.Path)
+		got[rel] = warning.Kind
+	}
+	assert.Equal(t, map[string]string{
+		"good/README":        WarningUnknownFile,
+		"good/.DS_Store":     WarningUnknownFile,
+		"good/1.crt.lock":    WarningLockFile,
+		"good/.1.crt.tmp123": WarningTempFile,
+		"good/2.key4567":     WarningTempFile,
+		".DS_Store":          WarningUnknownFile,
+		".serial.tmp42":      WarningTempFile,
+		"serial123":          WarningTempFile,
+		"crl.pem456":         WarningTempFile,
+		"index.txt789":       WarningTempFile,
+	}, got)
+
+	removed, err := stor.CleanTemp()
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		filepath.Join(storPath, ".serial.tmp42"),
+		filepath.Join(storPath, "serial123"),
+		filepath.Join(storPath, "crl.pem456"),
+		filepath.Join(storPath, "index.txt789"),
+		filepath.Join(storPath, "good", ".1.crt.tmp123"),
+		filepath.Join(storPath, "good", "2.key4567"),
+	}, removed)
+	assert.FileExists(t, filepath.Join(storPath, "serial"))
+	assert.FileExists(t, filepath.Join(storPath, "crl.pem"))
+	assert.FileExists(t, filepath.Join(storPath, "index.txt"))
+	assert.FileExists(t, filepath.Join(storPath, "good", "README"))
+
+	removed, err = NewDirKeyStorage(filepath.Join(storPath, "not_exist")).CleanTemp()
+	assert.NoError(t, err)
+	assert.Empty(t, removed)
+}
+
+func TestDirKeyStorage_CleanStaleTemp(t *testing.T) {
+	storPath := t.TempDir()
+	stor := NewDirKeyStorage(storPath)
+	stale := filepath.Join(storPath, ".serial.tmp1")
+	fresh := filepath.Join(storPath, ".serial.tmp2")
+	assert.NoError(t, ioutil.WriteFile(stale, []byte("1"), 0644))
+	assert.NoError(t, ioutil.WriteFile(fresh, []byte("1"), 0644))
+	old := time.Now().Add(-2 * time.Hour)
+	assert.NoError(t, os.Chtimes(stale, old, old))
+
+	removed, err := stor.CleanStaleTemp(time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{stale}, removed)
+	assert.FileExists(t, fresh)
+
+	stop, err := stor.StartTempCleanup(time.Millisecond, 10*time.Millisecond)
+	assert.NoError(t, err)
+	defer stop()
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(fresh)
+		return os.IsNotExist(err)
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestDirKeyStorage_PutKeyMode(t *testing.T) {
+	stor := NewDirKeyStorage(t.TempDir())
+	p := pair.NewX509Pair([]byte("key"), []byte("cert"), "cn", big.NewInt(1))
+	assert.NoError(t, stor.Put(p))
+	certPath, keyPath := stor.PairPaths(p)
+	if runtime.GOOS != "windows" {
+		stat, err := os.Stat(keyPath)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), stat.Mode().Perm())
+		stat, err = os.Stat(certPath)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0644), stat.Mode().Perm())
+	}
+	assert.NoError(t, restrictAccess(keyPath))
+}
+
+func TestDirKeyStorage_SerialFormat(t *testing.T) {
+	dir := t.TempDir()
+	stor := NewDirKeyStorage(dir)
+	assert.NoError(t, stor.Put(pair.NewX509Pair([]byte("key"), []byte("cert"), "cn", big.NewInt(0x1a))))
+	stor.SerialFormat(4, true)
+	assert.NoError(t, stor.Put(pair.NewX509Pair([]byte("key"), []byte("cert"), "cn", big.NewInt(0x2b))))
+	stor.SerialFormat(1, false)
+	assert.NoError(t, stor.Put(pair.NewX509Pair([]byte("key"), []byte("cert"), "cn", big.NewInt(0x3c))))
+	assert.FileExists(t, filepath.Join(dir, "cn", "1a.crt"))
+	assert.FileExists(t, filepath.Join(dir, "cn", "002B.crt"))
+	assert.FileExists(t, filepath.Join(dir, "cn", "002B.key"))
+	assert.FileExists(t, filepath.Join(dir, "cn", "3c.crt"))
+
+	certPath, keyPath := stor.PairPaths(pair.NewX509Pair(nil, nil, "cn", big.NewInt(0x2b)))
+	assert.Equal(t, filepath.Join(dir, "cn", "002B.crt"), certPath)
+	assert.Equal(t, filepath.Join(dir, "cn", "002B.key"), keyPath)
+	certPath, _ = stor.PairPaths(pair.NewX509Pair(nil, nil, "cn", big.NewInt(0x4d)))
+	assert.Equal(t, filepath.Join(dir, "cn", "4d.crt"), certPath)
+
+	got, err := stor.GetBySerial(big.NewInt(0x2b))
+	assert.NoError(t, err)
+	assert.Equal(t, "cn", got.CN)
+	pairs, err := stor.GetByCN("cn")
+	assert.NoError(t, err)
+	assert.Len(t, pairs, 3)
+	assert.NoError(t, stor.DeleteBySerial(big.NewInt(0x2b)))
+	assert.NoFileExists(t, filepath.Join(dir, "cn", "002B.key"))
+	_, err = stor.GetBySerial(big.NewInt(0x1a))
+	assert.NoError(t, err)
+}
+
+func TestFileSerialProvider_OpenSSLFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "serial")
+	p := NewFileSerialProvider(path)
+	p.OpenSSLFormat(true)
+	for _, want := range []int64{1, 2} {
+		got, err := p.Next()
+		assert.NoError(t, err)
+		assert.Equal(t, big.NewInt(want), got)
+	}
+	content, _ := ioutil.ReadFile(path)
+	assert.Equal(t, "03\n", string(content))
+	old, _ := ioutil.ReadFile(path + ".old")
+	assert.Equal(t, "02\n", string(old))
+
+	assert.NoError(t, p.AdvanceTo(big.NewInt(0xfe)))
+	content, _ = ioutil.ReadFile(path)
+	assert.Equal(t, "FF\n", string(content))
+	last, err := p.Last()
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(0xfe), last)
+	got, err := p.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(0xff), got)
+	content, _ = ioutil.ReadFile(path)
+	assert.Equal(t, "0100\n", string(content))
+
+	p.OpenSSLFormat(false)
+	got, err = p.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(0x101), got)
+
+	p.OpenSSLFormat(true)
+	assert.NoError(t, ioutil.WriteFile(path, []byte("garbage\n"), 0644))
+	_, err = p.Next()
+	assert.Error(t, err)
+	_, err = p.Last()
+	assert.Error(t, err)
+	content, _ = ioutil.ReadFile(path)
+	assert.Equal(t, "garbage\n", string(content))
+}
+
+func TestFileSerialProvider_NextConcurrent(t *testing.T) {
+	p := NewFileSerialProvider(filepath.Join(t.TempDir(), "serial"))
+	const goroutines, perGoroutine = 16, 20
+	serials := make(chan string, goroutines*perGoroutine)
+	wg := sync.WaitGroup{}
+	wg.Add(goroutines)
+	for g := 0; g < goroutines; g++ {
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perGoroutine; i++ {
+				serial, err := p.Next()
+				if assert.NoError(t, err) {
+					serials <- serial.String()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(serials)
+	seen := map[string]bool{}
+	for serial := range serials {
+		assert.False(t, seen[serial], "serial %v is returned twice", serial)
+		seen[serial] = true
+	}
+	assert.Len(t, seen, goroutines*perGoroutine)
+}
+
+func TestDirKeyStorage_GetLastByCnSkipsBroken(t *testing.T) {
+	stor := NewDirKeyStorage(t.TempDir())
+	assert.NoError(t, stor.Put(pair.NewX509Pair([]byte("key"), []byte("cert"), "client", big.NewInt(1))))
+	assert.NoError(t, stor.Put(pair.NewX509Pair([]byte("key"), []byte("cert"), "client", big.NewInt(2))))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(stor.keydir, "client", "3.crt"), []byte("cert"), 0644))
+
+	got, err := stor.GetLastByCn("client")
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(2), got.Serial)
+
+	stor.CollectErrors(true)
+	got, err = stor.GetLastByCn("client")
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(2), got.Serial)
+}
+
+func Test_checkName(t *testing.T) {
+	for _, name := range []string{"client", "Иван Петров", "张伟", "server.example.com", "console", "CON", "trailing."} {
+		assert.NoError(t, checkName(name), name)
+	}
+	for _, name := range []string{"", "..", "a/b", "a\\b", "nul\x00", ".hidden"} {
+		assert.Error(t, checkName(name), name)
+	}
+}
+
+func Test_checkWindowsName(t *testing.T) {
+	for _, name := range []string{"client", "Иван Петров", "张伟", "server.example.com", "console"} {
+		assert.NoError(t, checkWindowsName(name), name)
+	}
+	for _, name := range []string{"a:b", "tab\tname", "\xff", "trailing.", "trailing ", "CON", "nul.txt", "com1"} {
+		assert.Error(t, checkWindowsName(name), name)
+	}
+}
+
+// concurrently run fn b.N times in total with n goroutines
+func benchConcurrent(b *testing.B, n int, fn func() error) {
+	jobs := make(chan struct{}, b.N)
+	for i := 0; i < b.N; i++ {
+		jobs <- struct{}{}
+	}
+	close(jobs)
+	wg := sync.WaitGroup{}
+	wg.Add(n)
+	b.ResetTimer()
+	for g := 0; g < n; g++ {
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				if err := fn(); err != nil {
+					b.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkFileSerialProvider_NextConcurrent(b *testing.B) {
+	for _, n := range []int{1, 8, 32} {
+		b.Run(fmt.Sprintf("goroutines=%d", n), func(b *testing.B) {
+			provider := NewFileSerialProvider(filepath.Join(b.TempDir(), "serial"))
+			benchConcurrent(b, n, func() error {
+				_, err := provider.Next()
+				return err
+			})
+		})
+	}
+}
+
+func BenchmarkDirKeyStorage_LockConcurrent(b *testing.B) {
+	for _, n := range []int{1, 8, 32} {
+		b.Run(fmt.Sprintf("goroutines=%d", n), func(b *testing.B) {
+			stor := NewDirKeyStorage(b.TempDir())
+			benchConcurrent(b, n, func() error {
+				unlock, err := stor.Lock("ca")
+				if err != nil {
+					return err
+				}
+				return unlock()
+			})
+		})
+	}
+}
+
+func BenchmarkDirKeyStorage_GetLastByCn(b *testing.B) {
+	stor := benchStorage(b, 1, 50)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := stor.GetLastByCn("cn0"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestDirTokenStore(t *testing.T) {
+	dir := t.TempDir()
+	s := NewDirTokenStore(dir)
+	_, err := s.Take("abc")
+	assert.Error(t, err)
+	assert.NoError(t, s.Put("abc", []byte("record")))
+	assert.Error(t, s.Put("../abc", []byte("escape")))
+
+	taken := make(chan []byte, 4)
+	wg := sync.WaitGroup{}
+	for i := 0; i < cap(taken); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if content, err := s.Take("abc"); err == nil {
+				taken <- content
+			}
+		}()
+	}
+	wg.Wait()
+	close(taken)
+	assert.Len(t, taken, 1)
+	assert.Equal(t, []byte("record"), <-taken)
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestDirRequestStore(t *testing.T) {
+	s := NewDirRequestStore(filepath.Join(t.TempDir(), "reqs"))
+	all, err := s.GetAll()
+	assert.NoError(t, err)
+	assert.Empty(t, all)
+	assert.NoError(t, s.Put("05", []byte("five")))
+	assert.NoError(t, s.Put("0a", []byte("ten")))
+	assert.Error(t, s.Put("../0b", []byte("escape")))
+	all, err = s.GetAll()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"05": []byte("five"), "0a": []byte("ten")}, all)
+
+	content, err := s.Take("05")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("five"), content)
+	_, err = s.Take("05")
+	assert.Error(t, err)
+	all, err = s.GetAll()
+	assert.NoError(t, err)
+	assert.Len(t, all, 1)
+}
+
+func TestDirKeyStorage_GetCertOnly(t *testing.T) {
+	stor := NewDirKeyStorage(t.TempDir())
+	certPEM := []byte("-----BEGIN CERTIFICATE-----\nY2VydA==\n-----END CERTIFICATE-----\n")
+	assert.NoError(t, stor.Put(pair.NewX509Pair([]byte("key"), certPEM, "client", big.NewInt(1))))
+	assert.NoError(t, stor.Put(pair.NewX509Pair([]byte("key"), certPEM, "client", big.NewInt(2))))
+	assert.NoError(t, os.Remove(filepath.Join(stor.keydir, "client", "1.key")))
+	assert.NoError(t, os.Remove(filepath.Join(stor.keydir, "client", "2.key")))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(stor.keydir, "client", "3.crt"), []byte("cert"), 0644))
+
+	_, err := stor.GetLastByCn("client")
+	assert.Error(t, err)
+	got, err := stor.GetCertOnly("client")
+	assert.NoError(t, err)
+	assert.Equal(t, pair.NewX509Pair(nil, certPEM, "client", big.NewInt(2)), got)
+
+	_, err = stor.GetBySerial(big.NewInt(1))
+	assert.Error(t, err)
+	got, err = stor.GetCertOnlyBySerial(big.NewInt(1))
+	assert.NoError(t, err)
+	assert.Equal(t, certPEM, got.CertPemBytes)
+	assert.Empty(t, got.KeyPemBytes)
+	_, err = stor.GetCertOnlyBySerial(big.NewInt(3))
+	assert.Error(t, err)
+
+	all, err := stor.GetAllCertOnly()
+	assert.NoError(t, err)
+	assert.Len(t, all, 2)
+	stor.CollectErrors(true)
+	all, err = stor.GetAllCertOnly()
+	assert.Len(t, all, 2)
+	var scanErr *ScanError
+	assert.ErrorAs(t, err, &scanErr)
+}
+
+func TestDirKeyStorage_GetByCNPattern(t *testing.T) {
+	stor := NewDirKeyStorage(t.TempDir())
+	got, err := NewDirKeyStorage(filepath.Join(t.TempDir(), "missing")).GetByCNPattern("*")
+	assert.NoError(t, err)
+	assert.Empty(t, got)
+	for i, cn := range []string{"vpn-client-1", "vpn-client-2", "vpn-server", "web"} {
+		assert.NoError(t, stor.Put(pair.NewX509Pair([]byte("key"), []byte("cert"), cn, big.NewInt(int64(i+1)))))
+	}
+	assert.NoError(t, stor.Put(pair.NewX509Pair([]byte("key"), []byte("cert"), "vpn-client-1", big.NewInt(5))))
+
+	names := func(pattern string) []string {
+		pairs, err := stor.GetByCNPattern(pattern)
+		assert.NoError(t, err)
+		res := make([]string, 0, len(pairs))
+		for _, p := range pairs {
+			res = append(res, fmt.Sprintf("%v/%v", p.CN, p.Serial))
+		}
+		return res
+	}
+	assert.Equal(t, []string{"vpn-client-1/1", "vpn-client-1/5", "vpn-client-2/2"}, names("vpn-client-*"))
+	assert.Equal(t, []string{"vpn-server/3"}, names("vpn-[^c]*"))
+	assert.Equal(t, []string{"web/4"}, names("web"))
+	assert.Empty(t, names("mail-*"))
+	_, err = stor.GetByCNPattern("vpn-[")
+	assert.Error(t, err)
+}
+
+// receivedWarnings return warnings buffered in ch
+func receivedWarnings(ch chan ScanWarning) []ScanWarning {
+	res := make([]ScanWarning, 0)
+	for {
+		select {
+		case warning := <-ch:
+			res = append(res, warning)
+		default:
+			return res
+		}
+	}
+}
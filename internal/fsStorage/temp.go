@@ -0,0 +1,96 @@
+package fsStorage
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// tempSuffix is a part of temp file name between hidden target name and random digits: .<target>.tmp<digits>
+const tempSuffix = ".tmp"
+
+// TempMaxAge is an age after which temp file can`t belong to a write in progress
+const TempMaxAge = time.Hour
+
+// tempFileRe match temp files of writeFileAtomic including ones left by versions writing them as <target><digits>,
+// in pair directories and for serial, crl and index files in keydir root
+var tempFileRe = regexp.MustCompile(`^(\..+\.tmp|[0-9a-fA-F]+\.(crt|key)|metadata\.json|serial|crl\.pem|index\.txt)[0-9]+$`)
+
+// isTempFile check that name is a temp file of writeFileAtomic
+func isTempFile(name string) bool {
+	return tempFileRe.MatchString(name)
+}
+
+// CleanTemp remove temp files left in keydir by interrupted writes and return their paths.
+// Temp files of the write in progress are removed as well, so run it when nobody writes to keydir.
+func (s *DirKeyStorage) CleanTemp() ([]string, error) {
+	return s.cleanTemp(time.Now())
+}
+
+// CleanStaleTemp remove temp files which weren`t modified for maxAge and return their paths.
+// It`s safe to run concurrently with writes if maxAge is much longer than a write, like TempMaxAge.
+func (s *DirKeyStorage) CleanStaleTemp(maxAge time.Duration) ([]string, error) {
+	return s.cleanTemp(time.Now().Add(-maxAge))
+}
+
+// StartTempCleanup remove stale temp files right away and then every interval until stop is called.
+// Errors of background runs are skipped, the next run retries.
+func (s *DirKeyStorage) StartTempCleanup(maxAge, interval time.Duration) (stop func(), err error) {
+	if _, err := s.CleanStaleTemp(maxAge); err != nil {
+		return nil, err
+	}
+	done := make(chan struct{})
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				_, _ = s.CleanStaleTemp(maxAge)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+	}, nil
+}
+
+// cleanTemp remove temp files modified before
+func (s *DirKeyStorage) cleanTemp(before time.Time) ([]string, error) {
+	removed := make([]string, 0)
+	err := filepath.WalkDir(s.keydir, func(path string, d fs.DirEntry, err error) error {
+		if os.IsNotExist(err) && path == s.keydir {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() || !isTempFile(d.Name()) {
+			return nil
+		}
+		info, err := d.Info()
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(before) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("can`t remove temp file %v: %w", path, err)
+		}
+		removed = append(removed, path)
+		return nil
+	})
+	if err != nil {
+		return removed, fmt.Errorf("can`t clean temp files in %v: %w", s.keydir, err)
+	}
+	return removed, nil
+}
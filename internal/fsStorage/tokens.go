@@ -0,0 +1,57 @@
+package fsStorage
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const tokenFileExtension = ".token" // enrollment token file extension
+
+// DirTokenStore implement TokenStore interface with storing every token record as file in dir
+type DirTokenStore struct {
+	dir string
+}
+
+func NewDirTokenStore(dir string) *DirTokenStore {
+	return &DirTokenStore{dir: dir}
+}
+
+// Put token record with id. Overwrite if already exist.
+func (s *DirTokenStore) Put(id string, content []byte) error {
+	if err := checkNewName(id); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return fmt.Errorf("can`t create token dir %v: %w", s.dir, err)
+	}
+	path := filepath.Join(s.dir, id+tokenFileExtension)
+	if err := writeFileAtomic(path, bytes.NewReader(content), 0600); err != nil {
+		return fmt.Errorf("can`t write token %v: %w", path, err)
+	}
+	return nil
+}
+
+// Take remove token record with id and return its content. Record is renamed before reading, so only one
+// of concurrent callers gets it, even from different processes.
+func (s *DirTokenStore) Take(id string) ([]byte, error) {
+	if err := checkName(id); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(s.dir, id+tokenFileExtension)
+	// taken record looks like temp file, so it`s cleaned if process crashes before removing it
+	taken := filepath.Join(s.dir, "."+id+tokenFileExtension+tempSuffix+"0")
+	if err := os.Rename(path, taken); err != nil {
+		return nil, fmt.Errorf("can`t take token %v: %w", id, err)
+	}
+	defer func() {
+		_ = os.Remove(taken)
+	}()
+	content, err := ioutil.ReadFile(taken)
+	if err != nil {
+		return nil, fmt.Errorf("can`t read token %v: %w", id, err)
+	}
+	return content, nil
+}
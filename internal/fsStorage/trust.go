@@ -0,0 +1,75 @@
+package fsStorage
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const trustFileExtension = ".pem" // trusted certificate file extension
+
+// DirTrustStore implement TrustStore interface with storing every trusted certificate as pem file in dir
+type DirTrustStore struct {
+	dir string
+}
+
+func NewDirTrustStore(dir string) *DirTrustStore {
+	return &DirTrustStore{dir: dir}
+}
+
+// Put certificate content with name. Overwrite if already exist.
+func (s *DirTrustStore) Put(name string, content []byte) error {
+	if err := checkNewName(name); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("can`t create trust store dir %v: %w", s.dir, err)
+	}
+	path := filepath.Join(s.dir, name+trustFileExtension)
+	if err := writeFileAtomic(path, bytes.NewReader(content), 0644); err != nil {
+		return fmt.Errorf("can`t write trusted cert %v: %w", path, err)
+	}
+	return nil
+}
+
+// Delete certificate with name
+func (s *DirTrustStore) Delete(name string) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+	path := filepath.Join(s.dir, name+trustFileExtension)
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("can`t delete trusted cert %v: %w", path, err)
+	}
+	return nil
+}
+
+// GetAll return content of all certificates ordered by name. Empty without trust store dir.
+func (s *DirTrustStore) GetAll() ([][]byte, error) {
+	entries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return [][]byte{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can`t read trust store dir %v: %w", s.dir, err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && filepath.Ext(entry.Name()) == trustFileExtension {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	res := make([][]byte, 0, len(names))
+	for _, name := range names {
+		content, err := ioutil.ReadFile(filepath.Join(s.dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("can`t read trusted cert %v: %w", name, err)
+		}
+		res = append(res, content)
+	}
+	return res, nil
+}
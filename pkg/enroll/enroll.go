@@ -0,0 +1,177 @@
+// Package enroll is a client of pki.EnrollHandler. Key is generated locally and never leaves the client,
+// only CSR is sent to the CA:
+//
+//	client := &enroll.Client{URL: "https://pki.example.com"}
+//	certPair, err := client.Enroll(enroll.Request{CommonName: "host1"})
+//	err = enroll.WriteFiles(certPair, "host1.crt", "host1.key")
+package enroll
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kemsta/go-easyrsa/pkg/pair"
+	"github.com/kemsta/go-easyrsa/pkg/pki"
+)
+
+// DefaultKeySize is size of generated rsa keys
+const DefaultKeySize = 2048
+
+// Request describe requested certificate subject
+type Request struct {
+	CommonName  string
+	DNSNames    []string
+	IPAddresses []net.IP
+}
+
+// Client enroll certificates at CA serving pki.EnrollHandler at pki.EnrollPath
+type Client struct {
+	URL     string       // base url of CA server
+	Token   string       // enrollment token if CA requires it, see pki.MintToken
+	KeySize int          // DefaultKeySize if it`s zero
+	Client  *http.Client // http.DefaultClient by default
+}
+
+// Enroll generate key, submit CSR for req and return pair with issued certificate and the key
+func (c *Client) Enroll(req Request) (*pair.X509Pair, error) {
+	keySize := c.KeySize
+	if keySize == 0 {
+		keySize = DefaultKeySize
+	}
+	key, err := rsa.GenerateKey(rand.Reader, keySize)
+	if err != nil {
+		return nil, fmt.Errorf("can`t generate key: %w", err)
+	}
+	defer pair.WipeRSAKey(key)
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:     pkix.Name{CommonName: req.CommonName},
+		DNSNames:    req.DNSNames,
+		IPAddresses: req.IPAddresses,
+	}, key)
+	if err != nil {
+		return nil, fmt.Errorf("can`t create csr: %w", err)
+	}
+	headers, err := signedHeaders(key, csr)
+	if err != nil {
+		return nil, err
+	}
+	certPEM, err := c.post(pki.EnrollPath, pki.MIMEPKCS10, csr, headers)
+	if err != nil {
+		return nil, fmt.Errorf("can`t enroll: %w", err)
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != pki.PEMCertificateBlock {
+		return nil, fmt.Errorf("can`t enroll: response isn`t a pem certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("can`t parse issued certificate: %w", err)
+	}
+	if public, ok := cert.PublicKey.(*rsa.PublicKey); !ok || !public.Equal(&key.PublicKey) {
+		return nil, fmt.Errorf("issued certificate doesn`t match the key")
+	}
+	keyDER := x509.MarshalPKCS1PrivateKey(key)
+	defer pair.Wipe(keyDER)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: pki.PEMRSAPrivateKeyBlock, Bytes: keyDER})
+	name := req.CommonName
+	if name == "" && len(req.DNSNames) > 0 {
+		name = req.DNSNames[0]
+	}
+	return pair.NewX509Pair(keyPEM, certPEM, name, cert.SerialNumber), nil
+}
+
+// signedHeaders return nonce, time and signature headers of CSR for CA requiring signed requests,
+// see pki.EnrollReplayWindow
+func signedHeaders(key *rsa.PrivateKey, csr []byte) (http.Header, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("can`t generate nonce: %w", err)
+	}
+	headers := http.Header{}
+	headers.Set(pki.HeaderEnrollNonce, base64.RawURLEncoding.EncodeToString(nonce))
+	headers.Set(pki.HeaderEnrollTime, time.Now().UTC().Format(time.RFC3339))
+	digest := pki.EnrollDigest(headers.Get(pki.HeaderEnrollNonce), headers.Get(pki.HeaderEnrollTime), csr)
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest)
+	if err != nil {
+		return nil, fmt.Errorf("can`t sign request: %w", err)
+	}
+	headers.Set(pki.HeaderEnrollSignature, base64.StdEncoding.EncodeToString(signature))
+	return headers, nil
+}
+
+// Error is a failed response of CA server
+type Error struct {
+	Status  string        // http status
+	Code    pki.ErrorCode // code to branch on, empty if server doesn`t send it
+	Message string        // human readable message
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%v: %s", e.Status, e.Message)
+}
+
+// post content with headers to path of CA server and return response body
+func (c *Client) post(path, contentType string, content []byte, headers http.Header) ([]byte, error) {
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(c.URL, "/")+path, bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", contentType)
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &Error{Status: resp.Status, Code: pki.ErrorCode(resp.Header.Get(pki.ErrorCodeHeader)),
+			Message: string(bytes.TrimSpace(body))}
+	}
+	return body, nil
+}
+
+// WriteFiles write certificate and key of pair into files. Key file is readable by owner only.
+func WriteFiles(certPair *pair.X509Pair, certPath, keyPath string) error {
+	for _, file := range []struct {
+		path    string
+		content []byte
+		perm    os.FileMode
+	}{{keyPath, certPair.KeyPemBytes, 0600}, {certPath, certPair.CertPemBytes, 0644}} {
+		if err := os.MkdirAll(filepath.Dir(file.path), 0750); err != nil {
+			return fmt.Errorf("can`t create dir for %v: %w", file.path, err)
+		}
+		if err := ioutil.WriteFile(file.path, file.content, file.perm); err != nil {
+			return fmt.Errorf("can`t write %v: %w", file.path, err)
+		}
+	}
+	return nil
+}
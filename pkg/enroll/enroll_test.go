@@ -0,0 +1,65 @@
+package enroll
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kemsta/go-easyrsa/pkg/pki"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_Enroll(t *testing.T) {
+	ca, err := pki.InitPKI(t.TempDir(), nil)
+	assert.NoError(t, err)
+	_, err = ca.NewCa()
+	assert.NoError(t, err)
+	handler, err := pki.NewEnrollHandler(ca, pki.ProfileClient, pki.OpenEnrollment(), pki.EnrollReplayWindow(time.Minute))
+	assert.NoError(t, err)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	client := &Client{URL: server.URL + "/", KeySize: 2048}
+	certPair, err := client.Enroll(Request{CommonName: "host1", DNSNames: []string{"host1.example.com"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "host1", certPair.CN)
+	_, cert, err := certPair.Decode()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"host1.example.com"}, cert.DNSNames)
+	assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}, cert.ExtKeyUsage)
+	stored, err := ca.Storage.GetBySerial(certPair.Serial)
+	assert.NoError(t, err)
+	assert.Empty(t, stored.KeyPemBytes)
+
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "host1.crt"), filepath.Join(dir, "private", "host1.key")
+	assert.NoError(t, WriteFiles(certPair, certPath, keyPath))
+	_, err = tls.LoadX509KeyPair(certPath, keyPath)
+	assert.NoError(t, err)
+
+	_, err = client.Enroll(Request{})
+	assert.Error(t, err)
+}
+
+func TestClient_EnrollErrorCode(t *testing.T) {
+	ca, err := pki.InitPKI(t.TempDir(), nil, pki.WithDNSGuard(pki.DNSGuard{Zones: []string{"corp.example.com"}}))
+	assert.NoError(t, err)
+	_, err = ca.NewCa()
+	assert.NoError(t, err)
+	handler, err := pki.NewEnrollHandler(ca, pki.ProfileServer, pki.OpenEnrollment())
+	assert.NoError(t, err)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	client := &Client{URL: server.URL, KeySize: 2048}
+	_, err = client.Enroll(Request{CommonName: "web", DNSNames: []string{"web.example.org"}})
+	var apiErr *Error
+	if assert.True(t, errors.As(err, &apiErr)) {
+		assert.Equal(t, pki.CodePolicyDenied, apiErr.Code)
+		assert.Contains(t, apiErr.Message, "web.example.org")
+	}
+}
@@ -0,0 +1,135 @@
+package pair
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+// JWK is a public json web key (RFC 7517) with certificate chain
+type JWK struct {
+	Kty     string   `json:"kty"`
+	Kid     string   `json:"kid,omitempty"`
+	Use     string   `json:"use,omitempty"`
+	Alg     string   `json:"alg,omitempty"`
+	Crv     string   `json:"crv,omitempty"`
+	N       string   `json:"n,omitempty"`
+	E       string   `json:"e,omitempty"`
+	X       string   `json:"x,omitempty"`
+	Y       string   `json:"y,omitempty"`
+	X5c     []string `json:"x5c,omitempty"`
+	X5tS256 string   `json:"x5t#S256,omitempty"`
+}
+
+// JWKS is a json web key set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// PublicJWK return certificate public key as json web key. Key id is RFC 7638 thumbprint.
+func (pair *X509Pair) PublicJWK() (*JWK, error) {
+	cert, err := pair.DecodeCert()
+	if err != nil {
+		return nil, err
+	}
+	b64 := base64.RawURLEncoding.EncodeToString
+	res := &JWK{Use: "sig"}
+	var thumbprintInput interface{}
+	switch pub := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		res.Kty, res.Alg = "RSA", "RS256"
+		res.N = b64(pub.N.Bytes())
+		res.E = b64(big.NewInt(int64(pub.E)).Bytes())
+		thumbprintInput = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{res.E, res.Kty, res.N}
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		res.Kty, res.Crv = "EC", pub.Curve.Params().Name
+		switch res.Crv {
+		case "P-256":
+			res.Alg = "ES256"
+		case "P-384":
+			res.Alg = "ES384"
+		case "P-521":
+			res.Alg = "ES512"
+		}
+		res.X = b64(pub.X.FillBytes(make([]byte, size)))
+		res.Y = b64(pub.Y.FillBytes(make([]byte, size)))
+		thumbprintInput = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{res.Crv, res.Kty, res.X, res.Y}
+	case ed25519.PublicKey:
+		res.Kty, res.Crv, res.Alg = "OKP", "Ed25519", "EdDSA"
+		res.X = b64(pub)
+		thumbprintInput = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{res.Crv, res.Kty, res.X}
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T of %v with serial %v", pub, pair.CN, pair.Serial)
+	}
+	thumbprint, err := json.Marshal(thumbprintInput)
+	if err != nil {
+		return nil, fmt.Errorf("can`t marshal jwk thumbprint input: %w", err)
+	}
+	kid := sha256.Sum256(thumbprint)
+	res.Kid = b64(kid[:])
+	res.X5c = []string{base64.StdEncoding.EncodeToString(cert.Raw)}
+	x5t := sha256.Sum256(cert.Raw)
+	res.X5tS256 = b64(x5t[:])
+	return res, nil
+}
+
+// PublicKey return public key of RSA, EC or OKP json web key
+func (k *JWK) PublicKey() (crypto.PublicKey, error) {
+	decode := base64.RawURLEncoding.DecodeString
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("bad jwk modulus: %w", err)
+		}
+		e, err := decode(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("bad jwk exponent %q", k.E)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+		curve, ok := curves[k.Crv]
+		if !ok {
+			return nil, fmt.Errorf("unsupported jwk curve %q", k.Crv)
+		}
+		x, errX := decode(k.X)
+		y, errY := decode(k.Y)
+		if errX != nil || errY != nil {
+			return nil, fmt.Errorf("bad jwk point")
+		}
+		pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, fmt.Errorf("jwk point isn`t on curve %v", k.Crv)
+		}
+		return pub, nil
+	case "OKP":
+		x, err := decode(k.X)
+		if err != nil || k.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("unsupported jwk key %v %v", k.Kty, k.Crv)
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported jwk key type %q", k.Kty)
+}
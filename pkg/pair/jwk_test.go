@@ -0,0 +1,92 @@
+package pair
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func selfSignedPair(t *testing.T, key crypto.Signer) *X509Pair {
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "ca"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	assert.NoError(t, err)
+	return NewX509Pair(nil, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), "ca", big.NewInt(1))
+}
+
+func TestX509Pair_PublicJWK(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	b64 := base64.RawURLEncoding.EncodeToString
+	t.Run("rsa", func(t *testing.T) {
+		got, err := selfSignedPair(t, rsaKey).PublicJWK()
+		assert.NoError(t, err)
+		assert.Equal(t, "RSA", got.Kty)
+		assert.Equal(t, "RS256", got.Alg)
+		assert.Equal(t, "AQAB", got.E)
+		assert.Equal(t, b64(rsaKey.N.Bytes()), got.N)
+		kid := sha256.Sum256([]byte(`{"e":"AQAB","kty":"RSA","n":"` + got.N + `"}`))
+		assert.Equal(t, b64(kid[:]), got.Kid)
+		assert.Len(t, got.X5c, 1)
+	})
+	t.Run("ec", func(t *testing.T) {
+		got, err := selfSignedPair(t, ecKey).PublicJWK()
+		assert.NoError(t, err)
+		assert.Equal(t, "EC", got.Kty)
+		assert.Equal(t, "P-256", got.Crv)
+		assert.Equal(t, "ES256", got.Alg)
+		assert.Len(t, got.X, 43)
+		assert.Len(t, got.Y, 43)
+		kid := sha256.Sum256([]byte(`{"crv":"P-256","kty":"EC","x":"` + got.X + `","y":"` + got.Y + `"}`))
+		assert.Equal(t, b64(kid[:]), got.Kid)
+	})
+	t.Run("ed25519", func(t *testing.T) {
+		got, err := selfSignedPair(t, edKey).PublicJWK()
+		assert.NoError(t, err)
+		assert.Equal(t, "OKP", got.Kty)
+		assert.Equal(t, "EdDSA", got.Alg)
+		assert.Equal(t, b64(edKey.Public().(ed25519.PublicKey)), got.X)
+	})
+	t.Run("broken cert", func(t *testing.T) {
+		_, err := NewX509Pair(nil, []byte("bad"), "ca", big.NewInt(1)).PublicJWK()
+		assert.Error(t, err)
+	})
+}
+
+func TestJWK_PublicKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	assert.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	for _, key := range []crypto.Signer{rsaKey, ecKey, edKey} {
+		jwk, err := selfSignedPair(t, key).PublicJWK()
+		assert.NoError(t, err)
+		pub, err := jwk.PublicKey()
+		assert.NoError(t, err)
+		assert.True(t, pub.(interface{ Equal(crypto.PublicKey) bool }).Equal(key.Public()))
+	}
+	for _, jwk := range []JWK{{Kty: "oct"}, {Kty: "EC", Crv: "P-256K"}, {Kty: "EC", Crv: "P-256", X: "AQ", Y: "AQ"}, {Kty: "RSA", N: "AQ"}} {
+		_, err := jwk.PublicKey()
+		assert.Error(t, err)
+	}
+}
@@ -1,42 +1,176 @@
 package pair
 
 import (
+	"crypto"
+	"crypto/ecdsa"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"math/big"
 )
 
 // X509Pair represent pair cert and key
 type X509Pair struct {
-	KeyPemBytes  []byte   // pem encoded rsa.PrivateKey bytes
+	KeyPemBytes  []byte   // pem encoded private key bytes
 	CertPemBytes []byte   // pem encoded x509.Certificate bytes
-	CN           string   // common name
+	CN           string   // common name or identity name. Pairs are stored with it
 	Serial       *big.Int // serial number
 }
 
+// DecodeError is returned when pair can`t be decoded. It references pair by cn and serial only
+// and never contains key or certificate content.
+type DecodeError struct {
+	CN     string   // pair name
+	Serial *big.Int // pair serial
+	Part   string   // "key" or "cert"
+	Err    error    // parse error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("can`t parse %s of %v with serial %v: %v", e.Part, e.CN, e.Serial, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+var errNoPemBlock = errors.New("no pem block")
+
 // Decode pem bytes to rsa.PrivateKey and x509.Certificate
 func (pair *X509Pair) Decode() (key *rsa.PrivateKey, cert *x509.Certificate, err error) {
 	block, _ := pem.Decode(pair.KeyPemBytes)
 	if block == nil {
-		return nil, nil, fmt.Errorf("can`t parse key: %v", string(pair.KeyPemBytes))
+		return nil, nil, pair.decodeError("key", errNoPemBlock)
 	}
 
 	key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	Wipe(block.Bytes)
 	if err != nil {
-		return nil, nil, fmt.Errorf("can`t parse key %v: %w", string(block.Bytes), err)
+		return nil, nil, pair.decodeError("key", err)
 	}
 
-	block, _ = pem.Decode(pair.CertPemBytes)
+	cert, err = pair.DecodeCert()
+	if err != nil {
+		return nil, nil, err
+	}
+	return
+}
+
+// DecodeSigner decode pem bytes to rsa or ecdsa private key and x509.Certificate. PKCS#1, SEC 1 and PKCS#8
+// keys are accepted.
+func (pair *X509Pair) DecodeSigner() (crypto.Signer, *x509.Certificate, error) {
+	block, _ := pem.Decode(pair.KeyPemBytes)
 	if block == nil {
-		return nil, nil, fmt.Errorf("can`t parse cert: %v", string(pair.CertPemBytes))
+		return nil, nil, pair.decodeError("key", errNoPemBlock)
 	}
-	cert, err = x509.ParseCertificate(block.Bytes)
+	key, err := parsePrivateKey(block.Bytes)
+	Wipe(block.Bytes)
 	if err != nil {
-		return nil, nil, fmt.Errorf("can`t parse cert %v: %w", string(block.Bytes), err)
+		return nil, nil, pair.decodeError("key", err)
 	}
-	return
+	cert, err := pair.DecodeCert()
+	if err != nil {
+		WipeKey(key)
+		return nil, nil, err
+	}
+	return key, cert, nil
+}
+
+func parsePrivateKey(der []byte) (crypto.Signer, error) {
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return key, nil
+	case *ecdsa.PrivateKey:
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %T", key)
+}
+
+// DecodeCert decode only certificate pem bytes to x509.Certificate
+func (pair *X509Pair) DecodeCert() (*x509.Certificate, error) {
+	block, _ := pem.Decode(pair.CertPemBytes)
+	if block == nil {
+		return nil, pair.decodeError("cert", errNoPemBlock)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, pair.decodeError("cert", err)
+	}
+	return cert, nil
+}
+
+func (pair *X509Pair) decodeError(part string, err error) error {
+	return &DecodeError{CN: pair.CN, Serial: pair.Serial, Part: part, Err: err}
+}
+
+// String describe pair without key and certificate content, so pair is safe to format in errors and logs
+func (pair *X509Pair) String() string {
+	return fmt.Sprintf("X509Pair{CN: %q, Serial: %v}", pair.CN, pair.Serial)
+}
+
+// GoString describe pair without key and certificate content for %#v verb
+func (pair *X509Pair) GoString() string {
+	return pair.String()
+}
+
+// Zeroize overwrite private key pem bytes with zeros. Key can`t be decoded after it.
+// It's a best-effort protection of key material in memory dumps.
+func (pair *X509Pair) Zeroize() {
+	Wipe(pair.KeyPemBytes)
+}
+
+// Wipe overwrite buffer with zeros
+func Wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// WipeRSAKey overwrite private parts of rsa key with zeros. Key is unusable after it.
+func WipeRSAKey(key *rsa.PrivateKey) {
+	if key == nil {
+		return
+	}
+	wipeInt(key.D)
+	for _, prime := range key.Primes {
+		wipeInt(prime)
+	}
+	wipeInt(key.Precomputed.Dp)
+	wipeInt(key.Precomputed.Dq)
+	wipeInt(key.Precomputed.Qinv)
+}
+
+// WipeKey overwrite private parts of rsa or ecdsa key with zeros. Key is unusable after it.
+func WipeKey(key crypto.Signer) {
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		WipeRSAKey(key)
+	case *ecdsa.PrivateKey:
+		wipeInt(key.D)
+	}
+}
+
+func wipeInt(i *big.Int) {
+	if i == nil {
+		return
+	}
+	words := i.Bits()
+	for j := range words {
+		words[j] = 0
+	}
+	i.SetInt64(0)
 }
 
 // NewX509Pair create new X509Pair object
@@ -0,0 +1,99 @@
+package pair
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestX509Pair_Zeroize(t *testing.T) {
+	p := NewX509Pair([]byte("secret"), []byte("cert"), "cn", big.NewInt(1))
+	p.Zeroize()
+	assert.Equal(t, make([]byte, 6), p.KeyPemBytes)
+	assert.Equal(t, []byte("cert"), p.CertPemBytes)
+}
+
+func TestWipeRSAKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.NoError(t, err)
+	WipeRSAKey(key)
+	assert.Equal(t, 0, key.D.Sign())
+	for _, prime := range key.Primes {
+		assert.Equal(t, 0, prime.Sign())
+	}
+	WipeRSAKey(nil)
+}
+
+func TestX509Pair_DecodeKeyError(t *testing.T) {
+	secret := "super secret key material"
+	p := NewX509Pair(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: []byte(secret)}), nil, "cn", big.NewInt(1))
+	_, _, err := p.Decode()
+	assert.Error(t, err)
+	assert.False(t, strings.Contains(err.Error(), secret))
+	p = NewX509Pair([]byte(secret), nil, "cn", big.NewInt(1))
+	_, _, err = p.Decode()
+	assert.Error(t, err)
+	assert.False(t, strings.Contains(err.Error(), secret))
+}
+
+func TestX509Pair_DecodeWipeKeyDer(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 1024)
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	p := NewX509Pair(keyPem, []byte("bad cert"), "cn", big.NewInt(1))
+	_, _, err := p.Decode()
+	assert.Error(t, err)
+	assert.Equal(t, keyPem, p.KeyPemBytes, "pem bytes of pair must be untouched")
+}
+
+func TestX509Pair_DecodeError(t *testing.T) {
+	secret := "super secret cert material"
+	key, _ := rsa.GenerateKey(rand.Reader, 1024)
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	p := NewX509Pair(keyPem, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte(secret)}), "cn", big.NewInt(1))
+	_, _, err := p.Decode()
+	var decodeErr *DecodeError
+	assert.True(t, errors.As(err, &decodeErr))
+	assert.Equal(t, "cert", decodeErr.Part)
+	assert.Equal(t, "cn", decodeErr.CN)
+	assert.False(t, strings.Contains(err.Error(), secret))
+}
+
+func TestX509Pair_String(t *testing.T) {
+	p := NewX509Pair([]byte("secret key"), []byte("secret cert"), "cn", big.NewInt(1))
+	for _, format := range []string{"%v", "%+v", "%#v", "%s"} {
+		got := fmt.Sprintf(format, p)
+		assert.False(t, strings.Contains(got, "secret"), format)
+		assert.True(t, strings.Contains(got, "cn"), format)
+	}
+}
+
+func TestX509Pair_DecodeSigner(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(1)}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.NoError(t, err)
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+	p := NewX509Pair(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer}), certPem, "cn", big.NewInt(1))
+	signer, cert, err := p.DecodeSigner()
+	assert.NoError(t, err)
+	assert.True(t, key.Equal(signer))
+	assert.Equal(t, int64(1), cert.SerialNumber.Int64())
+
+	WipeKey(signer)
+	assert.Equal(t, 0, signer.(*ecdsa.PrivateKey).D.Sign())
+	p.KeyPemBytes = []byte("bad key")
+	_, _, err = p.DecodeSigner()
+	assert.Error(t, err)
+}
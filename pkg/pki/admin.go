@@ -0,0 +1,88 @@
+package pki
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+)
+
+const (
+	RevokePath = "/revoke" // path of RevokeHandler in serve command
+	CertsPath  = "/certs"  // path of CertsHandler in serve command
+)
+
+// RevokeHandler revoke certificate with hex serial from posted form field "serial". Optional "reason" field
+// is openssl reason name like keyCompromise. It doesn`t check caller, wrap it with RequireRole.
+type RevokeHandler struct {
+	pki *PKI
+}
+
+// NewRevokeHandler return http handler revoking certificates
+func NewRevokeHandler(p *PKI) *RevokeHandler {
+	return &RevokeHandler{pki: p}
+}
+
+// ServeHTTP implement http.Handler. Only POST requests are allowed.
+func (h *RevokeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, "POST")
+		return
+	}
+	serial, ok := new(big.Int).SetString(r.FormValue("serial"), 16)
+	if !ok {
+		httpError(w, CodeBadRequest, fmt.Sprintf("bad serial %q", r.FormValue("serial")))
+		return
+	}
+	logRequest(r, func(record *RequestLog) { record.Serial = serial.Text(16) })
+	var opts []RevokeOption
+	if name := r.FormValue("reason"); name != "" {
+		reason, err := ParseRevocationReason(name)
+		if err != nil {
+			httpError(w, CodeBadRequest, err.Error())
+			return
+		}
+		opts = append(opts, Reason(reason))
+	}
+	stored, err := h.pki.certBySerial(serial)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	logRequest(r, func(record *RequestLog) { record.CN = stored.CN })
+	if err := h.pki.RevokeOne(serial, opts...); err != nil {
+		serverError(w, fmt.Errorf("can`t revoke: %w", err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CertsHandler serve info of all stored certificates as json, see List. It doesn`t check caller,
+// wrap it with RequireRole.
+type CertsHandler struct {
+	pki *PKI
+}
+
+// NewCertsHandler return http handler listing certificates
+func NewCertsHandler(p *PKI) *CertsHandler {
+	return &CertsHandler{pki: p}
+}
+
+// ServeHTTP implement http.Handler. Only GET and HEAD requests are allowed.
+func (h *CertsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		methodNotAllowed(w, "GET, HEAD")
+		return
+	}
+	list, err := h.pki.List()
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	content, err := json.Marshal(list)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	writeContent(w, "application/json", content)
+}
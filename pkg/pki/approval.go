@@ -0,0 +1,110 @@
+package pki
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/kemsta/go-easyrsa/pkg/pair"
+)
+
+// PendingRequest is a CSR waiting for approval. Serial is reserved for its certificate on submission.
+type PendingRequest struct {
+	Serial    *big.Int  `json:"serial"`
+	Profile   Profile   `json:"profile"`
+	CSR       []byte    `json:"csr"`
+	Submitted time.Time `json:"submitted"`
+}
+
+// Submit put CSR into approval queue instead of signing it, see WithRequestStore. It returns serial which
+// identifies request in Approve and Deny and which is used for the certificate once request is approved.
+func (p *PKI) Submit(csr []byte, profile Profile) (*big.Int, error) {
+	if p.requests == nil {
+		return nil, errors.New("can`t submit request: no request store")
+	}
+	if _, err := ParseRequest(csr); err != nil {
+		return nil, err
+	}
+	serial, err := p.nextSerial()
+	if err != nil {
+		return nil, fmt.Errorf("can`t reserve serial: %w", err)
+	}
+	content, err := json.Marshal(PendingRequest{Serial: serial, Profile: profile, CSR: csr, Submitted: p.now().UTC()})
+	if err != nil {
+		return nil, fmt.Errorf("can`t encode request: %w", err)
+	}
+	if err := p.requests.Put(requestID(serial), content); err != nil {
+		return nil, fmt.Errorf("can`t store request: %w", err)
+	}
+	return serial, nil
+}
+
+// Pending return requests waiting for approval sorted by serial
+func (p *PKI) Pending() ([]*PendingRequest, error) {
+	if p.requests == nil {
+		return nil, errors.New("can`t get pending requests: no request store")
+	}
+	records, err := p.requests.GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("can`t get pending requests: %w", err)
+	}
+	res := make([]*PendingRequest, 0, len(records))
+	for id, content := range records {
+		req := &PendingRequest{}
+		if err := json.Unmarshal(content, req); err != nil {
+			return nil, fmt.Errorf("can`t decode request %v: %w", id, err)
+		}
+		res = append(res, req)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Serial.Cmp(res[j].Serial) == -1
+	})
+	return res, nil
+}
+
+// Approve sign pending request with serial like SignRequest. Request stays pending if it can`t be signed,
+// e.g. because of policy.
+func (p *PKI) Approve(serial *big.Int, opts ...CertificateOption) (*pair.X509Pair, error) {
+	req, content, err := p.takeRequest(serial)
+	if err != nil {
+		return nil, err
+	}
+	res, err := p.SignRequest(req.CSR, req.Profile, append(opts, reservedSerial(req.Serial))...)
+	if err != nil {
+		if putErr := p.requests.Put(requestID(serial), content); putErr != nil {
+			return nil, fmt.Errorf("%v, request %x is lost: %w", err, serial, putErr)
+		}
+		return nil, err
+	}
+	return res, nil
+}
+
+// Deny drop pending request with serial. Its serial is never used.
+func (p *PKI) Deny(serial *big.Int) error {
+	_, _, err := p.takeRequest(serial)
+	return err
+}
+
+// takeRequest remove pending request with serial from store, so concurrent approvers can`t sign it twice
+func (p *PKI) takeRequest(serial *big.Int) (*PendingRequest, []byte, error) {
+	if p.requests == nil {
+		return nil, nil, errors.New("can`t take request: no request store")
+	}
+	content, err := p.requests.Take(requestID(serial))
+	if err != nil {
+		return nil, nil, fmt.Errorf("there is no pending request %x: %w", serial, err)
+	}
+	req := &PendingRequest{}
+	if err := json.Unmarshal(content, req); err != nil {
+		return nil, nil, fmt.Errorf("can`t decode request %x: %w", serial, err)
+	}
+	return req, content, nil
+}
+
+// requestID return store id of request with serial
+func requestID(serial *big.Int) string {
+	return fmt.Sprintf("%x", serial)
+}
@@ -0,0 +1,84 @@
+package pki
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPKI_Approve(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	csr := func(cn string) []byte {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		assert.NoError(t, err)
+		res, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+			Subject: pkix.Name{CommonName: cn},
+		}, key)
+		assert.NoError(t, err)
+		return res
+	}
+	_, err := pki.Submit(csr("device"), ProfileClient)
+	assert.Error(t, err, "no request store")
+	WithRequestDir(t.TempDir())(pki)
+	_, err = pki.NewCa()
+	assert.NoError(t, err)
+	_, err = pki.Submit([]byte("garbage"), ProfileClient)
+	assert.Error(t, err)
+
+	approved, err := pki.Submit(csr("device"), ProfileClient)
+	assert.NoError(t, err)
+	denied, err := pki.Submit(csr("other"), ProfileServer)
+	assert.NoError(t, err)
+	pending, err := pki.Pending()
+	assert.NoError(t, err)
+	if assert.Len(t, pending, 2) {
+		assert.Equal(t, approved, pending[0].Serial)
+		assert.Equal(t, ProfileServer, pending[1].Profile)
+	}
+	_, err = pki.Storage.GetLastByCn("device")
+	assert.Error(t, err, "pending request isn`t signed")
+
+	cert, err := pki.Approve(approved)
+	assert.NoError(t, err)
+	assert.Equal(t, approved, cert.Serial)
+	_, err = pki.Approve(approved)
+	assert.Error(t, err, "request is approved once")
+	assert.NoError(t, pki.Deny(denied))
+	assert.Error(t, pki.Deny(denied))
+	_, err = pki.Approve(big.NewInt(100))
+	assert.Error(t, err)
+	pending, err = pki.Pending()
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+
+	next, err := pki.NewCert("next", Client())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, next.Serial.Cmp(denied), "reserved serials aren`t reused")
+}
+
+func TestPKI_Approve_Failed(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	WithRequestDir(t.TempDir())(pki)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: "device"},
+	}, key)
+	assert.NoError(t, err)
+	serial, err := pki.Submit(csr, ProfileClient)
+	assert.NoError(t, err)
+
+	_, err = pki.Approve(serial)
+	assert.Error(t, err, "there is no ca")
+	pending, err := pki.Pending()
+	assert.NoError(t, err)
+	assert.Len(t, pending, 1, "request stays pending")
+}
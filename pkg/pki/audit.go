@@ -0,0 +1,42 @@
+package pki
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Audit actions
+const (
+	AuditClampValidity  = "clamp-validity"  // NotAfter of requested certificate was reduced by policy
+	AuditRejectValidity = "reject-validity" // requested certificate was rejected by policy
+	AuditRejectDNS      = "reject-dns"      // dns name of requested server certificate was rejected by DNSGuard
+)
+
+// AuditRecord is a PKI decision written into audit log as a json line
+type AuditRecord struct {
+	Time        time.Time `json:"time"`
+	Action      string    `json:"action"`
+	Name        string    `json:"name"`                  // pair name in storage
+	Profile     Profile   `json:"profile,omitempty"`     // certificate profile
+	Detail      string    `json:"detail,omitempty"`      // human readable decision details
+	Fingerprint string    `json:"fingerprint,omitempty"` // fingerprint of issued certificate, empty for rejections
+}
+
+// audit append record to audit log if there is one
+func (p *PKI) audit(record AuditRecord) error {
+	if p.auditLog == nil {
+		return nil
+	}
+	if record.Time.IsZero() {
+		record.Time = time.Now().UTC()
+	}
+	content, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("can`t encode audit record: %w", err)
+	}
+	if err := p.auditLog.Append(content); err != nil {
+		return fmt.Errorf("can`t write audit record: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package pki
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Role is a set of server operations which caller is allowed to do
+type Role string
+
+const (
+	RoleIssuer  Role = "issuer"  // sign certificates, e.g. enroll without single-use token
+	RoleRevoker Role = "revoker" // revoke certificates
+	RoleAuditor Role = "auditor" // list certificates
+)
+
+// ParseRole return role by name, error if the role is unknown
+func ParseRole(name string) (Role, error) {
+	switch role := Role(name); role {
+	case RoleIssuer, RoleRevoker, RoleAuditor:
+		return role, nil
+	}
+	return "", fmt.Errorf("unknown role %q, expected %v, %v or %v", name, RoleIssuer, RoleRevoker, RoleAuditor)
+}
+
+// Authenticator return roles of http request caller, nil if caller is unknown
+type Authenticator func(r *http.Request) []Role
+
+type rolesKey struct{}
+
+// APITokens authenticate callers by bearer tokens mapped to roles
+func APITokens(tokens map[string][]Role) Authenticator {
+	return func(r *http.Request) []Role {
+		token, ok := bearerToken(r)
+		if !ok {
+			return nil
+		}
+		for known, roles := range tokens {
+			if subtle.ConstantTimeCompare([]byte(known), []byte(token)) == 1 {
+				return roles
+			}
+		}
+		return nil
+	}
+}
+
+// ParseAPITokens parse lines of "TOKEN ROLE[,ROLE...]" for APITokens. Empty lines and lines starting with # are skipped.
+func ParseAPITokens(content []byte) (map[string][]Role, error) {
+	res := map[string][]Role{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("bad api token at line %v, expected TOKEN ROLE[,ROLE...]", line)
+		}
+		for _, name := range strings.Split(fields[1], ",") {
+			role, err := ParseRole(name)
+			if err != nil {
+				return nil, fmt.Errorf("bad api token at line %v: %w", line, err)
+			}
+			res[fields[0]] = append(res[fields[0]], role)
+		}
+	}
+	return res, scanner.Err()
+}
+
+// ClientCertRoles authenticate callers by organizational units of tls client certificates issued by PKI.
+// Revoked certificates have no roles. Server must verify client certificates, e.g. with CertPool as ClientCAs.
+func (p *PKI) ClientCertRoles(byOU map[string][]Role) Authenticator {
+	return func(r *http.Request) []Role {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			return nil
+		}
+		leaf := r.TLS.VerifiedChains[0][0]
+		if !p.issuedHere(leaf) || p.IsRevoked(leaf.SerialNumber) {
+			return nil
+		}
+		var res []Role
+		for _, unit := range leaf.Subject.OrganizationalUnit {
+			res = append(res, byOU[unit]...)
+		}
+		return res
+	}
+}
+
+// AnyAuthenticator return roles of caller from all authenticators
+func AnyAuthenticator(auths ...Authenticator) Authenticator {
+	return func(r *http.Request) []Role {
+		var res []Role
+		for _, auth := range auths {
+			res = append(res, auth(r)...)
+		}
+		return res
+	}
+}
+
+// WithRoles attach roles of caller from auth to request context for RequireRole and EnrollHandler.
+// Requests aren`t rejected here, nil auth passes them as is.
+func WithRoles(auth Authenticator, next http.Handler) http.Handler {
+	if auth == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if roles := auth(r); len(roles) > 0 {
+			r = r.WithContext(context.WithValue(r.Context(), rolesKey{}, roles))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireRole pass requests of callers with role to next. Unknown callers get 401, callers without role get 403.
+func RequireRole(role Role, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		roles, _ := r.Context().Value(rolesKey{}).([]Role)
+		if len(roles) == 0 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			httpError(w, CodeUnauthorized, http.StatusText(http.StatusUnauthorized))
+			return
+		}
+		if !HasRole(r.Context(), role) {
+			httpError(w, CodeForbidden, fmt.Sprintf("%v role is required", role))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// HasRole return true if caller of request context has role
+func HasRole(ctx context.Context, role Role) bool {
+	roles, _ := ctx.Value(rolesKey{}).([]Role)
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// bearerToken return token of bearer authorization header
+func bearerToken(r *http.Request) (string, bool) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return token, token != "" && token != r.Header.Get("Authorization")
+}
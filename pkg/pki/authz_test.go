@@ -0,0 +1,148 @@
+package pki
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAPITokens(t *testing.T) {
+	tokens, err := ParseAPITokens([]byte("# tokens\n\nsecret1 issuer,auditor\nsecret2 revoker\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]Role{
+		"secret1": {RoleIssuer, RoleAuditor},
+		"secret2": {RoleRevoker},
+	}, tokens)
+	_, err = ParseAPITokens([]byte("secret1\n"))
+	assert.Error(t, err)
+	_, err = ParseAPITokens([]byte("secret1 issuer,admin\n"))
+	assert.Error(t, err)
+}
+
+func TestRequireRole(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+	user, err := pki.NewClientCert("user")
+	assert.NoError(t, err)
+	mux := http.NewServeMux()
+	mux.Handle(RevokePath, RequireRole(RoleRevoker, NewRevokeHandler(pki)))
+	mux.Handle(CertsPath, RequireRole(RoleAuditor, NewCertsHandler(pki)))
+	handler := WithRoles(APITokens(map[string][]Role{
+		"revoker": {RoleRevoker},
+		"auditor": {RoleAuditor},
+	}), mux)
+	do := func(method, path, token string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+	revoke := url.Values{"serial": {user.Serial.Text(16)}, "reason": {"keyCompromise"}}
+
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodPost, RevokePath, "", revoke).Code)
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodPost, RevokePath, "unknown", revoke).Code)
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, RevokePath, "auditor", revoke).Code)
+	assert.False(t, pki.IsRevoked(user.Serial))
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, RevokePath, "revoker", url.Values{"serial": {"x"}}).Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPost, RevokePath, "revoker", url.Values{"serial": {"ff"}}).Code)
+	assert.Equal(t, http.StatusNoContent, do(http.MethodPost, RevokePath, "revoker", revoke).Code)
+	assert.True(t, pki.IsRevoked(user.Serial))
+
+	assert.Equal(t, http.StatusForbidden, do(http.MethodGet, CertsPath, "revoker", nil).Code)
+	w := do(http.MethodGet, CertsPath, "auditor", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var list []CertInfo
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Len(t, list, 2)
+	assert.Equal(t, CertStatusRevoked, list[1].Status)
+}
+
+func TestEnrollHandler_IssuerRole(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	WithTokenDir(t.TempDir())(pki)
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+	csr, _, err := pki.NewRequest("device", 0)
+	assert.NoError(t, err)
+	enroll, err := NewEnrollHandler(pki, ProfileClient)
+	assert.NoError(t, err)
+	handler := WithRoles(APITokens(map[string][]Role{"automation": {RoleIssuer}}), enroll)
+	post := func(token string) int {
+		req := httptest.NewRequest(http.MethodPost, EnrollPath, bytes.NewReader(csr))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusUnauthorized, post("unknown"))
+	assert.Equal(t, http.StatusOK, post("automation"))
+}
+
+func TestPKI_ClientCertRoles(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+	server, err := pki.NewServerCert("server", IPAddresses([]net.IP{net.ParseIP("127.0.0.1")}))
+	assert.NoError(t, err)
+	ou := func(unit string) Option {
+		return func(cert *x509.Certificate) {
+			cert.Subject = pkix.Name{CommonName: cert.Subject.CommonName, OrganizationalUnit: []string{unit}}
+		}
+	}
+	ops, err := pki.NewClientCert("ops", ou("ops"))
+	assert.NoError(t, err)
+	dev, err := pki.NewClientCert("dev", ou("dev"))
+	assert.NoError(t, err)
+
+	serverCert, err := tls.X509KeyPair(server.CertPemBytes, server.KeyPemBytes)
+	assert.NoError(t, err)
+	pool, err := pki.CertPool()
+	assert.NoError(t, err)
+	srv := httptest.NewUnstartedServer(WithRoles(pki.ClientCertRoles(map[string][]Role{"ops": {RoleAuditor}}),
+		RequireRole(RoleAuditor, NewCertsHandler(pki))))
+	srv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.VerifyClientCertIfGiven,
+	}
+	srv.StartTLS()
+	defer srv.Close()
+	get := func(certPEM, keyPEM []byte) int {
+		tlsConfig := &tls.Config{RootCAs: pool}
+		if certPEM != nil {
+			clientCert, err := tls.X509KeyPair(certPEM, keyPEM)
+			assert.NoError(t, err)
+			tlsConfig.Certificates = []tls.Certificate{clientCert}
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+		resp, err := client.Get(srv.URL + CertsPath)
+		if !assert.NoError(t, err) {
+			return 0
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	assert.Equal(t, http.StatusUnauthorized, get(nil, nil))
+	assert.Equal(t, http.StatusUnauthorized, get(dev.CertPemBytes, dev.KeyPemBytes))
+	assert.Equal(t, http.StatusOK, get(ops.CertPemBytes, ops.KeyPemBytes))
+	assert.NoError(t, pki.RevokeOne(ops.Serial))
+	assert.Equal(t, http.StatusUnauthorized, get(ops.CertPemBytes, ops.KeyPemBytes))
+}
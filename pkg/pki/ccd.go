@@ -0,0 +1,184 @@
+package pki
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kemsta/go-easyrsa/internal/fsStorage"
+)
+
+// ClientConfig is OpenVPN client-config-dir settings of client
+type ClientConfig struct {
+	StaticIP   string   `json:"static_ip,omitempty"`  // ifconfig-push local address
+	Netmask    string   `json:"netmask,omitempty"`    // ifconfig-push netmask or remote endpoint
+	Routes     []string `json:"routes,omitempty"`     // iroute networks behind client as "network netmask"
+	Push       []string `json:"push,omitempty"`       // options pushed to client, e.g. "route 10.0.0.0 255.0.0.0"
+	Directives []string `json:"directives,omitempty"` // other ccd lines as is
+}
+
+// encode client config in ccd file format
+func (c *ClientConfig) encode() []byte {
+	var buf bytes.Buffer
+	if c.StaticIP != "" {
+		fmt.Fprintf(&buf, "ifconfig-push %s %s\n", c.StaticIP, c.Netmask)
+	}
+	for _, route := range c.Routes {
+		fmt.Fprintf(&buf, "iroute %s\n", route)
+	}
+	for _, push := range c.Push {
+		fmt.Fprintf(&buf, "push %s\n", quoteOpenVPN(push))
+	}
+	for _, directive := range c.Directives {
+		fmt.Fprintf(&buf, "%s\n", directive)
+	}
+	return buf.Bytes()
+}
+
+// openVPNEscaper escape characters which are special in double quoted string of OpenVPN config
+var openVPNEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// openVPNUnescaper reverse openVPNEscaper
+var openVPNUnescaper = strings.NewReplacer(`\\`, `\`, `\"`, `"`)
+
+// quoteOpenVPN return s in double quotes with backslash escaping like OpenVPN config parser expects
+func quoteOpenVPN(s string) string {
+	return `"` + openVPNEscaper.Replace(s) + `"`
+}
+
+// unquoteOpenVPN return value of double or single quoted OpenVPN argument, not quoted one is returned as is
+func unquoteOpenVPN(s string) string {
+	switch {
+	case len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"':
+		return openVPNUnescaper.Replace(s[1 : len(s)-1])
+	case len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'':
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// parseClientConfig parse ccd file content. "disable" is skipped since it`s derived from revocation.
+func parseClientConfig(content []byte) (*ClientConfig, error) {
+	res := &ClientConfig{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") || line == "disable" {
+			continue
+		}
+		directive, args, _ := strings.Cut(line, " ")
+		args = strings.TrimSpace(args)
+		switch directive {
+		case "ifconfig-push":
+			fields := strings.Fields(args)
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("bad ifconfig-push %q", line)
+			}
+			res.StaticIP, res.Netmask = fields[0], fields[1]
+		case "iroute":
+			res.Routes = append(res.Routes, strings.Join(strings.Fields(args), " "))
+		case "push":
+			res.Push = append(res.Push, unquoteOpenVPN(args))
+		default:
+			res.Directives = append(res.Directives, line)
+		}
+	}
+	return res, scanner.Err()
+}
+
+// ExportCCD write OpenVPN client-config-dir into dir. Files are named after identities in storage like
+// ImportCCD expects, so pairs should be stored under their common names for OpenVPN to match them.
+// Identities without valid certificates are disabled. It returns names of written files.
+func (p *PKI) ExportCCD(dir string) ([]string, error) {
+	pairs, err := p.Storage.GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("can`t get pairs: %w", err)
+	}
+	now := time.Now()
+	revoked := p.revokedSerials(true)
+	valid := map[string]bool{}
+	identities := map[string]bool{}
+	for _, certPair := range pairs {
+		cert, err := certPair.DecodeCert()
+		if err != nil {
+			return nil, err
+		}
+		if cert.IsCA || cert.Subject.CommonName == "" {
+			continue
+		}
+		identities[certPair.CN] = true
+		if !now.After(cert.NotAfter) && !revoked[certPair.Serial.String()] {
+			valid[certPair.CN] = true
+		}
+	}
+	names := make([]string, 0, len(identities))
+	for name := range identities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("can`t create ccd dir %v: %w", dir, err)
+	}
+	ccd := fsStorage.NewDirPublisher(dir)
+	res := make([]string, 0, len(names))
+	for _, name := range names {
+		meta, err := p.Metadata(name)
+		if err != nil && err != errNoMetadataStore {
+			return res, err
+		}
+		content := []byte{}
+		if meta.OpenVPN != nil {
+			content = meta.OpenVPN.encode()
+		}
+		if !valid[name] {
+			content = append([]byte("disable\n"), content...)
+		}
+		if err := ccd.Publish(name, content); err != nil {
+			return res, fmt.Errorf("can`t write ccd for %v: %w", name, err)
+		}
+		res = append(res, name)
+	}
+	return res, nil
+}
+
+// ImportCCD read OpenVPN client-config-dir into metadata of identities stored with file names.
+// Files without identities are skipped. It returns names of imported identities.
+func (p *PKI) ImportCCD(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("can`t read ccd dir %v: %w", dir, err)
+	}
+	res := make([]string, 0)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		if _, err := p.Storage.GetLastByCn(name); err != nil {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return res, fmt.Errorf("can`t read ccd for %v: %w", name, err)
+		}
+		config, err := parseClientConfig(content)
+		if err != nil {
+			return res, fmt.Errorf("can`t parse ccd for %v: %w", name, err)
+		}
+		meta, err := p.Metadata(name)
+		if err != nil {
+			return res, err
+		}
+		meta.OpenVPN = config
+		if err := p.SetMetadata(name, meta); err != nil {
+			return res, err
+		}
+		res = append(res, name)
+	}
+	return res, nil
+}
@@ -0,0 +1,59 @@
+package pki
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPKI_ExportCCD(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	ccdDir := filepath.Join(testData, "ccd")
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+	_, err = pki.NewCert("alice", Client())
+	assert.NoError(t, err)
+	bob, err := pki.NewCert("bob", Client())
+	assert.NoError(t, err)
+	assert.NoError(t, pki.RevokeOne(bob.Serial))
+	_, err = pki.NewCert("carol-laptop", Client(), CN("carol"))
+	assert.NoError(t, err)
+	assert.NoError(t, pki.SetMetadata("alice", Metadata{OpenVPN: &ClientConfig{
+		StaticIP: "10.8.0.10",
+		Netmask:  "255.255.255.0",
+		Routes:   []string{"192.168.1.0 255.255.255.0"},
+		Push:     []string{"route 10.0.0.0 255.0.0.0", `setenv-safe NAME "C:\Program Files"`},
+	}}))
+
+	got, err := pki.ExportCCD(ccdDir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"alice", "bob", "carol-laptop"}, got)
+	assert.NoFileExists(t, filepath.Join(ccdDir, "carol"))
+	alice, err := os.ReadFile(filepath.Join(ccdDir, "alice"))
+	assert.NoError(t, err)
+	assert.Equal(t, "ifconfig-push 10.8.0.10 255.255.255.0\n"+
+		"iroute 192.168.1.0 255.255.255.0\n"+
+		"push \"route 10.0.0.0 255.0.0.0\"\n"+
+		`push "setenv-safe NAME \"C:\\Program Files\""`+"\n", string(alice))
+	entries, err := os.ReadDir(ccdDir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3, "temp files of atomic writes are removed")
+	bobCCD, err := os.ReadFile(filepath.Join(ccdDir, "bob"))
+	assert.NoError(t, err)
+	assert.Equal(t, "disable\n", string(bobCCD))
+
+	assert.NoError(t, os.WriteFile(filepath.Join(ccdDir, "bob"), []byte("# comment\nifconfig-push 10.8.0.11 255.255.255.0\ncomp-lzo no\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(ccdDir, "unknown"), []byte("ifconfig-push 10.8.0.12 255.255.255.0\n"), 0644))
+	imported, err := pki.ImportCCD(ccdDir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"alice", "bob", "carol-laptop"}, imported)
+	meta, err := pki.Metadata("bob")
+	assert.NoError(t, err)
+	assert.Equal(t, &ClientConfig{StaticIP: "10.8.0.11", Netmask: "255.255.255.0", Directives: []string{"comp-lzo no"}}, meta.OpenVPN)
+	meta, err = pki.Metadata("alice")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"route 10.0.0.0 255.0.0.0", `setenv-safe NAME "C:\Program Files"`}, meta.OpenVPN.Push)
+}
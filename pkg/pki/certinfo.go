@@ -0,0 +1,108 @@
+package pki
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/kemsta/go-easyrsa/pkg/pair"
+)
+
+// CertInfo is a decoded summary of stored certificate, so listings don`t need to decode pairs themselves
+type CertInfo struct {
+	Name           string     `json:"name"`                     // pair name in storage
+	CommonName     string     `json:"cn"`                       // subject common name
+	Serial         *big.Int   `json:"serial"`                   // certificate serial
+	Status         CertStatus `json:"status"`                   // revocation and expiry status
+	CA             bool       `json:"ca"`                       // certificate is a CA
+	Profile        Profile    `json:"profile,omitempty"`        // profile inferred from extended key usages
+	NotBefore      time.Time  `json:"notBefore"`                // start of validity
+	NotAfter       time.Time  `json:"notAfter"`                 // end of validity
+	DNSNames       []string   `json:"dnsNames,omitempty"`       // dns subject alternative names
+	IPAddresses    []net.IP   `json:"ipAddresses,omitempty"`    // ip subject alternative names
+	EmailAddresses []string   `json:"emailAddresses,omitempty"` // email subject alternative names
+	Fingerprint    string     `json:"fingerprint"`              // hex encoded sha256 of DER certificate
+	Issuer         string     `json:"issuer"`                   // issuer DN
+	Labels         Labels     `json:"labels,omitempty"`         // labels of identity, see Labeled
+}
+
+// Named select certificates stored with name
+func Named(name string) CertFilter {
+	return func(pair *pair.X509Pair, _ *x509.Certificate) bool {
+		return pair.CN == name
+	}
+}
+
+// List return info of all stored certificates sorted by serial
+func (p *PKI) List() ([]CertInfo, error) {
+	return p.Find(nil)
+}
+
+// Find return info of stored certificates matching filter sorted by serial. All of them are returned if filter is nil.
+func (p *PKI) Find(filter CertFilter) ([]CertInfo, error) {
+	pairs, err := p.allCerts()
+	if err != nil {
+		return nil, fmt.Errorf("can`t get pairs: %w", err)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].Serial.Cmp(pairs[j].Serial) == -1
+	})
+	revoked := map[string]pkix.RevokedCertificate{}
+	if list, err := p.GetCRL(); err == nil {
+		for _, entry := range list.TBSCertList.RevokedCertificates {
+			revoked[entry.SerialNumber.String()] = entry
+		}
+	}
+	_, withLabels := p.Storage.(MetadataStore)
+	labels := map[string]Labels{}
+	now := time.Now()
+	res := make([]CertInfo, 0, len(pairs))
+	for _, certPair := range pairs {
+		cert, err := certPair.DecodeCert()
+		if err != nil {
+			return nil, fmt.Errorf("can`t decode %v cert: %w", certPair.CN, err)
+		}
+		if filter != nil && !filter(certPair, cert) {
+			continue
+		}
+		info := CertInfo{
+			Name:           certPair.CN,
+			CommonName:     cert.Subject.CommonName,
+			Serial:         certPair.Serial,
+			Status:         CertStatusValid,
+			CA:             cert.IsCA,
+			Profile:        certProfile(Identity{}, cert),
+			NotBefore:      cert.NotBefore,
+			NotAfter:       cert.NotAfter,
+			DNSNames:       cert.DNSNames,
+			IPAddresses:    cert.IPAddresses,
+			EmailAddresses: cert.EmailAddresses,
+			Fingerprint:    certFingerprint(cert),
+			Issuer:         cert.Issuer.String(),
+		}
+		if withLabels {
+			if _, ok := labels[certPair.CN]; !ok {
+				if labels[certPair.CN], err = p.Labels(certPair.CN); err != nil {
+					return nil, fmt.Errorf("can`t get labels of %v: %w", certPair.CN, err)
+				}
+			}
+			if len(labels[certPair.CN]) > 0 {
+				info.Labels = labels[certPair.CN]
+			}
+		}
+		if entry, ok := revoked[certPair.Serial.String()]; ok {
+			info.Status = CertStatusRevoked
+			if revocationReason(entry) == ReasonCertificateHold {
+				info.Status = CertStatusSuspended
+			}
+		} else if now.After(cert.NotAfter) {
+			info.Status = CertStatusExpired
+		}
+		res = append(res, info)
+	}
+	return res, nil
+}
@@ -0,0 +1,57 @@
+package pki
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPKI_List(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	ca, err := pki.NewCa()
+	assert.NoError(t, err)
+	server, err := pki.NewServerCert("server", DNSNames([]string{"server.example.com"}))
+	assert.NoError(t, err)
+	held, err := pki.NewClientCert("held")
+	assert.NoError(t, err)
+	revoked, err := pki.NewClientCert("revoked")
+	assert.NoError(t, err)
+	_, err = pki.NewClientCert("expired", NotAfter(time.Now().Add(-time.Hour)))
+	assert.NoError(t, err)
+	assert.NoError(t, pki.Hold(held.Serial))
+	assert.NoError(t, pki.RevokeOne(revoked.Serial))
+
+	list, err := pki.List()
+	assert.NoError(t, err)
+	assert.Len(t, list, 5)
+	statuses := make([]CertStatus, 0, len(list))
+	for _, info := range list {
+		statuses = append(statuses, info.Status)
+	}
+	assert.Equal(t, []CertStatus{CertStatusValid, CertStatusValid, CertStatusSuspended, CertStatusRevoked,
+		CertStatusExpired}, statuses)
+	assert.Equal(t, ca.Serial, list[0].Serial)
+	assert.True(t, list[0].CA)
+	assert.Equal(t, "server", list[1].Name)
+	assert.Equal(t, []string{"server.example.com"}, list[1].DNSNames)
+	assert.Len(t, list[1].Fingerprint, 64)
+	assert.Equal(t, list[0].Issuer, list[1].Issuer)
+	serverCert, err := server.DecodeCert()
+	assert.NoError(t, err)
+	assert.Equal(t, serverCert.NotAfter, list[1].NotAfter)
+
+	found, err := pki.Find(Named("server"))
+	assert.NoError(t, err)
+	assert.Equal(t, list[1:2], found)
+	found, err = pki.Find(Named("missing"))
+	assert.NoError(t, err)
+	assert.Empty(t, found)
+
+	content, err := json.Marshal(list[2])
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), `"status":"suspended"`)
+	assert.Contains(t, string(content), `"name":"held"`)
+}
@@ -0,0 +1,23 @@
+package pki
+
+import (
+	"math/big"
+
+	"github.com/kemsta/go-easyrsa/pkg/pair"
+)
+
+// certBySerial return stored pair with serial. Key isn`t read if storage is a CertReader.
+func (p *PKI) certBySerial(serial *big.Int) (*pair.X509Pair, error) {
+	if reader, ok := p.Storage.(CertReader); ok {
+		return reader.GetCertOnlyBySerial(serial)
+	}
+	return p.Storage.GetBySerial(serial)
+}
+
+// allCerts return all stored pairs. Keys aren`t read if storage is a CertReader.
+func (p *PKI) allCerts() ([]*pair.X509Pair, error) {
+	if reader, ok := p.Storage.(CertReader); ok {
+		return reader.GetAllCertOnly()
+	}
+	return p.Storage.GetAll()
+}
@@ -0,0 +1,40 @@
+package pki
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPKI_ReportsWithoutKeys(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	ca, err := pki.NewCa()
+	assert.NoError(t, err)
+	client, err := pki.NewCert("client", Client())
+	assert.NoError(t, err)
+	assert.NoError(t, pki.RevokeOne(client.Serial))
+	keys, err := filepath.Glob(filepath.Join(testData, "*", "*.key"))
+	assert.NoError(t, err)
+	assert.Len(t, keys, 2)
+	for _, key := range keys {
+		assert.NoError(t, os.Remove(key))
+	}
+
+	status, err := pki.Status(client.Serial)
+	assert.NoError(t, err)
+	assert.Equal(t, CertStatusRevoked, status)
+	list, err := pki.List()
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+	index, err := pki.Index()
+	assert.NoError(t, err)
+	assert.Len(t, index.Entries, 2)
+	bundle, err := pki.GetTrustBundle()
+	assert.NoError(t, err)
+	assert.Equal(t, ca.CertPemBytes, bundle)
+	_, err = pki.Verify(client.CertPemBytes)
+	assert.Error(t, err)
+}
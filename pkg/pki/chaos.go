@@ -0,0 +1,266 @@
+package pki
+
+import (
+	"context"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/kemsta/go-easyrsa/pkg/pair"
+)
+
+// ErrInjected is returned by chaos storages instead of result of operation chosen to fail
+var ErrInjected = errors.New("injected failure")
+
+// ChaosOp is a storage operation which faults can be injected into
+type ChaosOp string
+
+// Operations of chaos storages
+const (
+	ChaosPut    ChaosOp = "put"    // KeyStorage.Put and CRLHolder.Put
+	ChaosGet    ChaosOp = "get"    // all KeyStorage and CertReader reads and CRLHolder.Get
+	ChaosDelete ChaosOp = "delete" // KeyStorage.DeleteByCn and DeleteBySerial
+	ChaosLock   ChaosOp = "lock"   // Locker.Lock
+)
+
+// Faults describe failures injected by chaos storages. Rates are probabilities from 0 to 1.
+type Faults struct {
+	ErrorRate        float64       // operation fails with ErrInjected without reaching the storage
+	PartialWriteRate float64       // Put writes truncated content and fails with ErrInjected
+	LockTimeoutRate  float64       // Lock fails after LockDelay with error matching ErrInjected and context.DeadlineExceeded
+	LockDelay        time.Duration // wait before injected lock timeout
+	Ops              []ChaosOp     // operations faults are injected into, all of them if empty
+	Seed             int64         // seed of fault decisions, the same seed and call sequence fail the same way
+}
+
+// lockTimeout is injected lock timeout, it looks like timeout of fs storage lock
+type lockTimeout struct {
+	wait time.Duration
+}
+
+func (e lockTimeout) Error() string {
+	return fmt.Sprintf("injected lock timeout, waited %v", e.wait)
+}
+
+func (e lockTimeout) Is(target error) bool {
+	return target == ErrInjected || target == context.DeadlineExceeded || target == ErrLocked
+}
+
+// chaos decide which operations fail
+type chaos struct {
+	faults   Faults
+	mu       sync.Mutex
+	rand     *rand.Rand
+	injected int
+}
+
+func newChaos(faults Faults) *chaos {
+	return &chaos{faults: faults, rand: rand.New(rand.NewSource(faults.Seed))}
+}
+
+// roll return true if fault with rate is injected into op
+func (c *chaos) roll(op ChaosOp, rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	if len(c.faults.Ops) > 0 {
+		found := false
+		for _, o := range c.faults.Ops {
+			found = found || o == op
+		}
+		if !found {
+			return false
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.rand.Float64() >= rate {
+		return false
+	}
+	c.injected++
+	return true
+}
+
+// fail return ErrInjected if error is injected into op
+func (c *chaos) fail(op ChaosOp) error {
+	if c.roll(op, c.faults.ErrorRate) {
+		return fmt.Errorf("can`t %v: %w", op, ErrInjected)
+	}
+	return nil
+}
+
+// Injected return number of faults injected so far
+func (c *chaos) Injected() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.injected
+}
+
+// truncate return first half of content, like a write interrupted by crash or full disk
+func truncate(content []byte) []byte {
+	return append([]byte(nil), content[:len(content)/2]...)
+}
+
+// ChaosKeyStorage inject failures into operations of wrapped storage.
+// It lets applications test how they behave when the PKI backend misbehaves:
+//
+//	storage := NewChaosKeyStorage(fsStorage.NewDirKeyStorage(dir), Faults{ErrorRate: 0.1, Ops: []ChaosOp{ChaosPut}})
+type ChaosKeyStorage struct {
+	*chaos
+	Storage KeyStorage
+}
+
+// NewChaosKeyStorage ChaosKeyStorage "constructor"
+func NewChaosKeyStorage(storage KeyStorage, faults Faults) *ChaosKeyStorage {
+	return &ChaosKeyStorage{chaos: newChaos(faults), Storage: storage}
+}
+
+// Put pair to storage, partially written pair has halves of certificate and key
+func (s *ChaosKeyStorage) Put(p *pair.X509Pair) error {
+	if err := s.fail(ChaosPut); err != nil {
+		return err
+	}
+	if s.roll(ChaosPut, s.faults.PartialWriteRate) {
+		partial := *p
+		partial.CertPemBytes = truncate(p.CertPemBytes)
+		partial.KeyPemBytes = truncate(p.KeyPemBytes)
+		if err := s.Storage.Put(&partial); err != nil {
+			return err
+		}
+		return fmt.Errorf("can`t put %v with serial %v completely: %w", p.CN, p.Serial, ErrInjected)
+	}
+	return s.Storage.Put(p)
+}
+
+// GetByCN return all pairs with cn from storage
+func (s *ChaosKeyStorage) GetByCN(cn string) ([]*pair.X509Pair, error) {
+	if err := s.fail(ChaosGet); err != nil {
+		return nil, err
+	}
+	return s.Storage.GetByCN(cn)
+}
+
+// GetLastByCn return last pair with cn from storage
+func (s *ChaosKeyStorage) GetLastByCn(cn string) (*pair.X509Pair, error) {
+	if err := s.fail(ChaosGet); err != nil {
+		return nil, err
+	}
+	return s.Storage.GetLastByCn(cn)
+}
+
+// GetBySerial return pair with serial from storage
+func (s *ChaosKeyStorage) GetBySerial(serial *big.Int) (*pair.X509Pair, error) {
+	if err := s.fail(ChaosGet); err != nil {
+		return nil, err
+	}
+	return s.Storage.GetBySerial(serial)
+}
+
+// DeleteByCn delete pairs with cn from storage
+func (s *ChaosKeyStorage) DeleteByCn(cn string) error {
+	if err := s.fail(ChaosDelete); err != nil {
+		return err
+	}
+	return s.Storage.DeleteByCn(cn)
+}
+
+// DeleteBySerial delete pair with serial from storage
+func (s *ChaosKeyStorage) DeleteBySerial(serial *big.Int) error {
+	if err := s.fail(ChaosDelete); err != nil {
+		return err
+	}
+	return s.Storage.DeleteBySerial(serial)
+}
+
+// GetAll return all pairs from storage
+func (s *ChaosKeyStorage) GetAll() ([]*pair.X509Pair, error) {
+	if err := s.fail(ChaosGet); err != nil {
+		return nil, err
+	}
+	return s.Storage.GetAll()
+}
+
+// GetCertOnly return last certificate with cn from storage, without key if it`s a CertReader
+func (s *ChaosKeyStorage) GetCertOnly(cn string) (*pair.X509Pair, error) {
+	if err := s.fail(ChaosGet); err != nil {
+		return nil, err
+	}
+	if reader, ok := s.Storage.(CertReader); ok {
+		return reader.GetCertOnly(cn)
+	}
+	return s.Storage.GetLastByCn(cn)
+}
+
+// GetCertOnlyBySerial return certificate with serial from storage, without key if it`s a CertReader
+func (s *ChaosKeyStorage) GetCertOnlyBySerial(serial *big.Int) (*pair.X509Pair, error) {
+	if err := s.fail(ChaosGet); err != nil {
+		return nil, err
+	}
+	if reader, ok := s.Storage.(CertReader); ok {
+		return reader.GetCertOnlyBySerial(serial)
+	}
+	return s.Storage.GetBySerial(serial)
+}
+
+// GetAllCertOnly return all certificates from storage, without keys if it`s a CertReader
+func (s *ChaosKeyStorage) GetAllCertOnly() ([]*pair.X509Pair, error) {
+	if err := s.fail(ChaosGet); err != nil {
+		return nil, err
+	}
+	if reader, ok := s.Storage.(CertReader); ok {
+		return reader.GetAllCertOnly()
+	}
+	return s.Storage.GetAll()
+}
+
+// Lock operation with name in storage if it`s a Locker. Injected timeout fails after LockDelay.
+func (s *ChaosKeyStorage) Lock(name string) (unlock func() error, err error) {
+	if err := s.fail(ChaosLock); err != nil {
+		return nil, err
+	}
+	if s.roll(ChaosLock, s.faults.LockTimeoutRate) {
+		time.Sleep(s.faults.LockDelay)
+		return nil, fmt.Errorf("can`t lock %v: %w", name, lockTimeout{wait: s.faults.LockDelay})
+	}
+	if locker, ok := s.Storage.(Locker); ok {
+		return locker.Lock(name)
+	}
+	return func() error { return nil }, nil
+}
+
+// ChaosCRLHolder inject failures into operations of wrapped crl holder
+type ChaosCRLHolder struct {
+	*chaos
+	Holder CRLHolder
+}
+
+// NewChaosCRLHolder ChaosCRLHolder "constructor"
+func NewChaosCRLHolder(holder CRLHolder, faults Faults) *ChaosCRLHolder {
+	return &ChaosCRLHolder{chaos: newChaos(faults), Holder: holder}
+}
+
+// Put crl content to holder, partially written crl has half of content
+func (h *ChaosCRLHolder) Put(content []byte) error {
+	if err := h.fail(ChaosPut); err != nil {
+		return err
+	}
+	if h.roll(ChaosPut, h.faults.PartialWriteRate) {
+		if err := h.Holder.Put(truncate(content)); err != nil {
+			return err
+		}
+		return fmt.Errorf("can`t put crl completely: %w", ErrInjected)
+	}
+	return h.Holder.Put(content)
+}
+
+// Get crl from holder
+func (h *ChaosCRLHolder) Get() (*pkix.CertificateList, error) {
+	if err := h.fail(ChaosGet); err != nil {
+		return nil, err
+	}
+	return h.Holder.Get()
+}
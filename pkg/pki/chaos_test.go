@@ -0,0 +1,74 @@
+package pki
+
+import (
+	"context"
+	"crypto/x509/pkix"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kemsta/go-easyrsa/internal/fsStorage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChaosKeyStorage(t *testing.T) {
+	_ = os.MkdirAll(testData, 0777)
+	defer func() {
+		_ = os.RemoveAll(testData)
+	}()
+	storage := fsStorage.NewDirKeyStorage(filepath.Join(testData, "keys"))
+	crlHolder := fsStorage.NewFileCRLHolder(filepath.Join(testData, "crl.pem"))
+	pki := NewPKI(storage, fsStorage.NewFileSerialProvider(filepath.Join(testData, "serial")), crlHolder, pkix.Name{})
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+
+	chaos := NewChaosKeyStorage(storage, Faults{ErrorRate: 1, Ops: []ChaosOp{ChaosPut}})
+	pki.Storage = chaos
+	_, err = pki.NewCert("client", Client())
+	assert.ErrorIs(t, err, ErrInjected)
+	_, err = chaos.GetLastByCn("ca")
+	assert.NoError(t, err, "reads aren`t affected")
+	assert.Equal(t, 1, chaos.Injected())
+
+	chaos = NewChaosKeyStorage(storage, Faults{PartialWriteRate: 1})
+	pki.Storage = chaos
+	_, err = pki.NewCert("partial", Client())
+	assert.ErrorIs(t, err, ErrInjected)
+	partial, err := storage.GetLastByCn("partial")
+	assert.NoError(t, err)
+	_, err = partial.DecodeCert()
+	assert.Error(t, err, "truncated certificate can`t be decoded")
+
+	chaos = NewChaosKeyStorage(storage, Faults{LockTimeoutRate: 1, LockDelay: 10 * time.Millisecond})
+	start := time.Now()
+	_, err = chaos.Lock("issue")
+	assert.ErrorIs(t, err, ErrInjected)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+
+	holder := NewChaosCRLHolder(crlHolder, Faults{PartialWriteRate: 1})
+	pki.Storage = storage
+	pki.crlHolder = holder
+	_, err = pki.NewCert("revoked", Client())
+	assert.NoError(t, err)
+	assert.ErrorIs(t, pki.RevokeAllByCN("revoked"), ErrInjected)
+	_, err = crlHolder.Get()
+	assert.Error(t, err, "truncated crl can`t be parsed")
+}
+
+func TestChaosKeyStorage_Seed(t *testing.T) {
+	failures := func() []bool {
+		chaos := NewChaosKeyStorage(fsStorage.NewDirKeyStorage(t.TempDir()), Faults{ErrorRate: 0.5, Seed: 42})
+		var res []bool
+		for i := 0; i < 20; i++ {
+			_, err := chaos.GetAll()
+			res = append(res, err != nil)
+		}
+		return res
+	}
+	first := failures()
+	assert.Equal(t, first, failures())
+	assert.Contains(t, first, true)
+	assert.Contains(t, first, false)
+}
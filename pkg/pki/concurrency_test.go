@@ -0,0 +1,74 @@
+package pki
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPKI_NewCertConcurrent(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+
+	const count = 16
+	serials := make(chan string, count)
+	var wg sync.WaitGroup
+	wg.Add(count)
+	for i := 0; i < count; i++ {
+		go func(i int) {
+			defer wg.Done()
+			res, err := pki.NewCert(fmt.Sprintf("client%d", i))
+			if assert.NoError(t, err) {
+				serials <- res.Serial.String()
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(serials)
+	seen := map[string]bool{}
+	for serial := range serials {
+		assert.False(t, seen[serial], "serial %v is issued twice", serial)
+		seen[serial] = true
+	}
+	assert.Len(t, seen, count)
+	all, err := pki.Storage.GetAll()
+	assert.NoError(t, err)
+	assert.Len(t, all, count+1)
+}
+
+// BenchmarkPKI_NewCertConcurrent issue b.N certs by n goroutines against one DirKeyStorage.
+// Key generation dominates, so ns/op of goroutines=1 divided by cores is the limit without contention.
+func BenchmarkPKI_NewCertConcurrent(b *testing.B) {
+	for _, n := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("goroutines=%d", n), func(b *testing.B) {
+			pki, cleanup := getTmpPki()
+			defer cleanup()
+			if _, err := pki.NewCa(); err != nil {
+				b.Fatal(err)
+			}
+			jobs := make(chan int, b.N)
+			for i := 0; i < b.N; i++ {
+				jobs <- i
+			}
+			close(jobs)
+			var wg sync.WaitGroup
+			wg.Add(n)
+			b.ResetTimer()
+			for g := 0; g < n; g++ {
+				go func() {
+					defer wg.Done()
+					for i := range jobs {
+						if _, err := pki.NewCert(fmt.Sprintf("client%d", i)); err != nil {
+							b.Error(err)
+						}
+					}
+				}()
+			}
+			wg.Wait()
+		})
+	}
+}
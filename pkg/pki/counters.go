@@ -0,0 +1,67 @@
+package pki
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Counters is the state of serial and crl number counters. Nil value means the counter state is unknown.
+type Counters struct {
+	Serial    *big.Int `json:"serial,omitempty"`     // the last issued serial
+	CRLNumber *big.Int `json:"crl_number,omitempty"` // number of the last signed crl
+}
+
+// Counters return the last values of serial and crl number counters, export them with pairs to move PKI.
+// Serial is known if serial provider is a SerialReader. CRL number is the greater of counter set by WithCRLNumber
+// and number of current crl.
+func (p *PKI) Counters() (Counters, error) {
+	res := Counters{}
+	if reader, ok := p.serialProvider.(SerialReader); ok {
+		last, err := reader.Last()
+		if err != nil {
+			return res, fmt.Errorf("can`t read serial: %w", err)
+		}
+		res.Serial = last
+	}
+	if reader, ok := p.crlNumber.(SerialReader); ok {
+		last, err := reader.Last()
+		if err != nil {
+			return res, fmt.Errorf("can`t read crl number: %w", err)
+		}
+		res.CRLNumber = last
+	}
+	list, err := p.GetCRL()
+	if err != nil {
+		return res, fmt.Errorf("can`t get crl: %w", err)
+	}
+	if number := crlNumber(list); number != nil && (res.CRLNumber == nil || number.Cmp(res.CRLNumber) > 0) {
+		res.CRLNumber = number
+	}
+	return res, nil
+}
+
+// RestoreCounters advance serial and crl number counters to exported state, so numbering continues after it
+// on restored or migrated PKI. Counters are never moved back, nil values are skipped.
+// It fails if a counter to restore isn`t a SerialAdvancer.
+func (p *PKI) RestoreCounters(c Counters) error {
+	if c.Serial != nil {
+		advancer, ok := p.serialProvider.(SerialAdvancer)
+		if !ok {
+			return errors.New("can`t restore serial: serial provider can`t be advanced")
+		}
+		if err := advancer.AdvanceTo(c.Serial); err != nil {
+			return fmt.Errorf("can`t restore serial: %w", err)
+		}
+	}
+	if c.CRLNumber != nil {
+		advancer, ok := p.crlNumber.(SerialAdvancer)
+		if !ok {
+			return errors.New("can`t restore crl number: no crl number counter, see WithCRLNumber")
+		}
+		if err := advancer.AdvanceTo(c.CRLNumber); err != nil {
+			return fmt.Errorf("can`t restore crl number: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package pki
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPKI_Counters(t *testing.T) {
+	src, err := InitPKI(t.TempDir(), nil)
+	assert.NoError(t, err)
+	empty, err := src.Counters()
+	assert.NoError(t, err)
+	assert.Equal(t, Counters{Serial: big.NewInt(0), CRLNumber: big.NewInt(0)}, empty)
+
+	_, _ = src.NewCa()
+	client, _ := src.NewCert("client")
+	assert.NoError(t, src.RevokeOne(client.Serial))
+	assert.NoError(t, src.RefreshCRL())
+	counters, err := src.Counters()
+	assert.NoError(t, err)
+	assert.Equal(t, Counters{Serial: big.NewInt(2), CRLNumber: big.NewInt(2)}, counters)
+	content, err := json.Marshal(counters)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"serial":2,"crl_number":2}`, string(content))
+
+	dst, err := InitPKI(t.TempDir(), nil)
+	assert.NoError(t, err)
+	assert.NoError(t, dst.RestoreCounters(counters))
+	assert.NoError(t, dst.RestoreCounters(Counters{Serial: big.NewInt(1), CRLNumber: big.NewInt(1)}))
+	restored, err := dst.Counters()
+	assert.NoError(t, err)
+	assert.Equal(t, counters, restored)
+	ca, err := dst.NewCa()
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(3), ca.Serial)
+	assert.NoError(t, dst.RefreshCRL())
+	list, err := dst.GetCRL()
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(3), crlNumber(list))
+
+	noCounter, cleanup := getTmpPki()
+	defer cleanup()
+	assert.Error(t, noCounter.RestoreCounters(Counters{CRLNumber: big.NewInt(5)}))
+}
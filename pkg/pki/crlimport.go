@@ -0,0 +1,104 @@
+package pki
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+)
+
+// ImportCRL merge revocations from pem or der CRL signed by any valid CA of PKI, e.g. one produced by shell
+// easy-rsa working with the same CA, into local CRL and re-sign it. Serials revoked locally already are skipped,
+// entries of held certificates are replaced by permanent revocations. It returns imported entries.
+func (p *PKI) ImportCRL(content []byte) ([]pkix.RevokedCertificate, error) {
+	external, err := x509.ParseCRL(content)
+	if err != nil {
+		return nil, fmt.Errorf("can`t parse crl: %w", err)
+	}
+	_, caCerts, err := p.validCAs()
+	if err != nil {
+		return nil, err
+	}
+	if !signedByAny(external, caCerts) {
+		return nil, errors.New("can`t import crl: it isn`t signed by any ca")
+	}
+	local := make([]pkix.RevokedCertificate, 0)
+	if list, err := p.GetCRL(); err == nil {
+		local = list.TBSCertList.RevokedCertificates
+	}
+	entries := newRevocations(local, external.TBSCertList.RevokedCertificates)
+	if len(entries) == 0 {
+		return entries, nil
+	}
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		id, err := p.beginRevokeIntent(entry)
+		if err != nil {
+			p.abortIntents(ids)
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	err = p.updateCRL(func(list []pkix.RevokedCertificate) ([]pkix.RevokedCertificate, error) {
+		// crl could be changed since entries were selected
+		for _, entry := range newRevocations(list, entries) {
+			if revocationReason(entry) != ReasonCertificateHold {
+				list = removeHeld(list, entry.SerialNumber)
+			}
+			list = append(list, entry)
+		}
+		return list, nil
+	})
+	if err != nil {
+		p.abortIntents(ids)
+		return nil, err
+	}
+	for _, id := range ids {
+		if err := p.commitIntent(id); err != nil {
+			return entries, err
+		}
+	}
+	var hookErr error
+	for _, entry := range entries {
+		if err := p.runHooks(EventRevoke, p.revokedPair(entry.SerialNumber)); err != nil && hookErr == nil {
+			hookErr = err
+		}
+	}
+	return entries, hookErr
+}
+
+// newRevocations return entries of external which change local list: serials absent in local and permanent
+// revocations of serials held in local. Only the first entry of every serial is taken.
+func newRevocations(local, external []pkix.RevokedCertificate) []pkix.RevokedCertificate {
+	held := map[string]bool{}
+	known := map[string]bool{}
+	for _, entry := range local {
+		serial := entry.SerialNumber.String()
+		if revocationReason(entry) == ReasonCertificateHold {
+			held[serial] = true
+		} else {
+			known[serial] = true
+		}
+	}
+	res := make([]pkix.RevokedCertificate, 0)
+	for _, entry := range external {
+		serial := entry.SerialNumber.String()
+		isHold := revocationReason(entry) == ReasonCertificateHold
+		if known[serial] || (held[serial] && isHold) {
+			continue
+		}
+		res = append(res, entry)
+		known[serial] = true
+	}
+	return res
+}
+
+// signedByAny return true if list is signed by one of certs
+func signedByAny(list *pkix.CertificateList, certs []*x509.Certificate) bool {
+	for _, cert := range certs {
+		if cert.CheckCRLSignature(list) == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package pki
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPKI_ImportCRL(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	revoked := 0
+	WithHooks(EventRevoke, func(event Event) error {
+		revoked++
+		return nil
+	})(pki)
+	ca, err := pki.NewCa()
+	assert.NoError(t, err)
+	held, err := pki.NewCert("held", Client())
+	assert.NoError(t, err)
+	local, err := pki.NewCert("local", Client())
+	assert.NoError(t, err)
+	assert.NoError(t, pki.Hold(held.Serial))
+	assert.NoError(t, pki.RevokeOne(local.Serial))
+	revoked = 0
+
+	now := time.Now()
+	entries := []pkix.RevokedCertificate{
+		{SerialNumber: local.Serial, RevocationTime: now},
+		{SerialNumber: held.Serial, RevocationTime: now},
+		{SerialNumber: big.NewInt(100), RevocationTime: now},
+		{SerialNumber: big.NewInt(100), RevocationTime: now},
+	}
+	external, err := pki.newCrl(ca, entries, big.NewInt(1))
+	assert.NoError(t, err)
+	imported, err := pki.ImportCRL(external)
+	assert.NoError(t, err)
+	assert.Len(t, imported, 2)
+	assert.Equal(t, 2, revoked)
+	status, err := pki.Status(held.Serial)
+	assert.NoError(t, err)
+	assert.Equal(t, CertStatusRevoked, status)
+	assert.True(t, pki.IsRevoked(big.NewInt(100)))
+	list, err := pki.GetCRL()
+	assert.NoError(t, err)
+	assert.Len(t, list.TBSCertList.RevokedCertificates, 3)
+
+	imported, err = pki.ImportCRL(external)
+	assert.NoError(t, err)
+	assert.Empty(t, imported)
+
+	foreignKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	foreignTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "foreign"},
+		NotBefore:             now,
+		NotAfter:              now.Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCRLSign | x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, foreignTmpl, foreignTmpl, &foreignKey.PublicKey, foreignKey)
+	assert.NoError(t, err)
+	foreignCert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	foreign, err := foreignCert.CreateCRL(rand.Reader, foreignKey, entries, now, now.Add(time.Hour))
+	assert.NoError(t, err)
+	_, err = pki.ImportCRL(foreign)
+	assert.Error(t, err)
+	_, err = pki.ImportCRL([]byte("garbage"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,61 @@
+package pki
+
+import (
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// PruneCRL re-sign CRL without entries of certificates expired more than after ago and return their serials.
+// Expired certificates are rejected by NotAfter anyway, so entries are only kept for grace period.
+// Entries without stored certificate, e.g. imported ones, are kept.
+func (p *PKI) PruneCRL(after time.Duration) ([]*big.Int, error) {
+	pruned := make([]*big.Int, 0)
+	err := p.updateCRL(func(list []pkix.RevokedCertificate) ([]pkix.RevokedCertificate, error) {
+		res, removed, err := p.pruneExpired(list, after)
+		pruned = removed
+		return res, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pruned, nil
+}
+
+// pruneExpired return list without entries of stored certificates expired more than after ago and removed serials
+func (p *PKI) pruneExpired(list []pkix.RevokedCertificate, after time.Duration) ([]pkix.RevokedCertificate, []*big.Int, error) {
+	expired, err := p.expiredSerials(time.Now().Add(-after))
+	if err != nil {
+		return nil, nil, err
+	}
+	res := make([]pkix.RevokedCertificate, 0, len(list))
+	removed := make([]*big.Int, 0)
+	for _, entry := range list {
+		if expired[entry.SerialNumber.String()] {
+			removed = append(removed, entry.SerialNumber)
+			continue
+		}
+		res = append(res, entry)
+	}
+	return res, removed, nil
+}
+
+// expiredSerials return decimal serials of stored certificates with NotAfter before date
+func (p *PKI) expiredSerials(date time.Time) (map[string]bool, error) {
+	pairs, err := p.allCerts()
+	if err != nil {
+		return nil, fmt.Errorf("can`t get pairs: %w", err)
+	}
+	res := map[string]bool{}
+	for _, certPair := range pairs {
+		cert, err := certPair.DecodeCert()
+		if err != nil {
+			continue
+		}
+		if cert.NotAfter.Before(date) {
+			res[certPair.Serial.String()] = true
+		}
+	}
+	return res, nil
+}
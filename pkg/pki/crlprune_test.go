@@ -0,0 +1,70 @@
+package pki
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPKI_PruneCRL(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+	old, err := pki.NewCert("old", Client(), NotAfter(time.Now().Add(-48*time.Hour)))
+	assert.NoError(t, err)
+	recent, err := pki.NewCert("recent", Client(), NotAfter(time.Now().Add(-time.Hour)))
+	assert.NoError(t, err)
+	valid, err := pki.NewCert("valid", Client())
+	assert.NoError(t, err)
+	for _, serial := range []*big.Int{old.Serial, recent.Serial, valid.Serial, big.NewInt(100)} {
+		assert.NoError(t, pki.RevokeOne(serial))
+	}
+
+	report, err := pki.Health()
+	assert.NoError(t, err)
+	assert.Equal(t, 4, report.CRLEntries)
+	assert.Equal(t, 2, report.CRLExpiredEntries)
+	assert.True(t, report.CRLSize > 0)
+	assert.True(t, report.CRLNextUpdate.After(time.Now()))
+
+	pruned, err := pki.PruneCRL(24 * time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, []*big.Int{old.Serial}, pruned)
+	assert.False(t, pki.IsRevoked(old.Serial))
+	assert.True(t, pki.IsRevoked(recent.Serial))
+	assert.True(t, pki.IsRevoked(big.NewInt(100)))
+
+	pruned, err = pki.PruneCRL(0)
+	assert.NoError(t, err)
+	assert.Equal(t, []*big.Int{recent.Serial}, pruned)
+
+	smaller, err := pki.Health()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, smaller.CRLEntries)
+	assert.Equal(t, 0, smaller.CRLExpiredEntries)
+	assert.True(t, smaller.CRLSize < report.CRLSize)
+}
+
+func TestPKI_WithCRLPruning(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	WithCRLPruning(24 * time.Hour)(pki)
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+	old, err := pki.NewCert("old", Client(), NotAfter(time.Now().Add(-48*time.Hour)))
+	assert.NoError(t, err)
+	valid, err := pki.NewCert("valid", Client())
+	assert.NoError(t, err)
+
+	assert.NoError(t, pki.RevokeOne(old.Serial))
+	assert.False(t, pki.IsRevoked(old.Serial))
+	assert.NoError(t, pki.RevokeOne(valid.Serial))
+	assert.True(t, pki.IsRevoked(valid.Serial))
+
+	status, err := pki.Status(old.Serial)
+	assert.NoError(t, err)
+	assert.Equal(t, CertStatusExpired, status)
+}
@@ -0,0 +1,71 @@
+package pki
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+
+	"github.com/kemsta/go-easyrsa/pkg/pair"
+)
+
+// PEMCertificateRequestBlock is pem block header for x509.CertificateRequest
+const PEMCertificateRequestBlock = "CERTIFICATE REQUEST"
+
+// SignRequest issue certificate for pem or der CSR with key generated elsewhere, e.g. by enrollment client.
+// Common name and subject alternative names are taken from CSR, other subject fields and extensions come
+// from PKI and options like for Issue. Pair is stored without key. CSR is kept with pair if storage is a CSRStore.
+func (p *PKI) SignRequest(csr []byte, profile Profile, opts ...CertificateOption) (*pair.X509Pair, error) {
+	req, err := ParseRequest(csr)
+	if err != nil {
+		return nil, err
+	}
+	id := Identity{
+		CommonName:  req.Subject.CommonName,
+		DNSNames:    req.DNSNames,
+		IPAddresses: req.IPAddresses,
+		Profile:     profile,
+	}
+	return p.issue(context.Background(), id, req.PublicKey, nil,
+		append([]CertificateOption{fromRequest(encodeRequest(req))}, opts...))
+}
+
+// storedRequest return request kept with pair with serial, nil if there is no one
+func (p *PKI) storedRequest(serial *big.Int) (*x509.CertificateRequest, error) {
+	store, ok := p.Storage.(CSRStore)
+	if !ok {
+		return nil, nil
+	}
+	content, err := store.GetCSR(serial)
+	if err != nil {
+		return nil, fmt.Errorf("can`t get csr of pair with serial %v: %w", serial, err)
+	}
+	if len(content) == 0 {
+		return nil, nil
+	}
+	return ParseRequest(content)
+}
+
+// encodeRequest return request as pem
+func encodeRequest(req *x509.CertificateRequest) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: PEMCertificateRequestBlock, Bytes: req.Raw})
+}
+
+// ParseRequest parse pem or der CSR and check its signature
+func ParseRequest(csr []byte) (*x509.CertificateRequest, error) {
+	if block, _ := pem.Decode(csr); block != nil {
+		if block.Type != PEMCertificateRequestBlock {
+			return nil, fmt.Errorf("can`t parse csr: unexpected pem block %q", block.Type)
+		}
+		csr = block.Bytes
+	}
+	req, err := x509.ParseCertificateRequest(csr)
+	if err != nil {
+		return nil, fmt.Errorf("can`t parse csr: %w", err)
+	}
+	if err := req.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("bad csr signature: %w", err)
+	}
+	return req, nil
+}
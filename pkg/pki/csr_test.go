@@ -0,0 +1,104 @@
+package pki
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kemsta/go-easyrsa/pkg/pair"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPKI_SignRequest(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "device", Organization: []string{"ignored"}},
+		DNSNames: []string{"device.example.com"},
+	}, key)
+	assert.NoError(t, err)
+	csrPEM := pem.EncodeToMemory(&pem.Block{Type: PEMCertificateRequestBlock, Bytes: der})
+
+	certPair, err := pki.SignRequest(csrPEM, ProfileServer)
+	assert.NoError(t, err)
+	assert.Equal(t, "device", certPair.CN)
+	assert.Empty(t, certPair.KeyPemBytes)
+	cert, err := certPair.DecodeCert()
+	assert.NoError(t, err)
+	assert.True(t, key.PublicKey.Equal(cert.PublicKey))
+	assert.Empty(t, cert.Subject.Organization)
+	assert.Equal(t, []string{"device.example.com"}, cert.DNSNames)
+	assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}, cert.ExtKeyUsage)
+
+	broken := append([]byte{}, der...)
+	broken[len(broken)-1] ^= 0xff
+	_, err = pki.SignRequest(broken, ProfileServer)
+	assert.Error(t, err)
+
+	_, err = NewEnrollHandler(pki, ProfileClient)
+	assert.ErrorIs(t, err, ErrOpenEnrollment)
+	restricted, err := NewEnrollHandler(pki, ProfileClient, EnrollRequireIssuer())
+	assert.NoError(t, err)
+	anonymous := httptest.NewRecorder()
+	restricted.ServeHTTP(anonymous, httptest.NewRequest(http.MethodPost, EnrollPath, bytes.NewReader(der)))
+	assert.Equal(t, http.StatusUnauthorized, anonymous.Code)
+
+	handler, err := NewEnrollHandler(pki, ProfileClient, OpenEnrollment())
+	assert.NoError(t, err)
+	post := func(method string, body []byte) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, EnrollPath, bytes.NewReader(body)))
+		return rec
+	}
+	rec := post(http.MethodPost, der)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, MIMEPEMFile, rec.Header().Get("Content-Type"))
+	block, _ := pem.Decode(rec.Body.Bytes())
+	assert.NotNil(t, block)
+	assert.Equal(t, http.StatusBadRequest, post(http.MethodPost, []byte("garbage")).Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, post(http.MethodGet, nil).Code)
+}
+
+func TestPKI_EnrollReservedName(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	ca, err := pki.NewCa()
+	assert.NoError(t, err)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: pki.CAName()},
+	}, key)
+	assert.NoError(t, err)
+	handler, err := NewEnrollHandler(pki, ProfileClient, OpenEnrollment())
+	assert.NoError(t, err)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, EnrollPath, bytes.NewReader(der)))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, string(CodePolicyDenied), rec.Header().Get(ErrorCodeHeader))
+	_, err = pki.NewCert(pki.CrossName(), Client())
+	assert.ErrorIs(t, err, ErrReservedName)
+
+	// leaf stored with ca name by older versions is skipped
+	leaf, err := pki.NewCert("leaf", Client())
+	assert.NoError(t, err)
+	assert.NoError(t, pki.Storage.Put(pair.NewX509Pair(nil, leaf.CertPemBytes, pki.CAName(), big.NewInt(100))))
+	last, err := pki.GetLastCA()
+	assert.NoError(t, err)
+	assert.Equal(t, ca.Serial, last.Serial)
+	client, err := pki.NewCert("client", Client())
+	assert.NoError(t, err)
+	assert.NoError(t, pki.RevokeOne(client.Serial))
+}
@@ -0,0 +1,152 @@
+package pki
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+var (
+	oidCTPoison  = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 3}
+	oidCTSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+)
+
+// SCT is a signed certificate timestamp returned by certificate transparency log, see RFC 6962
+type SCT struct {
+	Version    uint8  `json:"sct_version"`
+	LogID      []byte `json:"id"`
+	Timestamp  uint64 `json:"timestamp"`  // milliseconds since epoch
+	Extensions []byte `json:"extensions"` // usually empty
+	Signature  []byte `json:"signature"`  // tls encoded digitally-signed struct
+}
+
+// serialize return tls encoded SCT as it`s embedded into certificate
+func (s *SCT) serialize() ([]byte, error) {
+	if len(s.LogID) != 32 {
+		return nil, fmt.Errorf("bad log id length %v", len(s.LogID))
+	}
+	var buf bytes.Buffer
+	buf.WriteByte(s.Version)
+	buf.Write(s.LogID)
+	_ = binary.Write(&buf, binary.BigEndian, s.Timestamp)
+	_ = binary.Write(&buf, binary.BigEndian, uint16(len(s.Extensions)))
+	buf.Write(s.Extensions)
+	buf.Write(s.Signature)
+	return buf.Bytes(), nil
+}
+
+// CTLog is a certificate transparency log. AddPreChain submit DER precertificate followed by its issuer chain
+// and return SCT promising to include it into log.
+type CTLog interface {
+	AddPreChain(ctx context.Context, chain [][]byte) (*SCT, error)
+}
+
+// CTLogClient is a CTLog talking RFC 6962 http api
+type CTLogClient struct {
+	URL    string       // log url, e.g. https://ct.example.com/2024, /ct/v1/add-pre-chain is appended
+	Client *http.Client // http.DefaultClient by default
+}
+
+// AddPreChain post chain to add-pre-chain endpoint of log
+func (c *CTLogClient) AddPreChain(ctx context.Context, chain [][]byte) (*SCT, error) {
+	body, err := json.Marshal(struct {
+		Chain [][]byte `json:"chain"`
+	}{Chain: chain})
+	if err != nil {
+		return nil, err
+	}
+	endpoint := strings.TrimSuffix(c.URL, "/") + "/ct/v1/add-pre-chain"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("can`t create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("can`t post %v: %w", endpoint, err)
+	}
+	defer resp.Body.Close()
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("can`t read response of %v: %w", endpoint, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%v responded %v: %s", endpoint, resp.Status, bytes.TrimSpace(content))
+	}
+	sct := &SCT{}
+	if err := json.Unmarshal(content, sct); err != nil {
+		return nil, fmt.Errorf("can`t decode sct from %v: %w", endpoint, err)
+	}
+	return sct, nil
+}
+
+// signLeaf sign template of leaf certificate with CA key. If there are CT logs, poisoned precertificate
+// is submitted to every one of them first and returned SCTs are embedded into certificate.
+func (p *PKI) signLeaf(ctx context.Context, tmpl, caCert *x509.Certificate, public crypto.PublicKey,
+	caKey crypto.Signer) ([]byte, error) {
+	if len(p.ctLogs) > 0 {
+		ext, err := p.submitPrecert(ctx, tmpl, caCert, public, caKey)
+		if err != nil {
+			return nil, err
+		}
+		tmpl.ExtraExtensions = append(tmpl.ExtraExtensions, ext)
+	}
+	return x509.CreateCertificate(p.random(), tmpl, caCert, public, caKey)
+}
+
+// submitPrecert submit precertificate of template to CT logs and return SCT list extension
+func (p *PKI) submitPrecert(ctx context.Context, tmpl, caCert *x509.Certificate, public crypto.PublicKey,
+	caKey crypto.Signer) (pkix.Extension, error) {
+	precertTmpl := *tmpl
+	precertTmpl.ExtraExtensions = append(append([]pkix.Extension{}, tmpl.ExtraExtensions...),
+		pkix.Extension{Id: oidCTPoison, Critical: true, Value: asn1.NullBytes})
+	precert, err := x509.CreateCertificate(p.random(), &precertTmpl, caCert, public, caKey)
+	if err != nil {
+		return pkix.Extension{}, fmt.Errorf("can`t create precertificate: %w", err)
+	}
+	issuers, err := p.chainOf(caCert)
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+	chain := [][]byte{precert}
+	for _, issuer := range issuers {
+		chain = append(chain, issuer.Raw)
+	}
+	var list bytes.Buffer
+	for _, log := range p.ctLogs {
+		sct, err := log.AddPreChain(ctx, chain)
+		if err != nil {
+			return pkix.Extension{}, fmt.Errorf("can`t submit precertificate to ct log: %w", err)
+		}
+		serialized, err := sct.serialize()
+		if err != nil {
+			return pkix.Extension{}, fmt.Errorf("bad sct: %w", err)
+		}
+		_ = binary.Write(&list, binary.BigEndian, uint16(len(serialized)))
+		list.Write(serialized)
+	}
+	if list.Len() > 0xffff {
+		return pkix.Extension{}, errors.New("sct list is too long")
+	}
+	tlsList := make([]byte, 2, 2+list.Len())
+	binary.BigEndian.PutUint16(tlsList, uint16(list.Len()))
+	value, err := asn1.Marshal(append(tlsList, list.Bytes()...))
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+	return pkix.Extension{Id: oidCTSCTList, Value: value}, nil
+}
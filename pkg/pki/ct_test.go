@@ -0,0 +1,77 @@
+package pki
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func hasExtension(cert *x509.Certificate, id asn1.ObjectIdentifier) []byte {
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(id) {
+			return ext.Value
+		}
+	}
+	return nil
+}
+
+func TestWithCTLogs(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+
+	sct := SCT{LogID: bytes.Repeat([]byte{7}, 32), Timestamp: 1700000000000, Signature: []byte{4, 3, 0, 2, 0xca, 0xfe}}
+	fail := false
+	var submitted [][]byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/log/ct/v1/add-pre-chain", r.URL.Path)
+		if fail {
+			http.Error(w, "log is frozen", http.StatusServiceUnavailable)
+			return
+		}
+		var req struct {
+			Chain [][]byte `json:"chain"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		submitted = req.Chain
+		assert.NoError(t, json.NewEncoder(w).Encode(sct))
+	}))
+	defer server.Close()
+	WithCTLogs(&CTLogClient{URL: server.URL + "/log/"})(pki)
+
+	issued, err := pki.NewCert("www", Server())
+	assert.NoError(t, err)
+	cert, err := issued.DecodeCert()
+	assert.NoError(t, err)
+	if assert.Len(t, submitted, 2) {
+		precert, err := x509.ParseCertificate(submitted[0])
+		assert.NoError(t, err)
+		assert.Equal(t, cert.SerialNumber, precert.SerialNumber)
+		assert.NotNil(t, hasExtension(precert, oidCTPoison))
+		assert.Nil(t, hasExtension(precert, oidCTSCTList))
+	}
+	assert.Nil(t, hasExtension(cert, oidCTPoison))
+	var list []byte
+	_, err = asn1.Unmarshal(hasExtension(cert, oidCTSCTList), &list)
+	assert.NoError(t, err)
+	serialized, err := sct.serialize()
+	assert.NoError(t, err)
+	want := make([]byte, 4)
+	binary.BigEndian.PutUint16(want, uint16(len(serialized)+2))
+	binary.BigEndian.PutUint16(want[2:], uint16(len(serialized)))
+	assert.Equal(t, append(want, serialized...), list)
+
+	fail = true
+	_, err = pki.NewCert("api", Server())
+	assert.ErrorContains(t, err, "log is frozen")
+	_, err = pki.Storage.GetLastByCn("api")
+	assert.Error(t, err, "certificate isn`t stored without sct")
+}
@@ -0,0 +1,97 @@
+package pki
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// LeafDefaults are extension defaults of CA inherited by every leaf certificate it signs.
+// Extensions set by certificate options are kept as is.
+type LeafDefaults struct {
+	CRLDistributionPoints  []string `json:"crlDistributionPoints,omitempty"`  // crl urls
+	OCSPServers            []string `json:"ocspServers,omitempty"`            // authority information access ocsp urls
+	IssuingCertificateURLs []string `json:"issuingCertificateURLs,omitempty"` // authority information access ca urls
+	Policies               []string `json:"policies,omitempty"`               // policy oids, e.g. 1.3.6.1.4.1.99999.1
+}
+
+// apply set extensions which are empty in template
+func (d LeafDefaults) apply(template *x509.Certificate) error {
+	if len(template.CRLDistributionPoints) == 0 {
+		template.CRLDistributionPoints = d.CRLDistributionPoints
+	}
+	if len(template.OCSPServer) == 0 {
+		template.OCSPServer = d.OCSPServers
+	}
+	if len(template.IssuingCertificateURL) == 0 {
+		template.IssuingCertificateURL = d.IssuingCertificateURLs
+	}
+	if len(template.PolicyIdentifiers) == 0 {
+		for _, policy := range d.Policies {
+			oid, err := parseOID(policy)
+			if err != nil {
+				return err
+			}
+			template.PolicyIdentifiers = append(template.PolicyIdentifiers, oid)
+		}
+	}
+	return nil
+}
+
+// SetLeafDefaults save extension defaults for leaves signed by CA pairs with name in CA metadata.
+// Storage should be a MetadataStore.
+func (p *PKI) SetLeafDefaults(caName string, defaults LeafDefaults) error {
+	for _, policy := range defaults.Policies {
+		if _, err := parseOID(policy); err != nil {
+			return err
+		}
+	}
+	meta, err := p.Metadata(caName)
+	if err != nil {
+		return err
+	}
+	meta.LeafDefaults = &defaults
+	return p.SetMetadata(caName, meta)
+}
+
+// LeafDefaults return extension defaults for leaves signed by CA pairs with name. It`s empty if nothing was saved.
+func (p *PKI) LeafDefaults(caName string) (LeafDefaults, error) {
+	meta, err := p.Metadata(caName)
+	if err != nil || meta.LeafDefaults == nil {
+		return LeafDefaults{}, err
+	}
+	return *meta.LeafDefaults, nil
+}
+
+// applyLeafDefaults apply extension defaults of CA with name to template. Storages without metadata have no defaults.
+// Crl urls set by WithCRLDistributionPoints are used if CA has no ones.
+func (p *PKI) applyLeafDefaults(template *x509.Certificate, caName string) error {
+	defaults, err := p.LeafDefaults(caName)
+	if err != nil && !errors.Is(err, errNoMetadataStore) {
+		return fmt.Errorf("can`t get leaf defaults of %v: %w", caName, err)
+	}
+	if len(defaults.CRLDistributionPoints) == 0 {
+		defaults.CRLDistributionPoints = p.crlURLs
+	}
+	return defaults.apply(template)
+}
+
+// parseOID parse object identifier in dotted form
+func parseOID(value string) (asn1.ObjectIdentifier, error) {
+	parts := strings.Split(value, ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("bad oid %q", value)
+	}
+	oid := make(asn1.ObjectIdentifier, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("bad oid %q", value)
+		}
+		oid[i] = n
+	}
+	return oid, nil
+}
@@ -0,0 +1,75 @@
+package pki
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPKI_SetLeafDefaults(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+	assert.Error(t, pki.SetLeafDefaults("ca", LeafDefaults{Policies: []string{"bad"}}))
+	assert.NoError(t, pki.SetLeafDefaults("ca", LeafDefaults{
+		CRLDistributionPoints:  []string{"http://pki.example.com/crl.pem"},
+		OCSPServers:            []string{"http://ocsp.example.com"},
+		IssuingCertificateURLs: []string{"http://pki.example.com/ca.crt"},
+		Policies:               []string{"1.3.6.1.4.1.99999.1"},
+	}))
+
+	inherited, err := pki.NewCert("inherited")
+	assert.NoError(t, err)
+	cert, err := inherited.DecodeCert()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"http://pki.example.com/crl.pem"}, cert.CRLDistributionPoints)
+	assert.Equal(t, []string{"http://ocsp.example.com"}, cert.OCSPServer)
+	assert.Equal(t, []string{"http://pki.example.com/ca.crt"}, cert.IssuingCertificateURL)
+	assert.Equal(t, []asn1.ObjectIdentifier{{1, 3, 6, 1, 4, 1, 99999, 1}}, cert.PolicyIdentifiers)
+
+	overridden, err := pki.NewCert("overridden", Option(func(c *x509.Certificate) {
+		c.CRLDistributionPoints = []string{"http://other.example.com/crl.pem"}
+	}))
+	assert.NoError(t, err)
+	cert, err = overridden.DecodeCert()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"http://other.example.com/crl.pem"}, cert.CRLDistributionPoints)
+	assert.Equal(t, []string{"http://ocsp.example.com"}, cert.OCSPServer)
+
+	preview, err := pki.Preview("preview")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"http://pki.example.com/crl.pem"}, preview.CRLDistributionPoints)
+}
+
+func TestWithCRLDistributionPoints(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	WithCRLDistributionPoints("http://pki.example.com/crl.pem")(pki)
+	caPair, err := pki.NewCa()
+	assert.NoError(t, err)
+	cert, err := caPair.DecodeCert()
+	assert.NoError(t, err)
+	assert.Empty(t, cert.CRLDistributionPoints)
+
+	injected, err := pki.NewCert("injected")
+	assert.NoError(t, err)
+	cert, err = injected.DecodeCert()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"http://pki.example.com/crl.pem"}, cert.CRLDistributionPoints)
+
+	explicit, err := pki.NewCert("explicit", CRLDistributionPoints([]string{"http://other.example.com/crl.pem"}))
+	assert.NoError(t, err)
+	cert, err = explicit.DecodeCert()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"http://other.example.com/crl.pem"}, cert.CRLDistributionPoints)
+
+	assert.NoError(t, pki.SetLeafDefaults("ca", LeafDefaults{CRLDistributionPoints: []string{"http://ca.example.com/crl.pem"}}))
+	inherited, err := pki.NewCert("inherited")
+	assert.NoError(t, err)
+	cert, err = inherited.DecodeCert()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"http://ca.example.com/crl.pem"}, cert.CRLDistributionPoints)
+}
@@ -0,0 +1,111 @@
+package pki
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// DefaultDNSGuardTimeout limit resolution of all DNS names of one certificate unless DNSGuard.Timeout is set
+const DefaultDNSGuardTimeout = 10 * time.Second
+
+// Resolver look up addresses of host, *net.Resolver is a Resolver
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// DNSGuard check DNS names of server certificates before issue, as a guard against typos and hostile names
+// in automated pipelines. Every name must be in one of Zones if they are set and must resolve.
+// Every resolved address must be in one of Subnets if they are set. Wildcard names are checked only against Zones.
+type DNSGuard struct {
+	Zones    []string     // allowed zones, e.g. "example.com" allows example.com and names below it
+	Subnets  []*net.IPNet // allowed subnets of resolved addresses
+	Resolver Resolver     // net.DefaultResolver if nil
+	Timeout  time.Duration
+}
+
+// DNSGuardError is returned when DNS name of server certificate is rejected by DNSGuard
+type DNSGuardError struct {
+	Name   string
+	Reason string
+}
+
+func (e *DNSGuardError) Error() string {
+	return fmt.Sprintf("dns name %q is rejected: %v", e.Name, e.Reason)
+}
+
+// check return DNSGuardError of the first rejected name
+func (g *DNSGuard) check(ctx context.Context, names []string) error {
+	timeout := g.Timeout
+	if timeout <= 0 {
+		timeout = DefaultDNSGuardTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	resolver := g.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSuffix(name, "."))
+		if len(g.Zones) > 0 && !inZones(name, g.Zones) {
+			return &DNSGuardError{Name: name, Reason: fmt.Sprintf("not in allowed zones %v", strings.Join(g.Zones, ", "))}
+		}
+		if strings.HasPrefix(name, "*.") {
+			continue
+		}
+		addrs, err := resolver.LookupIPAddr(ctx, name)
+		if err != nil {
+			return &DNSGuardError{Name: name, Reason: fmt.Sprintf("can`t resolve: %v", err)}
+		}
+		if len(addrs) == 0 {
+			return &DNSGuardError{Name: name, Reason: "doesn`t resolve"}
+		}
+		if len(g.Subnets) == 0 {
+			continue
+		}
+		for _, addr := range addrs {
+			if !inSubnets(addr.IP, g.Subnets) {
+				return &DNSGuardError{Name: name, Reason: fmt.Sprintf("resolves to %v out of allowed subnets", addr.IP)}
+			}
+		}
+	}
+	return nil
+}
+
+// inZones return true if name is one of zones or below one of them
+func inZones(name string, zones []string) bool {
+	for _, zone := range zones {
+		zone = strings.ToLower(strings.Trim(zone, "."))
+		if name == zone || strings.HasSuffix(name, "."+zone) {
+			return true
+		}
+	}
+	return false
+}
+
+func inSubnets(ip net.IP, subnets []*net.IPNet) bool {
+	for _, subnet := range subnets {
+		if subnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// guardDNS check DNS names of server certificate with DNS guard if it`s set.
+// It returns audit record of rejection, nil if names are accepted.
+func (p *PKI) guardDNS(ctx context.Context, id Identity, template *x509.Certificate) (*AuditRecord, error) {
+	profile := certProfile(id, template)
+	if p.dnsGuard == nil || profile != ProfileServer || len(template.DNSNames) == 0 {
+		return nil, nil
+	}
+	err := p.dnsGuard.check(ctx, template.DNSNames)
+	if err == nil {
+		return nil, nil
+	}
+	return &AuditRecord{Action: AuditRejectDNS, Name: id.Key(), Profile: profile, Detail: err.Error()}, err
+}
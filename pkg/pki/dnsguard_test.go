@@ -0,0 +1,99 @@
+package pki
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeResolver map[string][]string
+
+func (r fakeResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	ips, ok := r[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	res := make([]net.IPAddr, 0, len(ips))
+	for _, ip := range ips {
+		res = append(res, net.IPAddr{IP: net.ParseIP(ip)})
+	}
+	return res, nil
+}
+
+func TestDNSGuard_check(t *testing.T) {
+	_, internal, _ := net.ParseCIDR("10.0.0.0/8")
+	guard := DNSGuard{
+		Zones:   []string{"corp.example.com."},
+		Subnets: []*net.IPNet{internal},
+		Resolver: fakeResolver{
+			"web.corp.example.com": {"10.1.2.3"},
+			"corp.example.com":     {"10.0.0.1"},
+			"db.corp.example.com":  {"10.1.2.4", "203.0.113.7"},
+			"web.example.com":      {"10.1.2.5"},
+		},
+	}
+	tests := []struct {
+		name   string
+		names  []string
+		reason string
+	}{
+		{name: "allowed", names: []string{"web.corp.example.com", "Corp.Example.com."}},
+		{name: "wildcard", names: []string{"*.corp.example.com"}},
+		{name: "other zone", names: []string{"web.example.com"}, reason: "not in allowed zones"},
+		{name: "suffix isn`t zone", names: []string{"evilcorp.example.com"}, reason: "not in allowed zones"},
+		{name: "typo", names: []string{"wbe.corp.example.com"}, reason: "can`t resolve"},
+		{name: "public address", names: []string{"web.corp.example.com", "db.corp.example.com"},
+			reason: "resolves to 203.0.113.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := guard.check(context.Background(), tt.names)
+			if tt.reason == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var guardErr *DNSGuardError
+			if assert.True(t, errors.As(err, &guardErr)) {
+				assert.Contains(t, guardErr.Reason, tt.reason)
+			}
+		})
+	}
+}
+
+func TestWithDNSGuard(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	auditPath := filepath.Join(testData, "audit.log")
+	WithAuditFile(auditPath)(pki)
+	WithDNSGuard(DNSGuard{Zones: []string{"example.com"}, Resolver: fakeResolver{"www.example.com": {"192.0.2.1"}}})(pki)
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+
+	_, err = pki.Issue(Identity{CommonName: "www", DNSNames: []string{"www.example.com"}, Profile: ProfileServer})
+	assert.NoError(t, err)
+	_, err = pki.Issue(Identity{CommonName: "api", DNSNames: []string{"api.example.org"}, Profile: ProfileServer})
+	var guardErr *DNSGuardError
+	assert.True(t, errors.As(err, &guardErr))
+	_, err = pki.Storage.GetLastByCn("api")
+	assert.Error(t, err, "rejected cert isn`t stored")
+	_, err = pki.Issue(Identity{CommonName: "laptop", DNSNames: []string{"laptop.home"}, Profile: ProfileClient})
+	assert.NoError(t, err, "client certs aren`t guarded")
+
+	content, err := os.ReadFile(auditPath)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if assert.Len(t, lines, 1) {
+		var record AuditRecord
+		assert.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+		assert.Equal(t, AuditRejectDNS, record.Action)
+		assert.Equal(t, "api", record.Name)
+		assert.Contains(t, record.Detail, "api.example.org")
+	}
+}
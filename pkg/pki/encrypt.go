@@ -0,0 +1,115 @@
+package pki
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"time"
+)
+
+// Encrypter encrypt exported content for its recipients
+type Encrypter func(plain []byte) ([]byte, error)
+
+// AgeRecipients return encrypter to age recipients like "age1..." or ssh public keys. age binary is required.
+func AgeRecipients(recipients ...string) (Encrypter, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("no age recipients")
+	}
+	args := []string{"--encrypt", "--armor"}
+	for _, recipient := range recipients {
+		args = append(args, "--recipient", recipient)
+	}
+	return execEncrypter("age", args...), nil
+}
+
+// GPGRecipients return encrypter to OpenPGP recipients from gpg keyring, e.g. key ids or emails.
+// Armored message is produced. gpg binary is required.
+func GPGRecipients(recipients ...string) (Encrypter, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("no gpg recipients")
+	}
+	args := []string{"--batch", "--yes", "--trust-model", "always", "--armor", "--encrypt"}
+	for _, recipient := range recipients {
+		args = append(args, "--recipient", recipient)
+	}
+	return execEncrypter("gpg", args...), nil
+}
+
+// execEncrypter return encrypter which pipe plain content through command
+func execEncrypter(name string, args ...string) Encrypter {
+	return func(plain []byte) ([]byte, error) {
+		cmd := exec.Command(name, args...)
+		cmd.Stdin = bytes.NewReader(plain)
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return nil, fmt.Errorf("%v: %w: %s", name, err, bytes.TrimSpace(stderr.Bytes()))
+		}
+		return stdout.Bytes(), nil
+	}
+}
+
+// ExportEncrypted return the last pair with name as pem bundle of key, certificate and CA chain encrypted
+// by encrypter, so it can be sent over email or chat without sharing password
+func (p *PKI) ExportEncrypted(name string, encrypter Encrypter) ([]byte, error) {
+	last, err := p.Storage.GetLastByCn(name)
+	if err != nil {
+		return nil, fmt.Errorf("can`t get %v: %w", name, err)
+	}
+	chain, err := p.GetTrustBundle()
+	if err != nil {
+		return nil, fmt.Errorf("can`t get ca chain: %w", err)
+	}
+	var bundle bytes.Buffer
+	bundle.Write(last.KeyPemBytes)
+	bundle.Write(last.CertPemBytes)
+	bundle.Write(chain)
+	encrypted, err := encrypter(bundle.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("can`t encrypt %v: %w", name, err)
+	}
+	return encrypted, nil
+}
+
+// BackupEncrypted return tar.gz archive of all pairs, crl, index and counters encrypted by encrypter.
+// Archive layout is the same as logical names of snapshot files.
+func (p *PKI) BackupEncrypted(encrypter Encrypter) ([]byte, error) {
+	files, err := p.backupFiles()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	for _, name := range names {
+		header := &tar.Header{Name: name, Mode: 0600, Size: int64(len(files[name])), ModTime: now}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, fmt.Errorf("can`t write %v to archive: %w", name, err)
+		}
+		if _, err := tw.Write(files[name]); err != nil {
+			return nil, fmt.Errorf("can`t write %v to archive: %w", name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("can`t close archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("can`t close archive: %w", err)
+	}
+	encrypted, err := encrypter(archive.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("can`t encrypt backup: %w", err)
+	}
+	return encrypted, nil
+}
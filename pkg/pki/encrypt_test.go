@@ -0,0 +1,63 @@
+package pki
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPKI_ExportEncrypted(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	ca, err := pki.NewCa()
+	assert.NoError(t, err)
+	client, err := pki.NewCert("client")
+	assert.NoError(t, err)
+
+	plain := execEncrypter("cat")
+	bundle, err := pki.ExportEncrypted("client", plain)
+	assert.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(bundle, client.KeyPemBytes))
+	assert.True(t, bytes.HasSuffix(bundle, ca.CertPemBytes))
+	_, err = pki.ExportEncrypted("nobody", plain)
+	assert.Error(t, err)
+	_, err = pki.ExportEncrypted("client", execEncrypter("false"))
+	assert.Error(t, err)
+
+	archive, err := pki.BackupEncrypted(plain)
+	assert.NoError(t, err)
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	assert.NoError(t, err)
+	tr := tar.NewReader(gz)
+	names := make([]string, 0)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		names = append(names, header.Name)
+	}
+	assert.Equal(t, []string{"counters.json", "index.txt", "pairs/ca/1.crt", "pairs/ca/1.key", "pairs/client/2.crt", "pairs/client/2.key"}, names)
+
+	_, err = AgeRecipients()
+	assert.Error(t, err)
+	_, err = GPGRecipients()
+	assert.Error(t, err)
+}
+
+func TestGPGRecipients(t *testing.T) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg isn`t installed")
+	}
+	t.Setenv("GNUPGHOME", t.TempDir())
+	encrypter, err := GPGRecipients("nobody@example.invalid")
+	assert.NoError(t, err)
+	_, err = encrypter([]byte("secret"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,126 @@
+package pki
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const (
+	MIMEPKCS10 = "application/pkcs10" // DER certificate request, RFC 5967
+	EnrollPath = "/enroll"            // path of EnrollHandler in serve command
+)
+
+// maxRequestSize limit size of posted CSR
+const maxRequestSize = 64 << 10
+
+// EnrollHandler sign CSRs posted by enrollment clients like pkg/enroll. Request body is pem or der CSR,
+// response is pem certificate. Certificates are issued with fixed profile, so clients can`t ask for other usages.
+// If PKI has token store, every request must have single-use token from MintToken as bearer authorization
+// unless caller has RoleIssuer, see WithRoles. Without token store only callers with RoleIssuer are accepted
+// unless enrollment is open.
+type EnrollHandler struct {
+	pki           *PKI
+	profile       Profile
+	replay        *replayGuard
+	requireIssuer bool
+	open          bool
+}
+
+// ErrOpenEnrollment is returned by NewEnrollHandler for PKI without token store unless enrollment is restricted
+// with EnrollRequireIssuer or opened with OpenEnrollment explicitly
+var ErrOpenEnrollment = errors.New("enrollment needs token store, issuer authentication or explicit open enrollment")
+
+// EnrollOption tune EnrollHandler
+type EnrollOption func(*EnrollHandler)
+
+// EnrollReplayWindow require requests signed by CSR key with nonce and time headers, see EnrollDigest.
+// Requests older than window or with nonce seen within window are rejected.
+func EnrollReplayWindow(window time.Duration) EnrollOption {
+	return func(h *EnrollHandler) {
+		h.replay = newReplayGuard(window)
+	}
+}
+
+// EnrollRequireIssuer accept requests of callers with RoleIssuer set by authenticator of WithRoles.
+// Tokens are accepted too if PKI has token store.
+func EnrollRequireIssuer() EnrollOption {
+	return func(h *EnrollHandler) {
+		h.requireIssuer = true
+	}
+}
+
+// OpenEnrollment sign CSRs of any caller if PKI has no token store, e.g. on isolated provisioning network.
+// Anyone who reaches the handler gets certificates signed by CA.
+func OpenEnrollment() EnrollOption {
+	return func(h *EnrollHandler) {
+		h.open = true
+	}
+}
+
+// NewEnrollHandler return http handler signing CSRs with profile. It returns ErrOpenEnrollment if PKI has
+// no token store and neither EnrollRequireIssuer nor OpenEnrollment is set.
+func NewEnrollHandler(p *PKI, profile Profile, opts ...EnrollOption) (*EnrollHandler, error) {
+	h := &EnrollHandler{pki: p, profile: profile}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if p.tokens == nil && !h.requireIssuer && !h.open {
+		return nil, ErrOpenEnrollment
+	}
+	return h, nil
+}
+
+// ServeHTTP implement http.Handler. Only POST requests are allowed.
+func (h *EnrollHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, "POST")
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	if err != nil {
+		httpError(w, CodeTooLarge, fmt.Sprintf("can`t read request: %v", err))
+		return
+	}
+	req, err := ParseRequest(body)
+	if err != nil {
+		httpError(w, CodeBadRequest, err.Error())
+		return
+	}
+	logRequest(r, func(record *RequestLog) { record.CN = req.Subject.CommonName })
+	if h.replay != nil {
+		if err := h.replay.check(r, body, req.PublicKey, time.Now()); err != nil {
+			code := CodeBadRequest
+			if errors.Is(err, ErrReplayed) {
+				code = CodeReplayed
+			}
+			httpError(w, code, err.Error())
+			return
+		}
+	}
+	if !HasRole(r.Context(), RoleIssuer) {
+		switch {
+		case h.pki.tokens != nil:
+			token, _ := bearerToken(r)
+			if err := h.pki.RedeemToken(token, req); err != nil {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				httpError(w, CodeUnauthorized, err.Error())
+				return
+			}
+		case !h.open:
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			httpError(w, CodeUnauthorized, fmt.Sprintf("%v role is required", RoleIssuer))
+			return
+		}
+	}
+	// certificate is issued even if hooks fail, so client gets it anyway
+	certPair, err := h.pki.SignRequest(body, h.profile)
+	if certPair == nil {
+		serverError(w, fmt.Errorf("can`t sign request: %w", err))
+		return
+	}
+	logRequest(r, func(record *RequestLog) { record.Serial = certPair.Serial.Text(16) })
+	writeContent(w, MIMEPEMFile, certPair.CertPemBytes)
+}
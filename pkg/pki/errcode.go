@@ -0,0 +1,93 @@
+package pki
+
+import (
+	"errors"
+	"net/http"
+	"os"
+)
+
+// ErrorCode is a stable machine readable code of failed http request. Clients should branch on it
+// instead of parsing messages, which aren`t stable.
+type ErrorCode string
+
+const (
+	CodePolicyDenied     ErrorCode = "POLICY_DENIED"      // request is rejected by validity policy, DNS guard or reserved name
+	CodeNotFound         ErrorCode = "NOT_FOUND"          // there is no certificate or CA
+	CodeCAExpired        ErrorCode = "CA_EXPIRED"         // signing CA is expired
+	CodeLocked           ErrorCode = "LOCKED"             // storage lock isn`t acquired in time, request can be retried
+	CodeBadRequest       ErrorCode = "BAD_REQUEST"        // request can`t be parsed
+	CodeUnauthorized     ErrorCode = "UNAUTHORIZED"       // caller is unknown or its token is bad
+	CodeForbidden        ErrorCode = "FORBIDDEN"          // caller lacks role
+	CodeReplayed         ErrorCode = "REPLAYED"           // enrollment request is replayed
+	CodeMethodNotAllowed ErrorCode = "METHOD_NOT_ALLOWED" // http method isn`t supported by endpoint
+	CodeTooLarge         ErrorCode = "TOO_LARGE"          // request body is too large
+	CodeInternal         ErrorCode = "INTERNAL"           // any other failure
+)
+
+// ErrorCodeHeader is a response header with ErrorCode of failed request. Body keeps human readable message.
+const ErrorCodeHeader = "X-Error-Code"
+
+// ErrorCodeOf map typed errors of library to ErrorCode, CodeInternal for other errors
+func ErrorCodeOf(err error) ErrorCode {
+	var validityErr *ValidityPolicyError
+	var guardErr *DNSGuardError
+	var outlivesErr *OutlivesIssuerError
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &validityErr), errors.As(err, &guardErr), errors.As(err, &outlivesErr),
+		errors.Is(err, ErrReservedName):
+		return CodePolicyDenied
+	case errors.Is(err, ErrCAExpired):
+		return CodeCAExpired
+	case errors.Is(err, ErrLocked):
+		return CodeLocked
+	case errors.Is(err, ErrNotFound), errors.Is(err, os.ErrNotExist):
+		return CodeNotFound
+	case errors.Is(err, ErrReplayed):
+		return CodeReplayed
+	case errors.Is(err, ErrBadToken):
+		return CodeUnauthorized
+	default:
+		return CodeInternal
+	}
+}
+
+// HTTPStatus return http status of responses with code
+func (c ErrorCode) HTTPStatus() int {
+	switch c {
+	case CodePolicyDenied, CodeForbidden:
+		return http.StatusForbidden
+	case CodeNotFound:
+		return http.StatusNotFound
+	case CodeCAExpired, CodeReplayed:
+		return http.StatusConflict
+	case CodeLocked:
+		return http.StatusServiceUnavailable
+	case CodeBadRequest:
+		return http.StatusBadRequest
+	case CodeUnauthorized:
+		return http.StatusUnauthorized
+	case CodeMethodNotAllowed:
+		return http.StatusMethodNotAllowed
+	case CodeTooLarge:
+		return http.StatusRequestEntityTooLarge
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// httpError reply with message and status of code, code is set into ErrorCodeHeader
+func httpError(w http.ResponseWriter, code ErrorCode, message string) {
+	w.Header().Set(ErrorCodeHeader, string(code))
+	if code == CodeLocked {
+		w.Header().Set("Retry-After", "1")
+	}
+	http.Error(w, message, code.HTTPStatus())
+}
+
+// methodNotAllowed reply with allowed methods
+func methodNotAllowed(w http.ResponseWriter, allowed string) {
+	w.Header().Set("Allow", allowed)
+	httpError(w, CodeMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed))
+}
@@ -0,0 +1,78 @@
+package pki
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorCodeOf(t *testing.T) {
+	tests := []struct {
+		err  error
+		want ErrorCode
+	}{
+		{err: nil, want: ""},
+		{err: fmt.Errorf("can`t issue: %w", &ValidityPolicyError{Profile: ProfileServer}), want: CodePolicyDenied},
+		{err: &DNSGuardError{Name: "www.example.com"}, want: CodePolicyDenied},
+		{err: fmt.Errorf("ca with serial 1: %w", ErrCAExpired), want: CodeCAExpired},
+		{err: fmt.Errorf("can`t lock: %w", lockTimeout{}), want: CodeLocked},
+		{err: fmt.Errorf("ff %w", ErrNotFound), want: CodeNotFound},
+		{err: ErrReplayed, want: CodeReplayed},
+		{err: ErrBadToken, want: CodeUnauthorized},
+		{err: errors.New("disk is full"), want: CodeInternal},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, ErrorCodeOf(tt.err), fmt.Sprint(tt.err))
+	}
+	assert.Equal(t, http.StatusServiceUnavailable, CodeLocked.HTTPStatus())
+	assert.Equal(t, http.StatusInternalServerError, CodeInternal.HTTPStatus())
+}
+
+func TestErrorCodeHeader(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	now := time.Now()
+	WithClock(func() time.Time { return now })(pki)
+	_, err := pki.NewCa(NotAfter(now.Add(time.Hour)))
+	assert.NoError(t, err)
+	revoke := NewRevokeHandler(pki)
+	do := func(handler http.Handler, method, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := do(revoke, http.MethodPost, url.Values{"serial": {"ff"}}.Encode())
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, string(CodeNotFound), w.Header().Get(ErrorCodeHeader))
+	w = do(revoke, http.MethodPost, url.Values{"serial": {"x"}}.Encode())
+	assert.Equal(t, string(CodeBadRequest), w.Header().Get(ErrorCodeHeader))
+	w = do(revoke, http.MethodGet, "")
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, string(CodeMethodNotAllowed), w.Header().Get(ErrorCodeHeader))
+
+	now = now.Add(2 * time.Hour)
+	_, err = pki.NewCert("late", Client())
+	assert.ErrorIs(t, err, ErrCAExpired)
+	csr, _, err := pki.NewRequest("late", 0)
+	assert.NoError(t, err)
+	enroll, err := NewEnrollHandler(pki, ProfileClient, OpenEnrollment())
+	assert.NoError(t, err)
+	w = do(enroll, http.MethodPost, string(csr))
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, string(CodeCAExpired), w.Header().Get(ErrorCodeHeader))
+
+	w = do(revoke, http.MethodPost, url.Values{"serial": {big.NewInt(1).Text(16)}}.Encode())
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Header().Get(ErrorCodeHeader))
+}
@@ -0,0 +1,120 @@
+package pki
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/kemsta/go-easyrsa/pkg/pair"
+)
+
+// CertFilter select certificates for bulk operations
+type CertFilter func(pair *pair.X509Pair, cert *x509.Certificate) bool
+
+// IssuedBefore select certificates with NotBefore earlier than date
+func IssuedBefore(date time.Time) CertFilter {
+	return func(_ *pair.X509Pair, cert *x509.Certificate) bool {
+		return cert.NotBefore.Before(date)
+	}
+}
+
+// OrganizationalUnit select certificates with ou in subject
+func OrganizationalUnit(ou string) CertFilter {
+	return func(_ *pair.X509Pair, cert *x509.Certificate) bool {
+		for _, unit := range cert.Subject.OrganizationalUnit {
+			if unit == ou {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// CNPattern select certificates with name matching glob pattern of path.Match, e.g. "vpn-client-*".
+// Malformed pattern selects nothing.
+func CNPattern(pattern string) CertFilter {
+	return func(pair *pair.X509Pair, _ *x509.Certificate) bool {
+		matched, _ := path.Match(pattern, pair.CN)
+		return matched
+	}
+}
+
+// AllOf select certificates matching every filter
+func AllOf(filters ...CertFilter) CertFilter {
+	return func(pair *pair.X509Pair, cert *x509.Certificate) bool {
+		for _, filter := range filters {
+			if !filter(pair, cert) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// RevokeWhere revoke all not revoked leaf certificates matching filter with one CRL update, e.g. after key
+// compromise event. CA certificates are never selected. It returns revoked pairs sorted by serial.
+func (p *PKI) RevokeWhere(filter CertFilter, opts ...RevokeOption) ([]*pair.X509Pair, error) {
+	pairs, err := p.Storage.GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("can`t get pairs: %w", err)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].Serial.Cmp(pairs[j].Serial) == -1
+	})
+	revoked := p.revokedSerials(false)
+	now := time.Now()
+	selected := make([]*pair.X509Pair, 0)
+	entries := make([]pkix.RevokedCertificate, 0)
+	for _, certPair := range pairs {
+		cert, err := certPair.DecodeCert()
+		if err != nil {
+			return nil, err
+		}
+		if cert.IsCA || revoked[certPair.Serial.String()] || !filter(certPair, cert) {
+			continue
+		}
+		entry := pkix.RevokedCertificate{SerialNumber: certPair.Serial, RevocationTime: now}
+		for _, opt := range opts {
+			opt(&entry)
+		}
+		selected = append(selected, certPair)
+		entries = append(entries, entry)
+	}
+	if len(selected) == 0 {
+		return selected, nil
+	}
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		id, err := p.beginRevokeIntent(entry)
+		if err != nil {
+			p.abortIntents(ids)
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	err = p.updateCRL(func(list []pkix.RevokedCertificate) ([]pkix.RevokedCertificate, error) {
+		for _, entry := range entries {
+			list = removeHeld(list, entry.SerialNumber)
+		}
+		return append(list, entries...), nil
+	})
+	if err != nil {
+		p.abortIntents(ids)
+		return nil, err
+	}
+	for _, id := range ids {
+		if err := p.commitIntent(id); err != nil {
+			return selected, err
+		}
+	}
+	var hookErr error
+	for _, certPair := range selected {
+		if err := p.runHooks(EventRevoke, certPair); err != nil && hookErr == nil {
+			hookErr = err
+		}
+	}
+	return selected, hookErr
+}
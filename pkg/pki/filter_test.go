@@ -0,0 +1,102 @@
+package pki
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/kemsta/go-easyrsa/pkg/pair"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPKI_RevokeWhere(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	revokedByHooks := 0
+	WithHooks(EventRevoke, func(event Event) error {
+		revokedByHooks++
+		return nil
+	})(pki)
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+	ou := func(unit string) Option {
+		return func(cert *x509.Certificate) {
+			cert.Subject = pkix.Name{CommonName: cert.Subject.CommonName, OrganizationalUnit: []string{unit}}
+		}
+	}
+	ops1, err := pki.NewCert("ops1", Client(), ou("ops"))
+	assert.NoError(t, err)
+	ops2, err := pki.NewCert("ops2", Client(), ou("ops"))
+	assert.NoError(t, err)
+	_, err = pki.NewCert("dev", Client(), ou("dev"))
+	assert.NoError(t, err)
+	assert.NoError(t, pki.RevokeOne(ops2.Serial))
+	revokedByHooks = 0
+
+	got, err := pki.RevokeWhere(AllOf(OrganizationalUnit("ops"), IssuedBefore(time.Now())), Reason(ReasonKeyCompromise))
+	assert.NoError(t, err)
+	assert.Len(t, got, 1)
+	assert.Equal(t, ops1.Serial, got[0].Serial)
+	assert.Equal(t, 1, revokedByHooks)
+	assert.True(t, pki.IsRevoked(ops1.Serial))
+
+	got, err = pki.RevokeWhere(func(*pair.X509Pair, *x509.Certificate) bool { return true })
+	assert.NoError(t, err)
+	assert.Len(t, got, 1)
+	assert.Equal(t, "dev", got[0].CN)
+	list, err := pki.GetCRL()
+	assert.NoError(t, err)
+	assert.Len(t, list.TBSCertList.RevokedCertificates, 3)
+}
+
+// failingJournal fail Begin of intent number failAt
+type failingJournal struct {
+	Journal
+	begins, failAt int
+}
+
+func (j *failingJournal) Begin(intent []byte) (string, error) {
+	j.begins++
+	if j.begins == j.failAt {
+		return "", ErrInjected
+	}
+	return j.Journal.Begin(intent)
+}
+
+func TestPKI_RevokeWhere_abortIntents(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	WithJournalDir(t.TempDir())(pki)
+	journal := pki.journal
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+	for _, cn := range []string{"client1", "client2", "client3"} {
+		_, err = pki.NewCert(cn, Client())
+		assert.NoError(t, err)
+	}
+	all := func(*pair.X509Pair, *x509.Certificate) bool { return true }
+
+	pki.journal = &failingJournal{Journal: journal, failAt: 2}
+	_, err = pki.RevokeWhere(all)
+	assert.ErrorIs(t, err, ErrInjected)
+	pending, err := journal.Pending()
+	assert.NoError(t, err)
+	assert.Empty(t, pending, "intents begun before failure are dropped")
+
+	pki.journal = journal
+	crlHolder := pki.crlHolder
+	pki.crlHolder = NewChaosCRLHolder(crlHolder, Faults{ErrorRate: 1, Ops: []ChaosOp{ChaosPut}})
+	_, err = pki.RevokeWhere(all)
+	assert.ErrorIs(t, err, ErrInjected)
+	pending, err = journal.Pending()
+	assert.NoError(t, err)
+	assert.Empty(t, pending, "intents of failed crl update are dropped")
+
+	pki.crlHolder = crlHolder
+	recovered, err := pki.Recover()
+	assert.NoError(t, err)
+	assert.Zero(t, recovered)
+	assert.False(t, pki.IsRevoked(big.NewInt(2)))
+}
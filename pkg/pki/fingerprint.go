@@ -0,0 +1,71 @@
+package pki
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/kemsta/go-easyrsa/pkg/pair"
+)
+
+// ErrDuplicate is returned on attempt to store certificate which is already stored
+var ErrDuplicate = errors.New("certificate is already stored")
+
+// Fingerprint return hex encoded hash of DER certificate, sha256 unless WithFingerprintHash set other hash
+func (p *PKI) Fingerprint(cert *x509.Certificate) string {
+	return p.fingerprint(cert.Raw)
+}
+
+func (p *PKI) fingerprint(der []byte) string {
+	hash := p.fingerprintHash
+	if hash == 0 {
+		hash = crypto.SHA256
+	}
+	h := hash.New()
+	_, _ = h.Write(der)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// GetByFingerprint return pair whose certificate has fingerprint, see Fingerprint. Colons and case are ignored,
+// so fingerprints printed by openssl x509 -fingerprint are accepted. Storage is asked by index if it`s
+// a FingerprintIndex and scanned otherwise.
+func (p *PKI) GetByFingerprint(fingerprint string) (*pair.X509Pair, error) {
+	fingerprint = strings.ToLower(strings.ReplaceAll(fingerprint, ":", ""))
+	if index, ok := p.Storage.(FingerprintIndex); ok {
+		return index.GetByFingerprint(fingerprint)
+	}
+	pairs, err := p.Storage.GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("can`t get pairs: %w", err)
+	}
+	for _, certPair := range pairs {
+		if cert, err := certPair.DecodeCert(); err == nil && p.Fingerprint(cert) == fingerprint {
+			return certPair, nil
+		}
+	}
+	return nil, fmt.Errorf("fingerprint %v %w", fingerprint, ErrNotFound)
+}
+
+// RebuildFingerprintIndex rebuild fingerprint index of storage, e.g. after pairs were copied into key dir by hand.
+// There is nothing to do for storages without index.
+func (p *PKI) RebuildFingerprintIndex() error {
+	if index, ok := p.Storage.(FingerprintIndex); ok {
+		return index.RebuildFingerprints()
+	}
+	return nil
+}
+
+// storedCopy return stored pair with the same certificate as cert, nil if there is no one
+func (p *PKI) storedCopy(cert *x509.Certificate) (*pair.X509Pair, error) {
+	res, err := p.GetByFingerprint(p.Fingerprint(cert))
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can`t look up fingerprint: %w", err)
+	}
+	return res, nil
+}
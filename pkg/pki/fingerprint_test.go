@@ -0,0 +1,54 @@
+package pki
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPKI_GetByFingerprint(t *testing.T) {
+	pki, err := InitPKI(t.TempDir(), nil)
+	assert.NoError(t, err)
+	_, err = pki.NewCa()
+	assert.NoError(t, err)
+	server, err := pki.NewCert("server", Server())
+	assert.NoError(t, err)
+	cert, err := server.DecodeCert()
+	assert.NoError(t, err)
+	sum := sha256.Sum256(cert.Raw)
+	assert.Equal(t, hex.EncodeToString(sum[:]), pki.Fingerprint(cert))
+
+	found, err := pki.GetByFingerprint(strings.ToUpper(pki.Fingerprint(cert)))
+	assert.NoError(t, err)
+	assert.Equal(t, server.Serial, found.Serial)
+	client, err := pki.NewCert("client", Client())
+	assert.NoError(t, err)
+	clientCert, err := client.DecodeCert()
+	assert.NoError(t, err)
+	found, err = pki.GetByFingerprint(pki.Fingerprint(clientCert))
+	assert.NoError(t, err)
+	assert.Equal(t, "client", found.CN)
+	_, err = pki.GetByFingerprint(strings.Repeat("0", 64))
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	t.Run("scan", func(t *testing.T) {
+		scanned := NewPKI(struct{ KeyStorage }{pki.Storage}, pki.serialProvider, pki.crlHolder, pkix.Name{})
+		found, err := scanned.GetByFingerprint(pki.Fingerprint(clientCert))
+		assert.NoError(t, err)
+		assert.Equal(t, "client", found.CN)
+		_, err = scanned.GetByFingerprint(strings.Repeat("0", 64))
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+	t.Run("other hash", func(t *testing.T) {
+		WithFingerprintHash(crypto.SHA512)(pki)
+		assert.Len(t, pki.Fingerprint(cert), 128)
+		found, err := pki.GetByFingerprint(pki.Fingerprint(cert))
+		assert.NoError(t, err)
+		assert.Equal(t, server.Serial, found.Serial)
+	})
+}
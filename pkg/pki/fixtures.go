@@ -0,0 +1,139 @@
+package pki
+
+import (
+	"crypto/rsa"
+	"fmt"
+	"io"
+	"math/big"
+	mathrand "math/rand"
+	"net"
+	"os"
+	"time"
+)
+
+// FixtureTime is the fixed clock of fixture PKIs. Valid fixture certificates don`t expire for DefaultExpireYears after it.
+var FixtureTime = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Common names of fixture certificates
+const (
+	FixtureServer  = "server"  // valid server certificate for localhost and 127.0.0.1
+	FixtureClient  = "client"  // valid client certificate
+	FixtureExpired = "expired" // client certificate expired a day after FixtureTime
+	FixtureRevoked = "revoked" // revoked client certificate
+)
+
+// GenerateFixtures create small PKI in dir with ca, valid, expired and revoked certificates for integration
+// tests of other projects. Keys are generated from seed and all times are FixtureTime, so the same seed and
+// options produce the same files. dir must be empty or missing.
+func GenerateFixtures(dir string, seed int64, opts ...PKIOption) (*PKI, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("can`t read %v: %w", dir, err)
+	}
+	if len(entries) > 0 {
+		return nil, fmt.Errorf("%v isn`t empty", dir)
+	}
+	opts = append([]PKIOption{
+		WithRand(mathrand.New(mathrand.NewSource(seed))),
+		WithClock(func() time.Time { return FixtureTime }),
+		withFixtureKeys(),
+	}, opts...)
+	pki, err := InitPKI(dir, nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := pki.NewCa(); err != nil {
+		return nil, fmt.Errorf("can`t create ca: %w", err)
+	}
+	if _, err := pki.NewCert(FixtureServer, Server(), DNSNames([]string{"localhost"}),
+		IPAddresses([]net.IP{net.IPv4(127, 0, 0, 1)})); err != nil {
+		return nil, fmt.Errorf("can`t create %v cert: %w", FixtureServer, err)
+	}
+	if _, err := pki.NewCert(FixtureClient, Client()); err != nil {
+		return nil, fmt.Errorf("can`t create %v cert: %w", FixtureClient, err)
+	}
+	if _, err := pki.NewCert(FixtureExpired, Client(), NotAfter(FixtureTime.Add(24*time.Hour))); err != nil {
+		return nil, fmt.Errorf("can`t create %v cert: %w", FixtureExpired, err)
+	}
+	revoked, err := pki.NewCert(FixtureRevoked, Client())
+	if err != nil {
+		return nil, fmt.Errorf("can`t create %v cert: %w", FixtureRevoked, err)
+	}
+	if err := pki.RevokeOne(revoked.Serial); err != nil {
+		return nil, fmt.Errorf("can`t revoke %v cert: %w", FixtureRevoked, err)
+	}
+	return pki, nil
+}
+
+// withFixtureKeys generate rsa keys with fixtureRSAKey, so keys depend only on entropy from WithRand.
+// It`s used by GenerateFixtures only, never for real issuance.
+func withFixtureKeys() PKIOption {
+	return func(p *PKI) {
+		p.fixtureKeys = true
+	}
+}
+
+// fixtureRSAKey generate rsa key of bits size reading entropy in a fixed order, unlike crypto/rsa which may
+// consume it differently between Go versions. It`s for reproducible fixtures only.
+func fixtureRSAKey(random io.Reader, bits int) (*rsa.PrivateKey, error) {
+	e := big.NewInt(65537)
+	one := big.NewInt(1)
+	for {
+		primes := [2]*big.Int{}
+		for i, size := range []int{bits - bits/2, bits / 2} {
+			prime, err := fixturePrime(random, size)
+			if err != nil {
+				return nil, err
+			}
+			primes[i] = prime
+		}
+		pm1 := new(big.Int).Sub(primes[0], one)
+		qm1 := new(big.Int).Sub(primes[1], one)
+		if primes[0].Cmp(primes[1]) == 0 || new(big.Int).Mod(pm1, e).Sign() == 0 || new(big.Int).Mod(qm1, e).Sign() == 0 {
+			continue
+		}
+		n := new(big.Int).Mul(primes[0], primes[1])
+		if n.BitLen() != bits {
+			continue
+		}
+		d := new(big.Int).ModInverse(e, new(big.Int).Mul(pm1, qm1))
+		if d == nil {
+			continue
+		}
+		key := &rsa.PrivateKey{
+			PublicKey: rsa.PublicKey{N: n, E: int(e.Int64())},
+			D:         d,
+			Primes:    primes[:],
+		}
+		key.Precompute()
+		if err := key.Validate(); err != nil {
+			return nil, fmt.Errorf("can`t generate key: %w", err)
+		}
+		return key, nil
+	}
+}
+
+// fixturePrime return prime with exactly bits length and two top bits set, so product of two such primes
+// has double length
+func fixturePrime(random io.Reader, bits int) (*big.Int, error) {
+	buf := make([]byte, (bits+7)/8)
+	excess := uint(len(buf)*8 - bits)
+	res := new(big.Int)
+	for {
+		if _, err := io.ReadFull(random, buf); err != nil {
+			return nil, fmt.Errorf("can`t read entropy: %w", err)
+		}
+		buf[0] &= byte(0xff >> excess)
+		if excess < 7 {
+			buf[0] |= 0xc0 >> excess
+		} else {
+			buf[0] |= 1
+			buf[1] |= 0x80
+		}
+		buf[len(buf)-1] |= 1
+		res.SetBytes(buf)
+		if res.ProbablyPrime(20) {
+			return res, nil
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package pki
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateFixtures(t *testing.T) {
+	files := func(dir string) map[string][]byte {
+		res := map[string][]byte{}
+		assert.NoError(t, filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			content, err := os.ReadFile(path)
+			rel, _ := filepath.Rel(dir, path)
+			res[rel] = content
+			return err
+		}))
+		return res
+	}
+	first, second, other := t.TempDir(), t.TempDir(), t.TempDir()
+	pki, err := GenerateFixtures(first, 1, WithKeySize(2048))
+	assert.NoError(t, err)
+	_, err = GenerateFixtures(second, 1, WithKeySize(2048))
+	assert.NoError(t, err)
+	_, err = GenerateFixtures(other, 2, WithKeySize(2048))
+	assert.NoError(t, err)
+	assert.Equal(t, files(first), files(second))
+	assert.NotEqual(t, files(first)["crl.pem"], files(other)["crl.pem"])
+
+	_, err = GenerateFixtures(first, 1)
+	assert.Error(t, err, "dir isn`t empty")
+
+	for cn, status := range map[string]CertStatus{
+		FixtureServer:  CertStatusValid,
+		FixtureClient:  CertStatusValid,
+		FixtureExpired: CertStatusExpired,
+		FixtureRevoked: CertStatusRevoked,
+	} {
+		certs, err := pki.Find(Named(cn))
+		assert.NoError(t, err)
+		if assert.Len(t, certs, 1) {
+			assert.Equal(t, status, certs[0].Status, cn)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package pki
+
+import (
+	"fmt"
+	"time"
+)
+
+// HealthReport describe state of PKI which degrades over time, e.g. CRL growing with every revocation
+type HealthReport struct {
+	CRLSize           int       // size of pem encoded CRL in bytes, 0 if CRL wasn`t signed yet
+	CRLEntries        int       // count of CRL entries
+	CRLExpiredEntries int       // count of CRL entries of expired certificates which can be pruned
+	CRLNextUpdate     time.Time // next update of CRL, clients reject CRL after it
+}
+
+// Health return health report of PKI
+func (p *PKI) Health() (*HealthReport, error) {
+	list, err := p.GetCRL()
+	if err != nil {
+		return nil, fmt.Errorf("can`t get crl: %w", err)
+	}
+	crl, err := p.crlPEM()
+	if err != nil {
+		return nil, err
+	}
+	expired, err := p.expiredSerials(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	report := &HealthReport{
+		CRLSize:       len(crl),
+		CRLEntries:    len(list.TBSCertList.RevokedCertificates),
+		CRLNextUpdate: list.TBSCertList.NextUpdate,
+	}
+	for _, entry := range list.TBSCertList.RevokedCertificates {
+		if expired[entry.SerialNumber.String()] {
+			report.CRLExpiredEntries++
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,147 @@
+package pki
+
+import (
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// CertStatus is a revocation and expiry status of certificate. Only CertStatusValid certificates are acceptable.
+type CertStatus int
+
+const (
+	CertStatusUnknown   CertStatus = iota // there is neither stored pair nor CRL entry with serial
+	CertStatusValid                       // certificate isn`t revoked
+	CertStatusRevoked                     // certificate is revoked permanently
+	CertStatusSuspended                   // certificate is on hold and can be released with RemoveFromCRL
+	CertStatusExpired                     // certificate isn`t revoked, but its NotAfter has passed
+)
+
+var statusNames = map[CertStatus]string{
+	CertStatusUnknown:   "unknown",
+	CertStatusValid:     "valid",
+	CertStatusRevoked:   "revoked",
+	CertStatusSuspended: "suspended",
+	CertStatusExpired:   "expired",
+}
+
+func (s CertStatus) String() string {
+	if name, ok := statusNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("status(%d)", int(s))
+}
+
+// MarshalText encode status as its name, e.g. in json
+func (s CertStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decode status from its name
+func (s *CertStatus) UnmarshalText(text []byte) error {
+	for status, name := range statusNames {
+		if name == string(text) {
+			*s = status
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown certificate status %q", text)
+}
+
+// Hold suspend certificate with serial. It`s revoked with certificateHold reason until RemoveFromCRL,
+// e.g. to disable vpn access of user temporarily.
+func (p *PKI) Hold(serial *big.Int) error {
+	return p.RevokeOne(serial, Reason(ReasonCertificateHold))
+}
+
+// RemoveFromCRL release suspended certificate with serial. Only certificates on hold can be released,
+// revocation with other reasons is permanent.
+func (p *PKI) RemoveFromCRL(serial *big.Int) error {
+	status, err := p.Status(serial)
+	if err != nil {
+		return err
+	}
+	if status != CertStatusSuspended {
+		return fmt.Errorf("serial %v is %v, only suspended certificates can be released", serial, status)
+	}
+	entry := pkix.RevokedCertificate{SerialNumber: serial, RevocationTime: p.now()}
+	id, err := p.beginIntent(intentRelease, entry)
+	if err != nil {
+		return err
+	}
+	if err := p.release(serial); err != nil {
+		return err
+	}
+	if err := p.commitIntent(id); err != nil {
+		return err
+	}
+	return p.runHooks(EventRelease, p.revokedPair(serial))
+}
+
+// Unrevoke remove CRL entry of certificate with serial revoked with ReasonCertificateHold and sign CRL again.
+// It`s RemoveFromCRL under the name known from other CAs, permanent revocation can`t be undone either.
+func (p *PKI) Unrevoke(serial *big.Int) error {
+	return p.RemoveFromCRL(serial)
+}
+
+// release remove certificateHold entry with serial from CRL
+func (p *PKI) release(serial *big.Int) error {
+	return p.updateCRL(func(list []pkix.RevokedCertificate) ([]pkix.RevokedCertificate, error) {
+		for _, entry := range list {
+			if entry.SerialNumber.Cmp(serial) != 0 {
+				continue
+			}
+			if reason := revocationReason(entry); reason != ReasonCertificateHold {
+				return nil, fmt.Errorf("serial %v is revoked permanently with reason %v", serial, reason)
+			}
+		}
+		held := removeHeld(list, serial)
+		if len(held) == len(list) {
+			return nil, fmt.Errorf("serial %v is not on hold", serial)
+		}
+		return held, nil
+	})
+}
+
+// Status return revocation status of certificate with serial. Revocation takes precedence over expiry,
+// so expired certificate is reported as revoked or suspended if it has CRL entry.
+func (p *PKI) Status(serial *big.Int) (CertStatus, error) {
+	list, err := p.GetCRL()
+	if err != nil {
+		return CertStatusUnknown, fmt.Errorf("can`t get crl: %w", err)
+	}
+	for _, entry := range list.TBSCertList.RevokedCertificates {
+		if entry.SerialNumber.Cmp(serial) != 0 {
+			continue
+		}
+		if revocationReason(entry) == ReasonCertificateHold {
+			return CertStatusSuspended, nil
+		}
+		return CertStatusRevoked, nil
+	}
+	certPair, err := p.certBySerial(serial)
+	if err != nil {
+		return CertStatusUnknown, nil
+	}
+	cert, err := certPair.DecodeCert()
+	if err != nil {
+		return CertStatusUnknown, err
+	}
+	if time.Now().After(cert.NotAfter) {
+		return CertStatusExpired, nil
+	}
+	return CertStatusValid, nil
+}
+
+// removeHeld return list without certificateHold entries with serial
+func removeHeld(list []pkix.RevokedCertificate, serial *big.Int) []pkix.RevokedCertificate {
+	res := make([]pkix.RevokedCertificate, 0, len(list))
+	for _, entry := range list {
+		if entry.SerialNumber.Cmp(serial) == 0 && revocationReason(entry) == ReasonCertificateHold {
+			continue
+		}
+		res = append(res, entry)
+	}
+	return res
+}
@@ -0,0 +1,102 @@
+package pki
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPKI_Hold(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	released := 0
+	WithHooks(EventRelease, func(event Event) error {
+		released++
+		assert.Equal(t, "user", event.CN)
+		return nil
+	})(pki)
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+	user, err := pki.NewCert("user", Client())
+	assert.NoError(t, err)
+
+	status, err := pki.Status(user.Serial)
+	assert.NoError(t, err)
+	assert.Equal(t, CertStatusValid, status)
+	status, err = pki.Status(big.NewInt(100))
+	assert.NoError(t, err)
+	assert.Equal(t, CertStatusUnknown, status)
+	assert.Error(t, pki.RemoveFromCRL(user.Serial))
+
+	assert.NoError(t, pki.Hold(user.Serial))
+	status, err = pki.Status(user.Serial)
+	assert.NoError(t, err)
+	assert.Equal(t, CertStatusSuspended, status)
+	assert.True(t, pki.IsRevoked(user.Serial))
+
+	assert.NoError(t, pki.RemoveFromCRL(user.Serial))
+	assert.Equal(t, 1, released)
+	status, err = pki.Status(user.Serial)
+	assert.NoError(t, err)
+	assert.Equal(t, CertStatusValid, status)
+	assert.False(t, pki.IsRevoked(user.Serial))
+
+	assert.NoError(t, pki.Hold(user.Serial))
+	assert.NoError(t, pki.RevokeOne(user.Serial, Reason(ReasonKeyCompromise)))
+	status, err = pki.Status(user.Serial)
+	assert.NoError(t, err)
+	assert.Equal(t, CertStatusRevoked, status)
+	assert.Error(t, pki.RemoveFromCRL(user.Serial))
+	list, err := pki.GetCRL()
+	assert.NoError(t, err)
+	assert.Len(t, list.TBSCertList.RevokedCertificates, 1)
+	assert.Equal(t, ReasonKeyCompromise, revocationReason(list.TBSCertList.RevokedCertificates[0]))
+	assert.Equal(t, "suspended", CertStatusSuspended.String())
+}
+
+func TestPKI_Unrevoke(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+	user, err := pki.NewCert("user", Client())
+	assert.NoError(t, err)
+	other, err := pki.NewCert("other", Client())
+	assert.NoError(t, err)
+	assert.NoError(t, pki.RevokeOne(user.Serial, Reason(ReasonCertificateHold)))
+	assert.NoError(t, pki.RevokeOne(other.Serial))
+	held, err := pki.GetCRL()
+	assert.NoError(t, err)
+
+	assert.NoError(t, pki.Unrevoke(user.Serial))
+	assert.False(t, pki.IsRevoked(user.Serial))
+	assert.True(t, pki.IsRevoked(other.Serial))
+	list, err := pki.GetCRL()
+	assert.NoError(t, err)
+	assert.Len(t, list.TBSCertList.RevokedCertificates, 1)
+	assert.Equal(t, 1, crlNumber(list).Cmp(crlNumber(held)))
+	assert.Error(t, pki.Unrevoke(user.Serial))
+	assert.Error(t, pki.Unrevoke(other.Serial))
+}
+
+func TestPKI_StatusExpired(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+	expired, err := pki.NewCert("expired", Client(), NotAfter(time.Now().Add(-time.Hour)))
+	assert.NoError(t, err)
+
+	status, err := pki.Status(expired.Serial)
+	assert.NoError(t, err)
+	assert.Equal(t, CertStatusExpired, status)
+	assert.False(t, pki.IsRevoked(expired.Serial))
+	assert.Equal(t, "expired", status.String())
+
+	assert.NoError(t, pki.RevokeOne(expired.Serial))
+	status, err = pki.Status(expired.Serial)
+	assert.NoError(t, err)
+	assert.Equal(t, CertStatusRevoked, status)
+}
@@ -0,0 +1,133 @@
+package pki
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"os/exec"
+	"strings"
+	"text/template"
+
+	"github.com/kemsta/go-easyrsa/pkg/pair"
+)
+
+// EventType is a kind of PKI change
+type EventType string
+
+const (
+	EventIssue   EventType = "issue"   // new pair was issued, including CA pairs
+	EventRevoke  EventType = "revoke"  // pair was revoked or put on hold and crl was updated
+	EventRelease EventType = "release" // pair was released from hold and crl was updated
+)
+
+// Event describe PKI change for hooks. Paths are empty if storage is not on the file system.
+type Event struct {
+	Type     EventType
+	CN       string   // pair name in storage
+	Serial   *big.Int // pair serial
+	CertPath string   // path to certificate file
+	KeyPath  string   // path to private key file
+	CRLPath  string   // path to crl file
+}
+
+// Hook is called after PKI change
+type Hook func(Event) error
+
+// HookError is returned when operation has been completed, but some of hooks failed
+type HookError struct {
+	Event Event
+	Err   error
+}
+
+func (e *HookError) Error() string {
+	return fmt.Sprintf("%v hook for %v with serial %v failed: %v", e.Event.Type, e.Event.CN, e.Event.Serial, e.Err)
+}
+
+func (e *HookError) Unwrap() error {
+	return e.Err
+}
+
+// HookErrors is returned by operations on several pairs when hooks of some of them failed.
+// Operation has been completed for all pairs.
+type HookErrors []*HookError
+
+func (e HookErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap return all hook errors
+func (e HookErrors) Unwrap() []error {
+	res := make([]error, len(e))
+	for i, err := range e {
+		res[i] = err
+	}
+	return res
+}
+
+// PairPather is an optional KeyStorage interface for storages which keep pairs in files
+type PairPather interface {
+	PairPaths(pair *pair.X509Pair) (certPath, keyPath string) // Paths of pair files
+}
+
+// Pather is an optional CRLHolder interface for holders which keep crl in file
+type Pather interface {
+	Path() string // Path of file
+}
+
+// ExecHook return hook which run command. Command is split by spaces and every argument is a text/template
+// with Event fields, e.g. "systemctl reload openvpn@{{.CN}}" or "cp {{.CRLPath}} /etc/openvpn/crl.pem".
+// Shell is not involved, so there is no need to quote substituted values.
+func ExecHook(command string) (Hook, error) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty hook command")
+	}
+	args := make([]*template.Template, 0, len(fields))
+	for i, field := range fields {
+		tmpl, err := template.New(fmt.Sprintf("arg%v", i)).Option("missingkey=error").Parse(field)
+		if err != nil {
+			return nil, fmt.Errorf("can`t parse hook argument %q: %w", field, err)
+		}
+		args = append(args, tmpl)
+	}
+	return func(event Event) error {
+		rendered := make([]string, 0, len(args))
+		for _, arg := range args {
+			var buf bytes.Buffer
+			if err := arg.Execute(&buf, event); err != nil {
+				return fmt.Errorf("can`t render hook argument: %w", err)
+			}
+			rendered = append(rendered, buf.String())
+		}
+		out, err := exec.Command(rendered[0], rendered[1:]...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%v: %w: %s", strings.Join(rendered, " "), err, bytes.TrimSpace(out))
+		}
+		return nil
+	}, nil
+}
+
+// runHooks call hooks for event type. Pair is used to fill event fields.
+func (p *PKI) runHooks(eventType EventType, certPair *pair.X509Pair) error {
+	hooks := p.hooks[eventType]
+	if len(hooks) == 0 {
+		return nil
+	}
+	event := Event{Type: eventType, CN: certPair.CN, Serial: certPair.Serial}
+	if pather, ok := p.Storage.(PairPather); ok && certPair.CN != "" {
+		event.CertPath, event.KeyPath = pather.PairPaths(certPair)
+	}
+	if pather, ok := p.crlHolder.(Pather); ok {
+		event.CRLPath = pather.Path()
+	}
+	for _, hook := range hooks {
+		if err := hook(event); err != nil {
+			return &HookError{Event: event, Err: err}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package pki
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecHook(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		_, err := ExecHook(" ")
+		assert.Error(t, err)
+	})
+	t.Run("bad template", func(t *testing.T) {
+		_, err := ExecHook("echo {{.CN")
+		assert.Error(t, err)
+	})
+	t.Run("success", func(t *testing.T) {
+		hook, err := ExecHook("go env {{.CN}}")
+		assert.NoError(t, err)
+		assert.NoError(t, hook(Event{Type: EventIssue, CN: "GOOS", Serial: big.NewInt(1)}))
+	})
+	t.Run("failed command", func(t *testing.T) {
+		hook, err := ExecHook("go {{.CN}}")
+		assert.NoError(t, err)
+		assert.Error(t, hook(Event{Type: EventIssue, CN: "not-a-command"}))
+	})
+}
+
+func TestPKI_Hooks(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	var events []Event
+	recorder := func(event Event) error {
+		events = append(events, event)
+		return nil
+	}
+	WithHooks(EventIssue, recorder)(pki)
+	WithHooks(EventRevoke, recorder)(pki)
+	_, _ = pki.NewCa()
+	server, err := pki.NewCert("server", Server())
+	assert.NoError(t, err)
+	WithSerialFileNames(4, true)(pki)
+	assert.NoError(t, pki.RevokeOne(server.Serial))
+	assert.Len(t, events, 3)
+	assert.Equal(t, EventIssue, events[1].Type)
+	assert.Equal(t, "server", events[1].CN)
+	assert.FileExists(t, events[1].CertPath)
+	assert.FileExists(t, events[1].KeyPath)
+	assert.Equal(t, EventRevoke, events[2].Type)
+	assert.Equal(t, server.Serial, events[2].Serial)
+	assert.Equal(t, events[1].CertPath, events[2].CertPath)
+	assert.FileExists(t, events[2].KeyPath)
+	assert.FileExists(t, events[2].CRLPath)
+
+	t.Run("failed hook", func(t *testing.T) {
+		WithHooks(EventIssue, func(event Event) error {
+			return errors.New("reload failed")
+		})(pki)
+		got, err := pki.NewCert("client", Client())
+		var hookErr *HookError
+		assert.True(t, errors.As(err, &hookErr))
+		assert.NotNil(t, got)
+		assert.Equal(t, "client", hookErr.Event.CN)
+	})
+}
+
+func TestPKI_RevokeAllByCNHookErrors(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+	first, err := pki.NewCert("client")
+	assert.NoError(t, err)
+	second, err := pki.NewCert("client")
+	assert.NoError(t, err)
+	WithHooks(EventRevoke, func(event Event) error {
+		return errors.New("reload failed")
+	})(pki)
+
+	err = pki.RevokeAllByCN("client")
+	var hookErrs HookErrors
+	if assert.True(t, errors.As(err, &hookErrs)) {
+		assert.Len(t, hookErrs, 2)
+	}
+	var hookErr *HookError
+	assert.True(t, errors.As(err, &hookErr))
+	assert.True(t, pki.IsRevoked(first.Serial))
+	assert.True(t, pki.IsRevoked(second.Serial))
+}
@@ -0,0 +1,113 @@
+package pki
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// MIME types of served PKI files
+const (
+	MIMEPKIXCert = "application/pkix-cert"  // DER certificate, RFC 2585
+	MIMEPKIXCRL  = "application/pkix-crl"   // DER crl, RFC 2585
+	MIMEPEMFile  = "application/x-pem-file" // PEM bundle
+)
+
+// Handler serve public PKI files over http, so devices can bootstrap trust by URL:
+//
+//	/ca.crt     certificate of the last CA as DER or as PEM if client accepts application/x-pem-file first
+//	/ca.der     certificate of the last CA as DER
+//	/chain.pem  all non-expired CA and intermediate certificates as PEM
+//	/crl.pem    current crl as PEM
+type Handler struct {
+	pki *PKI
+	mux *http.ServeMux
+}
+
+// NewHandler return http handler of PKI files
+func NewHandler(p *PKI) *Handler {
+	h := &Handler{pki: p, mux: http.NewServeMux()}
+	h.mux.HandleFunc("/"+PublishCACert, h.serveCA)
+	h.mux.HandleFunc("/ca.der", h.serveCA)
+	h.mux.HandleFunc("/"+PublishChain, h.serveChain)
+	h.mux.HandleFunc("/"+PublishCRL, h.serveCRL)
+	return h
+}
+
+// ServeHTTP implement http.Handler. Only GET and HEAD requests are allowed.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		methodNotAllowed(w, "GET, HEAD")
+		return
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) serveCA(w http.ResponseWriter, r *http.Request) {
+	ca, err := h.pki.GetLastCA()
+	if err != nil {
+		serverError(w, fmt.Errorf("can`t get ca: %w", err))
+		return
+	}
+	if r.URL.Path == "/"+PublishCACert && preferPEM(r) {
+		writeContent(w, MIMEPEMFile, ca.CertPemBytes)
+		return
+	}
+	cert, err := ca.DecodeCert()
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	writeContent(w, MIMEPKIXCert, cert.Raw)
+}
+
+func (h *Handler) serveChain(w http.ResponseWriter, _ *http.Request) {
+	chain, err := h.pki.GetTrustBundle()
+	if err != nil {
+		serverError(w, fmt.Errorf("can`t get chain: %w", err))
+		return
+	}
+	writeContent(w, MIMEPEMFile, chain)
+}
+
+func (h *Handler) serveCRL(w http.ResponseWriter, r *http.Request) {
+	crl, err := h.pki.crlPEM()
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	if crl == nil {
+		http.NotFound(w, r)
+		return
+	}
+	writeContent(w, MIMEPEMFile, crl)
+}
+
+// preferPEM return true if PEM certificate is listed in Accept header before DER one
+func preferPEM(r *http.Request) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case MIMEPEMFile:
+			return true
+		case MIMEPKIXCert:
+			return false
+		}
+	}
+	return false
+}
+
+func writeContent(w http.ResponseWriter, contentType string, content []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+	_, _ = w.Write(content)
+}
+
+// serverError reply with err, status and code are mapped from err by ErrorCodeOf
+func serverError(w http.ResponseWriter, err error) {
+	httpError(w, ErrorCodeOf(err), err.Error())
+}
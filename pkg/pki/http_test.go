@@ -0,0 +1,63 @@
+package pki
+
+import (
+	"bytes"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	handler := NewHandler(pki)
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	rec := get("/ca.crt", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, string(CodeNotFound), rec.Header().Get(ErrorCodeHeader))
+
+	ca, err := pki.NewCa()
+	assert.NoError(t, err)
+	caCert, err := ca.DecodeCert()
+	assert.NoError(t, err)
+
+	for _, path := range []string{"/ca.crt", "/ca.der"} {
+		rec := get(path, "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, MIMEPKIXCert, rec.Header().Get("Content-Type"))
+		cert, err := x509.ParseCertificate(rec.Body.Bytes())
+		assert.NoError(t, err)
+		assert.True(t, cert.Equal(caCert))
+	}
+	rec = get("/ca.crt", "application/x-pem-file, application/pkix-cert;q=0.5")
+	assert.Equal(t, MIMEPEMFile, rec.Header().Get("Content-Type"))
+	assert.Equal(t, ca.CertPemBytes, rec.Body.Bytes())
+	assert.Equal(t, MIMEPKIXCert, get("/ca.crt", "application/pkix-cert, application/x-pem-file").Header().Get("Content-Type"))
+	assert.Equal(t, MIMEPKIXCert, get("/ca.der", "application/x-pem-file").Header().Get("Content-Type"))
+
+	rec = get("/chain.pem", "")
+	assert.Equal(t, MIMEPEMFile, rec.Header().Get("Content-Type"))
+	assert.True(t, bytes.Contains(rec.Body.Bytes(), ca.CertPemBytes))
+
+	assert.Equal(t, http.StatusNotFound, get("/crl.pem", "").Code)
+	client, err := pki.NewCert("client")
+	assert.NoError(t, err)
+	assert.NoError(t, pki.RevokeOne(client.Serial))
+	assert.Equal(t, http.StatusOK, get("/crl.pem", "").Code)
+
+	assert.Equal(t, http.StatusNotFound, get("/ca.key", "").Code)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/ca.crt", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
@@ -0,0 +1,57 @@
+package pki
+
+import (
+	"bytes"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPKI_InternationalizedNames(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+	tests := []struct {
+		cn string
+		dn string
+	}{
+		{cn: "Иван Петров", dn: `/CN=\xD0\x98\xD0\xB2\xD0\xB0\xD0\xBD \xD0\x9F\xD0\xB5\xD1\x82\xD1\x80\xD0\xBE\xD0\xB2`},
+		{cn: "张伟", dn: `/CN=\xE5\xBC\xA0\xE4\xBC\x9F`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cn, func(t *testing.T) {
+			issued, err := pki.NewCert(tt.cn)
+			assert.NoError(t, err)
+			assert.DirExists(t, filepath.Join(testData, tt.cn))
+			stored, err := pki.Storage.GetLastByCn(tt.cn)
+			assert.NoError(t, err)
+			cert, err := stored.DecodeCert()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.cn, cert.Subject.CommonName)
+			utf8CN := append([]byte{asn1.TagUTF8String, byte(len(tt.cn))}, tt.cn...)
+			assert.True(t, bytes.Contains(cert.RawSubject, utf8CN), "cn must be UTF8String")
+
+			index, err := pki.Index()
+			assert.NoError(t, err)
+			var buf bytes.Buffer
+			assert.NoError(t, index.Encode(&buf))
+			decoded := &Index{}
+			assert.NoError(t, decoded.Decode(&buf))
+			assert.Equal(t, index, decoded)
+			for _, entry := range decoded.Entries {
+				if entry.Serial.Cmp(issued.Serial) == 0 {
+					assert.Equal(t, tt.dn, entry.DN)
+				}
+			}
+		})
+	}
+}
+
+func Test_oneLineDNEscaping(t *testing.T) {
+	name := pkix.Name{Organization: []string{`a/b\c`}, CommonName: "Ω"}
+	assert.Equal(t, `/O=a\x2Fb\x5Cc/CN=\xCE\xA9`, oneLineDN(name))
+}
@@ -0,0 +1,73 @@
+package pki
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Profile is a named set of certificate options for typical certificate usages.
+// Options of built-in profiles can be overridden and own profiles can be added with WithProfile.
+type Profile string
+
+const (
+	ProfileNone   Profile = ""       // no additional usages
+	ProfileServer Profile = "server" // tls server usages. See Server()
+	ProfileClient Profile = "client" // tls client usages. See Client()
+)
+
+func (p Profile) options() ([]Option, error) {
+	switch p {
+	case ProfileNone:
+		return nil, nil
+	case ProfileServer:
+		return []Option{Server()}, nil
+	case ProfileClient:
+		return []Option{Client()}, nil
+	}
+	return nil, fmt.Errorf("unknown profile %q", string(p))
+}
+
+// Identity describe certificate subject: common name, subject alternative names and usage profile.
+// Name is a storage key for identity pairs. It can be omitted, common name or the first SAN will be used
+// in this case, so SAN-only certificates with empty common name are possible.
+type Identity struct {
+	Name        string   // storage key
+	CommonName  string   // subject common name
+	DNSNames    []string // dns subject alternative names
+	IPAddresses []net.IP // ip subject alternative names
+	Profile     Profile  // usage profile
+}
+
+// Key return name which identity pairs are stored with
+func (i Identity) Key() string {
+	switch {
+	case i.Name != "":
+		return i.Name
+	case i.CommonName != "":
+		return i.CommonName
+	case len(i.DNSNames) > 0:
+		return sanKeyReplacer.Replace(i.DNSNames[0])
+	case len(i.IPAddresses) > 0:
+		return sanKeyReplacer.Replace(i.IPAddresses[0].String())
+	}
+	return ""
+}
+
+// sanKeyReplacer replace wildcards and ipv6 colons which can't be used in file names on all platforms
+var sanKeyReplacer = strings.NewReplacer("*", "_", ":", "_")
+
+// options return certificate options of identity with options of its profile
+func (i Identity) options(profileOpts []Option) ([]CertificateOption, error) {
+	if i.Key() == "" {
+		return nil, fmt.Errorf("identity has neither name, common name nor subject alternative names")
+	}
+	opts := append(certificateOptions(profileOpts), CN(i.CommonName))
+	if len(i.DNSNames) > 0 {
+		opts = append(opts, DNSNames(i.DNSNames))
+	}
+	if len(i.IPAddresses) > 0 {
+		opts = append(opts, IPAddresses(i.IPAddresses))
+	}
+	return opts, nil
+}
@@ -0,0 +1,151 @@
+package pki
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kemsta/go-easyrsa/pkg/pair"
+)
+
+// ImportCA adopt an existing CA, e.g. a root generated by openssl or easy-rsa. Key is PKCS#1, SEC 1 or PKCS#8
+// RSA or ECDSA key, encrypted one is decrypted with passphrase from WithCAPassphrase.
+// Certificate should be a valid CA certificate of the key. It`s stored with CA name and serial provider is advanced
+// past its serial if it supports SerialAdvancer. Next certs are signed by the CA with the greatest serial, see GetLastCA,
+// so imported CA with smaller serial than stored CAs signs only certs issued with IssuedBy its serial.
+func (p *PKI) ImportCA(keyPEM, certPEM []byte) (*pair.X509Pair, error) {
+	certBlock, cert, err := parseCACert(certPEM)
+	if err != nil {
+		return nil, err
+	}
+	caKeyPEM, err := p.importedKey(keyPEM, cert)
+	if err != nil {
+		return nil, err
+	}
+	return p.storeImportedCA(caKeyPEM, certBlock, cert)
+}
+
+// importedKey check that key is the key of imported CA certificate and encode it like keys of generated CAs
+func (p *PKI) importedKey(keyPEM []byte, cert *x509.Certificate) ([]byte, error) {
+	plainPEM, encrypted, err := p.decryptKeyPEM(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	if encrypted {
+		defer pair.Wipe(plainPEM)
+	}
+	key, err := parseCAKey(plainPEM)
+	if err != nil {
+		return nil, fmt.Errorf("can`t parse ca key: %w", err)
+	}
+	defer pair.WipeKey(key)
+	if public, ok := cert.PublicKey.(interface{ Equal(crypto.PublicKey) bool }); !ok || !public.Equal(key.Public()) {
+		return nil, fmt.Errorf("ca %v with serial %v doesn`t match the key", cert.Subject.CommonName, cert.SerialNumber)
+	}
+	return p.encodeCAKey(key)
+}
+
+// ImportCaCert adopt CA certificate without key, whose key is used by signer from WithSigner,
+// e.g. one kept in KMS. It`s stored and picked for signing like ImportCA does.
+func (p *PKI) ImportCaCert(certPEM []byte) (*pair.X509Pair, error) {
+	if p.signer == nil {
+		return nil, errors.New("can`t import ca cert without key: no signer")
+	}
+	certBlock, cert, err := parseCACert(certPEM)
+	if err != nil {
+		return nil, err
+	}
+	if public, ok := cert.PublicKey.(interface{ Equal(crypto.PublicKey) bool }); !ok || !public.Equal(p.signer.Public()) {
+		return nil, fmt.Errorf("ca %v with serial %v: %w", cert.Subject.CommonName, cert.SerialNumber, ErrSignerMismatch)
+	}
+	return p.storeImportedCA(nil, certBlock, cert)
+}
+
+// parseCACert parse pem certificate and check that it`s a valid CA
+func parseCACert(certPEM []byte) (*pem.Block, *x509.Certificate, error) {
+	certBlock, _ := pem.Decode(certPEM)
+	if certBlock == nil || certBlock.Type != PEMCertificateBlock {
+		return nil, nil, errors.New("can`t parse ca cert: no certificate pem block")
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("can`t parse ca cert: %w", err)
+	}
+	if !cert.BasicConstraintsValid || !cert.IsCA {
+		return nil, nil, fmt.Errorf("cert %v with serial %v is not a ca", cert.Subject.CommonName, cert.SerialNumber)
+	}
+	if time.Now().After(cert.NotAfter) {
+		return nil, nil, fmt.Errorf("ca %v with serial %v expired at %v", cert.Subject.CommonName, cert.SerialNumber, cert.NotAfter)
+	}
+	return certBlock, cert, nil
+}
+
+// storeImportedCA put imported CA pair with CA name and advance serial past it
+func (p *PKI) storeImportedCA(keyPEM []byte, certBlock *pem.Block, cert *x509.Certificate) (*pair.X509Pair, error) {
+	unlock, err := p.lock(p.CAName())
+	if err != nil {
+		return nil, fmt.Errorf("can`t lock ca creation: %w", err)
+	}
+	defer unlock()
+	if existing, err := p.storedCopy(cert); err != nil {
+		return nil, err
+	} else if existing != nil {
+		return nil, fmt.Errorf("ca %v with serial %v is stored as %v: %w",
+			cert.Subject.CommonName, cert.SerialNumber, existing.CN, ErrDuplicate)
+	}
+	if existing, err := p.Storage.GetBySerial(cert.SerialNumber); err == nil {
+		return nil, fmt.Errorf("serial %v is already used by %v", cert.SerialNumber, existing.CN)
+	}
+	if advancer, ok := p.serialProvider.(SerialAdvancer); ok {
+		if err := advancer.AdvanceTo(cert.SerialNumber); err != nil {
+			return nil, fmt.Errorf("can`t advance serial: %w", err)
+		}
+	}
+	res := pair.NewX509Pair(keyPEM, pem.EncodeToMemory(certBlock), p.CAName(), cert.SerialNumber)
+	if err := p.Storage.Put(res); err != nil {
+		return nil, fmt.Errorf("can't put imported ca into storage: %w", err)
+	}
+	if err := p.exportIndex(); err != nil {
+		return nil, fmt.Errorf("can`t export index: %w", err)
+	}
+	return res, nil
+}
+
+// parseCAKey parse PKCS#1, SEC 1 or PKCS#8 pem encoded rsa or ecdsa key. Blocks before key block,
+// like EC PARAMETERS written by openssl ecparam, are skipped.
+func parseCAKey(keyPEM []byte) (crypto.Signer, error) {
+	var block *pem.Block
+	for {
+		block, keyPEM = pem.Decode(keyPEM)
+		if block == nil {
+			return nil, errors.New("no private key pem block")
+		}
+		if strings.HasSuffix(block.Type, "PRIVATE KEY") {
+			break
+		}
+	}
+	defer pair.Wipe(block.Bytes)
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("neither PKCS#1, SEC 1 nor PKCS#8 key: %w", err)
+	}
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return key, nil
+	case *ecdsa.PrivateKey:
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %T, only rsa and ecdsa keys are supported", key)
+}
@@ -0,0 +1,135 @@
+package pki
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func foreignCA(t *testing.T, serial *big.Int, isCA bool) (keyPEM, certPEM []byte) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "Foreign Root"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer}),
+		pem.EncodeToMemory(&pem.Block{Type: PEMCertificateBlock, Bytes: der})
+}
+
+func TestPKI_ImportCA(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	serial, _ := new(big.Int).SetString("4f1c2a9be0d3a5c7e9f10b2d4e6a8c0e1f3a5b7d", 16)
+
+	t.Run("not ca", func(t *testing.T) {
+		keyPEM, certPEM := foreignCA(t, big.NewInt(1), false)
+		_, err := pki.ImportCA(keyPEM, certPEM)
+		assert.Error(t, err)
+	})
+	t.Run("key mismatch", func(t *testing.T) {
+		_, certPEM := foreignCA(t, big.NewInt(1), true)
+		otherKey, _ := foreignCA(t, big.NewInt(1), true)
+		_, err := pki.ImportCA(otherKey, certPEM)
+		assert.Error(t, err)
+	})
+	t.Run("success", func(t *testing.T) {
+		keyPEM, certPEM := foreignCA(t, serial, true)
+		ca, err := pki.ImportCA(keyPEM, certPEM)
+		assert.NoError(t, err)
+		assert.Equal(t, "ca", ca.CN)
+		assert.Equal(t, 0, serial.Cmp(ca.Serial))
+		_, err = pki.ImportCA(keyPEM, certPEM)
+		assert.ErrorIs(t, err, ErrDuplicate)
+
+		cert, err := pki.NewCert("client", Client())
+		assert.NoError(t, err)
+		assert.Equal(t, 0, new(big.Int).Add(serial, big.NewInt(1)).Cmp(cert.Serial))
+		_, caCert, err := ca.Decode()
+		assert.NoError(t, err)
+		_, clientCert, err := cert.Decode()
+		assert.NoError(t, err)
+		assert.NoError(t, clientCert.CheckSignatureFrom(caCert))
+		assert.NoError(t, pki.RevokeOne(cert.Serial))
+		assert.True(t, pki.IsRevoked(cert.Serial))
+	})
+	t.Run("ecdsa key of openssl ecparam", func(t *testing.T) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		assert.NoError(t, err)
+		template := &x509.Certificate{
+			SerialNumber:          big.NewInt(0x500),
+			Subject:               pkix.Name{CommonName: "EC Root"},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(time.Hour),
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+			KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+		assert.NoError(t, err)
+		keyDer, err := x509.MarshalECPrivateKey(key)
+		assert.NoError(t, err)
+		keyPEM := append(pem.EncodeToMemory(&pem.Block{Type: "EC PARAMETERS", Bytes: []byte{6, 8, 42, 134, 72, 206, 61, 3, 1, 7}}),
+			pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})...)
+		ca, err := pki.ImportCA(keyPEM, pem.EncodeToMemory(&pem.Block{Type: PEMCertificateBlock, Bytes: der}))
+		assert.NoError(t, err)
+		last, err := pki.GetLastCA()
+		assert.NoError(t, err)
+		assert.Equal(t, 0, serial.Cmp(last.Serial), "ca with smaller serial doesn`t become the last one")
+		caCert, err := ca.DecodeCert()
+		assert.NoError(t, err)
+		cert, err := pki.Issue(Identity{CommonName: "server"}, Server(), IssuedBy(ca.Serial))
+		assert.NoError(t, err)
+		serverCert, err := cert.DecodeCert()
+		assert.NoError(t, err)
+		assert.NoError(t, serverCert.CheckSignatureFrom(caCert))
+	})
+}
+
+func TestPKI_ImportCaCert(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	keyPEM, certPEM := foreignCA(t, big.NewInt(100), true)
+	_, err := pki.ImportCaCert(certPEM)
+	assert.Error(t, err)
+
+	key, err := parseCAKey(keyPEM)
+	assert.NoError(t, err)
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	WithSigner(other)(pki)
+	_, err = pki.ImportCaCert(certPEM)
+	assert.ErrorIs(t, err, ErrSignerMismatch)
+
+	WithSigner(key)(pki)
+	ca, err := pki.ImportCaCert(certPEM)
+	assert.NoError(t, err)
+	assert.Empty(t, ca.KeyPemBytes)
+	caCert, err := ca.DecodeCert()
+	assert.NoError(t, err)
+
+	client, err := pki.NewCert("client")
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(101), client.Serial)
+	clientCert, err := client.DecodeCert()
+	assert.NoError(t, err)
+	assert.NoError(t, clientCert.CheckSignatureFrom(caCert))
+}
@@ -0,0 +1,273 @@
+package pki
+
+import (
+	"bufio"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+)
+
+// IndexStatus is a certificate status in openssl index file
+type IndexStatus byte
+
+const (
+	StatusValid   IndexStatus = 'V' // valid certificate
+	StatusRevoked IndexStatus = 'R' // revoked certificate
+	StatusExpired IndexStatus = 'E' // expired certificate
+)
+
+const (
+	indexUTCTimeFormat         = "060102150405Z"
+	indexGeneralizedTimeFormat = "20060102150405Z"
+	indexUnknownFilename       = "unknown"
+	indexReasonKeyTime         = "keyTime" // openssl name for keyCompromise reason with compromise time
+)
+
+// IndexEntry is a line of openssl index file
+type IndexEntry struct {
+	Status         IndexStatus
+	Expiry         time.Time
+	Revocation     time.Time        // zero if not revoked
+	Reason         RevocationReason // revocation reason
+	InvalidityDate time.Time        // key compromise time, zero if unknown
+	Serial         *big.Int
+	Filename       string   // openssl always write "unknown" here
+	DN             string   // subject in openssl oneline format: /C=../O=../CN=..
+	Extra          []string // unknown trailing fields of some openssl variants, encoded back verbatim
+}
+
+// Index is an openssl compatible certificate database (index.txt). It's consumable by openssl ocsp responder
+// and other tools which can read easy-rsa pki.
+type Index struct {
+	Entries []IndexEntry
+}
+
+// Encode index in openssl format
+func (i *Index) Encode(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, entry := range i.Entries {
+		revocation := ""
+		if !entry.Revocation.IsZero() {
+			revocation = formatIndexTime(entry.Revocation)
+			switch {
+			case !entry.InvalidityDate.IsZero():
+				revocation += "," + indexReasonKeyTime + "," + entry.InvalidityDate.UTC().Format(indexGeneralizedTimeFormat)
+			case entry.Reason != ReasonUnspecified:
+				revocation += "," + entry.Reason.String()
+			}
+		}
+		filename := entry.Filename
+		if filename == "" {
+			filename = indexUnknownFilename
+		}
+		extra := ""
+		if len(entry.Extra) != 0 {
+			extra = "\t" + strings.Join(entry.Extra, "\t")
+		}
+		if _, err := fmt.Fprintf(bw, "%c\t%s\t%s\t%s\t%s\t%s%s\n", entry.Status, formatIndexTime(entry.Expiry),
+			revocation, formatIndexSerial(entry.Serial), filename, entry.DN, extra); err != nil {
+			return fmt.Errorf("can`t write index entry %v: %w", entry.Serial, err)
+		}
+	}
+	return bw.Flush()
+}
+
+// Decode index in openssl format. Fields after subject are kept in Extra, so foreign index survives
+// decode and encode round trip.
+func (i *Index) Decode(r io.Reader) error {
+	entries := make([]IndexEntry, 0)
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		entry, err := parseIndexEntry(scanner.Text())
+		if err != nil {
+			return fmt.Errorf("can`t parse index line %v: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("can`t read index: %w", err)
+	}
+	i.Entries = entries
+	return nil
+}
+
+func parseIndexEntry(line string) (IndexEntry, error) {
+	fields := strings.Split(line, "\t")
+	if len(fields) < 6 {
+		return IndexEntry{}, fmt.Errorf("wrong fields count %v", len(fields))
+	}
+	entry := IndexEntry{Filename: fields[4], DN: fields[5]}
+	if len(fields) > 6 {
+		entry.Extra = fields[6:]
+	}
+	if len(fields[0]) != 1 {
+		return entry, fmt.Errorf("bad status %q", fields[0])
+	}
+	entry.Status = IndexStatus(fields[0][0])
+	var err error
+	if entry.Expiry, err = parseIndexTime(fields[1]); err != nil {
+		return entry, fmt.Errorf("bad expiry: %w", err)
+	}
+	if fields[2] != "" {
+		revocation := strings.Split(fields[2], ",")
+		if entry.Revocation, err = parseIndexTime(revocation[0]); err != nil {
+			return entry, fmt.Errorf("bad revocation time: %w", err)
+		}
+		if len(revocation) > 1 {
+			if revocation[1] == indexReasonKeyTime && len(revocation) > 2 {
+				entry.Reason = ReasonKeyCompromise
+				if entry.InvalidityDate, err = parseIndexTime(revocation[2]); err != nil {
+					return entry, fmt.Errorf("bad compromise time: %w", err)
+				}
+			} else if entry.Reason, err = ParseRevocationReason(revocation[1]); err != nil {
+				return entry, err
+			}
+		}
+	}
+	serial, ok := new(big.Int).SetString(fields[3], 16)
+	if !ok {
+		return entry, fmt.Errorf("bad serial %q", fields[3])
+	}
+	entry.Serial = serial
+	return entry, nil
+}
+
+// formatIndexTime format time as asn1 UTCTime before 2050 and GeneralizedTime after like openssl does
+func formatIndexTime(t time.Time) string {
+	t = t.UTC()
+	if t.Year() >= 2050 {
+		return t.Format(indexGeneralizedTimeFormat)
+	}
+	return t.Format(indexUTCTimeFormat)
+}
+
+func parseIndexTime(value string) (time.Time, error) {
+	if len(value) == len(indexGeneralizedTimeFormat) {
+		return time.Parse(indexGeneralizedTimeFormat, value)
+	}
+	return time.Parse(indexUTCTimeFormat, value)
+}
+
+// formatIndexSerial format serial as even length upper case hex like openssl does
+func formatIndexSerial(serial *big.Int) string {
+	res := strings.ToUpper(serial.Text(16))
+	if len(res)%2 != 0 {
+		res = "0" + res
+	}
+	return res
+}
+
+// oneLineDN format subject in openssl oneline format. Like openssl, bytes of values outside printable ASCII are
+// escaped as \xHH, e.g. utf-8 of non-latin names. Slash and backslash are escaped too, so values can`t
+// be confused with separators.
+func oneLineDN(name pkix.Name) string {
+	var b strings.Builder
+	for _, atv := range name.ToRDNSequence() {
+		for _, attr := range atv {
+			key, ok := oneLineDNKeys[attr.Type.String()]
+			if !ok {
+				key = attr.Type.String()
+			}
+			b.WriteString("/" + key + "=" + escapeDNValue(fmt.Sprint(attr.Value)))
+		}
+	}
+	return b.String()
+}
+
+func escapeDNValue(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c < 0x20 || c > 0x7e || c == '/' || c == '\\' {
+			fmt.Fprintf(&b, "\\x%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+var oneLineDNKeys = map[string]string{
+	asn1.ObjectIdentifier{2, 5, 4, 6}.String():                       "C",
+	asn1.ObjectIdentifier{2, 5, 4, 8}.String():                       "ST",
+	asn1.ObjectIdentifier{2, 5, 4, 7}.String():                       "L",
+	asn1.ObjectIdentifier{2, 5, 4, 10}.String():                      "O",
+	asn1.ObjectIdentifier{2, 5, 4, 11}.String():                      "OU",
+	asn1.ObjectIdentifier{2, 5, 4, 3}.String():                       "CN",
+	asn1.ObjectIdentifier{2, 5, 4, 5}.String():                       "serialNumber",
+	asn1.ObjectIdentifier{2, 5, 4, 9}.String():                       "street",
+	asn1.ObjectIdentifier{2, 5, 4, 17}.String():                      "postalCode",
+	asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 1}.String():       "emailAddress",
+	asn1.ObjectIdentifier{0, 9, 2342, 19200300, 100, 1, 25}.String(): "DC",
+}
+
+// Index build openssl compatible index of all pairs in storage with their revocation status
+func (p *PKI) Index() (*Index, error) {
+	pairs, err := p.allCerts()
+	if err != nil {
+		return nil, fmt.Errorf("can`t get pairs: %w", err)
+	}
+	revoked := map[string]pkix.RevokedCertificate{}
+	if list, err := p.GetCRL(); err == nil {
+		for _, entry := range list.TBSCertList.RevokedCertificates {
+			revoked[entry.SerialNumber.String()] = entry
+		}
+	}
+	now := time.Now()
+	res := &Index{Entries: make([]IndexEntry, 0, len(pairs))}
+	for _, certPair := range pairs {
+		cert, err := certPair.DecodeCert()
+		if err != nil {
+			return nil, fmt.Errorf("can`t decode %v cert: %w", certPair.CN, err)
+		}
+		entry := IndexEntry{
+			Status:   StatusValid,
+			Expiry:   cert.NotAfter,
+			Serial:   cert.SerialNumber,
+			Filename: indexUnknownFilename,
+			DN:       oneLineDN(cert.Subject),
+		}
+		if revokedEntry, ok := revoked[cert.SerialNumber.String()]; ok {
+			entry.Status = StatusRevoked
+			entry.Revocation = revokedEntry.RevocationTime
+			entry.Reason = revocationReason(revokedEntry)
+			entry.InvalidityDate, _ = invalidityDate(revokedEntry)
+		} else if now.After(cert.NotAfter) {
+			entry.Status = StatusExpired
+		}
+		res.Entries = append(res.Entries, entry)
+	}
+	sort.Slice(res.Entries, func(i, j int) bool {
+		return res.Entries[i].Serial.Cmp(res.Entries[j].Serial) == -1
+	})
+	return res, nil
+}
+
+// exportIndex regenerate index in index holder if it's configured
+func (p *PKI) exportIndex() error {
+	if p.indexHolder == nil {
+		return nil
+	}
+	index, err := p.Index()
+	if err != nil {
+		return err
+	}
+	var buf strings.Builder
+	if err := index.Encode(&buf); err != nil {
+		return err
+	}
+	if err := p.indexHolder.Put([]byte(buf.String())); err != nil {
+		return fmt.Errorf("can`t put index: %w", err)
+	}
+	return nil
+}
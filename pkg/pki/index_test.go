@@ -0,0 +1,108 @@
+package pki
+
+import (
+	"bytes"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndex_EncodeDecode(t *testing.T) {
+	index := &Index{Entries: []IndexEntry{
+		{
+			Status:   StatusValid,
+			Expiry:   time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC),
+			Serial:   big.NewInt(1),
+			Filename: "unknown",
+			DN:       "/CN=ca",
+		},
+		{
+			Status:     StatusRevoked,
+			Expiry:     time.Date(2125, 1, 2, 3, 4, 5, 0, time.UTC),
+			Revocation: time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC),
+			Reason:     ReasonSuperseded,
+			Serial:     big.NewInt(171),
+			Filename:   "unknown",
+			DN:         "/O=org/CN=server",
+		},
+		{
+			Status:         StatusRevoked,
+			Expiry:         time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC),
+			Revocation:     time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC),
+			Reason:         ReasonKeyCompromise,
+			InvalidityDate: time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC),
+			Serial:         big.NewInt(4096),
+			Filename:       "unknown",
+			DN:             "/CN=client",
+		},
+	}}
+	var buf bytes.Buffer
+	assert.NoError(t, index.Encode(&buf))
+	assert.Equal(t, "V\t300102030405Z\t\t01\tunknown\t/CN=ca\n"+
+		"R\t21250102030405Z\t220102030405Z,superseded\tAB\tunknown\t/O=org/CN=server\n"+
+		"R\t300102030405Z\t220102030405Z,keyTime,20210102030405Z\t1000\tunknown\t/CN=client\n", buf.String())
+	decoded := &Index{}
+	assert.NoError(t, decoded.Decode(&buf))
+	assert.Equal(t, index, decoded)
+}
+
+func TestIndex_DecodeExtraFields(t *testing.T) {
+	content := "V\t300102030405Z\t\t01\tunknown\t/CN=ca\tattr=1\t\n" +
+		"R\t300102030405Z\t220102030405Z\t02\tunknown\t/CN=client\n"
+	decoded := &Index{}
+	assert.NoError(t, decoded.Decode(bytes.NewBufferString(content)))
+	assert.Equal(t, []string{"attr=1", ""}, decoded.Entries[0].Extra)
+	assert.Nil(t, decoded.Entries[1].Extra)
+	var buf bytes.Buffer
+	assert.NoError(t, decoded.Encode(&buf))
+	assert.Equal(t, content, buf.String())
+}
+
+func TestIndex_DecodeBroken(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "fields count", content: "V\t300102030405Z\t\t01\tunknown\n"},
+		{name: "status", content: "VV\t300102030405Z\t\t01\tunknown\t/CN=ca\n"},
+		{name: "expiry", content: "V\t3001\t\t01\tunknown\t/CN=ca\n"},
+		{name: "serial", content: "V\t300102030405Z\t\tXX\tunknown\t/CN=ca\n"},
+		{name: "reason", content: "R\t300102030405Z\t220102030405Z,bad\t01\tunknown\t/CN=ca\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, (&Index{}).Decode(bytes.NewBufferString(tt.content)))
+		})
+	}
+}
+
+func Test_oneLineDN(t *testing.T) {
+	name := pkix.Name{Country: []string{"US"}, Organization: []string{"org"}, CommonName: "server"}
+	assert.Equal(t, "/C=US/O=org/CN=server", oneLineDN(name))
+}
+
+func TestPKI_Index(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	indexPath, _ := filepath.Abs(filepath.Join(testData, "index.txt"))
+	WithIndexFile(indexPath)(pki)
+	_, _ = pki.NewCa()
+	server, _ := pki.NewCert("server", Server())
+	_, _ = pki.NewCert("client", Client())
+	assert.NoError(t, pki.RevokeOne(server.Serial, Reason(ReasonSuperseded)))
+	content, err := ioutil.ReadFile(indexPath)
+	assert.NoError(t, err)
+	index := &Index{}
+	assert.NoError(t, index.Decode(bytes.NewReader(content)))
+	assert.Len(t, index.Entries, 3)
+	assert.Equal(t, StatusValid, index.Entries[0].Status)
+	assert.Equal(t, "/CN=ca", index.Entries[0].DN)
+	assert.Equal(t, StatusRevoked, index.Entries[1].Status)
+	assert.Equal(t, ReasonSuperseded, index.Entries[1].Reason)
+	assert.Equal(t, StatusValid, index.Entries[2].Status)
+}
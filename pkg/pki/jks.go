@@ -0,0 +1,204 @@
+package pki
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/kemsta/go-easyrsa/pkg/pair"
+)
+
+const (
+	jksMagic          = 0xfeedfeed
+	jksVersion        = 2
+	jksPrivateKeyTag  = 1
+	jksTrustedCertTag = 2
+	jksCertType       = "X.509"
+	jksSaltLen        = sha1.Size
+)
+
+// jksKeyProtector is an OID of key protection algorithm of sun JKS provider
+var jksKeyProtector = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 42, 2, 17, 1, 1}
+
+// ExportTruststore return java keystore in JKS format with trusted entries of all valid CA certificates,
+// so java applications trust certificates of pki. Aliases are "ca-" with hex serial.
+// Store password protects integrity of truststore and is required by keytool.
+func (p *PKI) ExportTruststore(password string) ([]byte, error) {
+	caPairs, caCerts, err := p.validCAs()
+	if err != nil {
+		return nil, fmt.Errorf("can`t get ca chain: %w", err)
+	}
+	if len(caCerts) == 0 {
+		return nil, errors.New("there are no valid ca certs")
+	}
+	w := newJKSWriter(p.now().UnixMilli(), len(caCerts))
+	for i, cert := range caCerts {
+		w.trustedCert("ca-"+caPairs[i].Serial.Text(16), cert)
+	}
+	return w.finish(password), nil
+}
+
+// ExportKeystore return java keystore in JKS format with private key entry of the last pair with cn
+// and its chain up to the root. Alias is lowercase cn, key and store are protected with the same password
+// as keytool and most java servers expect.
+func (p *PKI) ExportKeystore(cn, password string) ([]byte, error) {
+	if password == "" {
+		return nil, errors.New("keystore password is required")
+	}
+	last, err := p.Storage.GetLastByCn(cn)
+	if err != nil {
+		return nil, fmt.Errorf("can`t get %v: %w", cn, err)
+	}
+	key, cert, err := last.DecodeSigner()
+	if err != nil {
+		return nil, fmt.Errorf("can`t decode %v pair: %w", cn, err)
+	}
+	defer pair.WipeKey(key)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("can`t marshal %v key: %w", cn, err)
+	}
+	defer pair.Wipe(der)
+	chain, err := p.chainOf(cert)
+	if err != nil {
+		return nil, err
+	}
+	protected, err := jksProtectKey(p.random(), der, password)
+	if err != nil {
+		return nil, fmt.Errorf("can`t protect %v key: %w", cn, err)
+	}
+	w := newJKSWriter(p.now().UnixMilli(), 1)
+	w.privateKey(strings.ToLower(cn), protected, chain)
+	return w.finish(password), nil
+}
+
+// chainOf return cert followed by valid CA certificates up to self-signed one
+func (p *PKI) chainOf(cert *x509.Certificate) ([]*x509.Certificate, error) {
+	_, caCerts, err := p.validCAs()
+	if err != nil {
+		return nil, fmt.Errorf("can`t get ca chain: %w", err)
+	}
+	chain := []*x509.Certificate{cert}
+	for current := cert; len(chain) <= len(caCerts); {
+		var issuer *x509.Certificate
+		for _, caCert := range caCerts {
+			if !caCert.Equal(current) && current.CheckSignatureFrom(caCert) == nil {
+				issuer = caCert
+				break
+			}
+		}
+		if issuer == nil {
+			break
+		}
+		chain = append(chain, issuer)
+		current = issuer
+	}
+	return chain, nil
+}
+
+// jksProtectKey encrypt pkcs8 key like sun KeyProtector and wrap it to EncryptedPrivateKeyInfo
+func jksProtectKey(rand io.Reader, key []byte, password string) ([]byte, error) {
+	salt := make([]byte, jksSaltLen)
+	if _, err := io.ReadFull(rand, salt); err != nil {
+		return nil, err
+	}
+	passwd := jksPassword(password)
+	encrypted := make([]byte, 0, jksSaltLen+len(key)+sha1.Size)
+	encrypted = append(encrypted, salt...)
+	digest := salt
+	for i := 0; i < len(key); i += sha1.Size {
+		sum := sha1.Sum(append(append([]byte{}, passwd...), digest...))
+		digest = sum[:]
+		for j := 0; j < sha1.Size && i+j < len(key); j++ {
+			encrypted = append(encrypted, key[i+j]^digest[j])
+		}
+	}
+	check := sha1.Sum(append(append([]byte{}, passwd...), key...))
+	encrypted = append(encrypted, check[:]...)
+	return asn1.Marshal(struct {
+		Algo pkix.AlgorithmIdentifier
+		Data []byte
+	}{
+		Algo: pkix.AlgorithmIdentifier{Algorithm: jksKeyProtector, Parameters: asn1.NullRawValue},
+		Data: encrypted,
+	})
+}
+
+// jksPassword return password as big-endian utf-16 like java chars
+func jksPassword(password string) []byte {
+	res := make([]byte, 0, 2*len(password))
+	for _, c := range utf16.Encode([]rune(password)) {
+		res = append(res, byte(c>>8), byte(c))
+	}
+	return res
+}
+
+// jksWriter encode JKS entries, see sun.security.provider.JavaKeyStore
+type jksWriter struct {
+	buf       bytes.Buffer
+	timestamp int64
+}
+
+func newJKSWriter(timestamp int64, count int) *jksWriter {
+	w := &jksWriter{timestamp: timestamp}
+	w.uint32(jksMagic)
+	w.uint32(jksVersion)
+	w.uint32(uint32(count))
+	return w
+}
+
+func (w *jksWriter) trustedCert(alias string, cert *x509.Certificate) {
+	w.uint32(jksTrustedCertTag)
+	w.utf(alias)
+	w.uint64(uint64(w.timestamp))
+	w.cert(cert)
+}
+
+func (w *jksWriter) privateKey(alias string, protected []byte, chain []*x509.Certificate) {
+	w.uint32(jksPrivateKeyTag)
+	w.utf(alias)
+	w.uint64(uint64(w.timestamp))
+	w.uint32(uint32(len(protected)))
+	w.buf.Write(protected)
+	w.uint32(uint32(len(chain)))
+	for _, cert := range chain {
+		w.cert(cert)
+	}
+}
+
+// finish append integrity digest keyed with password and return keystore
+func (w *jksWriter) finish(password string) []byte {
+	h := sha1.New()
+	h.Write(jksPassword(password))
+	h.Write([]byte("Mighty Aphrodite"))
+	h.Write(w.buf.Bytes())
+	w.buf.Write(h.Sum(nil))
+	return w.buf.Bytes()
+}
+
+func (w *jksWriter) cert(cert *x509.Certificate) {
+	w.utf(jksCertType)
+	w.uint32(uint32(len(cert.Raw)))
+	w.buf.Write(cert.Raw)
+}
+
+func (w *jksWriter) utf(s string) {
+	_ = binary.Write(&w.buf, binary.BigEndian, uint16(len(s)))
+	w.buf.WriteString(s)
+}
+
+func (w *jksWriter) uint32(v uint32) {
+	_ = binary.Write(&w.buf, binary.BigEndian, v)
+}
+
+func (w *jksWriter) uint64(v uint64) {
+	_ = binary.Write(&w.buf, binary.BigEndian, v)
+}
@@ -0,0 +1,194 @@
+package pki
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// must stop test if assertion failed
+func must(t *testing.T, ok bool) {
+	if !ok {
+		t.FailNow()
+	}
+}
+
+type jksEntry struct {
+	tag   uint32
+	alias string
+	key   []byte
+	certs []*x509.Certificate
+}
+
+// readJKS decode keystore like java does and check its integrity digest
+func readJKS(t *testing.T, content []byte, password string) []jksEntry {
+	must(t, assert.Greater(t, len(content), sha1.Size))
+	body, digest := content[:len(content)-sha1.Size], content[len(content)-sha1.Size:]
+	h := sha1.New()
+	h.Write(jksPassword(password))
+	h.Write([]byte("Mighty Aphrodite"))
+	h.Write(body)
+	must(t, assert.Equal(t, h.Sum(nil), digest, "keystore is tampered or password is incorrect"))
+
+	r := bytes.NewReader(body)
+	var magic, version, count uint32
+	must(t, assert.NoError(t, binary.Read(r, binary.BigEndian, &magic)))
+	must(t, assert.NoError(t, binary.Read(r, binary.BigEndian, &version)))
+	must(t, assert.NoError(t, binary.Read(r, binary.BigEndian, &count)))
+	assert.Equal(t, uint32(jksMagic), magic)
+	assert.Equal(t, uint32(jksVersion), version)
+	readBytes := func(n int) []byte {
+		res := make([]byte, n)
+		_, err := r.Read(res)
+		must(t, assert.NoError(t, err))
+		return res
+	}
+	readUTF := func() string {
+		var n uint16
+		must(t, assert.NoError(t, binary.Read(r, binary.BigEndian, &n)))
+		return string(readBytes(int(n)))
+	}
+	readCert := func() *x509.Certificate {
+		assert.Equal(t, jksCertType, readUTF())
+		var n uint32
+		must(t, assert.NoError(t, binary.Read(r, binary.BigEndian, &n)))
+		cert, err := x509.ParseCertificate(readBytes(int(n)))
+		must(t, assert.NoError(t, err))
+		return cert
+	}
+	res := make([]jksEntry, 0, count)
+	for i := uint32(0); i < count; i++ {
+		var entry jksEntry
+		var timestamp int64
+		must(t, assert.NoError(t, binary.Read(r, binary.BigEndian, &entry.tag)))
+		entry.alias = readUTF()
+		must(t, assert.NoError(t, binary.Read(r, binary.BigEndian, &timestamp)))
+		switch entry.tag {
+		case jksTrustedCertTag:
+			entry.certs = append(entry.certs, readCert())
+		case jksPrivateKeyTag:
+			var n, chainLen uint32
+			must(t, assert.NoError(t, binary.Read(r, binary.BigEndian, &n)))
+			entry.key = readBytes(int(n))
+			must(t, assert.NoError(t, binary.Read(r, binary.BigEndian, &chainLen)))
+			for j := uint32(0); j < chainLen; j++ {
+				entry.certs = append(entry.certs, readCert())
+			}
+		default:
+			t.Fatalf("unknown entry tag %v", entry.tag)
+		}
+		res = append(res, entry)
+	}
+	assert.Zero(t, r.Len())
+	return res
+}
+
+// recoverJKSKey decrypt protected key like sun KeyProtector does
+func recoverJKSKey(t *testing.T, protected []byte, password string) []byte {
+	var info struct {
+		Algo pkix.AlgorithmIdentifier
+		Data []byte
+	}
+	_, err := asn1.Unmarshal(protected, &info)
+	must(t, assert.NoError(t, err))
+	assert.True(t, info.Algo.Algorithm.Equal(jksKeyProtector))
+	passwd := jksPassword(password)
+	salt, encrypted := info.Data[:jksSaltLen], info.Data[jksSaltLen:len(info.Data)-sha1.Size]
+	key := make([]byte, len(encrypted))
+	digest := salt
+	for i := range encrypted {
+		if i%sha1.Size == 0 {
+			sum := sha1.Sum(append(append([]byte{}, passwd...), digest...))
+			digest = sum[:]
+		}
+		key[i] = encrypted[i] ^ digest[i%sha1.Size]
+	}
+	check := sha1.Sum(append(append([]byte{}, passwd...), key...))
+	assert.Equal(t, check[:], info.Data[len(info.Data)-sha1.Size:])
+	return key
+}
+
+func TestPKI_ExportTruststore(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	_, err := pki.ExportTruststore("changeit")
+	assert.Error(t, err)
+	ca, err := pki.NewCa()
+	assert.NoError(t, err)
+
+	content, err := pki.ExportTruststore("changeit")
+	assert.NoError(t, err)
+	entries := readJKS(t, content, "changeit")
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, uint32(jksTrustedCertTag), entries[0].tag)
+		assert.Equal(t, "ca-"+ca.Serial.Text(16), entries[0].alias)
+		assert.Equal(t, ca.Serial, entries[0].certs[0].SerialNumber)
+	}
+}
+
+func TestPKI_ExportKeystore(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	ca, err := pki.NewCa()
+	assert.NoError(t, err)
+	server, err := pki.NewCert("Server", Server())
+	assert.NoError(t, err)
+
+	_, err = pki.ExportKeystore("Server", "")
+	assert.Error(t, err)
+	_, err = pki.ExportKeystore("nobody", "secret")
+	assert.Error(t, err)
+	content, err := pki.ExportKeystore("Server", "sécret")
+	assert.NoError(t, err)
+	entries := readJKS(t, content, "sécret")
+	must(t, assert.Len(t, entries, 1))
+	assert.Equal(t, uint32(jksPrivateKeyTag), entries[0].tag)
+	assert.Equal(t, "server", entries[0].alias)
+	if assert.Len(t, entries[0].certs, 2) {
+		assert.Equal(t, server.Serial, entries[0].certs[0].SerialNumber)
+		assert.Equal(t, ca.Serial, entries[0].certs[1].SerialNumber)
+	}
+	key, _, err := server.DecodeSigner()
+	assert.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+	assert.Equal(t, der, recoverJKSKey(t, entries[0].key, "sécret"))
+}
+
+// TestReadJKS_Keytool check keystore reader used by tests above against keystore made by keytool, so the reader
+// doesn`t just mirror the encoder. Fixture is made in testdata with
+//
+//	keytool -genkeypair -keystore keytool.jks -storetype JKS -storepass changeit -keypass changeit \
+//		-alias server -keyalg RSA -keysize 2048 -dname CN=server -validity 36500
+//	keytool -exportcert -keystore keytool.jks -storepass changeit -alias server -file server.crt
+//	keytool -importcert -keystore keytool.jks -storepass changeit -alias ca -file server.crt -noprompt
+func TestReadJKS_Keytool(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join("testdata", "keytool.jks"))
+	if os.IsNotExist(err) {
+		t.Skip("no keystore made by keytool in testdata")
+	}
+	must(t, assert.NoError(t, err))
+	entries := readJKS(t, content, "changeit")
+	must(t, assert.Len(t, entries, 2))
+	byAlias := make(map[string]jksEntry)
+	for _, entry := range entries {
+		byAlias[entry.alias] = entry
+	}
+	trusted, server := byAlias["ca"], byAlias["server"]
+	assert.Equal(t, uint32(jksTrustedCertTag), trusted.tag)
+	must(t, assert.Equal(t, uint32(jksPrivateKeyTag), server.tag))
+	must(t, assert.Len(t, server.certs, 1))
+	assert.Equal(t, server.certs[0].Raw, trusted.certs[0].Raw)
+	key, err := x509.ParsePKCS8PrivateKey(recoverJKSKey(t, server.key, "changeit"))
+	must(t, assert.NoError(t, err))
+	assert.Equal(t, server.certs[0].PublicKey, key.(interface{ Public() crypto.PublicKey }).Public())
+}
@@ -0,0 +1,114 @@
+package pki
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+const (
+	intentRevoke  = "revoke"  // intent to add entry into CRL
+	intentRelease = "release" // intent to remove certificateHold entry from CRL
+)
+
+// intent is a journal record of change which is going to be done
+type intent struct {
+	Op    string `json:"op"`
+	Entry []byte `json:"entry"` // DER encoded pkix.RevokedCertificate, only serial matters for release
+}
+
+// beginRevokeIntent persist intent to revoke entry. Empty id without journal.
+func (p *PKI) beginRevokeIntent(entry pkix.RevokedCertificate) (string, error) {
+	return p.beginIntent(intentRevoke, entry)
+}
+
+// beginIntent persist intent of operation with entry. Empty id without journal.
+func (p *PKI) beginIntent(op string, entry pkix.RevokedCertificate) (string, error) {
+	if p.journal == nil {
+		return "", nil
+	}
+	der, err := asn1.Marshal(entry)
+	if err != nil {
+		return "", fmt.Errorf("can`t encode %v intent for %v: %w", op, entry.SerialNumber, err)
+	}
+	content, err := json.Marshal(intent{Op: op, Entry: der})
+	if err != nil {
+		return "", fmt.Errorf("can`t encode %v intent for %v: %w", op, entry.SerialNumber, err)
+	}
+	id, err := p.journal.Begin(content)
+	if err != nil {
+		return "", fmt.Errorf("can`t begin %v intent for %v: %w", op, entry.SerialNumber, err)
+	}
+	return id, nil
+}
+
+func (p *PKI) commitIntent(id string) error {
+	if p.journal == nil {
+		return nil
+	}
+	if err := p.journal.Commit(id); err != nil {
+		return fmt.Errorf("can`t commit intent %v: %w", id, err)
+	}
+	return nil
+}
+
+// abortIntents drop intents of batch which failed before it was completed, so Recover doesn`t finish changes
+// reported to caller as failed. Errors of dropping are ignored in favor of error of the batch.
+func (p *PKI) abortIntents(ids []string) {
+	for _, id := range ids {
+		_ = p.commitIntent(id)
+	}
+}
+
+// Recover finish changes interrupted by crash from pending journal intents in order of their ids.
+// It returns number of finished intents. Hooks are called for them like for usual changes.
+func (p *PKI) Recover() (int, error) {
+	if p.journal == nil {
+		return 0, nil
+	}
+	pending, err := p.journal.Pending()
+	if err != nil {
+		return 0, fmt.Errorf("can`t get pending intents: %w", err)
+	}
+	ids := make([]string, 0, len(pending))
+	for id := range pending {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	var hookErr error
+	for i, id := range ids {
+		rec := intent{}
+		if err := json.Unmarshal(pending[id], &rec); err != nil {
+			return i, fmt.Errorf("can`t decode intent %v: %w", id, err)
+		}
+		entry := pkix.RevokedCertificate{}
+		if _, err := asn1.Unmarshal(rec.Entry, &entry); err != nil {
+			return i, fmt.Errorf("can`t decode %v intent %v: %w", rec.Op, id, err)
+		}
+		eventType := EventRevoke
+		switch rec.Op {
+		case intentRevoke:
+			err = p.revoke(entry)
+		case intentRelease:
+			eventType = EventRelease
+			// it could be released before crash
+			if status, _ := p.Status(entry.SerialNumber); status == CertStatusSuspended {
+				err = p.release(entry.SerialNumber)
+			}
+		default:
+			return i, fmt.Errorf("unknown operation %q of intent %v", rec.Op, id)
+		}
+		if err != nil {
+			return i, fmt.Errorf("can`t finish %v intent %v: %w", rec.Op, id, err)
+		}
+		if err := p.commitIntent(id); err != nil {
+			return i, err
+		}
+		if err := p.runHooks(eventType, p.revokedPair(entry.SerialNumber)); err != nil && hookErr == nil {
+			hookErr = err
+		}
+	}
+	return len(ids), hookErr
+}
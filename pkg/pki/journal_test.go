@@ -0,0 +1,45 @@
+package pki
+
+import (
+	"crypto/x509/pkix"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPKI_Recover(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	WithJournalDir(filepath.Join(testData, ".journal"))(pki)
+	revoked := 0
+	WithHooks(EventRevoke, func(event Event) error {
+		revoked++
+		return nil
+	})(pki)
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+	cert, err := pki.NewCert("client", Client())
+	assert.NoError(t, err)
+
+	// crash after intent is persisted but before crl is written
+	_, err = pki.beginRevokeIntent(pkix.RevokedCertificate{SerialNumber: cert.Serial, RevocationTime: time.Now()})
+	assert.NoError(t, err)
+	assert.False(t, pki.IsRevoked(cert.Serial))
+
+	got, err := pki.Recover()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, got)
+	assert.True(t, pki.IsRevoked(cert.Serial))
+	assert.Equal(t, 1, revoked)
+	got, err = pki.Recover()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, got)
+
+	assert.NoError(t, pki.RevokeOne(big.NewInt(100)))
+	pending, err := pki.journal.Pending()
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+}
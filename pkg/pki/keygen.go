@@ -0,0 +1,185 @@
+package pki
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/kemsta/go-easyrsa/pkg/pair"
+)
+
+// KeyAlgorithm is an algorithm of generated keys
+type KeyAlgorithm string
+
+const (
+	KeyRSA   KeyAlgorithm = "rsa"   // rsa keys, DefaultKeySizeBytes bits by default
+	KeyECDSA KeyAlgorithm = "ecdsa" // ecdsa keys on P-256, P-384 or P-521 curve by key size, P-256 by default
+)
+
+// PEMECPrivateKeyBlock is pem block header for ecdsa.PrivateKey
+const PEMECPrivateKeyBlock = "EC PRIVATE KEY"
+
+// MinRSAKeySize is the smallest size of new rsa keys, smaller keys are rejected
+const MinRSAKeySize = 2048
+
+// GenerateKey generate key with algorithm and size of PKI without issuing certificate, e.g. for CSR
+// which is signed later. It`s *rsa.PrivateKey or *ecdsa.PrivateKey, see EncodeKey.
+func (p *PKI) GenerateKey() (crypto.Signer, error) {
+	return p.GenerateKeyContext(context.Background())
+}
+
+// GenerateKeyContext is GenerateKey cancelled with ctx
+func (p *PKI) GenerateKeyContext(ctx context.Context) (crypto.Signer, error) {
+	return p.newKey(ctx)
+}
+
+// EncodeKey encode rsa key as PKCS#1 or ecdsa key as SEC 1 pem like keys of issued pairs
+func EncodeKey(key crypto.Signer) ([]byte, error) {
+	return encodeKey(key)
+}
+
+// CheckKeySize check that new keys of algorithm can be bits size, zero is the default size of algorithm
+func CheckKeySize(algorithm KeyAlgorithm, bits int) error {
+	switch algorithm {
+	case "", KeyRSA:
+		if bits != 0 && bits < MinRSAKeySize {
+			return fmt.Errorf("rsa key size %v is less than %v", bits, MinRSAKeySize)
+		}
+		return nil
+	case KeyECDSA:
+		_, err := ecdsaCurve(bits)
+		return err
+	}
+	return fmt.Errorf("unknown key algorithm %q", string(algorithm))
+}
+
+// newKey generate key with algorithm and size from WithKeyAlgorithm and WithKeySize
+func (p *PKI) newKey(ctx context.Context) (crypto.Signer, error) {
+	return p.newSizedKey(ctx, p.keySize)
+}
+
+// newSizedKey generate key with algorithm from WithKeyAlgorithm and bits size, the default one of algorithm if zero
+func (p *PKI) newSizedKey(ctx context.Context, bits int) (crypto.Signer, error) {
+	if err := CheckKeySize(p.keyAlgorithm, bits); err != nil {
+		return nil, err
+	}
+	switch p.keyAlgorithm {
+	case "", KeyRSA:
+		if bits == 0 {
+			bits = DefaultKeySizeBytes
+		}
+		return p.generateKey(ctx, bits)
+	case KeyECDSA:
+		curve, err := ecdsaCurve(bits)
+		if err != nil {
+			return nil, err
+		}
+		key, err := ecdsa.GenerateKey(curve, p.random())
+		if err != nil {
+			return nil, fmt.Errorf("can`t generate key: %w", err)
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key algorithm %q", string(p.keyAlgorithm))
+}
+
+// defaultValidity return validity from WithDefaultValidity or DefaultExpireYears
+func (p *PKI) defaultValidity() time.Duration {
+	if p.validity != 0 {
+		return p.validity
+	}
+	return time.Duration(24*365*DefaultExpireYears) * time.Hour
+}
+
+// cryptoDefaults return certificate options applied before all other ones
+func (p *PKI) cryptoDefaults() []CertificateOption {
+	if p.signatureAlg == x509.UnknownSignatureAlgorithm {
+		return nil
+	}
+	return []CertificateOption{SignatureAlgorithm(p.signatureAlg)}
+}
+
+// ecdsaCurve return curve of key size, P-256 for zero size
+func ecdsaCurve(bits int) (elliptic.Curve, error) {
+	switch bits {
+	case 0, 256:
+		return elliptic.P256(), nil
+	case 384:
+		return elliptic.P384(), nil
+	case 521:
+		return elliptic.P521(), nil
+	}
+	return nil, fmt.Errorf("ecdsa key size %v isn`t one of 256, 384 and 521", bits)
+}
+
+// marshalKey return pem block type and der of rsa or ecdsa key. der should be wiped after use.
+func marshalKey(key crypto.Signer) (string, []byte, error) {
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return PEMRSAPrivateKeyBlock, x509.MarshalPKCS1PrivateKey(key), nil
+	case *ecdsa.PrivateKey:
+		der, err := x509.MarshalECPrivateKey(key)
+		return PEMECPrivateKeyBlock, der, err
+	}
+	return "", nil, fmt.Errorf("unsupported key type %T", key)
+}
+
+// encodeKey encode rsa or ecdsa key to pem
+func encodeKey(key crypto.Signer) ([]byte, error) {
+	blockType, der, err := marshalKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("can`t encode key: %w", err)
+	}
+	defer pair.Wipe(der)
+	return pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), nil
+}
+
+// KeyGenProgress describe rsa key generation state for progress callbacks
+type KeyGenProgress struct {
+	Bits  int // key size
+	Reads int // entropy reads so far, roughly one per tested prime candidate
+}
+
+// generateKey generate rsa key of bits size with crypto/rsa. Generation is cancelled with ctx between entropy reads,
+// progress is reported after every read if WithKeyGenProgress is set.
+func (p *PKI) generateKey(ctx context.Context, bits int) (*rsa.PrivateKey, error) {
+	if p.fixtureKeys {
+		return fixtureRSAKey(p.random(), bits)
+	}
+	random := &keyGenReader{ctx: ctx, random: p.random(), progress: p.keyGenProgress, state: KeyGenProgress{Bits: bits}}
+	key, err := rsa.GenerateKey(random, bits)
+	if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+		return nil, ctxErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can`t generate key: %w", err)
+	}
+	return key, nil
+}
+
+// keyGenReader is an entropy source of key generation which fails once ctx is done and reports every read
+type keyGenReader struct {
+	ctx      context.Context
+	random   io.Reader
+	progress func(KeyGenProgress)
+	state    KeyGenProgress
+}
+
+func (r *keyGenReader) Read(b []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := r.random.Read(b)
+	r.state.Reads++
+	if r.progress != nil {
+		r.progress(r.state)
+	}
+	return n, err
+}
@@ -0,0 +1,148 @@
+package pki
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	mathrand "math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPKI_generateKey(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	var last KeyGenProgress
+	calls := 0
+	WithKeyGenProgress(func(progress KeyGenProgress) {
+		calls++
+		last = progress
+	})(pki)
+	key, err := pki.generateKey(context.Background(), 2048)
+	assert.NoError(t, err)
+	assert.NoError(t, key.Validate())
+	assert.Equal(t, 2048, key.N.BitLen())
+	assert.Equal(t, 65537, key.E)
+	assert.True(t, calls >= 2)
+	assert.Equal(t, 2048, last.Bits)
+	assert.Equal(t, calls, last.Reads)
+}
+
+func TestPKI_generateKeyCancel(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	ctx, cancel := context.WithCancel(context.Background())
+	WithKeyGenProgress(func(progress KeyGenProgress) {
+		if progress.Reads >= 2 {
+			cancel()
+		}
+	})(pki)
+	_, err := pki.generateKey(ctx, 4096)
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func Test_fixtureRSAKey(t *testing.T) {
+	first, err := fixtureRSAKey(mathrand.New(mathrand.NewSource(1)), 1024)
+	assert.NoError(t, err)
+	assert.NoError(t, first.Validate())
+	assert.Equal(t, 1024, first.N.BitLen())
+	second, err := fixtureRSAKey(mathrand.New(mathrand.NewSource(1)), 1024)
+	assert.NoError(t, err)
+	assert.Equal(t, first.N, second.N)
+}
+
+func TestPKI_IssueContext(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = pki.NewCertContext(ctx, "cancelled")
+	assert.True(t, errors.Is(err, context.Canceled))
+	_, err = pki.Storage.GetLastByCn("cancelled")
+	assert.Error(t, err)
+
+	calls := 0
+	WithKeyGenProgress(func(KeyGenProgress) { calls++ })(pki)
+	_, err = pki.NewCertContext(context.Background(), "client")
+	assert.NoError(t, err)
+	assert.True(t, calls > 0)
+}
+
+func TestPKI_CryptoDefaults(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	WithKeyAlgorithm(KeyECDSA)(pki)
+	WithKeySize(384)(pki)
+	WithDefaultValidity(30 * 24 * time.Hour)(pki)
+	WithDefaultSignatureAlgorithm(x509.ECDSAWithSHA384)(pki)
+	ca, err := pki.NewCa()
+	assert.NoError(t, err)
+	caCert, err := ca.DecodeCert()
+	assert.NoError(t, err)
+	assert.Equal(t, x509.ECDSAWithSHA384, caCert.SignatureAlgorithm)
+	assert.Equal(t, elliptic.P384(), caCert.PublicKey.(*ecdsa.PublicKey).Curve)
+	assert.WithinDuration(t, time.Now().Add(30*24*time.Hour), caCert.NotAfter, time.Minute)
+
+	user, err := pki.NewCert("user", Client(), SignatureAlgorithm(x509.ECDSAWithSHA512))
+	assert.NoError(t, err)
+	cert, err := user.DecodeCert()
+	assert.NoError(t, err)
+	assert.NoError(t, cert.CheckSignatureFrom(caCert))
+	assert.Equal(t, x509.ECDSAWithSHA512, cert.SignatureAlgorithm)
+	assert.Equal(t, caCert.NotAfter, cert.NotAfter)
+	_, err = tls.X509KeyPair(user.CertPemBytes, user.KeyPemBytes)
+	assert.NoError(t, err)
+	assert.NoError(t, pki.RevokeOne(user.Serial))
+
+	WithKeySize(1024)(pki)
+	_, err = pki.NewCert("bad", Client())
+	assert.Error(t, err)
+	WithKeyAlgorithm("dsa")(pki)
+	_, err = pki.NewCert("bad", Client())
+	assert.Error(t, err)
+}
+
+func TestPKI_GenerateKey(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	WithKeySize(1024)(pki)
+	_, err := pki.GenerateKey()
+	assert.Error(t, err)
+	WithKeySize(3072)(pki)
+	key, err := pki.GenerateKey()
+	assert.NoError(t, err)
+	assert.Equal(t, 3072, key.(*rsa.PrivateKey).N.BitLen())
+	keyPEM, err := EncodeKey(key)
+	assert.NoError(t, err)
+	block, _ := pem.Decode(keyPEM)
+	assert.Equal(t, PEMRSAPrivateKeyBlock, block.Type)
+
+	WithKeyAlgorithm(KeyECDSA)(pki)
+	WithKeySize(0)(pki)
+	key, err = pki.GenerateKey()
+	assert.NoError(t, err)
+	assert.Equal(t, elliptic.P256(), key.(*ecdsa.PrivateKey).Curve)
+	keyPEM, err = EncodeKey(key)
+	assert.NoError(t, err)
+	block, _ = pem.Decode(keyPEM)
+	assert.Equal(t, PEMECPrivateKeyBlock, block.Type)
+	all, err := pki.Storage.GetAll()
+	assert.NoError(t, err)
+	assert.Empty(t, all)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	WithKeyAlgorithm(KeyRSA)(pki)
+	_, err = pki.GenerateKeyContext(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+}
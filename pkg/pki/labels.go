@@ -0,0 +1,105 @@
+package pki
+
+import (
+	"crypto/x509"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kemsta/go-easyrsa/pkg/pair"
+)
+
+// Labels are arbitrary tags of identity like team=infra or env=prod kept in its metadata,
+// so certificates can be found and handled as inventory
+type Labels map[string]string
+
+// ParseLabels parse labels from key=value strings
+func ParseLabels(pairs ...string) (Labels, error) {
+	res := Labels{}
+	for _, p := range pairs {
+		key, value, ok := strings.Cut(p, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("bad label %q, expected key=value", p)
+		}
+		res[key] = value
+	}
+	return res, nil
+}
+
+// String return labels as sorted comma separated key=value list
+func (l Labels) String() string {
+	res := make([]string, 0, len(l))
+	for key, value := range l {
+		res = append(res, key+"="+value)
+	}
+	sort.Strings(res)
+	return strings.Join(res, ",")
+}
+
+// matches check that l has every label of selector
+func (l Labels) matches(selector Labels) bool {
+	for key, value := range selector {
+		if got, ok := l[key]; !ok || got != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Labeled label identity of issued certificate, existing labels with the same keys are replaced.
+// Storage should be a MetadataStore.
+func Labeled(labels Labels) CertificateOption {
+	return issuanceOption(func(i *issuance) {
+		if i.labels == nil {
+			i.labels = Labels{}
+		}
+		for key, value := range labels {
+			i.labels[key] = value
+		}
+	})
+}
+
+// Labels return labels of identity with name. They are empty if nothing was saved.
+func (p *PKI) Labels(name string) (Labels, error) {
+	meta, err := p.Metadata(name)
+	if err != nil || meta.Labels == nil {
+		return Labels{}, err
+	}
+	return meta.Labels, nil
+}
+
+// Label add labels to identity with name, labels with empty value are removed. Storage should be a MetadataStore.
+func (p *PKI) Label(name string, labels Labels) error {
+	meta, err := p.Metadata(name)
+	if err != nil {
+		return err
+	}
+	if meta.Labels == nil {
+		meta.Labels = Labels{}
+	}
+	for key, value := range labels {
+		if value == "" {
+			delete(meta.Labels, key)
+			continue
+		}
+		meta.Labels[key] = value
+	}
+	if len(meta.Labels) == 0 {
+		meta.Labels = nil
+	}
+	return p.SetMetadata(name, meta)
+}
+
+// HasLabels select certificates of identities having every label of selector, e.g. for Find and RevokeWhere.
+// Labels of every identity are read once, identities with unreadable metadata aren`t selected.
+func (p *PKI) HasLabels(selector Labels) CertFilter {
+	cache := map[string]Labels{}
+	return func(pair *pair.X509Pair, _ *x509.Certificate) bool {
+		labels, ok := cache[pair.CN]
+		if !ok {
+			labels, _ = p.Labels(pair.CN)
+			cache[pair.CN] = labels
+		}
+		return labels.matches(selector)
+	}
+}
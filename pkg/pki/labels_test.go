@@ -0,0 +1,82 @@
+package pki
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLabels(t *testing.T) {
+	labels, err := ParseLabels("team=infra", "env=prod", "empty=")
+	assert.NoError(t, err)
+	assert.Equal(t, Labels{"team": "infra", "env": "prod", "empty": ""}, labels)
+	assert.Equal(t, "empty=,env=prod,team=infra", labels.String())
+	_, err = ParseLabels("team")
+	assert.Error(t, err)
+	_, err = ParseLabels("=infra")
+	assert.Error(t, err)
+}
+
+func TestPKI_Labels(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+	_, err = pki.Issue(Identity{CommonName: "db"}, Client(), Labeled(Labels{"team": "infra", "env": "prod"}))
+	assert.NoError(t, err)
+	_, err = pki.Issue(Identity{CommonName: "web"}, Client(), Labeled(Labels{"team": "web", "env": "prod"}))
+	assert.NoError(t, err)
+	_, err = pki.NewCert("dev", Client())
+	assert.NoError(t, err)
+
+	labels, err := pki.Labels("db")
+	assert.NoError(t, err)
+	assert.Equal(t, Labels{"team": "infra", "env": "prod"}, labels)
+	assert.NoError(t, pki.Label("dev", Labels{"env": "dev", "team": "infra"}))
+	assert.NoError(t, pki.Label("db", Labels{"env": ""}))
+	labels, err = pki.Labels("db")
+	assert.NoError(t, err)
+	assert.Equal(t, Labels{"team": "infra"}, labels)
+
+	infos, err := pki.Find(pki.HasLabels(Labels{"team": "infra"}))
+	assert.NoError(t, err)
+	if assert.Len(t, infos, 2) {
+		assert.Equal(t, "db", infos[0].Name)
+		assert.Equal(t, Labels{"team": "infra", "env": "dev"}, infos[1].Labels)
+	}
+	infos, err = pki.Find(Named("ca"))
+	assert.NoError(t, err)
+	if assert.Len(t, infos, 1) {
+		assert.Nil(t, infos[0].Labels)
+	}
+
+	revoked, err := pki.RevokeWhere(pki.HasLabels(Labels{"env": "prod"}))
+	assert.NoError(t, err)
+	if assert.Len(t, revoked, 1) {
+		assert.Equal(t, "web", revoked[0].CN)
+	}
+
+	pki.Storage = NewChaosKeyStorage(pki.Storage, Faults{})
+	_, err = pki.Issue(Identity{CommonName: "other"}, Client(), Labeled(Labels{"team": "infra"}))
+	assert.ErrorIs(t, err, errNoMetadataStore)
+}
+
+func TestPKI_LabelsFailedAfterPut(t *testing.T) {
+	dir := t.TempDir()
+	index := filepath.Join(dir, "index.txt")
+	pki, err := InitPKI(dir, nil, WithIndexFile(index))
+	assert.NoError(t, err)
+	_, err = pki.NewCa()
+	assert.NoError(t, err)
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "db", "metadata.json"), 0755))
+
+	res, err := pki.Issue(Identity{CommonName: "db"}, Client(), Labeled(Labels{"team": "infra"}))
+	assert.Error(t, err)
+	if assert.NotNil(t, res, "stored pair is returned with error") {
+		content, err := os.ReadFile(index)
+		assert.NoError(t, err)
+		assert.Contains(t, string(content), "CN=db", "index is exported anyway")
+	}
+}
@@ -0,0 +1,49 @@
+package pki
+
+import (
+	"errors"
+	"time"
+
+	"github.com/kemsta/go-easyrsa/internal/fsStorage"
+)
+
+// TempMaxAge is an age after which temp file of fs storage can`t belong to a write in progress
+const TempMaxAge = fsStorage.TempMaxAge
+
+// tempCleaner is a storage which can remove temp files left by interrupted writes like the fs one
+type tempCleaner interface {
+	CleanTemp() ([]string, error)
+	CleanStaleTemp(maxAge time.Duration) ([]string, error)
+	StartTempCleanup(maxAge, interval time.Duration) (stop func(), err error)
+}
+
+var errNoTempCleaner = errors.New("storage doesn`t support temp files cleaning")
+
+// CleanTemp remove temp files left in storage by interrupted writes and return their paths.
+// Storage should support it like the fs one.
+func (p *PKI) CleanTemp() ([]string, error) {
+	cleaner, ok := p.Storage.(tempCleaner)
+	if !ok {
+		return nil, errNoTempCleaner
+	}
+	return cleaner.CleanTemp()
+}
+
+// CleanStaleTemp remove temp files which weren`t modified for maxAge, so writes in progress are kept
+func (p *PKI) CleanStaleTemp(maxAge time.Duration) ([]string, error) {
+	cleaner, ok := p.Storage.(tempCleaner)
+	if !ok {
+		return nil, errNoTempCleaner
+	}
+	return cleaner.CleanStaleTemp(maxAge)
+}
+
+// StartTempCleanup remove stale temp files right away and then every interval until stop is called.
+// It`s meant for long-running processes.
+func (p *PKI) StartTempCleanup(maxAge, interval time.Duration) (stop func(), err error) {
+	cleaner, ok := p.Storage.(tempCleaner)
+	if !ok {
+		return nil, errNoTempCleaner
+	}
+	return cleaner.StartTempCleanup(maxAge, interval)
+}
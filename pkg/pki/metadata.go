@@ -0,0 +1,51 @@
+package pki
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Metadata is sidecar information about identity kept next to its pairs
+type Metadata struct {
+	OpenVPN      *ClientConfig `json:"openvpn,omitempty"`      // OpenVPN client-config-dir settings
+	LeafDefaults *LeafDefaults `json:"leafDefaults,omitempty"` // extension defaults of leaves signed by CA
+	Labels       Labels        `json:"labels,omitempty"`       // inventory tags, see Labeled
+	Rotations    []Rotation    `json:"rotations,omitempty"`    // records of CA rotations, see RotateCA
+}
+
+// errNoMetadataStore is returned when storage doesn`t support metadata
+var errNoMetadataStore = errors.New("storage doesn`t support metadata")
+
+// SetMetadata save metadata of identity with name. Storage should be a MetadataStore.
+func (p *PKI) SetMetadata(name string, meta Metadata) error {
+	store, ok := p.Storage.(MetadataStore)
+	if !ok {
+		return errNoMetadataStore
+	}
+	content, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("can`t encode metadata of %v: %w", name, err)
+	}
+	return store.PutMetadata(name, content)
+}
+
+// Metadata return metadata of identity with name. It`s empty if nothing was saved.
+func (p *PKI) Metadata(name string) (Metadata, error) {
+	meta := Metadata{}
+	store, ok := p.Storage.(MetadataStore)
+	if !ok {
+		return meta, errNoMetadataStore
+	}
+	content, err := store.GetMetadata(name)
+	if err != nil {
+		return meta, err
+	}
+	if len(content) == 0 {
+		return meta, nil
+	}
+	if err := json.Unmarshal(content, &meta); err != nil {
+		return meta, fmt.Errorf("can`t decode metadata of %v: %w", name, err)
+	}
+	return meta, nil
+}
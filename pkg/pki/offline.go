@@ -0,0 +1,245 @@
+package pki
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/kemsta/go-easyrsa/pkg/pair"
+)
+
+const rootSuffix = "-root"
+
+// RootName return storage name of offline root certificate of intermediate PKI CA, see ImportIntermediate
+func (p *PKI) RootName() string {
+	return p.CAName() + rootSuffix
+}
+
+// NewIntermediateRequest generate CA key and CSR for intermediate CA with common name cn, which is signed by
+// offline root with SignIntermediate. Key is encoded like keys of generated CAs and kept by storage as pending key
+// of CA until ImportIntermediate, so it never leaves keydir. It`s returned in pair without certificate as well.
+// Key is nil if signer from WithSigner signs CSR.
+func (p *PKI) NewIntermediateRequest(cn string, opts ...RequestOption) ([]byte, *pair.X509Pair, error) {
+	signer, keyPEM := p.signer, []byte(nil)
+	if signer == nil {
+		pending, ok := p.Storage.(PendingKeyStore)
+		if !ok {
+			return nil, nil, errors.New("can`t keep intermediate key: storage can`t keep pending keys")
+		}
+		key, err := p.newKey(context.Background())
+		if err != nil {
+			return nil, nil, err
+		}
+		defer pair.WipeKey(key)
+		if keyPEM, err = p.encodeCAKey(key); err != nil {
+			return nil, nil, err
+		}
+		if err := pending.PutPendingKey(p.CAName(), keyPEM); err != nil {
+			return nil, nil, fmt.Errorf("can`t keep intermediate key: %w", err)
+		}
+		signer = key
+	}
+	tmpl := &x509.CertificateRequest{
+		Subject:            p.subjTemplate,
+		SignatureAlgorithm: p.signatureAlg,
+	}
+	tmpl.Subject.CommonName = cn
+	for _, opt := range opts {
+		opt(tmpl)
+	}
+	if err := checkSignatureAlgorithm(tmpl.SignatureAlgorithm, signer.Public()); err != nil {
+		return nil, nil, err
+	}
+	der, err := x509.CreateCertificateRequest(p.random(), tmpl, signer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("can`t create csr: %w", err)
+	}
+	csrPEM := pem.EncodeToMemory(&pem.Block{Type: PEMCertificateRequestBlock, Bytes: der})
+	return csrPEM, pair.NewX509Pair(keyPEM, nil, p.CAName(), nil), nil
+}
+
+// SignIntermediate sign pem or der CSR of intermediate CA from NewIntermediateRequest with the last CA, so root key
+// stays in offline PKI. Subject and key come from CSR, path length is 0, crl urls are set by WithCRLDistributionPoints
+// and validity is PKI default unless options change them. Validity is capped by root expiry. Serial is current time
+// followed by random bits, so it grows with renewals and doesn`t collide with serials of online PKI. Certificate is
+// stored without key under common name of CSR, send it followed by root certificate to online PKI for ImportIntermediate.
+func (p *PKI) SignIntermediate(csr []byte, opts ...CertificateOption) (*pair.X509Pair, error) {
+	req, err := ParseRequest(csr)
+	if err != nil {
+		return nil, err
+	}
+	name := req.Subject.CommonName
+	if p.reservedName(name) {
+		return nil, fmt.Errorf("intermediate can`t be named %v like ca", name)
+	}
+	caPair, err := p.GetLastCA()
+	if err != nil {
+		return nil, fmt.Errorf("can`t get ca pair: %w", err)
+	}
+	caKey, caCert, release, err := p.caSigner(caPair)
+	if err != nil {
+		return nil, fmt.Errorf("can`t parse ca pair: %w", err)
+	}
+	defer release()
+	now := p.now()
+	switch {
+	case !caCert.IsCA:
+		return nil, fmt.Errorf("pair %v with serial %v is not a ca", caPair.CN, caPair.Serial)
+	case now.After(caCert.NotAfter):
+		return nil, fmt.Errorf("%v with serial %v: %w", caPair.CN, caPair.Serial, ErrCAExpired)
+	case caCert.MaxPathLenZero:
+		return nil, fmt.Errorf("ca %v with serial %v has path length 0 and can`t sign intermediates",
+			caPair.CN, caPair.Serial)
+	}
+	serial, err := timeSerial(p.random(), now)
+	if err != nil {
+		return nil, fmt.Errorf("can`t generate serial: %w", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      req.Subject,
+		NotBefore:    now.Add(-10 * time.Minute).UTC(),
+		NotAfter:     now.Add(p.defaultValidity()).UTC(),
+	}
+	if _, err := newIssuance(tmpl, []CertificateOption{CA()}, p.cryptoDefaults(), opts); err != nil {
+		return nil, err
+	}
+	if len(tmpl.CRLDistributionPoints) == 0 {
+		tmpl.CRLDistributionPoints = p.crlURLs
+	}
+	if tmpl.NotAfter.After(caCert.NotAfter) {
+		tmpl.NotAfter = caCert.NotAfter
+	}
+	if err := checkSignatureAlgorithm(tmpl.SignatureAlgorithm, caKey.Public()); err != nil {
+		return nil, err
+	}
+	der, err := x509.CreateCertificate(p.random(), tmpl, caCert, req.PublicKey, caKey)
+	if err != nil {
+		return nil, fmt.Errorf("can`t create intermediate cert: %w", err)
+	}
+	res := pair.NewX509Pair(nil, pem.EncodeToMemory(&pem.Block{Type: PEMCertificateBlock, Bytes: der}), name, serial)
+	if err := p.Storage.Put(res); err != nil {
+		return nil, fmt.Errorf("can`t put intermediate %v into storage: %w", name, err)
+	}
+	if err := p.exportIndex(); err != nil {
+		return nil, fmt.Errorf("can`t export index: %w", err)
+	}
+	if err := p.runHooks(EventIssue, res); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+// ImportIntermediate store intermediate certificate signed by offline root with key from NewIntermediateRequest
+// as the last CA, so it signs next certs. chainPEM is intermediate certificate followed by root certificate, root is
+// stored without key as RootName to complete chains and trust bundle. If keyPEM is nil, pending key kept by
+// NewIntermediateRequest is used and dropped after import, or key is kept by signer from WithSigner.
+// Serial provider isn`t advanced, serials used by imported certificates are skipped on issue.
+func (p *PKI) ImportIntermediate(keyPEM, chainPEM []byte) (*pair.X509Pair, error) {
+	certs := make([][]byte, 0, 2)
+	for block, rest := pem.Decode(chainPEM); block != nil; block, rest = pem.Decode(rest) {
+		certs = append(certs, pem.EncodeToMemory(block))
+	}
+	if len(certs) != 2 {
+		return nil, errors.New("can`t import intermediate: chain should be its certificate followed by root certificate")
+	}
+	certBlock, cert, err := parseCACert(certs[0])
+	if err != nil {
+		return nil, err
+	}
+	rootBlock, root, err := parseCACert(certs[1])
+	if err != nil {
+		return nil, err
+	}
+	if !isRoot(root) {
+		return nil, fmt.Errorf("ca %v with serial %v is not a root", root.Subject.CommonName, root.SerialNumber)
+	}
+	if err := cert.CheckSignatureFrom(root); err != nil {
+		return nil, fmt.Errorf("ca %v with serial %v isn`t signed by root %v: %w",
+			cert.Subject.CommonName, cert.SerialNumber, root.Subject.CommonName, err)
+	}
+	pending, _ := p.Storage.(PendingKeyStore)
+	fromPending := false
+	if keyPEM == nil && p.signer == nil && pending != nil {
+		if keyPEM, err = pending.GetPendingKey(p.CAName()); err != nil {
+			return nil, fmt.Errorf("can`t get pending key: %w", err)
+		}
+		if fromPending = len(keyPEM) > 0; !fromPending {
+			keyPEM = nil
+		}
+	}
+	var caKeyPEM []byte
+	switch {
+	case keyPEM != nil:
+		if caKeyPEM, err = p.importedKey(keyPEM, cert); err != nil {
+			return nil, err
+		}
+	case p.signer == nil:
+		return nil, errors.New("can`t import intermediate without key: no signer")
+	default:
+		if public, ok := cert.PublicKey.(interface{ Equal(crypto.PublicKey) bool }); !ok || !public.Equal(p.signer.Public()) {
+			return nil, fmt.Errorf("ca %v with serial %v: %w", cert.Subject.CommonName, cert.SerialNumber, ErrSignerMismatch)
+		}
+	}
+
+	unlock, err := p.lock(p.CAName())
+	if err != nil {
+		return nil, fmt.Errorf("can`t lock ca creation: %w", err)
+	}
+	defer unlock()
+	if _, err := p.putCACert(p.RootName(), nil, rootBlock, root); err != nil {
+		return nil, err
+	}
+	res, err := p.putCACert(p.CAName(), caKeyPEM, certBlock, cert)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.exportIndex(); err != nil {
+		return nil, fmt.Errorf("can`t export index: %w", err)
+	}
+	if fromPending {
+		if err := pending.DeletePendingKey(p.CAName()); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// putCACert put CA certificate with name unless the same certificate is already stored with it
+func (p *PKI) putCACert(name string, keyPEM []byte, certBlock *pem.Block, cert *x509.Certificate) (*pair.X509Pair, error) {
+	existing, err := p.storedCopy(cert)
+	switch {
+	case err != nil:
+		return nil, err
+	case existing != nil && existing.CN == name:
+		return existing, nil
+	case existing != nil:
+		return nil, fmt.Errorf("ca %v with serial %v is stored as %v: %w",
+			cert.Subject.CommonName, cert.SerialNumber, existing.CN, ErrDuplicate)
+	}
+	if existing, err := p.Storage.GetBySerial(cert.SerialNumber); err == nil {
+		return nil, fmt.Errorf("serial %v is already used by %v", cert.SerialNumber, existing.CN)
+	}
+	res := pair.NewX509Pair(keyPEM, pem.EncodeToMemory(certBlock), name, cert.SerialNumber)
+	if err := p.Storage.Put(res); err != nil {
+		return nil, fmt.Errorf("can`t put %v into storage: %w", name, err)
+	}
+	return res, nil
+}
+
+// timeSerial return serial of unix time now followed by 64 random bits
+func timeSerial(random io.Reader, now time.Time) (*big.Int, error) {
+	buf := make([]byte, 16)
+	binary.BigEndian.PutUint64(buf, uint64(now.Unix()))
+	if _, err := io.ReadFull(random, buf[8:]); err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(buf), nil
+}
@@ -0,0 +1,78 @@
+package pki
+
+import (
+	"bytes"
+	"crypto/x509"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOfflineRoot(t *testing.T) {
+	root, err := InitPKI(t.TempDir(), nil)
+	assert.NoError(t, err)
+	rootPair, err := root.NewCa(MaxPathLen(1))
+	assert.NoError(t, err)
+	online, err := InitPKI(t.TempDir(), nil)
+	assert.NoError(t, err)
+
+	csr, keyPair, err := online.NewIntermediateRequest("issuing-ca")
+	assert.NoError(t, err)
+	assert.Nil(t, keyPair.CertPemBytes)
+	intermediate, err := root.SignIntermediate(csr)
+	assert.NoError(t, err)
+	assert.Equal(t, "issuing-ca", intermediate.CN)
+	cert, err := intermediate.DecodeCert()
+	assert.NoError(t, err)
+	assert.True(t, cert.IsCA)
+	assert.True(t, cert.MaxPathLenZero)
+	assert.Equal(t, "issuing-ca", cert.Subject.CommonName)
+	last, err := root.GetLastCA()
+	assert.NoError(t, err)
+	assert.Equal(t, rootPair.Serial, last.Serial)
+
+	chain := append(append([]byte{}, intermediate.CertPemBytes...), rootPair.CertPemBytes...)
+	_, err = online.ImportIntermediate(keyPair.KeyPemBytes, intermediate.CertPemBytes)
+	assert.Error(t, err)
+	imported, err := online.ImportIntermediate(nil, chain)
+	assert.NoError(t, err)
+	pending, err := online.Storage.(PendingKeyStore).GetPendingKey(DefaultCAName)
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+	assert.Equal(t, DefaultCAName, imported.CN)
+	assert.Equal(t, intermediate.Serial, imported.Serial)
+	_, err = online.ImportIntermediate(keyPair.KeyPemBytes, chain)
+	assert.NoError(t, err)
+
+	leaf, err := online.NewServerCert("web", DNSNames([]string{"web.example.com"}))
+	assert.NoError(t, err)
+	assert.NotEqual(t, 0, leaf.Serial.Cmp(rootPair.Serial))
+	leafCert, err := leaf.DecodeCert()
+	assert.NoError(t, err)
+	rootCert, err := rootPair.DecodeCert()
+	assert.NoError(t, err)
+	roots, intermediates := x509.NewCertPool(), x509.NewCertPool()
+	roots.AddCert(rootCert)
+	intermediates.AddCert(cert)
+	_, err = leafCert.Verify(x509.VerifyOptions{DNSName: "web.example.com", Roots: roots, Intermediates: intermediates})
+	assert.NoError(t, err)
+	bundle, err := online.GetTrustBundle()
+	assert.NoError(t, err)
+	assert.True(t, bytes.Contains(bundle, rootPair.CertPemBytes))
+	assert.True(t, bytes.Contains(bundle, intermediate.CertPemBytes))
+	assert.NoError(t, online.RevokeOne(leaf.Serial))
+	assert.True(t, online.IsRevoked(leaf.Serial))
+	assert.NoError(t, root.RevokeOne(intermediate.Serial))
+	assert.True(t, root.IsRevoked(intermediate.Serial))
+
+	other, _, err := online.NewIntermediateRequest(DefaultCAName)
+	assert.NoError(t, err)
+	_, err = root.SignIntermediate(other)
+	assert.Error(t, err)
+	leafOnly, err := InitPKI(t.TempDir(), nil)
+	assert.NoError(t, err)
+	_, err = leafOnly.NewCa()
+	assert.NoError(t, err)
+	_, err = leafOnly.SignIntermediate(csr)
+	assert.Error(t, err)
+}
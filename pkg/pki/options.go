@@ -4,6 +4,7 @@ import (
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/asn1"
+	"math/big"
 	"net"
 	"time"
 )
@@ -16,6 +17,159 @@ func Apply(options []Option, cert *x509.Certificate) {
 	}
 }
 
+// CertificateOption tune certificate issuance. Every Option is a CertificateOption. Other ones, like IssuedBy,
+// change the way certificate is signed rather than certificate template.
+type CertificateOption interface {
+	apply(*issuance)
+}
+
+// issuance hold certificate template and signing settings
+type issuance struct {
+	template      *x509.Certificate
+	issuer        *big.Int // serial of CA pair for signing, the last CA if nil
+	issuerName    string   // name of CA for signing if issuer isn`t set, CA of PKI if empty
+	noDefaultSANs bool     // skip default SANs of PKI
+	defaultExpiry bool     // expiration wasn`t requested by options or policy
+	serial        *big.Int // serial reserved earlier, the next one of serial provider if nil
+	labels        Labels   // labels of identity saved after issue
+	csr           []byte   // pem request of certificate kept with pair, see CSRStore
+	err           error    // the first error of options, certificate isn`t issued with it
+}
+
+// capDefaultExpiry make default expiration not later than expiration of signing CA.
+// Requested expiration is kept as is, see PKI.CheckIssuerExpiry.
+func (i *issuance) capDefaultExpiry(caCert *x509.Certificate) {
+	if i.defaultExpiry && i.template.NotAfter.After(caCert.NotAfter) {
+		i.template.NotAfter = caCert.NotAfter
+	}
+}
+
+// newIssuance apply options to template and return issuance with the first error of options
+func newIssuance(template *x509.Certificate, opts ...[]CertificateOption) (*issuance, error) {
+	res := &issuance{template: template}
+	for _, list := range opts {
+		for _, opt := range list {
+			opt.apply(res)
+		}
+	}
+	return res, res.err
+}
+
+func (o Option) apply(i *issuance) {
+	o(i.template)
+}
+
+// certificateOptions convert options to CertificateOption
+func certificateOptions(opts []Option) []CertificateOption {
+	res := make([]CertificateOption, 0, len(opts))
+	for _, opt := range opts {
+		res = append(res, opt)
+	}
+	return res
+}
+
+type issuanceOption func(*issuance)
+
+func (o issuanceOption) apply(i *issuance) {
+	o(i)
+}
+
+// templateOption change certificate template like Option, but can fail, e.g. on encoding of extension
+type templateOption func(*x509.Certificate) error
+
+func (o templateOption) apply(i *issuance) {
+	if i.err == nil {
+		i.err = o(i.template)
+	}
+}
+
+// IssuedBy sign certificate with CA pair with serial instead of the last CA.
+// It's useful when an old CA generation or an intermediate must keep issuing. Ignored for self-signed CA.
+func IssuedBy(caSerial *big.Int) CertificateOption {
+	return issuanceOption(func(i *issuance) {
+		i.issuer = caSerial
+	})
+}
+
+// IssuedByCA sign certificate with the last CA pair with name instead of the last CA of PKI,
+// e.g. with "web-ca" when "vpn-ca" and "web-ca" share storage. IssuedBy takes precedence.
+func IssuedByCA(name string) CertificateOption {
+	return issuanceOption(func(i *issuance) {
+		i.issuerName = name
+	})
+}
+
+// reservedSerial issue certificate with serial reserved earlier, e.g. by Submit
+func reservedSerial(serial *big.Int) CertificateOption {
+	return issuanceOption(func(i *issuance) {
+		i.serial = serial
+	})
+}
+
+// fromRequest keep pem request csr with issued pair, so Renew can sign it again
+func fromRequest(csr []byte) CertificateOption {
+	return issuanceOption(func(i *issuance) {
+		i.csr = csr
+	})
+}
+
+// NoDefaultSANs issue certificate without default subject alternative names of PKI. See WithDefaultSANs.
+func NoDefaultSANs() CertificateOption {
+	return issuanceOption(func(i *issuance) {
+		i.noDefaultSANs = true
+	})
+}
+
+// CA set basic constraints and key usage for certificate authority. By default CA is allowed to sign
+// only leaf certificates (pathLen 0) and has digitalSignature usage for OCSP responses delegation.
+// Path length set by MaxPathLen or UnlimitedPathLen is kept regardless of option order.
+func CA() Option {
+	return func(certificate *x509.Certificate) {
+		certificate.BasicConstraintsValid = true
+		certificate.IsCA = true
+		certificate.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+		if certificate.MaxPathLen == 0 {
+			certificate.MaxPathLenZero = true
+		}
+	}
+}
+
+// CADigitalSignature include or exclude digitalSignature key usage in CA certificate
+func CADigitalSignature(enabled bool) Option {
+	return func(certificate *x509.Certificate) {
+		if enabled {
+			certificate.KeyUsage |= x509.KeyUsageDigitalSignature
+		} else {
+			certificate.KeyUsage &^= x509.KeyUsageDigitalSignature
+		}
+	}
+}
+
+// UnlimitedPathLen remove path length constraint, so CA is able to sign intermediate CAs
+func UnlimitedPathLen() Option {
+	return func(certificate *x509.Certificate) {
+		certificate.MaxPathLen = -1
+		certificate.MaxPathLenZero = false
+	}
+}
+
+// MaxPathLen allow CA to sign up to n levels of intermediate CAs below it, negative n removes constraint
+// like UnlimitedPathLen
+func MaxPathLen(n int) Option {
+	return func(certificate *x509.Certificate) {
+		if n < 0 {
+			n = -1
+		}
+		certificate.MaxPathLen = n
+		certificate.MaxPathLenZero = n == 0
+	}
+}
+
+// MaxPathLenZero allow CA to sign only leaf certificates, it`s a default of CA
+func MaxPathLenZero() Option {
+	return MaxPathLen(0)
+}
+
 func CN(cn string) Option {
 	return func(certificate *x509.Certificate) {
 		certificate.Subject.CommonName = cn
@@ -46,6 +200,20 @@ func Client() Option {
 	}
 }
 
+// KeyUsage set key usage of certificate
+func KeyUsage(usage x509.KeyUsage) Option {
+	return func(certificate *x509.Certificate) {
+		certificate.KeyUsage = usage
+	}
+}
+
+// ExtKeyUsage set extended key usages of certificate
+func ExtKeyUsage(usages ...x509.ExtKeyUsage) Option {
+	return func(certificate *x509.Certificate) {
+		certificate.ExtKeyUsage = usages
+	}
+}
+
 func DNSNames(names []string) Option {
 	return func(certificate *x509.Certificate) {
 		certificate.DNSNames = names
@@ -64,8 +232,23 @@ func ExcludedDNSDomains(names []string) Option {
 	}
 }
 
+// CRLDistributionPoints set urls where clients fetch crl of certificate issuer
+func CRLDistributionPoints(urls []string) Option {
+	return func(certificate *x509.Certificate) {
+		certificate.CRLDistributionPoints = urls
+	}
+}
+
 func NotAfter(time time.Time) Option {
 	return func(certificate *x509.Certificate) {
 		certificate.NotAfter = time
 	}
 }
+
+// SignatureAlgorithm sign certificate with algorithm instead of the default one of CA key type, e.g. to force
+// RSA-PSS or a stronger digest. Algorithm must match CA key type, it`s checked before signing.
+func SignatureAlgorithm(algorithm x509.SignatureAlgorithm) Option {
+	return func(certificate *x509.Certificate) {
+		certificate.SignatureAlgorithm = algorithm
+	}
+}
@@ -196,3 +196,86 @@ func TestNotAfter(t *testing.T) {
 		})
 	}
 }
+
+func TestCA(t *testing.T) {
+	want := &x509.Certificate{
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		MaxPathLen:            0,
+		MaxPathLenZero:        true,
+	}
+	cert := &x509.Certificate{}
+	if CA()(cert); !reflect.DeepEqual(cert, want) {
+		t.Errorf("CA() = %v, want %v", cert, want)
+	}
+}
+
+func TestCADigitalSignature(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		want    x509.KeyUsage
+	}{
+		{
+			name:    "disabled",
+			enabled: false,
+			want:    x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		},
+		{
+			name:    "enabled",
+			enabled: true,
+			want:    x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert := &x509.Certificate{}
+			CA()(cert)
+			if CADigitalSignature(tt.enabled)(cert); cert.KeyUsage != tt.want {
+				t.Errorf("CADigitalSignature() = %v, want %v", cert.KeyUsage, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnlimitedPathLen(t *testing.T) {
+	cert := &x509.Certificate{}
+	CA()(cert)
+	UnlimitedPathLen()(cert)
+	if cert.MaxPathLen != -1 || cert.MaxPathLenZero {
+		t.Errorf("UnlimitedPathLen() = %v/%v, want -1/false", cert.MaxPathLen, cert.MaxPathLenZero)
+	}
+}
+
+func TestMaxPathLen(t *testing.T) {
+	tests := []struct {
+		name     string
+		option   Option
+		caFirst  bool
+		want     int
+		wantZero bool
+	}{
+		{name: "two levels", option: MaxPathLen(2), caFirst: true, want: 2},
+		{name: "two levels before ca", option: MaxPathLen(2), want: 2},
+		{name: "zero", option: MaxPathLen(0), caFirst: true, want: 0, wantZero: true},
+		{name: "zero option", option: MaxPathLenZero(), caFirst: true, want: 0, wantZero: true},
+		{name: "negative", option: MaxPathLen(-5), caFirst: true, want: -1},
+		{name: "unlimited before ca", option: UnlimitedPathLen(), want: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert := &x509.Certificate{}
+			if tt.caFirst {
+				CA()(cert)
+				tt.option(cert)
+			} else {
+				tt.option(cert)
+				CA()(cert)
+			}
+			if cert.MaxPathLen != tt.want || cert.MaxPathLenZero != tt.wantZero {
+				t.Errorf("MaxPathLen() = %v/%v, want %v/%v", cert.MaxPathLen, cert.MaxPathLenZero, tt.want, tt.wantZero)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package pki
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/kemsta/go-easyrsa/pkg/pair"
+)
+
+// ErrCAKeyEncrypted is returned on use of encrypted CA key without passphrase provider
+var ErrCAKeyEncrypted = errors.New("ca key is encrypted, passphrase is required")
+
+// PassphraseProvider return passphrase of CA keys. It`s called every time CA key is encrypted or decrypted.
+type PassphraseProvider func() ([]byte, error)
+
+// PassphraseFromEnv return passphrase from environment variable
+func PassphraseFromEnv(name string) PassphraseProvider {
+	return func() ([]byte, error) {
+		value, ok := os.LookupEnv(name)
+		if !ok || value == "" {
+			return nil, fmt.Errorf("environment variable %v is empty", name)
+		}
+		return []byte(value), nil
+	}
+}
+
+// PassphraseFromFile return the first line of file, e.g. secret mounted by orchestrator
+func PassphraseFromFile(path string) PassphraseProvider {
+	return func() ([]byte, error) {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("can`t read passphrase file: %w", err)
+		}
+		defer pair.Wipe(content)
+		return firstLine(content)
+	}
+}
+
+// PassphraseFromCommand return the first line of command output, e.g. "pass show pki/ca" or "vault kv get ...".
+// Command is split by spaces, shell is not involved.
+func PassphraseFromCommand(command string) PassphraseProvider {
+	return func() ([]byte, error) {
+		fields := strings.Fields(command)
+		if len(fields) == 0 {
+			return nil, errors.New("empty passphrase command")
+		}
+		cmd := exec.Command(fields[0], fields[1:]...)
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return nil, fmt.Errorf("%v: %w: %s", command, err, bytes.TrimSpace(stderr.Bytes()))
+		}
+		defer pair.Wipe(stdout.Bytes())
+		return firstLine(stdout.Bytes())
+	}
+}
+
+// PassphraseFromPrompt write prompt to out and read line from in. Echo is disabled with stty when in is terminal.
+func PassphraseFromPrompt(prompt string, in io.Reader, out io.Writer) PassphraseProvider {
+	return func() ([]byte, error) {
+		_, _ = fmt.Fprint(out, prompt)
+		if file, ok := in.(*os.File); ok && isTerminal(file) {
+			if err := stty(file, "-echo"); err == nil {
+				defer func() {
+					_ = stty(file, "echo")
+					_, _ = fmt.Fprintln(out)
+				}()
+			}
+		}
+		line, err := bufio.NewReader(in).ReadBytes('\n')
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			return nil, fmt.Errorf("can`t read passphrase: %w", err)
+		}
+		defer pair.Wipe(line)
+		return firstLine(line)
+	}
+}
+
+// ParsePassphrase return provider by openssl -passin like spec: env:NAME, file:PATH, exec:COMMAND or stdin
+func ParsePassphrase(spec string) (PassphraseProvider, error) {
+	kind, value, _ := strings.Cut(spec, ":")
+	switch {
+	case kind == "env" && value != "":
+		return PassphraseFromEnv(value), nil
+	case kind == "file" && value != "":
+		return PassphraseFromFile(value), nil
+	case kind == "exec" && value != "":
+		return PassphraseFromCommand(value), nil
+	case spec == "stdin":
+		return PassphraseFromPrompt("Enter ca key passphrase: ", os.Stdin, os.Stderr), nil
+	}
+	return nil, fmt.Errorf("bad passphrase source %q, expected env:NAME, file:PATH, exec:COMMAND or stdin", spec)
+}
+
+// firstLine return copy of the first line without line break, it must not be empty
+func firstLine(content []byte) ([]byte, error) {
+	line := content
+	if i := bytes.IndexByte(content, '\n'); i >= 0 {
+		line = content[:i]
+	}
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	if len(line) == 0 {
+		return nil, errors.New("empty passphrase")
+	}
+	return append([]byte{}, line...), nil
+}
+
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func stty(file *os.File, arg string) error {
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = file
+	return cmd.Run()
+}
+
+// encodeCAKey encode CA key to pem encrypted with passphrase from WithCAPassphrase if it`s set.
+// Encrypted key is PKCS#8 with PBES2 and AES-256-CBC like keys of easy-rsa 3 and openssl genpkey.
+func (p *PKI) encodeCAKey(key crypto.Signer) ([]byte, error) {
+	if p.caPassphrase == nil {
+		return encodeKey(key)
+	}
+	passphrase, err := p.caPassphrase()
+	if err != nil {
+		return nil, fmt.Errorf("can`t get ca passphrase: %w", err)
+	}
+	defer pair.Wipe(passphrase)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("can`t encode ca key: %w", err)
+	}
+	defer pair.Wipe(der)
+	encrypted, err := encryptPKCS8(p.random(), der, passphrase, p.kdfRounds())
+	if err != nil {
+		return nil, fmt.Errorf("can`t encrypt ca key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: PEMEncryptedPrivateKeyBlock, Bytes: encrypted}), nil
+}
+
+// decryptKeyPEM return plain pem of encrypted key with passphrase from WithCAPassphrase. PKCS#8 encrypted keys
+// and legacy openssl pem encryption of easy-rsa 2 are supported. Not encrypted key is returned as is with
+// encrypted false, otherwise plain copy should be wiped after use.
+func (p *PKI) decryptKeyPEM(keyPEM []byte) (plain []byte, encrypted bool, err error) {
+	block, _ := pem.Decode(keyPEM)
+	if block != nil && block.Type == PEMEncryptedPrivateKeyBlock {
+		return p.decryptPKCS8PEM(block)
+	}
+	//nolint:staticcheck // legacy pem encryption is the format of easy-rsa 2 encrypted keys
+	if block == nil || !x509.IsEncryptedPEMBlock(block) {
+		return keyPEM, false, nil
+	}
+	if p.caPassphrase == nil {
+		return nil, true, ErrCAKeyEncrypted
+	}
+	passphrase, err := p.caPassphrase()
+	if err != nil {
+		return nil, true, fmt.Errorf("can`t get ca passphrase: %w", err)
+	}
+	defer pair.Wipe(passphrase)
+	//nolint:staticcheck // legacy pem encryption is the format of easy-rsa encrypted keys
+	der, err := x509.DecryptPEMBlock(block, passphrase)
+	if err != nil {
+		return nil, true, fmt.Errorf("can`t decrypt ca key: %w", err)
+	}
+	defer pair.Wipe(der)
+	return pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: der}), true, nil
+}
+
+// decryptPKCS8PEM return plain PKCS#8 pem of encrypted PKCS#8 block with passphrase from WithCAPassphrase
+func (p *PKI) decryptPKCS8PEM(block *pem.Block) (plain []byte, encrypted bool, err error) {
+	if p.caPassphrase == nil {
+		return nil, true, ErrCAKeyEncrypted
+	}
+	passphrase, err := p.caPassphrase()
+	if err != nil {
+		return nil, true, fmt.Errorf("can`t get ca passphrase: %w", err)
+	}
+	defer pair.Wipe(passphrase)
+	der, err := decryptPKCS8(block.Bytes, passphrase)
+	if err != nil {
+		return nil, true, fmt.Errorf("can`t decrypt ca key: %w", err)
+	}
+	defer pair.Wipe(der)
+	return pem.EncodeToMemory(&pem.Block{Type: PEMPKCS8PrivateKeyBlock, Bytes: der}), true, nil
+}
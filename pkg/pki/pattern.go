@@ -0,0 +1,36 @@
+package pki
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/kemsta/go-easyrsa/pkg/pair"
+)
+
+// GetByCNPattern return all pairs with name matching glob pattern of path.Match, e.g. "vpn-client-*",
+// so groups of identities can be handled without listing everything first. Storages which aren`t
+// a PatternReader are scanned completely.
+func (p *PKI) GetByCNPattern(pattern string) ([]*pair.X509Pair, error) {
+	return getByCNPattern(p.Storage, pattern)
+}
+
+// getByCNPattern query storage with pattern if it`s a PatternReader or filter all its pairs
+func getByCNPattern(storage KeyStorage, pattern string) ([]*pair.X509Pair, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("bad pattern %q: %w", pattern, err)
+	}
+	if reader, ok := storage.(PatternReader); ok {
+		return reader.GetByCNPattern(pattern)
+	}
+	pairs, err := storage.GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("can`t get pairs: %w", err)
+	}
+	res := make([]*pair.X509Pair, 0)
+	for _, p := range pairs {
+		if matched, _ := path.Match(pattern, p.CN); matched {
+			res = append(res, p)
+		}
+	}
+	return res, nil
+}
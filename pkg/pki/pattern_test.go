@@ -0,0 +1,43 @@
+package pki
+
+import (
+	"testing"
+
+	"github.com/kemsta/go-easyrsa/pkg/pair"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPKI_GetByCNPattern(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+	for _, cn := range []string{"vpn-client-1", "vpn-client-2", "vpn-server"} {
+		_, err := pki.NewCert(cn, Client())
+		assert.NoError(t, err)
+	}
+	names := func(pairs []*pair.X509Pair) []string {
+		res := make([]string, 0, len(pairs))
+		for _, p := range pairs {
+			res = append(res, p.CN)
+		}
+		return res
+	}
+
+	pairs, err := pki.GetByCNPattern("vpn-client-*")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"vpn-client-1", "vpn-client-2"}, names(pairs))
+	_, err = pki.GetByCNPattern("vpn-[")
+	assert.Error(t, err)
+
+	storage := pki.Storage
+	pki.Storage = NewChaosKeyStorage(storage, Faults{})
+	pairs, err = pki.GetByCNPattern("vpn-??????")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"vpn-server"}, names(pairs), "storage without pattern queries is scanned")
+	pki.Storage = storage
+
+	revoked, err := pki.RevokeWhere(CNPattern("vpn-client-*"))
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"vpn-client-1", "vpn-client-2"}, names(revoked))
+}
@@ -0,0 +1,295 @@
+package pki
+
+import (
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/kemsta/go-easyrsa/pkg/pair"
+)
+
+// pfxIterations is an iteration count of key derivation, the same as openssl uses
+const pfxIterations = 2048
+
+var (
+	oidData                 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidShroudedKeyBag       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidX509CertType         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidFriendlyName         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 20}
+	oidLocalKeyID           = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 21}
+	oidPBEWithSHAAnd3DESCBC = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 3}
+	oidSHA1                 = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+)
+
+type pfxContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue // explicitly tagged with pfxExplicit
+}
+
+type pfxSafeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue     // explicitly tagged with pfxExplicit
+	Attributes []pfxBagAttribute `asn1:"set,optional,omitempty"`
+}
+
+type pfxBagAttribute struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue `asn1:"set"`
+}
+
+type pfxCertBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+type pfxPBEParams struct {
+	Salt       []byte
+	Iterations int
+}
+
+type pfxEncryptedKey struct {
+	Algo pkix.AlgorithmIdentifier
+	Data []byte
+}
+
+type pfxDigestInfo struct {
+	Algo   pkix.AlgorithmIdentifier
+	Digest []byte
+}
+
+type pfxMacData struct {
+	Mac        pfxDigestInfo
+	Salt       []byte
+	Iterations int
+}
+
+type pfxPDU struct {
+	Version  int
+	AuthSafe pfxContentInfo
+	MacData  pfxMacData
+}
+
+// ExportPFX return PKCS#12 file with the last pair with cn and its chain up to the root, as Windows,
+// macOS keychain and browsers import it. Key is encrypted with pbeWithSHAAnd3-KeyTripleDES-CBC
+// and file is protected with HMAC-SHA1, which are supported by every Windows version.
+func (p *PKI) ExportPFX(cn, password string) ([]byte, error) {
+	last, err := p.Storage.GetLastByCn(cn)
+	if err != nil {
+		return nil, fmt.Errorf("can`t get %v: %w", cn, err)
+	}
+	key, cert, err := last.DecodeSigner()
+	if err != nil {
+		return nil, fmt.Errorf("can`t decode %v pair: %w", cn, err)
+	}
+	defer pair.WipeKey(key)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("can`t marshal %v key: %w", cn, err)
+	}
+	defer pair.Wipe(der)
+	chain, err := p.chainOf(cert)
+	if err != nil {
+		return nil, err
+	}
+	res, err := encodePFX(p.random(), der, chain, cn, password)
+	if err != nil {
+		return nil, fmt.Errorf("can`t encode %v pfx: %w", cn, err)
+	}
+	return res, nil
+}
+
+// encodePFX encode pkcs8 key and chain starting from its certificate to PKCS#12, see RFC 7292
+func encodePFX(rand io.Reader, key []byte, chain []*x509.Certificate, name, password string) ([]byte, error) {
+	if len(chain) == 0 {
+		return nil, errors.New("certificate is required")
+	}
+	localKeyID := sha1.Sum(chain[0].Raw)
+	attrs, err := pfxAttributes(name, localKeyID[:])
+	if err != nil {
+		return nil, err
+	}
+	certBags := make([]pfxSafeBag, 0, len(chain))
+	for i, cert := range chain {
+		bag, err := asn1.Marshal(pfxCertBag{ID: oidX509CertType, Data: cert.Raw})
+		if err != nil {
+			return nil, err
+		}
+		safeBag := pfxSafeBag{ID: oidCertBag, Value: pfxExplicit(bag)}
+		if i == 0 {
+			safeBag.Attributes = attrs
+		}
+		certBags = append(certBags, safeBag)
+	}
+	shrouded, err := pfxShroudKey(rand, key, password)
+	if err != nil {
+		return nil, err
+	}
+	keyBags := []pfxSafeBag{{ID: oidShroudedKeyBag, Value: pfxExplicit(shrouded), Attributes: attrs}}
+
+	contents := make([]pfxContentInfo, 0, 2)
+	for _, bags := range [][]pfxSafeBag{certBags, keyBags} {
+		info, err := pfxData(bags)
+		if err != nil {
+			return nil, err
+		}
+		contents = append(contents, info)
+	}
+	authSafe, err := asn1.Marshal(contents)
+	if err != nil {
+		return nil, err
+	}
+	macSalt := make([]byte, 8)
+	if _, err := io.ReadFull(rand, macSalt); err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha1.New, pfxKey(password, macSalt, 3, sha1.Size))
+	mac.Write(authSafe)
+	authSafeInfo, err := pfxOctets(authSafe)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(pfxPDU{
+		Version:  3,
+		AuthSafe: authSafeInfo,
+		MacData: pfxMacData{
+			Mac: pfxDigestInfo{
+				Algo:   pkix.AlgorithmIdentifier{Algorithm: oidSHA1, Parameters: asn1.NullRawValue},
+				Digest: mac.Sum(nil),
+			},
+			Salt:       macSalt,
+			Iterations: pfxIterations,
+		},
+	})
+}
+
+// pfxAttributes return friendlyName and localKeyId bag attributes which tie key and certificate together
+func pfxAttributes(name string, localKeyID []byte) ([]pfxBagAttribute, error) {
+	friendly, err := asn1.Marshal(asn1.RawValue{Tag: asn1.TagBMPString, Bytes: pfxBMPString(name, false)})
+	if err != nil {
+		return nil, err
+	}
+	keyID, err := asn1.Marshal(localKeyID)
+	if err != nil {
+		return nil, err
+	}
+	return []pfxBagAttribute{
+		{ID: oidFriendlyName, Value: asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: friendly}},
+		{ID: oidLocalKeyID, Value: asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: keyID}},
+	}, nil
+}
+
+// pfxShroudKey encrypt pkcs8 key to EncryptedPrivateKeyInfo with pbeWithSHAAnd3-KeyTripleDES-CBC
+func pfxShroudKey(rand io.Reader, key []byte, password string) ([]byte, error) {
+	salt := make([]byte, 8)
+	if _, err := io.ReadFull(rand, salt); err != nil {
+		return nil, err
+	}
+	block, err := des.NewTripleDESCipher(pfxKey(password, salt, 1, 24))
+	if err != nil {
+		return nil, err
+	}
+	padLen := block.BlockSize() - len(key)%block.BlockSize()
+	encrypted := make([]byte, len(key), len(key)+padLen)
+	copy(encrypted, key)
+	for i := 0; i < padLen; i++ {
+		encrypted = append(encrypted, byte(padLen))
+	}
+	cipher.NewCBCEncrypter(block, pfxKey(password, salt, 2, block.BlockSize())).CryptBlocks(encrypted, encrypted)
+	params, err := asn1.Marshal(pfxPBEParams{Salt: salt, Iterations: pfxIterations})
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(pfxEncryptedKey{
+		Algo: pkix.AlgorithmIdentifier{Algorithm: oidPBEWithSHAAnd3DESCBC, Parameters: asn1.RawValue{FullBytes: params}},
+		Data: encrypted,
+	})
+}
+
+// pfxData return data content info with safe contents of bags
+func pfxData(bags []pfxSafeBag) (pfxContentInfo, error) {
+	content, err := asn1.Marshal(bags)
+	if err != nil {
+		return pfxContentInfo{}, err
+	}
+	return pfxOctets(content)
+}
+
+// pfxOctets return data content info with content
+func pfxOctets(content []byte) (pfxContentInfo, error) {
+	octets, err := asn1.Marshal(content)
+	if err != nil {
+		return pfxContentInfo{}, err
+	}
+	return pfxContentInfo{ContentType: oidData, Content: pfxExplicit(octets)}, nil
+}
+
+// pfxExplicit wrap der to [0] EXPLICIT, asn1 package ignores explicit tag of raw values
+func pfxExplicit(der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der}
+}
+
+// pfxBMPString return big-endian utf-16 string, null terminated one is used as password
+func pfxBMPString(s string, terminated bool) []byte {
+	res := jksPassword(s)
+	if terminated {
+		res = append(res, 0, 0)
+	}
+	return res
+}
+
+// pfxKey derive size bytes of key material with SHA-1 for purpose id: 1 is a key, 2 is an iv, 3 is a mac key.
+// See RFC 7292 appendix B.2.
+func pfxKey(password string, salt []byte, id byte, size int) []byte {
+	const u, v = sha1.Size, 64
+	passwd := pfxBMPString(password, true)
+	d := make([]byte, v)
+	for i := range d {
+		d[i] = id
+	}
+	fill := func(src []byte) []byte {
+		res := make([]byte, v*((len(src)+v-1)/v))
+		for i := range res {
+			res[i] = src[i%len(src)]
+		}
+		return res
+	}
+	i := append(fill(salt), fill(passwd)...)
+	one := big.NewInt(1)
+	res := make([]byte, 0, size+u)
+	for len(res) < size {
+		h := sha1.New()
+		h.Write(d)
+		h.Write(i)
+		a := h.Sum(nil)
+		for r := 1; r < pfxIterations; r++ {
+			sum := sha1.Sum(a)
+			a = sum[:]
+		}
+		res = append(res, a...)
+		b := new(big.Int).SetBytes(fill(a))
+		b.Add(b, one)
+		for j := 0; j < len(i); j += v {
+			block := new(big.Int).SetBytes(i[j : j+v])
+			block.Add(block, b)
+			sum := block.Bytes()
+			if len(sum) > v {
+				sum = sum[len(sum)-v:]
+			}
+			chunk := i[j : j+v]
+			for k := range chunk {
+				chunk[k] = 0
+			}
+			copy(chunk[v-len(sum):], sum)
+		}
+	}
+	return res[:size]
+}
@@ -0,0 +1,106 @@
+package pki
+
+import (
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/asn1"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// readPFX check mac of PKCS#12 file and return its certificates and decrypted pkcs8 key
+func readPFX(t *testing.T, content []byte, password string) ([]*x509.Certificate, []byte, []pfxBagAttribute) {
+	var pdu pfxPDU
+	_, err := asn1.Unmarshal(content, &pdu)
+	must(t, assert.NoError(t, err))
+	assert.Equal(t, 3, pdu.Version)
+	var authSafe []byte
+	_, err = asn1.Unmarshal(pdu.AuthSafe.Content.Bytes, &authSafe)
+	must(t, assert.NoError(t, err))
+	mac := hmac.New(sha1.New, pfxKey(password, pdu.MacData.Salt, 3, sha1.Size))
+	mac.Write(authSafe)
+	must(t, assert.Equal(t, mac.Sum(nil), pdu.MacData.Mac.Digest, "pfx is tampered or password is incorrect"))
+
+	var contents []pfxContentInfo
+	_, err = asn1.Unmarshal(authSafe, &contents)
+	must(t, assert.NoError(t, err))
+	var certs []*x509.Certificate
+	var key []byte
+	var keyAttrs []pfxBagAttribute
+	for _, info := range contents {
+		assert.True(t, info.ContentType.Equal(oidData))
+		var safeContents []byte
+		_, err = asn1.Unmarshal(info.Content.Bytes, &safeContents)
+		must(t, assert.NoError(t, err))
+		var bags []pfxSafeBag
+		_, err = asn1.Unmarshal(safeContents, &bags)
+		must(t, assert.NoError(t, err))
+		for _, bag := range bags {
+			switch {
+			case bag.ID.Equal(oidCertBag):
+				var certBag pfxCertBag
+				_, err = asn1.Unmarshal(bag.Value.Bytes, &certBag)
+				must(t, assert.NoError(t, err))
+				cert, err := x509.ParseCertificate(certBag.Data)
+				must(t, assert.NoError(t, err))
+				certs = append(certs, cert)
+			case bag.ID.Equal(oidShroudedKeyBag):
+				var encrypted pfxEncryptedKey
+				_, err = asn1.Unmarshal(bag.Value.Bytes, &encrypted)
+				must(t, assert.NoError(t, err))
+				assert.True(t, encrypted.Algo.Algorithm.Equal(oidPBEWithSHAAnd3DESCBC))
+				var params pfxPBEParams
+				_, err = asn1.Unmarshal(encrypted.Algo.Parameters.FullBytes, &params)
+				must(t, assert.NoError(t, err))
+				block, err := des.NewTripleDESCipher(pfxKey(password, params.Salt, 1, 24))
+				must(t, assert.NoError(t, err))
+				key = make([]byte, len(encrypted.Data))
+				cipher.NewCBCDecrypter(block, pfxKey(password, params.Salt, 2, 8)).CryptBlocks(key, encrypted.Data)
+				key = key[:len(key)-int(key[len(key)-1])]
+				keyAttrs = bag.Attributes
+			default:
+				t.Fatalf("unexpected bag %v", bag.ID)
+			}
+		}
+	}
+	return certs, key, keyAttrs
+}
+
+func TestPKI_ExportPFX(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	ca, err := pki.NewCa()
+	assert.NoError(t, err)
+	client, err := pki.NewCert("client", Client())
+	assert.NoError(t, err)
+
+	_, err = pki.ExportPFX("nobody", "secret")
+	assert.Error(t, err)
+	for _, password := range []string{"secret", ""} {
+		content, err := pki.ExportPFX("client", password)
+		assert.NoError(t, err)
+		certs, key, attrs := readPFX(t, content, password)
+		if assert.Len(t, certs, 2) {
+			assert.Equal(t, client.Serial, certs[0].SerialNumber)
+			assert.Equal(t, ca.Serial, certs[1].SerialNumber)
+		}
+		signer, _, err := client.DecodeSigner()
+		assert.NoError(t, err)
+		der, err := x509.MarshalPKCS8PrivateKey(signer)
+		assert.NoError(t, err)
+		assert.Equal(t, der, key)
+		localKeyID := sha1.Sum(certs[0].Raw)
+		want, err := pfxAttributes("client", localKeyID[:])
+		assert.NoError(t, err)
+		if assert.Len(t, attrs, len(want)) {
+			for i := range want {
+				assert.True(t, want[i].ID.Equal(attrs[i].ID))
+				assert.Equal(t, want[i].Value.Bytes, attrs[i].Value.Bytes)
+			}
+		}
+	}
+}
@@ -1,16 +1,22 @@
 package pki
 
 import (
+	"bytes"
+	"context"
+	"crypto"
 	"crypto/rand"
-	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"errors"
 	"fmt"
+	"io"
 	"math/big"
 	"os"
 	"path"
 	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/kemsta/go-easyrsa/internal/fsStorage"
@@ -23,30 +29,123 @@ const (
 	PEMx509CRLBlock              = "X509 CRL"        // pem block header for CRL
 	DefaultKeySizeBytes   int    = 2048              // default key size in bytes
 	DefaultExpireYears           = 99                // default expire time for certs
+	DefaultCAName                = "ca"              // default name of CA pairs
 )
 
 // PKI struct holder
 type PKI struct {
-	Storage        KeyStorage
-	serialProvider SerialProvider
-	crlHolder      CRLHolder
-	subjTemplate   pkix.Name
+	Storage          KeyStorage
+	serialProvider   SerialProvider
+	crlHolder        CRLHolder
+	previousCRL      CRLHolder
+	crlNumber        SerialProvider
+	subjTemplate     pkix.Name
+	indexHolder      IndexHolder
+	hooks            map[EventType][]Hook
+	journal          Journal
+	lockObserver     func(LockWait)
+	lockTimeout      time.Duration
+	lockPeriod       time.Duration
+	trustStore       TrustStore
+	tokens           TokenStore
+	requests         RequestStore
+	defaultSANs      []SANRule
+	crlURLs          []string
+	fingerprintHash  crypto.Hash
+	validityPolicies map[Profile]ValidityPolicy
+	caName           string
+	chainStapling    ChainStapling
+	crlValidity      time.Duration
+	dnsGuard         *DNSGuard
+	ctLogs           []CTLog
+	auditLog         AuditLog
+	rand             io.Reader
+	signer           crypto.Signer
+	caPassphrase     PassphraseProvider
+	kdfIterations    int
+	profiles         map[Profile][]Option
+	keyGenProgress   func(KeyGenProgress)
+	fixtureKeys      bool
+	keyAlgorithm     KeyAlgorithm
+	keySize          int
+	validity         time.Duration
+	signatureAlg     x509.SignatureAlgorithm
+	crlPruneAfter    time.Duration
+	clock            func() time.Time
+	locksMu          sync.Mutex
+	locks            map[string]*sync.Mutex
+}
+
+// random return entropy source of PKI, crypto/rand by default
+func (p *PKI) random() io.Reader {
+	if p.rand != nil {
+		return p.rand
+	}
+	return rand.Reader
+}
+
+// kdfRounds return PBKDF2 iteration count for new encrypted keys, DefaultKDFIterations by default
+func (p *PKI) kdfRounds() int {
+	if p.kdfIterations > 0 {
+		return p.kdfIterations
+	}
+	return DefaultKDFIterations
+}
+
+// now return current time of PKI clock, time.Now by default
+func (p *PKI) now() time.Time {
+	if p.clock != nil {
+		return p.clock()
+	}
+	return time.Now()
 }
 
 // NewPKI PKI struct "constructor"
-func NewPKI(storage KeyStorage, sp SerialProvider, crlHolder CRLHolder, subjTemplate pkix.Name) *PKI {
-	return &PKI{Storage: storage, serialProvider: sp, crlHolder: crlHolder, subjTemplate: subjTemplate}
+func NewPKI(storage KeyStorage, sp SerialProvider, crlHolder CRLHolder, subjTemplate pkix.Name, opts ...PKIOption) *PKI {
+	res := &PKI{Storage: storage, serialProvider: sp, crlHolder: crlHolder, subjTemplate: subjTemplate}
+	for _, opt := range opts {
+		opt(res)
+	}
+	res.configureLocks(res.Storage, res.serialProvider, res.crlHolder, res.crlNumber, res.indexHolder, res.auditLog)
+	return res
+}
+
+// configureLocks pass lock waits of holders supporting it to lock observer and set their lock timing
+func (p *PKI) configureLocks(holders ...interface{}) {
+	for _, holder := range holders {
+		if observed, ok := holder.(interface{ ObserveLocks(func(LockWait)) }); ok && p.lockObserver != nil {
+			observed.ObserveLocks(p.lockObserver)
+		}
+		if timed, ok := holder.(interface {
+			LockTiming(timeout, period time.Duration)
+		}); ok {
+			timed.LockTiming(p.lockTimeout, p.lockPeriod)
+		}
+	}
 }
 
-// Init default pki with file storages
-func InitPKI(pkiDir string, subjTemplate *pkix.Name) (*PKI, error) {
+// Init default pki with file storages. Trusted CA certificates are kept in .trusted dir by default.
+// CRL of CA with name other than DefaultCAName is kept in NAME.crl.pem, so several roots can share pkiDir.
+// CRL of previous CA during rotation overlap is kept in previous.crl.pem or NAME.previous.crl.pem.
+// CRL numbers are counted in crlnumber file shared by all roots.
+func InitPKI(pkiDir string, subjTemplate *pkix.Name, opts ...PKIOption) (*PKI, error) {
 	if subjTemplate == nil {
 		subjTemplate = &pkix.Name{}
 	}
 	pki := NewPKI(fsStorage.NewDirKeyStorage(pkiDir),
 		fsStorage.NewFileSerialProvider(path.Join(pkiDir, "serial")),
 		fsStorage.NewFileCRLHolder(path.Join(pkiDir, "crl.pem")),
-		*subjTemplate)
+		*subjTemplate,
+		append([]PKIOption{
+			WithTrustStoreDir(path.Join(pkiDir, ".trusted")),
+			WithPreviousCRLHolder(fsStorage.NewFileCRLHolder(path.Join(pkiDir, "previous.crl.pem"))),
+			WithCRLNumber(fsStorage.NewFileSerialProvider(path.Join(pkiDir, "crlnumber"))),
+		}, opts...)...)
+	if name := pki.CAName(); name != DefaultCAName {
+		pki.crlHolder = fsStorage.NewFileCRLHolder(path.Join(pkiDir, name+".crl.pem"))
+		pki.previousCRL = fsStorage.NewFileCRLHolder(path.Join(pkiDir, name+".previous.crl.pem"))
+		pki.configureLocks(pki.crlHolder, pki.previousCRL)
+	}
 
 	if _, err := os.Stat(pkiDir); os.IsNotExist(err) {
 		if err := os.MkdirAll(pkiDir, 0750); err != nil {
@@ -56,180 +155,715 @@ func InitPKI(pkiDir string, subjTemplate *pkix.Name) (*PKI, error) {
 	return pki, nil
 }
 
-// NewCa creating new version self signed CA pair
+// NewCa creating new version self signed CA pair. Use NewCaContext for options which aren`t Option.
 func (p *PKI) NewCa(opts ...Option) (*pair.X509Pair, error) {
-	key, err := rsa.GenerateKey(rand.Reader, DefaultKeySizeBytes)
+	return p.NewCaContext(context.Background(), certificateOptions(opts)...)
+}
+
+// NewCaContext is NewCa cancelled with ctx during key generation, it takes any CertificateOption
+func (p *PKI) NewCaContext(ctx context.Context, opts ...CertificateOption) (*pair.X509Pair, error) {
+	res, _, err := p.NewCaGenerationContext(ctx, opts...)
+	return res, err
+}
+
+// NewCaGeneration creating new version self signed CA pair and return its generation number starting from 1.
+// CA creation is exclusive, so concurrent callers get sequential generations with increasing serials.
+func (p *PKI) NewCaGeneration(opts ...CertificateOption) (*pair.X509Pair, int, error) {
+	return p.NewCaGenerationContext(context.Background(), opts...)
+}
+
+// NewCaGenerationContext is NewCaGeneration cancelled with ctx. Nothing is stored if ctx is done before signing.
+func (p *PKI) NewCaGenerationContext(ctx context.Context, opts ...CertificateOption) (*pair.X509Pair, int, error) {
+	unlock, err := p.lock(p.CAName())
 	if err != nil {
-		return nil, fmt.Errorf("can`t generate key: %w", err)
+		return nil, 0, fmt.Errorf("can`t lock ca creation: %w", err)
 	}
+	defer unlock()
 
-	subj := p.subjTemplate
-	subj.CommonName = "ca"
+	generation := 1
+	if caPairs, err := p.Storage.GetByCN(p.CAName()); err == nil {
+		generation += len(caPairs)
+	}
 
-	serial, err := p.serialProvider.Next()
-	if err != nil {
-		return nil, fmt.Errorf("can`t get next serial: %w", err)
+	signer, keyPem := p.signer, []byte(nil)
+	if signer == nil {
+		key, err := p.newKey(ctx)
+		if err != nil {
+			return nil, 0, err
+		}
+		defer pair.WipeKey(key)
+		if keyPem, err = p.encodeCAKey(key); err != nil {
+			return nil, 0, err
+		}
+		signer = key
 	}
 
-	now := time.Now()
+	subj := p.subjTemplate
+	subj.CommonName = p.CAName()
+
+	now := p.now()
 
 	template := x509.Certificate{
-		SerialNumber:          serial,
-		Subject:               subj,
-		NotBefore:             now.Add(-10 * time.Minute).UTC(),
-		NotAfter:              now.Add(time.Duration(24*365*DefaultExpireYears) * time.Hour).UTC(),
-		BasicConstraintsValid: true,
-		IsCA:                  true,
-		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		Subject:   subj,
+		NotBefore: now.Add(-10 * time.Minute).UTC(),
+		NotAfter:  now.Add(p.defaultValidity()).UTC(),
+	}
+
+	if _, err := newIssuance(&template, []CertificateOption{CA()}, p.cryptoDefaults(), opts); err != nil {
+		return nil, 0, err
+	}
+	if err := checkSignatureAlgorithm(template.SignatureAlgorithm, signer.Public()); err != nil {
+		return nil, 0, err
 	}
 
-	Apply(opts, &template)
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+	serial, err := p.nextSerial()
+	if err != nil {
+		return nil, 0, fmt.Errorf("can`t get next serial: %w", err)
+	}
+	template.SerialNumber = serial
 
-	certificate, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	certificate, err := x509.CreateCertificate(p.random(), &template, &template, signer.Public(), signer)
 	if err != nil {
-		return nil, fmt.Errorf("can`t create cert: %w", err)
+		return nil, 0, fmt.Errorf("can`t create cert: %w", err)
 	}
 
 	res := pair.NewX509Pair(
-		pem.EncodeToMemory(&pem.Block{
-			Type:  PEMRSAPrivateKeyBlock,
-			Bytes: x509.MarshalPKCS1PrivateKey(key),
-		}),
+		keyPem,
 		pem.EncodeToMemory(&pem.Block{
 			Type:  PEMCertificateBlock,
 			Bytes: certificate,
 		}),
-		"ca",
+		p.CAName(),
 		serial)
 	err = p.Storage.Put(res)
 	if err != nil {
-		return nil, fmt.Errorf("can't put generated cert into storage: %w", err)
+		return nil, 0, fmt.Errorf("can't put generated cert into storage: %w", err)
 	}
-	return res, nil
+	if err := p.exportIndex(); err != nil {
+		return nil, 0, fmt.Errorf("can`t export index: %w", err)
+	}
+	if err := p.runHooks(EventIssue, res); err != nil {
+		return res, generation, err
+	}
+	return res, generation, nil
 }
 
-// NewCert generate new pair signed by last CA key
+// NewCert generate new pair signed by last CA key. Use NewCertContext or Issue for options which aren`t Option,
+// like IssuedBy.
 func (p *PKI) NewCert(cn string, opts ...Option) (*pair.X509Pair, error) {
-	caPair, err := p.GetLastCA()
-	if err != nil {
-		return nil, fmt.Errorf("can`t get ca pair: %w", err)
+	return p.Issue(Identity{CommonName: cn}, certificateOptions(opts)...)
+}
+
+// NewCertContext is NewCert cancelled with ctx during key generation, it takes any CertificateOption
+func (p *PKI) NewCertContext(ctx context.Context, cn string, opts ...CertificateOption) (*pair.X509Pair, error) {
+	return p.IssueContext(ctx, Identity{CommonName: cn}, opts...)
+}
+
+// NewServerCert generate new pair for cn with options of ProfileServer
+func (p *PKI) NewServerCert(cn string, opts ...CertificateOption) (*pair.X509Pair, error) {
+	return p.Issue(Identity{CommonName: cn, Profile: ProfileServer}, opts...)
+}
+
+// NewClientCert generate new pair for cn with options of ProfileClient
+func (p *PKI) NewClientCert(cn string, opts ...CertificateOption) (*pair.X509Pair, error) {
+	return p.Issue(Identity{CommonName: cn, Profile: ProfileClient}, opts...)
+}
+
+// ProfileOptions return options of profile set by WithProfile or built-in ones
+func (p *PKI) ProfileOptions(profile Profile) ([]Option, error) {
+	if opts, ok := p.profiles[profile]; ok {
+		return opts, nil
 	}
-	caKey, caCert, err := caPair.Decode()
+	return profile.options()
+}
+
+// Issue generate new pair for identity signed by last CA key or by CA from IssuedBy option.
+// Pair is stored with identity key.
+func (p *PKI) Issue(id Identity, opts ...CertificateOption) (*pair.X509Pair, error) {
+	return p.IssueContext(context.Background(), id, opts...)
+}
+
+// IssueContext is Issue cancelled with ctx. Nothing is stored and no serial is consumed if ctx is done before signing.
+func (p *PKI) IssueContext(ctx context.Context, id Identity, opts ...CertificateOption) (*pair.X509Pair, error) {
+	return p.issue(ctx, id, nil, nil, opts)
+}
+
+// issue sign certificate for identity with public key. New key is generated and stored with pair if public is nil,
+// otherwise keyPEM of public key is stored if it`s known. If labels or csr of stored pair can`t be saved, index is
+// exported anyway and the pair is returned with the error.
+func (p *PKI) issue(ctx context.Context, id Identity, public crypto.PublicKey, keyPEM []byte,
+	opts []CertificateOption) (*pair.X509Pair, error) {
+	if p.reservedName(id.Key()) {
+		return nil, fmt.Errorf("can`t issue %v: %w", id.Key(), ErrReservedName)
+	}
+	iss, decision, err := p.newLeafIssuance(id, opts)
 	if err != nil {
-		return nil, fmt.Errorf("can`t parse ca pair: %w", err)
+		if decision != nil {
+			if auditErr := p.audit(*decision); auditErr != nil {
+				return nil, auditErr
+			}
+		}
+		return nil, err
+	}
+	tmpl := iss.template
+	if decision, err := p.guardDNS(ctx, id, tmpl); err != nil {
+		if auditErr := p.audit(*decision); auditErr != nil {
+			return nil, auditErr
+		}
+		return nil, err
+	}
+	if _, ok := p.Storage.(MetadataStore); len(iss.labels) > 0 && !ok {
+		return nil, fmt.Errorf("can`t label certificate: %w", errNoMetadataStore)
 	}
 
-	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	caPair, err := p.getIssuer(iss)
 	if err != nil {
-		return nil, fmt.Errorf("can`t create private key: %w", err)
+		return nil, fmt.Errorf("can`t get ca pair: %w", err)
 	}
-
-	serial, err := p.serialProvider.Next()
+	caKey, caCert, release, err := p.caSigner(caPair)
 	if err != nil {
+		return nil, fmt.Errorf("can`t parse ca pair: %w", err)
+	}
+	defer release()
+	if !caCert.IsCA {
+		return nil, fmt.Errorf("pair %v with serial %v is not a ca", caPair.CN, caPair.Serial)
+	}
+	if p.now().After(caCert.NotAfter) {
+		return nil, fmt.Errorf("%v with serial %v: %w", caPair.CN, caPair.Serial, ErrCAExpired)
+	}
+	if err := p.applyLeafDefaults(tmpl, caPair.CN); err != nil {
+		return nil, err
+	}
+	iss.capDefaultExpiry(caCert)
+	if err := checkSignatureAlgorithm(tmpl.SignatureAlgorithm, caKey.Public()); err != nil {
 		return nil, err
 	}
 
-	now := time.Now()
-	subj := p.subjTemplate
-	subj.CommonName = cn
-	tmpl := x509.Certificate{
-		NotBefore:             now.Add(-10 * time.Minute).UTC(),
-		NotAfter:              now.Add(time.Duration(24*365*DefaultExpireYears) * time.Hour).UTC(),
-		SerialNumber:          serial,
-		Subject:               subj,
-		BasicConstraintsValid: true,
+	priKeyPem := keyPEM
+	if public == nil {
+		key, err := p.newKey(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer pair.WipeKey(key)
+		if priKeyPem, err = encodeKey(key); err != nil {
+			return nil, err
+		}
+		public = key.Public()
 	}
 
-	Apply(opts, &tmpl)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	serial := iss.serial
+	if serial == nil {
+		if serial, err = p.nextSerial(); err != nil {
+			return nil, err
+		}
+	}
+	tmpl.SerialNumber = serial
 
 	// Sign with CA's private key
-	cert, err := x509.CreateCertificate(rand.Reader, &tmpl, caCert, &key.PublicKey, caKey)
+	cert, err := p.signLeaf(ctx, tmpl, caCert, public, caKey)
 	if err != nil {
 		return nil, fmt.Errorf("certificate cannot be created: %w", err)
 	}
-
-	priKeyPem := pem.EncodeToMemory(&pem.Block{
-		Type:  PEMRSAPrivateKeyBlock,
-		Bytes: x509.MarshalPKCS1PrivateKey(key),
-	})
+	if decision != nil {
+		decision.Fingerprint = p.fingerprint(cert)
+		if err := p.audit(*decision); err != nil {
+			return nil, err
+		}
+	}
 
 	certPem := pem.EncodeToMemory(&pem.Block{
 		Type:  PEMCertificateBlock,
 		Bytes: cert,
 	})
 
-	res := pair.NewX509Pair(priKeyPem, certPem, cn, serial)
+	res := pair.NewX509Pair(priKeyPem, certPem, id.Key(), serial)
 
-	err = p.Storage.Put(res)
+	err = p.putLeaf(res, caCert)
 	if err != nil {
 		return nil, err
 	}
+	var metaErr error
+	if len(iss.labels) > 0 {
+		if err := p.Label(res.CN, iss.labels); err != nil {
+			metaErr = fmt.Errorf("can`t label %v: %w", res.CN, err)
+		}
+	}
+	if store, ok := p.Storage.(CSRStore); ok && iss.csr != nil && metaErr == nil {
+		if err := store.PutCSR(res, iss.csr); err != nil {
+			metaErr = fmt.Errorf("can`t put csr of %v: %w", res.CN, err)
+		}
+	}
+	if err := p.exportIndex(); err != nil {
+		return nil, fmt.Errorf("can`t export index: %w", err)
+	}
+	if metaErr != nil {
+		return res, metaErr
+	}
+	if err := p.runHooks(EventIssue, res); err != nil {
+		return res, err
+	}
 	return res, nil
 }
 
+// Preview return certificate template which NewCert would sign for cn with the same options.
+// Nothing is generated or stored and no serial is consumed, so inputs can be validated first.
+func (p *PKI) Preview(cn string, opts ...CertificateOption) (*x509.Certificate, error) {
+	return p.PreviewIdentity(Identity{CommonName: cn}, opts...)
+}
+
+// PreviewIdentity return certificate template which Issue would sign for identity with the same options.
+// Serial number is nil, issuer is the subject of signing CA.
+func (p *PKI) PreviewIdentity(id Identity, opts ...CertificateOption) (*x509.Certificate, error) {
+	tmpl, _, err := p.previewWithIssuer(id, opts)
+	return tmpl, err
+}
+
+// CheckIssuerExpiry return OutlivesIssuerError if certificate which Issue would sign for identity
+// expires after its signing CA. Default expiration is capped by CA expiration, so only requested one can.
+func (p *PKI) CheckIssuerExpiry(id Identity, opts ...CertificateOption) error {
+	tmpl, caCert, err := p.previewWithIssuer(id, opts)
+	if err != nil {
+		return err
+	}
+	if tmpl.NotAfter.After(caCert.NotAfter) {
+		return &OutlivesIssuerError{NotAfter: tmpl.NotAfter, Issuer: caCert.Subject.CommonName, IssuerNotAfter: caCert.NotAfter}
+	}
+	return nil
+}
+
+// ErrCAExpired is returned on attempt to sign certificate with expired CA
+var ErrCAExpired = errors.New("ca is expired")
+
+// ErrReservedName is returned on attempt to issue leaf certificate stored with name of CA pairs
+var ErrReservedName = errors.New("name is reserved for ca")
+
+// reservedName return true if name is a storage name of PKI CA, its cross certificates or its offline root
+func (p *PKI) reservedName(name string) bool {
+	return name == p.CAName() || name == p.CrossName() || name == p.RootName()
+}
+
+// OutlivesIssuerError describe certificate expiring after its signing CA. Clients stop trusting it
+// when CA expires.
+type OutlivesIssuerError struct {
+	NotAfter       time.Time // certificate expiration
+	Issuer         string    // CA common name
+	IssuerNotAfter time.Time // CA expiration
+}
+
+func (e *OutlivesIssuerError) Error() string {
+	return fmt.Sprintf("certificate valid until %v outlives ca %v expiring at %v",
+		e.NotAfter.Format(time.RFC3339), e.Issuer, e.IssuerNotAfter.Format(time.RFC3339))
+}
+
+// previewWithIssuer return certificate template for identity and certificate of its signing CA
+func (p *PKI) previewWithIssuer(id Identity, opts []CertificateOption) (*x509.Certificate, *x509.Certificate, error) {
+	iss, _, err := p.newLeafIssuance(id, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	caPair, err := p.getIssuer(iss)
+	if err != nil {
+		return nil, nil, fmt.Errorf("can`t get ca pair: %w", err)
+	}
+	caCert, err := caPair.DecodeCert()
+	if err != nil {
+		return nil, nil, fmt.Errorf("can`t parse ca pair: %w", err)
+	}
+	if !caCert.IsCA {
+		return nil, nil, fmt.Errorf("pair %v with serial %v is not a ca", caPair.CN, caPair.Serial)
+	}
+	if err := p.applyLeafDefaults(iss.template, caPair.CN); err != nil {
+		return nil, nil, err
+	}
+	iss.capDefaultExpiry(caCert)
+	if err := checkSignatureAlgorithm(iss.template.SignatureAlgorithm, caCert.PublicKey); err != nil {
+		return nil, nil, err
+	}
+	iss.template.Issuer = caCert.Subject
+	return iss.template, caCert, nil
+}
+
+// newLeafIssuance resolve template and signing settings of leaf certificate for identity.
+// Validity policy decision is returned for audit if policy was applied.
+func (p *PKI) newLeafIssuance(id Identity, opts []CertificateOption) (*issuance, *AuditRecord, error) {
+	profileOpts, err := p.ProfileOptions(id.Profile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("bad identity: %w", err)
+	}
+	idOpts, err := id.options(profileOpts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("bad identity: %w", err)
+	}
+
+	now := p.now()
+	defaultNotAfter := now.Add(p.defaultValidity()).UTC()
+	tmpl := &x509.Certificate{
+		NotBefore:             now.Add(-10 * time.Minute).UTC(),
+		NotAfter:              defaultNotAfter,
+		Subject:               p.subjTemplate,
+		BasicConstraintsValid: true,
+	}
+
+	iss, err := newIssuance(tmpl, p.cryptoDefaults(), idOpts, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !iss.noDefaultSANs {
+		p.applyDefaultSANs(tmpl)
+	}
+	decision, err := p.enforceValidity(id, tmpl, now)
+	if err != nil {
+		return nil, decision, err
+	}
+	iss.defaultExpiry = tmpl.NotAfter.Equal(defaultNotAfter)
+	return iss, decision, nil
+}
+
 // GetCRL return current revoke list
 func (p *PKI) GetCRL() (*pkix.CertificateList, error) {
 	return p.crlHolder.Get()
 }
 
-// GetLastCA return last CA pair
+// GetLastCA return CA pair with the greatest serial among pairs with name of PKI CA which can sign: certificate is
+// a CA and key is stored or kept by signer from WithSigner. Other pairs stored with the name are skipped.
 func (p *PKI) GetLastCA() (*pair.X509Pair, error) {
-	return p.Storage.GetLastByCn("ca")
+	last, err := p.Storage.GetLastByCn(p.CAName())
+	if err != nil || p.canSign(last) {
+		return last, err
+	}
+	pairs, err := p.Storage.GetByCN(p.CAName())
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].Serial.Cmp(pairs[j].Serial) == 1
+	})
+	for _, caPair := range pairs {
+		if p.canSign(caPair) {
+			return caPair, nil
+		}
+	}
+	return nil, fmt.Errorf("ca %v which can sign %w", p.CAName(), ErrNotFound)
 }
 
-// RevokeOne revoke one pair with serial
-func (p *PKI) RevokeOne(serial *big.Int) error {
-	list := make([]pkix.RevokedCertificate, 0)
-	if oldList, err := p.GetCRL(); err == nil {
-		list = oldList.TBSCertList.RevokedCertificates
+// canSign return true if pair is a CA with key in storage or with key of signer
+func (p *PKI) canSign(caPair *pair.X509Pair) bool {
+	cert, err := caPair.DecodeCert()
+	if err != nil || !cert.IsCA {
+		return false
+	}
+	return p.signer != nil || len(caPair.KeyPemBytes) > 0
+}
+
+// CAName return name of CA pairs set by WithCAName, DefaultCAName by default
+func (p *PKI) CAName() string {
+	if p.caName != "" {
+		return p.caName
+	}
+	return DefaultCAName
+}
+
+// CheckName verify that name is a valid name of new pair for fs storage: a single path element which isn`t
+// hidden. Use it before naming files after pairs, e.g. key and request of NewRequest.
+func CheckName(name string) error {
+	return fsStorage.CheckName(name)
+}
+
+// GetTrustBundle return all non-expired CA and intermediate certificates concatenated as pem.
+// It's the content of ca.crt file for clients.
+func (p *PKI) GetTrustBundle() ([]byte, error) {
+	caPairs, caCerts, err := p.validCAs()
+	if err != nil {
+		return nil, err
+	}
+	var bundle bytes.Buffer
+	for i, cert := range caCerts {
+		if err := pem.Encode(&bundle, &pem.Block{Type: PEMCertificateBlock, Bytes: cert.Raw}); err != nil {
+			return nil, fmt.Errorf("can`t encode %v cert: %w", caPairs[i].CN, err)
+		}
+	}
+	return bundle.Bytes(), nil
+}
+
+// JWKS return public keys of all non-expired CA and intermediate certificates as json web key set
+func (p *PKI) JWKS() (*pair.JWKS, error) {
+	caPairs, _, err := p.validCAs()
+	if err != nil {
+		return nil, err
 	}
-	caPairs, err := p.Storage.GetByCN("ca")
+	res := &pair.JWKS{Keys: make([]pair.JWK, 0, len(caPairs))}
+	for _, caPair := range caPairs {
+		jwk, err := caPair.PublicJWK()
+		if err != nil {
+			return nil, err
+		}
+		res.Keys = append(res.Keys, *jwk)
+	}
+	return res, nil
+}
+
+// CANames return sorted names of all roots in storage, see WithCAName
+func (p *PKI) CANames() ([]string, error) {
+	pairs, err := p.allCerts()
+	if err != nil {
+		return nil, fmt.Errorf("can`t get pairs: %w", err)
+	}
+	roots, err := rootNames(pairs)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]string, 0, len(roots))
+	for name := range roots {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// rootNames return set of names of self signed CA pairs
+func rootNames(pairs []*pair.X509Pair) (map[string]bool, error) {
+	res := make(map[string]bool)
+	for _, certPair := range pairs {
+		cert, err := certPair.DecodeCert()
+		if err != nil {
+			return nil, err
+		}
+		if isRoot(cert) {
+			res[certPair.CN] = true
+		}
+	}
+	return res, nil
+}
+
+// isRoot return true if cert is a self signed CA certificate
+func isRoot(cert *x509.Certificate) bool {
+	return cert.IsCA && bytes.Equal(cert.RawIssuer, cert.RawSubject) && cert.CheckSignatureFrom(cert) == nil
+}
+
+// foreignCA return true if name is a name of other root than PKI CA, its cross certificates or its offline root
+func (p *PKI) foreignCA(name string, roots map[string]bool) bool {
+	if p.reservedName(name) {
+		return false
+	}
+	return roots[name] || roots[strings.TrimSuffix(name, crossSuffix)]
+}
+
+// validCAs return all non-expired CA and intermediate pairs which aren`t retired by rotations with decoded certificates sorted by serial.
+// Other roots in storage and their cross certificates are skipped.
+func (p *PKI) validCAs() ([]*pair.X509Pair, []*x509.Certificate, error) {
+	pairs, err := p.allCerts()
 	if err != nil {
-		return fmt.Errorf("can`t get ca certs for signing crl: %w", err)
+		return nil, nil, fmt.Errorf("can`t get pairs: %w", err)
 	}
-	sort.Slice(caPairs, func(i, j int) bool {
-		return caPairs[i].Serial.Cmp(caPairs[j].Serial) == 1
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].Serial.Cmp(pairs[j].Serial) == -1
 	})
-	caKey, caCert, err := caPairs[0].Decode()
+	now := p.now()
+	rotations, err := p.Rotations()
+	if err != nil {
+		return nil, nil, fmt.Errorf("can`t get ca rotations: %w", err)
+	}
+	roots, err := rootNames(pairs)
 	if err != nil {
-		return fmt.Errorf("can`t decode ca certs for signing crl: %w", err)
+		return nil, nil, err
 	}
-	list = append(list, pkix.RevokedCertificate{
+	caPairs := make([]*pair.X509Pair, 0)
+	caCerts := make([]*x509.Certificate, 0)
+	for _, certPair := range pairs {
+		cert, err := certPair.DecodeCert()
+		if err != nil {
+			return nil, nil, err
+		}
+		if !cert.IsCA || now.After(cert.NotAfter) || retired(rotations, certPair.Serial, now) || p.foreignCA(certPair.CN, roots) {
+			continue
+		}
+		caPairs = append(caPairs, certPair)
+		caCerts = append(caCerts, cert)
+	}
+	if len(caPairs) == 0 {
+		return nil, nil, fmt.Errorf("there are no valid ca certificates")
+	}
+	return caPairs, caCerts, nil
+}
+
+// lock exclusive operation with name within process and across processes if storage is a Locker.
+// Every name has its own mutex, so e.g. hook revoking a pair while CA is created doesn`t wait for itself.
+// Locks aren`t reentrant, operation holding lock with name must not take it again.
+func (p *PKI) lock(name string) (unlock func(), err error) {
+	mu := p.nameMutex(name)
+	mu.Lock()
+	locker, ok := p.Storage.(Locker)
+	if !ok {
+		return mu.Unlock, nil
+	}
+	storageUnlock, err := locker.Lock(name)
+	if err != nil {
+		mu.Unlock()
+		return nil, err
+	}
+	return func() {
+		_ = storageUnlock()
+		mu.Unlock()
+	}, nil
+}
+
+// nameMutex return in-process mutex of lock with name
+func (p *PKI) nameMutex(name string) *sync.Mutex {
+	p.locksMu.Lock()
+	defer p.locksMu.Unlock()
+	if p.locks == nil {
+		p.locks = make(map[string]*sync.Mutex)
+	}
+	mu, ok := p.locks[name]
+	if !ok {
+		mu = &sync.Mutex{}
+		p.locks[name] = mu
+	}
+	return mu
+}
+
+// getIssuer return CA pair with serial of issuance, the last CA with its issuer name or the last CA of PKI
+func (p *PKI) getIssuer(iss *issuance) (*pair.X509Pair, error) {
+	switch {
+	case iss.issuer != nil:
+		return p.Storage.GetBySerial(iss.issuer)
+	case iss.issuerName != "":
+		return p.Storage.GetLastByCn(iss.issuerName)
+	default:
+		return p.GetLastCA()
+	}
+}
+
+// RevokeOne revoke one pair with serial. Options can add reason and invalidity date into CRL entry.
+func (p *PKI) RevokeOne(serial *big.Int, opts ...RevokeOption) error {
+	entry := pkix.RevokedCertificate{
 		SerialNumber:   serial,
-		RevocationTime: time.Now(),
-	})
-	crlBytes, err := caCert.CreateCRL(
-		rand.Reader, caKey, removeDups(list), time.Now(), time.Now().Add(DefaultExpireYears*365*24*time.Hour))
+		RevocationTime: p.now(),
+	}
+	for _, opt := range opts {
+		opt(&entry)
+	}
+	id, err := p.beginRevokeIntent(entry)
 	if err != nil {
-		return fmt.Errorf("can`t create crl: %w", err)
+		return err
+	}
+	if err := p.revoke(entry); err != nil {
+		return err
+	}
+	if err := p.commitIntent(id); err != nil {
+		return err
 	}
-	crlPem := pem.EncodeToMemory(&pem.Block{
-		Type:  PEMx509CRLBlock,
-		Bytes: crlBytes,
+	return p.runHooks(EventRevoke, p.revokedPair(serial))
+}
+
+// revoke add entry into CRL and export index. Entry of held certificate is replaced by entry with other reason,
+// other entries with the same serial are kept.
+func (p *PKI) revoke(entry pkix.RevokedCertificate) error {
+	return p.updateCRL(func(list []pkix.RevokedCertificate) ([]pkix.RevokedCertificate, error) {
+		if revocationReason(entry) != ReasonCertificateHold {
+			list = removeHeld(list, entry.SerialNumber)
+		}
+		return append(list, entry), nil
 	})
+}
+
+// updateCRL sign CRL with entries changed by change under lock and export index.
+// Entries of long-expired certificates are pruned if WithCRLPruning is set.
+func (p *PKI) updateCRL(change func([]pkix.RevokedCertificate) ([]pkix.RevokedCertificate, error)) error {
+	unlock, err := p.lock("crl")
+	if err != nil {
+		return fmt.Errorf("can`t lock crl: %w", err)
+	}
+	defer unlock()
+	list := make([]pkix.RevokedCertificate, 0)
+	oldList, err := p.GetCRL()
+	if err == nil {
+		list = oldList.TBSCertList.RevokedCertificates
+	}
+	list, err = change(list)
+	if err != nil {
+		return err
+	}
+	if p.crlPruneAfter > 0 {
+		if list, _, err = p.pruneExpired(list, p.crlPruneAfter); err != nil {
+			return err
+		}
+	}
+	caPair, err := p.GetLastCA()
+	if err != nil {
+		return fmt.Errorf("can`t get ca cert for signing crl: %w", err)
+	}
+	number, err := p.nextCRLNumber(oldList)
+	if err != nil {
+		return err
+	}
+	crlPem, err := p.newCrl(caPair, list, number)
+	if err != nil {
+		return err
+	}
 	err = p.crlHolder.Put(crlPem)
 	if err != nil {
 		return fmt.Errorf("can`t put new crl: %w", err)
 	}
+	if err := p.updatePreviousCRL(list, number); err != nil {
+		return err
+	}
+	if err := p.exportIndex(); err != nil {
+		return fmt.Errorf("can`t export index: %w", err)
+	}
 	return nil
 }
 
-// RevokeAllByCN revoke all pairs with common name
-func (p *PKI) RevokeAllByCN(cn string) error {
+// RefreshCRL sign CRL with the same entries and new this and next update, e.g. periodically when
+// WithCRLValidity is short. Entries of long-expired certificates are pruned if WithCRLPruning is set.
+func (p *PKI) RefreshCRL() error {
+	return p.updateCRL(func(list []pkix.RevokedCertificate) ([]pkix.RevokedCertificate, error) {
+		return list, nil
+	})
+}
+
+// revokedPair return stored pair with serial or pair without name if there is no one
+func (p *PKI) revokedPair(serial *big.Int) *pair.X509Pair {
+	if stored, err := p.Storage.GetBySerial(serial); err == nil {
+		return stored
+	}
+	return pair.NewX509Pair(nil, nil, "", serial)
+}
+
+// RevokeAllByCN revoke all pairs with common name. Failed hooks don`t stop revocation of other pairs,
+// their errors are returned together as HookErrors after all pairs are revoked.
+func (p *PKI) RevokeAllByCN(cn string, opts ...RevokeOption) error {
 	pairs, err := p.Storage.GetByCN(cn)
 	if err != nil {
 		return fmt.Errorf("can`t get pairs for revoke: %w", err)
 	}
+	var hookErrs HookErrors
 	for _, certPair := range pairs {
-		err := p.RevokeOne(certPair.Serial)
+		err := p.RevokeOne(certPair.Serial, opts...)
+		var hookErr *HookError
+		if errors.As(err, &hookErr) {
+			hookErrs = append(hookErrs, hookErr)
+			continue
+		}
 		if err != nil {
 			return fmt.Errorf("can`t revoke: %w", err)
 		}
 	}
+	if len(hookErrs) > 0 {
+		return hookErrs
+	}
 	return nil
 }
 
-// IsRevoked return true if it`s revoked serial
+// IsRevoked return true if it`s revoked serial. It checks CRL only, false doesn`t mean certificate is acceptable,
+// use Status to tell expired certificates.
 func (p *PKI) IsRevoked(serial *big.Int) bool {
 	revokedCerts, err := p.GetCRL()
 	if err != nil {
@@ -243,13 +877,48 @@ func (p *PKI) IsRevoked(serial *big.Int) bool {
 	return false
 }
 
+// revokedSerials return decimal serials of CRL entries. Certificates on hold are included if withHeld.
+func (p *PKI) revokedSerials(withHeld bool) map[string]bool {
+	res := map[string]bool{}
+	list, err := p.GetCRL()
+	if err != nil {
+		return res
+	}
+	for _, entry := range list.TBSCertList.RevokedCertificates {
+		if withHeld || revocationReason(entry) != ReasonCertificateHold {
+			res[entry.SerialNumber.String()] = true
+		}
+	}
+	return res
+}
+
+// nextSerial return next serial from provider skipping serials which are already present in storage.
+// It's possible after restoring an old serial file from backup. Only not found serial is free, any other
+// lookup error is returned. Every lookup lists all pair directories of DirKeyStorage, so each used serial
+// costs a full rescan of keydir.
+func (p *PKI) nextSerial() (*big.Int, error) {
+	for {
+		serial, err := p.serialProvider.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, err = p.Storage.GetBySerial(serial)
+		if errors.Is(err, ErrNotFound) {
+			return serial, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("can`t check serial %v: %w", serial, err)
+		}
+	}
+}
+
 func removeDups(list []pkix.RevokedCertificate) []pkix.RevokedCertificate {
-	encountered := map[int64]bool{}
+	encountered := map[string]bool{}
 	result := make([]pkix.RevokedCertificate, 0)
 	for _, cert := range list {
-		if !encountered[cert.SerialNumber.Int64()] {
+		if !encountered[cert.SerialNumber.String()] {
 			result = append(result, cert)
-			encountered[cert.SerialNumber.Int64()] = true
+			encountered[cert.SerialNumber.String()] = true
 		}
 	}
 	return result
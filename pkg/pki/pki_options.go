@@ -0,0 +1,409 @@
+package pki
+
+import (
+	"crypto"
+	"crypto/x509"
+	"github.com/kemsta/go-easyrsa/internal/fsStorage"
+	"io"
+	"time"
+)
+
+// PKIOption tune PKI on construction
+type PKIOption func(*PKI)
+
+// WithIndexHolder regenerate openssl compatible index in holder after every issue and revoke.
+// External OCSP responders like `openssl ocsp -index` can consume it.
+func WithIndexHolder(holder IndexHolder) PKIOption {
+	return func(p *PKI) {
+		p.indexHolder = holder
+	}
+}
+
+// WithIndexFile regenerate openssl compatible index in file after every issue and revoke
+func WithIndexFile(path string) PKIOption {
+	return WithIndexHolder(fsStorage.NewFileIndexHolder(path))
+}
+
+// WithRand use r as entropy source for key generation and signing instead of crypto/rand,
+// e.g. hardware RNG. r must be safe for concurrent use.
+func WithRand(r io.Reader) PKIOption {
+	return func(p *PKI) {
+		p.rand = r
+	}
+}
+
+// WithClock take issue, revocation and crl times from now instead of time.Now, e.g. to build
+// reproducible test fixtures together with WithRand
+func WithClock(now func() time.Time) PKIOption {
+	return func(p *PKI) {
+		p.clock = now
+	}
+}
+
+// WithSigner sign certificates and CRLs with signer instead of CA keys from storage, e.g. with key kept
+// in hardware token. New CA pairs are stored without keys, CA certificates must match signer public key.
+func WithSigner(signer crypto.Signer) PKIOption {
+	return func(p *PKI) {
+		p.signer = signer
+	}
+}
+
+// WithCAPassphrase encrypt new CA keys with passphrase from provider and decrypt encrypted CA keys with it,
+// e.g. CA keys generated by easy-rsa build-ca without nopass
+func WithCAPassphrase(provider PassphraseProvider) PKIOption {
+	return func(p *PKI) {
+		p.caPassphrase = provider
+	}
+}
+
+// WithKDFIterations set PBKDF2 iteration count of CA keys encrypted with WithCAPassphrase instead of
+// DefaultKDFIterations. Existing keys are decrypted with count stored in them.
+func WithKDFIterations(iterations int) PKIOption {
+	return func(p *PKI) {
+		p.kdfIterations = iterations
+	}
+}
+
+// WithProfile set options of profile for Issue, NewServerCert and NewClientCert instead of built-in ones,
+// e.g. to drop nsCertType or add EKUs globally. New profiles can be added as well.
+//
+//	WithProfile(ProfileServer, Server(), ExtKeyUsage(x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth))
+func WithProfile(profile Profile, opts ...Option) PKIOption {
+	return func(p *PKI) {
+		if p.profiles == nil {
+			p.profiles = map[Profile][]Option{}
+		}
+		p.profiles[profile] = opts
+	}
+}
+
+// WithKeyAlgorithm generate keys of new CAs and certificates with algorithm, KeyRSA by default
+func WithKeyAlgorithm(algorithm KeyAlgorithm) PKIOption {
+	return func(p *PKI) {
+		p.keyAlgorithm = algorithm
+	}
+}
+
+// WithKeySize generate keys of bits size instead of the default one of key algorithm.
+// It`s 256, 384 or 521 for KeyECDSA and at least MinRSAKeySize for KeyRSA, other sizes fail key generation.
+func WithKeySize(bits int) PKIOption {
+	return func(p *PKI) {
+		p.keySize = bits
+	}
+}
+
+// WithDefaultValidity issue CAs and certificates valid for validity unless NotAfter option or validity
+// policy is applied. Default validity of certificates is capped by expiration of their CA.
+func WithDefaultValidity(validity time.Duration) PKIOption {
+	return func(p *PKI) {
+		p.validity = validity
+	}
+}
+
+// WithDefaultSignatureAlgorithm sign CAs and certificates with algorithm unless SignatureAlgorithm option is used
+func WithDefaultSignatureAlgorithm(algorithm x509.SignatureAlgorithm) PKIOption {
+	return func(p *PKI) {
+		p.signatureAlg = algorithm
+	}
+}
+
+// WithCRLPruning drop CRL entries of certificates expired more than after ago every time CRL is signed,
+// so CRL doesn`t grow forever and stays fast to parse, e.g. for openvpn crl-verify
+func WithCRLPruning(after time.Duration) PKIOption {
+	return func(p *PKI) {
+		p.crlPruneAfter = after
+	}
+}
+
+// WithCRLValidity set next update of every signed CRL validity after this update instead of DefaultExpireYears.
+// Clients like openvpn and browsers reject CRL after next update, so it must be refreshed before, see RefreshCRL.
+func WithCRLValidity(validity time.Duration) PKIOption {
+	return func(p *PKI) {
+		p.crlValidity = validity
+	}
+}
+
+// WithKeyGenProgress call fn after every entropy read during rsa key generation,
+// e.g. to show that slow 4096 bit generation on small boxes is alive
+func WithKeyGenProgress(fn func(KeyGenProgress)) PKIOption {
+	return func(p *PKI) {
+		p.keyGenProgress = fn
+	}
+}
+
+// WithOpenSSLSerial keep serial and crl number files in openssl format with the next value as upper case hex and
+// previous content in <path>.old, so they can be shared with openssl ca and easy-rsa.
+// It takes effect for serial providers supporting it like the fs one.
+func WithOpenSSLSerial() PKIOption {
+	return func(p *PKI) {
+		for _, sp := range []SerialProvider{p.serialProvider, p.crlNumber} {
+			if provider, ok := sp.(interface{ OpenSSLFormat(bool) }); ok {
+				provider.OpenSSLFormat(true)
+			}
+		}
+	}
+}
+
+// WithPreviousCRLHolder keep CRL with the same entries signed by previous CA during overlap after RotateCA, so peers
+// trusting only previous CA can check revocations. InitPKI keeps it in previous.crl.pem.
+func WithPreviousCRLHolder(holder CRLHolder) PKIOption {
+	return func(p *PKI) {
+		p.previousCRL = holder
+	}
+}
+
+// WithCRLNumber take numbers of CRLs from counter, so consumers can tell stale CRL by its number. Without it
+// number of the next CRL is number of the current one plus one. InitPKI keeps counter in crlnumber file.
+func WithCRLNumber(counter SerialProvider) PKIOption {
+	return func(p *PKI) {
+		p.crlNumber = counter
+	}
+}
+
+// WithSerialFileNames name new pair files by serial zero padded to width hex digits, in upper case hex if upper
+// is set, so directory listings sort by serial and names match openssl newcerts. Existing files keep their names.
+// It takes effect for storages supporting it like the fs one.
+func WithSerialFileNames(width int, upper bool) PKIOption {
+	return func(p *PKI) {
+		if formatter, ok := p.Storage.(interface{ SerialFormat(int, bool) }); ok {
+			formatter.SerialFormat(width, upper)
+		}
+	}
+}
+
+// WithJournal persist write-ahead intents of revocations in journal. Revocation interrupted by crash
+// can be finished with PKI.Recover, so CRL and index don`t disagree.
+func WithJournal(journal Journal) PKIOption {
+	return func(p *PKI) {
+		p.journal = journal
+	}
+}
+
+// WithJournalDir persist write-ahead intents of revocations as files in dir
+func WithJournalDir(dir string) PKIOption {
+	return WithJournal(fsStorage.NewFileJournal(dir))
+}
+
+// LockWait describe one attempt to acquire lock of fs storage
+type LockWait = fsStorage.LockWait
+
+// Default lock timing of fs storage, see WithLockTiming
+const (
+	LockTimeout = fsStorage.LockTimeout
+	LockPeriod  = fsStorage.LockPeriod
+)
+
+// WithLockObserver call fn after every attempt to acquire lock in storage and holders supporting it,
+// e.g. for contention metrics or logging
+func WithLockObserver(fn func(LockWait)) PKIOption {
+	return func(p *PKI) {
+		p.lockObserver = fn
+	}
+}
+
+// WithLockTiming wait for locks of storage and holders supporting it up to timeout retrying every period
+// instead of LockTimeout and LockPeriod. Zero value keeps the default.
+func WithLockTiming(timeout, period time.Duration) PKIOption {
+	return func(p *PKI) {
+		p.lockTimeout, p.lockPeriod = timeout, period
+	}
+}
+
+// WithTrustStore keep trusted CA certificates without keys in store. They are used by Verify and CertPool.
+func WithTrustStore(store TrustStore) PKIOption {
+	return func(p *PKI) {
+		p.trustStore = store
+	}
+}
+
+// WithTrustStoreDir keep trusted CA certificates as pem files in dir
+func WithTrustStoreDir(dir string) PKIOption {
+	return WithTrustStore(fsStorage.NewDirTrustStore(dir))
+}
+
+// WithTokenStore keep enrollment tokens minted by MintToken in store. EnrollHandler requires token then.
+func WithTokenStore(store TokenStore) PKIOption {
+	return func(p *PKI) {
+		p.tokens = store
+	}
+}
+
+// WithTokenDir keep enrollment tokens as files in dir
+func WithTokenDir(dir string) PKIOption {
+	return WithTokenStore(fsStorage.NewDirTokenStore(dir))
+}
+
+// WithRequestStore keep CSRs submitted by Submit in store until they are approved or denied
+func WithRequestStore(store RequestStore) PKIOption {
+	return func(p *PKI) {
+		p.requests = store
+	}
+}
+
+// WithRequestDir keep CSRs waiting for approval as files in dir
+func WithRequestDir(dir string) PKIOption {
+	return WithRequestStore(fsStorage.NewDirRequestStore(dir))
+}
+
+// WithDefaultSANs add subject alternative names derived by rules to every issued leaf certificate
+// with common name. NoDefaultSANs option disables them for one certificate.
+func WithDefaultSANs(rules ...SANRule) PKIOption {
+	return func(p *PKI) {
+		p.defaultSANs = append(p.defaultSANs, rules...)
+	}
+}
+
+// WithCRLDistributionPoints set crl urls of every certificate signed by CA, leaves and intermediates.
+// CRLDistributionPoints option and leaf defaults of CA take precedence.
+func WithCRLDistributionPoints(urls ...string) PKIOption {
+	return func(p *PKI) {
+		p.crlURLs = append(p.crlURLs, urls...)
+	}
+}
+
+// WithValidityPolicy limit validity of leaf certificates per profile. Profile of certificate without
+// identity profile is inferred from extended key usages.
+func WithValidityPolicy(policies ...ValidityPolicy) PKIOption {
+	return func(p *PKI) {
+		if p.validityPolicies == nil {
+			p.validityPolicies = map[Profile]ValidityPolicy{}
+		}
+		for _, policy := range policies {
+			p.validityPolicies[policy.Profile] = policy
+		}
+	}
+}
+
+// WithDNSGuard check DNS names of server certificates with guard before issue, see DNSGuard
+func WithDNSGuard(guard DNSGuard) PKIOption {
+	return func(p *PKI) {
+		p.dnsGuard = &guard
+	}
+}
+
+// WithCTLogs submit precertificates of leaf certificates to certificate transparency logs and embed returned
+// SCTs into certificates. Certificate isn`t issued if some of logs fails.
+func WithCTLogs(logs ...CTLog) PKIOption {
+	return func(p *PKI) {
+		p.ctLogs = append(p.ctLogs, logs...)
+	}
+}
+
+// WithAuditLog write policy decisions into audit log
+func WithAuditLog(log AuditLog) PKIOption {
+	return func(p *PKI) {
+		p.auditLog = log
+	}
+}
+
+// WithAuditFile write policy decisions as json lines into file
+func WithAuditFile(path string) PKIOption {
+	return WithAuditLog(fsStorage.NewFileAuditLog(path))
+}
+
+// WithFingerprintHash compute fingerprints of certificates with hash instead of sha256, see Fingerprint.
+// Hash package should be linked in. Storages with fingerprint index like the fs one use it too.
+func WithFingerprintHash(hash crypto.Hash) PKIOption {
+	return func(p *PKI) {
+		p.fingerprintHash = hash
+		if hasher, ok := p.Storage.(interface{ FingerprintHash(crypto.Hash) }); ok {
+			hasher.FingerprintHash(hash)
+		}
+	}
+}
+
+// WithHooks call hooks after every change of event type. Operation result is returned together with
+// HookError if some of hooks failed.
+func WithHooks(eventType EventType, hooks ...Hook) PKIOption {
+	return func(p *PKI) {
+		if p.hooks == nil {
+			p.hooks = map[EventType][]Hook{}
+		}
+		p.hooks[eventType] = append(p.hooks[eventType], hooks...)
+	}
+}
+
+// WithPublisher publish CA certificate, crl and chain bundle after every issue, revoke and release.
+// Publishing failure is reported as HookError.
+func WithPublisher(publisher Publisher) PKIOption {
+	return func(p *PKI) {
+		publish := func(Event) error {
+			return p.Publish(publisher)
+		}
+		for _, eventType := range []EventType{EventIssue, EventRevoke, EventRelease} {
+			WithHooks(eventType, publish)(p)
+		}
+	}
+}
+
+// WithPublishDir publish CA certificate, crl and chain bundle as files in dir, e.g. web root of static server
+func WithPublishDir(dir string) PKIOption {
+	return WithPublisher(NewDirPublisher(dir))
+}
+
+// ScanError is returned by storage scans in error collection mode with every entry which can`t be read
+type ScanError = fsStorage.ScanError
+
+// WithScanErrors make storage scans fail with ScanError instead of silently skipping unreadable pairs.
+// It takes effect for storages supporting error collection mode like the fs one.
+func WithScanErrors() PKIOption {
+	return func(p *PKI) {
+		if collector, ok := p.Storage.(interface{ CollectErrors(bool) }); ok {
+			collector.CollectErrors(true)
+		}
+	}
+}
+
+// ScanWarning describe an entry of fs storage which doesn`t belong to it, like README or temp file
+type ScanWarning = fsStorage.ScanWarning
+
+// Kinds of scan warnings
+const (
+	WarningUnknownFile = fsStorage.WarningUnknownFile
+	WarningTempFile    = fsStorage.WarningTempFile
+	WarningLockFile    = fsStorage.WarningLockFile
+)
+
+// WithScanWarnings send every unrelated entry skipped by storage scans to ch. Scans wait until warning
+// is received, so ch must be drained. It takes effect for storages reporting warnings like the fs one.
+func WithScanWarnings(ch chan<- ScanWarning) PKIOption {
+	return func(p *PKI) {
+		if reporter, ok := p.Storage.(interface{ ReportWarnings(chan<- ScanWarning) }); ok {
+			reporter.ReportWarnings(ch)
+		}
+	}
+}
+
+// WithTempCleanup remove temp files which weren`t modified for maxAge, e.g. TempMaxAge, on construction.
+// Cleanup is best effort, it takes effect for storages supporting it like the fs one.
+func WithTempCleanup(maxAge time.Duration) PKIOption {
+	return func(p *PKI) {
+		if cleaner, ok := p.Storage.(tempCleaner); ok {
+			_, _ = cleaner.CleanStaleTemp(maxAge)
+		}
+	}
+}
+
+// Errors of fs storage
+var (
+	ErrCAMaterial    = fsStorage.ErrCAMaterial    // CA pair deletion wasn`t confirmed
+	ErrOutsideKeydir = fsStorage.ErrOutsideKeydir // pair path resolves outside keydir
+	ErrNotFound      = fsStorage.ErrNotFound      // there is no pair with name or serial
+	ErrLocked        = fsStorage.ErrLocked        // lock wasn`t acquired in time
+)
+
+// WithCAName use name for CA pairs and as default CA common name instead of DefaultCAName.
+// Several roots can share one storage this way, every one addressed by PKI with its name.
+func WithCAName(name string) PKIOption {
+	return func(p *PKI) {
+		p.caName = name
+	}
+}
+
+// WithChainStapling keep issuing CA chain up to root together with every new leaf certificate,
+// either in certificate file itself or in separate full chain file, since most TLS servers need it
+func WithChainStapling(mode ChainStapling) PKIOption {
+	return func(p *PKI) {
+		p.chainStapling = mode
+	}
+}
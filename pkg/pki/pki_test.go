@@ -1,15 +1,23 @@
 package pki
 
 import (
+	"crypto/rand"
+	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/pem"
 	"github.com/kemsta/go-easyrsa/internal/fsStorage"
+	"github.com/kemsta/go-easyrsa/pkg/pair"
 	"log"
 	"math/big"
+	"net"
 	"os"
 	"path"
 	"path/filepath"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -47,8 +55,17 @@ func TestPki_NewCa(t *testing.T) {
 		assert.NotNil(t, cert)
 		assert.Equal(t, cert.SerialNumber, big.NewInt(1))
 		assert.True(t, cert.IsCA)
+		assert.True(t, cert.MaxPathLenZero)
 		assert.Equal(t, cert.Subject.CommonName, "ca")
 	})
+	t.Run("relaxed ca", func(t *testing.T) {
+		got, err := pki.NewCa(UnlimitedPathLen(), CADigitalSignature(false))
+		assert.NoError(t, err)
+		_, cert, err := got.Decode()
+		assert.NoError(t, err)
+		assert.Equal(t, -1, cert.MaxPathLen)
+		assert.Equal(t, x509.KeyUsageCertSign|x509.KeyUsageCRLSign, cert.KeyUsage)
+	})
 }
 
 func TestPKI_newCert(t *testing.T) {
@@ -212,7 +229,10 @@ func TestInitPKI(t *testing.T) {
 				Storage:        fsStorage.NewDirKeyStorage(pkiDir),
 				serialProvider: fsStorage.NewFileSerialProvider(path.Join(pkiDir, "serial")),
 				crlHolder:      fsStorage.NewFileCRLHolder(path.Join(pkiDir, "crl.pem")),
+				previousCRL:    fsStorage.NewFileCRLHolder(path.Join(pkiDir, "previous.crl.pem")),
+				crlNumber:      fsStorage.NewFileSerialProvider(path.Join(pkiDir, "crlnumber")),
 				subjTemplate:   pkix.Name{},
+				trustStore:     fsStorage.NewDirTrustStore(path.Join(pkiDir, ".trusted")),
 			},
 			wantErr: false,
 		},
@@ -230,3 +250,603 @@ func TestInitPKI(t *testing.T) {
 		})
 	}
 }
+
+func TestPKI_nextSerial(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	_, _ = pki.NewCa()
+	// simulate restored old serial file: serials 2 and 3 are already used
+	_ = pki.Storage.Put(pair.NewX509Pair([]byte("keybytes"), []byte("certbytes"), "old", big.NewInt(2)))
+	_ = pki.Storage.Put(pair.NewX509Pair([]byte("keybytes"), []byte("certbytes"), "old", big.NewInt(3)))
+	t.Run("skip used serials", func(t *testing.T) {
+		got, err := pki.NewCert("server", Server())
+		assert.NoError(t, err)
+		assert.Equal(t, big.NewInt(4), got.Serial)
+		old, err := pki.Storage.GetBySerial(big.NewInt(2))
+		assert.NoError(t, err)
+		assert.Equal(t, "old", old.CN)
+	})
+	t.Run("lookup error", func(t *testing.T) {
+		storage := pki.Storage
+		defer func() { pki.Storage = storage }()
+		pki.Storage = NewChaosKeyStorage(storage, Faults{ErrorRate: 1, Ops: []ChaosOp{ChaosGet}})
+		_, err := pki.nextSerial()
+		assert.ErrorIs(t, err, ErrInjected)
+	})
+}
+
+func TestPKI_Issue(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	_, _ = pki.NewCa()
+	t.Run("san only", func(t *testing.T) {
+		got, err := pki.Issue(Identity{Name: "web", DNSNames: []string{"web.example.com"}, Profile: ProfileServer})
+		assert.NoError(t, err)
+		assert.Equal(t, "web", got.CN)
+		_, cert, err := got.Decode()
+		assert.NoError(t, err)
+		assert.Empty(t, cert.Subject.CommonName)
+		assert.Equal(t, []string{"web.example.com"}, cert.DNSNames)
+		assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}, cert.ExtKeyUsage)
+		stored, err := pki.Storage.GetLastByCn("web")
+		assert.NoError(t, err)
+		assert.Equal(t, got.Serial, stored.Serial)
+	})
+	t.Run("common name", func(t *testing.T) {
+		got, err := pki.Issue(Identity{CommonName: "client", Profile: ProfileClient})
+		assert.NoError(t, err)
+		assert.Equal(t, "client", got.CN)
+	})
+	t.Run("san fallback name", func(t *testing.T) {
+		got, err := pki.Issue(Identity{DNSNames: []string{"*.example.com"}, Profile: ProfileServer})
+		assert.NoError(t, err)
+		assert.Equal(t, "_.example.com", got.CN)
+	})
+	t.Run("empty identity", func(t *testing.T) {
+		got, err := pki.Issue(Identity{})
+		assert.Error(t, err)
+		assert.Nil(t, got)
+	})
+	t.Run("unknown profile", func(t *testing.T) {
+		got, err := pki.Issue(Identity{CommonName: "client", Profile: "unknown"})
+		assert.Error(t, err)
+		assert.Nil(t, got)
+	})
+}
+
+func TestIdentity_Key(t *testing.T) {
+	tests := []struct {
+		name string
+		id   Identity
+		want string
+	}{
+		{name: "name", id: Identity{Name: "name", CommonName: "cn"}, want: "name"},
+		{name: "cn", id: Identity{CommonName: "cn", DNSNames: []string{"dns"}}, want: "cn"},
+		{name: "dns", id: Identity{DNSNames: []string{"*.example.com"}}, want: "_.example.com"},
+		{name: "ip", id: Identity{IPAddresses: []net.IP{net.ParseIP("::1")}}, want: "__1"},
+		{name: "empty", id: Identity{}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.id.Key())
+		})
+	}
+}
+
+func TestPKI_GetTrustBundle(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	t.Run("empty", func(t *testing.T) {
+		got, err := pki.GetTrustBundle()
+		assert.Error(t, err)
+		assert.Nil(t, got)
+	})
+	t.Run("two ca", func(t *testing.T) {
+		_, _ = pki.NewCa()
+		_, _ = pki.NewCert("server", Server())
+		_, _ = pki.NewCa()
+		got, err := pki.GetTrustBundle()
+		assert.NoError(t, err)
+		pool := x509.NewCertPool()
+		assert.True(t, pool.AppendCertsFromPEM(got))
+		assert.Equal(t, 2, strings.Count(string(got), "BEGIN CERTIFICATE"))
+	})
+}
+
+func TestPKI_IssuedBy(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	oldCa, _ := pki.NewCa()
+	newCa, _ := pki.NewCa()
+	leaf, _ := pki.NewCert("leaf")
+	t.Run("old ca", func(t *testing.T) {
+		got, err := pki.Issue(Identity{CommonName: "server"}, Server(), IssuedBy(oldCa.Serial))
+		assert.NoError(t, err)
+		_, cert, _ := got.Decode()
+		_, oldCaCert, _ := oldCa.Decode()
+		_, newCaCert, _ := newCa.Decode()
+		assert.NoError(t, cert.CheckSignatureFrom(oldCaCert))
+		assert.Error(t, cert.CheckSignatureFrom(newCaCert))
+	})
+	t.Run("not a ca", func(t *testing.T) {
+		got, err := pki.Issue(Identity{CommonName: "server"}, IssuedBy(leaf.Serial))
+		assert.Error(t, err)
+		assert.Nil(t, got)
+	})
+	t.Run("not exist", func(t *testing.T) {
+		got, err := pki.Issue(Identity{CommonName: "server"}, IssuedBy(big.NewInt(42)))
+		assert.Error(t, err)
+		assert.Nil(t, got)
+	})
+}
+
+func TestPKI_MaxPathLen(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	root, err := pki.NewCa(MaxPathLen(1))
+	assert.NoError(t, err)
+	intermediate, err := pki.NewCert("intermediate", MaxPathLenZero(), CA())
+	assert.NoError(t, err)
+	sub, err := pki.Issue(Identity{CommonName: "sub"}, CA(), IssuedBy(intermediate.Serial))
+	assert.NoError(t, err)
+	leaf, err := pki.Issue(Identity{CommonName: "leaf"}, Client(), IssuedBy(intermediate.Serial))
+	assert.NoError(t, err)
+	subLeaf, err := pki.Issue(Identity{CommonName: "sub-leaf"}, Client(), IssuedBy(sub.Serial))
+	assert.NoError(t, err)
+
+	roots, intermediates := x509.NewCertPool(), x509.NewCertPool()
+	for _, certPair := range []*pair.X509Pair{root, intermediate, sub} {
+		cert, err := certPair.DecodeCert()
+		assert.NoError(t, err)
+		if certPair == root {
+			assert.Equal(t, 1, cert.MaxPathLen)
+			roots.AddCert(cert)
+			continue
+		}
+		assert.True(t, cert.MaxPathLenZero)
+		intermediates.AddCert(cert)
+	}
+	verify := func(certPair *pair.X509Pair) error {
+		cert, err := certPair.DecodeCert()
+		assert.NoError(t, err)
+		_, err = cert.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates,
+			KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}})
+		return err
+	}
+	assert.NoError(t, verify(leaf))
+	assert.Error(t, verify(subLeaf), "intermediate with pathLen 0 can`t sign CAs")
+}
+
+func TestPKI_NewCaGeneration(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	const count = 4
+	type result struct {
+		serial     *big.Int
+		generation int
+	}
+	results := make(chan result, count)
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, generation, err := pki.NewCaGeneration()
+			assert.NoError(t, err)
+			results <- result{serial: got.Serial, generation: generation}
+		}()
+	}
+	wg.Wait()
+	close(results)
+	generations := map[int]*big.Int{}
+	for res := range results {
+		generations[res.generation] = res.serial
+	}
+	assert.Len(t, generations, count)
+	for i := 2; i <= count; i++ {
+		assert.Equal(t, 1, generations[i].Cmp(generations[i-1]), "generation %v serial must be greater", i)
+	}
+	last, err := pki.GetLastCA()
+	assert.NoError(t, err)
+	assert.Equal(t, generations[count], last.Serial)
+}
+
+func TestPKI_lock_hookRefreshesCRL(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	WithHooks(EventIssue, func(event Event) error {
+		return pki.RefreshCRL()
+	})(pki)
+	done := make(chan error)
+	go func() {
+		_, err := pki.NewCa()
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("hook refreshing crl under ca lock is deadlocked")
+	}
+	_, err := pki.GetCRL()
+	assert.NoError(t, err)
+}
+
+func TestPKI_RevokeOneWithReason(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	_, _ = pki.NewCa()
+	server, _ := pki.NewCert("server", Server())
+	client, _ := pki.NewCert("client", Client())
+	compromisedAt := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.NoError(t, pki.RevokeOne(server.Serial, KeyCompromised(compromisedAt)))
+	assert.NoError(t, pki.RevokeOne(client.Serial, Reason(ReasonUnspecified)))
+	list, err := pki.GetCRL()
+	assert.NoError(t, err)
+	assert.Len(t, list.TBSCertList.RevokedCertificates, 2)
+	t.Run("key compromise", func(t *testing.T) {
+		entry := list.TBSCertList.RevokedCertificates[0]
+		assert.Equal(t, ReasonKeyCompromise, revocationReason(entry))
+		date, ok := invalidityDate(entry)
+		assert.True(t, ok)
+		assert.True(t, compromisedAt.Equal(date))
+	})
+	t.Run("unspecified", func(t *testing.T) {
+		entry := list.TBSCertList.RevokedCertificates[1]
+		assert.Empty(t, entry.Extensions)
+		assert.Equal(t, ReasonUnspecified, revocationReason(entry))
+		_, ok := invalidityDate(entry)
+		assert.False(t, ok)
+	})
+}
+
+func TestParseRevocationReason(t *testing.T) {
+	for _, reason := range []RevocationReason{ReasonKeyCompromise, ReasonCertificateHold, ReasonSuperseded} {
+		got, err := ParseRevocationReason(reason.String())
+		assert.NoError(t, err)
+		assert.Equal(t, reason, got)
+	}
+	_, err := ParseRevocationReason("bad")
+	assert.Error(t, err)
+}
+
+func TestPKI_JWKS(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	_, err := pki.JWKS()
+	assert.Error(t, err)
+	_, _ = pki.NewCa()
+	_, _ = pki.NewCert("server", Server())
+	_, _ = pki.NewCa()
+	got, err := pki.JWKS()
+	assert.NoError(t, err)
+	assert.Len(t, got.Keys, 2)
+	assert.NotEqual(t, got.Keys[0].Kid, got.Keys[1].Kid)
+}
+
+func TestWithScanErrors(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	WithScanErrors()(pki)
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+	_, err = pki.GetTrustBundle()
+	assert.NoError(t, err)
+	assert.NoError(t, os.MkdirAll(filepath.Join(testData, "broken"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(testData, "broken", "ff.crt"), []byte("cert"), 0644))
+	_, err = pki.GetTrustBundle()
+	var scanErr *ScanError
+	assert.ErrorAs(t, err, &scanErr)
+}
+
+func TestPKI_CheckIssuerExpiry(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	ca, err := pki.NewCa(NotAfter(time.Now().Add(365 * 24 * time.Hour)))
+	assert.NoError(t, err)
+	assert.NoError(t, pki.CheckIssuerExpiry(Identity{CommonName: "client"}))
+	client, err := pki.NewCert("client")
+	assert.NoError(t, err)
+	caCert, _ := ca.DecodeCert()
+	clientCert, _ := client.DecodeCert()
+	assert.Equal(t, caCert.NotAfter, clientCert.NotAfter)
+
+	err = pki.CheckIssuerExpiry(Identity{CommonName: "client"}, NotAfter(time.Now().Add(2*365*24*time.Hour)))
+	var outlives *OutlivesIssuerError
+	assert.ErrorAs(t, err, &outlives)
+	assert.Equal(t, "ca", outlives.Issuer)
+	assert.NoError(t, pki.CheckIssuerExpiry(Identity{CommonName: "client"}, NotAfter(time.Now().Add(24*time.Hour))))
+}
+
+// countingReader count bytes read from crypto/rand
+type countingReader struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (r *countingReader) Read(b []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n, err := rand.Read(b)
+	r.n += n
+	return n, err
+}
+
+func (r *countingReader) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.n
+}
+
+func TestWithRand(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	entropy := &countingReader{}
+	WithRand(entropy)(pki)
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+	afterCA := entropy.count()
+	assert.NotZero(t, afterCA)
+	_, err = pki.NewCert("client")
+	assert.NoError(t, err)
+	assert.Greater(t, entropy.count(), afterCA)
+}
+
+func TestWithOpenSSLSerial(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	WithOpenSSLSerial()(pki)
+	ca, err := pki.NewCa()
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(1), ca.Serial)
+	content, err := os.ReadFile(filepath.Join(testData, "serial"))
+	assert.NoError(t, err)
+	assert.Equal(t, "02\n", string(content))
+}
+
+func TestWithTempCleanup(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	stale := filepath.Join(testData, ".serial.tmp1")
+	fresh := filepath.Join(testData, ".serial.tmp2")
+	assert.NoError(t, os.WriteFile(stale, []byte("1"), 0644))
+	assert.NoError(t, os.WriteFile(fresh, []byte("1"), 0644))
+	old := time.Now().Add(-2 * time.Hour)
+	assert.NoError(t, os.Chtimes(stale, old, old))
+	WithTempCleanup(time.Hour)(pki)
+	assert.NoFileExists(t, stale)
+	assert.FileExists(t, fresh)
+	removed, err := pki.CleanTemp()
+	assert.NoError(t, err)
+	assert.Len(t, removed, 1)
+}
+
+func TestWithLockObserver(t *testing.T) {
+	_ = os.MkdirAll(testData, 0777)
+	defer func() {
+		_ = os.RemoveAll(testData)
+	}()
+	waits := map[string]int{}
+	pki, err := InitPKI(testData, nil, WithLockObserver(func(wait LockWait) {
+		assert.True(t, wait.Acquired)
+		waits[filepath.Base(wait.Path)]++
+	}), WithIndexFile(filepath.Join(testData, "index.txt")))
+	assert.NoError(t, err)
+	_, err = pki.NewCa()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{".ca.lock": 1, "serial.lock": 1, "index.txt.lock": 1}, waits)
+}
+
+func TestWithLockTiming(t *testing.T) {
+	dir := t.TempDir()
+	unlock, err := fsStorage.NewDirKeyStorage(dir).Lock(DefaultCAName)
+	must(t, assert.NoError(t, err))
+	defer func() {
+		_ = unlock()
+	}()
+	pki, err := InitPKI(dir, nil, WithLockTiming(LockPeriod*3, 0))
+	assert.NoError(t, err)
+	start := time.Now()
+	_, err = pki.NewCa()
+	assert.ErrorIs(t, err, ErrLocked)
+	assert.Less(t, time.Since(start), LockTimeout)
+}
+
+func TestWithCAName(t *testing.T) {
+	_ = os.MkdirAll(testData, 0777)
+	defer func() {
+		_ = os.RemoveAll(testData)
+	}()
+	vpn, err := InitPKI(testData, nil, WithCAName("vpn-ca"))
+	assert.NoError(t, err)
+	web, err := InitPKI(testData, nil, WithCAName("web-ca"))
+	assert.NoError(t, err)
+	vpnCA, err := vpn.NewCa()
+	assert.NoError(t, err)
+	webCA, err := web.NewCa()
+	assert.NoError(t, err)
+	assert.Equal(t, "vpn-ca", vpnCA.CN)
+	vpnCert, err := vpnCA.DecodeCert()
+	assert.NoError(t, err)
+	assert.Equal(t, "vpn-ca", vpnCert.Subject.CommonName)
+
+	names, err := web.CANames()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"vpn-ca", "web-ca"}, names)
+	last, err := web.GetLastCA()
+	assert.NoError(t, err)
+	assert.Equal(t, webCA.Serial, last.Serial)
+
+	client, err := vpn.NewCert("client", Client())
+	assert.NoError(t, err)
+	clientCert, err := client.DecodeCert()
+	assert.NoError(t, err)
+	assert.NoError(t, clientCert.CheckSignatureFrom(vpnCert))
+
+	bundle, err := vpn.GetTrustBundle()
+	assert.NoError(t, err)
+	block, rest := pem.Decode(bundle)
+	assert.Empty(t, rest, "bundle has vpn-ca only")
+	assert.Equal(t, vpnCert.Raw, block.Bytes)
+
+	assert.NoError(t, vpn.RevokeOne(client.Serial))
+	assert.FileExists(t, filepath.Join(testData, "vpn-ca.crl.pem"))
+	assert.NoFileExists(t, filepath.Join(testData, "web-ca.crl.pem"))
+	crl, err := vpn.GetCRL()
+	assert.NoError(t, err)
+	assert.NoError(t, vpnCert.CheckCRLSignature(crl))
+	assert.False(t, web.IsRevoked(client.Serial))
+}
+
+func TestIssuedByCA(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	WithCAName("vpn-ca")(pki)
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+	web, err := InitPKI(testData, nil, WithCAName("web-ca"))
+	assert.NoError(t, err)
+	webCA, err := web.NewCa()
+	assert.NoError(t, err)
+	webCert, err := webCA.DecodeCert()
+	assert.NoError(t, err)
+
+	res, err := pki.Issue(Identity{CommonName: "www"}, Server(), IssuedByCA("web-ca"))
+	assert.NoError(t, err)
+	cert, err := res.DecodeCert()
+	assert.NoError(t, err)
+	assert.NoError(t, cert.CheckSignatureFrom(webCert))
+	assert.Equal(t, "web-ca", cert.Issuer.CommonName)
+
+	vpnClient, err := pki.Issue(Identity{CommonName: "client"}, Client(), IssuedByCA("vpn-ca"))
+	assert.NoError(t, err)
+	cert, err = vpnClient.DecodeCert()
+	assert.NoError(t, err)
+	assert.Equal(t, "vpn-ca", cert.Issuer.CommonName)
+
+	_, err = pki.Issue(Identity{CommonName: "mail"}, Server(), IssuedByCA("client"))
+	assert.ErrorContains(t, err, "is not a ca")
+	_, err = pki.Issue(Identity{CommonName: "mail"}, Server(), IssuedByCA("db-ca"))
+	assert.Error(t, err)
+}
+
+func TestWithDefaultSANs(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	WithDefaultSANs(DNSSuffix(".internal.example.com"), func(cn string) ([]string, []net.IP) {
+		return []string{cn}, []net.IP{net.ParseIP("10.0.0.1")}
+	})(pki)
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		opts    []CertificateOption
+		wantDNS []string
+		wantIPs int
+	}{
+		{name: "defaults", wantDNS: []string{"web.internal.example.com", "web"}, wantIPs: 1},
+		{name: "with explicit", opts: []CertificateOption{DNSNames([]string{"web"})}, wantDNS: []string{"web", "web.internal.example.com"}, wantIPs: 1},
+		{name: "disabled", opts: []CertificateOption{NoDefaultSANs()}, wantDNS: nil, wantIPs: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := pki.Issue(Identity{CommonName: "web"}, append([]CertificateOption{Server()}, tt.opts...)...)
+			assert.NoError(t, err)
+			cert, err := res.DecodeCert()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantDNS, cert.DNSNames)
+			assert.Len(t, cert.IPAddresses, tt.wantIPs)
+		})
+	}
+
+	t.Run("not hostname", func(t *testing.T) {
+		res, err := pki.Issue(Identity{CommonName: "John Doe"}, Client())
+		assert.NoError(t, err)
+		cert, err := res.DecodeCert()
+		assert.NoError(t, err)
+		assert.Empty(t, cert.DNSNames)
+		assert.Len(t, cert.IPAddresses, 1)
+	})
+}
+
+func TestPKI_Preview(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	_, err := pki.Preview("web", Server())
+	assert.Error(t, err)
+	_, err = pki.NewCa()
+	assert.NoError(t, err)
+
+	preview, err := pki.Preview("web", Server(), DNSNames([]string{"web.example.com"}))
+	assert.NoError(t, err)
+	assert.Nil(t, preview.SerialNumber)
+	assert.Equal(t, "ca", preview.Issuer.CommonName)
+	_, err = pki.Storage.GetByCN("web")
+	assert.Error(t, err)
+
+	issued, err := pki.NewCert("web", Server(), DNSNames([]string{"web.example.com"}))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), issued.Serial.Int64())
+	cert, err := issued.DecodeCert()
+	assert.NoError(t, err)
+	assert.Equal(t, preview.Subject.CommonName, cert.Subject.CommonName)
+	assert.Equal(t, preview.DNSNames, cert.DNSNames)
+	assert.Equal(t, preview.ExtKeyUsage, cert.ExtKeyUsage)
+	assert.Equal(t, preview.KeyUsage, cert.KeyUsage)
+
+	_, err = pki.PreviewIdentity(Identity{})
+	assert.Error(t, err)
+}
+
+func TestWithCRLValidity(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	WithClock(func() time.Time { return now })(pki)
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+	assert.NoError(t, pki.RevokeOne(big.NewInt(42)))
+	list, err := pki.GetCRL()
+	assert.NoError(t, err)
+	assert.True(t, list.TBSCertList.NextUpdate.After(now.AddDate(DefaultExpireYears-1, 0, 0)))
+
+	WithCRLValidity(7 * 24 * time.Hour)(pki)
+	now = now.Add(time.Hour)
+	assert.NoError(t, pki.RefreshCRL())
+	list, err = pki.GetCRL()
+	assert.NoError(t, err)
+	assert.True(t, now.Equal(list.TBSCertList.ThisUpdate))
+	assert.True(t, now.Add(7*24*time.Hour).Equal(list.TBSCertList.NextUpdate))
+	if assert.Len(t, list.TBSCertList.RevokedCertificates, 1) {
+		assert.Equal(t, big.NewInt(42), list.TBSCertList.RevokedCertificates[0].SerialNumber)
+	}
+}
+
+func TestCRLNumber(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+	number := func() *big.Int {
+		list, err := pki.GetCRL()
+		assert.NoError(t, err)
+		return crlNumber(list)
+	}
+	assert.NoError(t, pki.RevokeOne(big.NewInt(42)))
+	assert.Equal(t, big.NewInt(1), number())
+	assert.NoError(t, pki.RefreshCRL())
+	assert.Equal(t, big.NewInt(2), number())
+
+	counterPath := filepath.Join(testData, "crlnumber")
+	WithCRLNumber(fsStorage.NewFileSerialProvider(counterPath))(pki)
+	assert.NoError(t, pki.RevokeOne(big.NewInt(43)))
+	assert.Equal(t, big.NewInt(3), number())
+	assert.NoError(t, os.WriteFile(counterPath, []byte("a"), 0644))
+	assert.NoError(t, pki.RefreshCRL())
+	assert.Equal(t, big.NewInt(11), number())
+	assert.NoError(t, os.Remove(counterPath))
+	assert.NoError(t, pki.RefreshCRL())
+	assert.Equal(t, big.NewInt(12), number())
+	assert.True(t, pki.IsRevoked(big.NewInt(42)))
+}
@@ -0,0 +1,136 @@
+package pki
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+)
+
+var (
+	oidCertificatePolicies = asn1.ObjectIdentifier{2, 5, 29, 32}
+	oidPolicyQualifierCPS  = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 2, 1}
+	oidQCStatements        = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 3}
+)
+
+// ETSI EN 319 412-5 qualified certificate statements and types
+var (
+	OIDQCCompliance = asn1.ObjectIdentifier{0, 4, 0, 1862, 1, 1}    // certificate is qualified
+	OIDQCSSCD       = asn1.ObjectIdentifier{0, 4, 0, 1862, 1, 4}    // key resides in qualified signature creation device
+	OIDQCType       = asn1.ObjectIdentifier{0, 4, 0, 1862, 1, 6}    // type of qualified certificate
+	OIDQCTypeESign  = asn1.ObjectIdentifier{0, 4, 0, 1862, 1, 6, 1} // electronic signature
+	OIDQCTypeESeal  = asn1.ObjectIdentifier{0, 4, 0, 1862, 1, 6, 2} // electronic seal
+	OIDQCTypeWeb    = asn1.ObjectIdentifier{0, 4, 0, 1862, 1, 6, 3} // website authentication
+)
+
+type policyQualifierInfo struct {
+	ID        asn1.ObjectIdentifier
+	Qualifier asn1.RawValue
+}
+
+type policyInformation struct {
+	ID         asn1.ObjectIdentifier
+	Qualifiers []policyQualifierInfo `asn1:"optional,omitempty"`
+}
+
+// CertificatePolicies add policy oids to certificate
+func CertificatePolicies(oids ...asn1.ObjectIdentifier) CertificateOption {
+	return templateOption(func(certificate *x509.Certificate) error {
+		for _, oid := range oids {
+			if err := addPolicy(certificate, policyInformation{ID: oid}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// CertificatePolicy add policy oid with certification practice statement urls as policy qualifiers
+func CertificatePolicy(oid asn1.ObjectIdentifier, cpsURLs ...string) CertificateOption {
+	return templateOption(func(certificate *x509.Certificate) error {
+		policy := policyInformation{ID: oid}
+		for _, url := range cpsURLs {
+			policy.Qualifiers = append(policy.Qualifiers, policyQualifierInfo{
+				ID:        oidPolicyQualifierCPS,
+				Qualifier: asn1.RawValue{Tag: asn1.TagIA5String, Bytes: []byte(url)},
+			})
+		}
+		return addPolicy(certificate, policy)
+	})
+}
+
+// addPolicy add policy to certificate. Policies without qualifiers are kept in PolicyIdentifiers, x509 package
+// can`t encode qualifiers, so once there is a qualified policy all of them are encoded in extra extension.
+func addPolicy(certificate *x509.Certificate, policy policyInformation) error {
+	i := extraExtensionIndex(certificate, oidCertificatePolicies)
+	if i < 0 && len(policy.Qualifiers) == 0 {
+		certificate.PolicyIdentifiers = append(certificate.PolicyIdentifiers, policy.ID)
+		return nil
+	}
+	policies := make([]policyInformation, 0)
+	if i >= 0 {
+		if _, err := asn1.Unmarshal(certificate.ExtraExtensions[i].Value, &policies); err != nil {
+			return fmt.Errorf("can`t parse certificate policies: %w", err)
+		}
+	}
+	for _, oid := range certificate.PolicyIdentifiers {
+		policies = append(policies, policyInformation{ID: oid})
+	}
+	certificate.PolicyIdentifiers = nil
+	policies = append(policies, policy)
+	value, err := asn1.Marshal(policies)
+	if err != nil {
+		return fmt.Errorf("can`t encode certificate policies: %w", err)
+	}
+	setExtraExtension(certificate, pkix.Extension{Id: oidCertificatePolicies, Value: value})
+	return nil
+}
+
+// QCStatement is a qualified certificate statement with optional statement info
+type QCStatement struct {
+	ID   asn1.ObjectIdentifier
+	Info asn1.RawValue `asn1:"optional"`
+}
+
+// QCTypeStatement return QcType statement with types like OIDQCTypeESign
+func QCTypeStatement(types ...asn1.ObjectIdentifier) (QCStatement, error) {
+	info, err := asn1.Marshal(types)
+	if err != nil {
+		return QCStatement{}, fmt.Errorf("can`t encode qc types: %w", err)
+	}
+	return QCStatement{ID: OIDQCType, Info: asn1.RawValue{FullBytes: info}}, nil
+}
+
+// QCStatements add qualified certificate statements extension, e.g.
+//
+//	qcType, err := QCTypeStatement(OIDQCTypeESign)
+//	...
+//	QCStatements(QCStatement{ID: OIDQCCompliance}, qcType)
+func QCStatements(statements ...QCStatement) CertificateOption {
+	return templateOption(func(certificate *x509.Certificate) error {
+		value, err := asn1.Marshal(statements)
+		if err != nil {
+			return fmt.Errorf("can`t encode qc statements: %w", err)
+		}
+		setExtraExtension(certificate, pkix.Extension{Id: oidQCStatements, Value: value})
+		return nil
+	})
+}
+
+func extraExtensionIndex(certificate *x509.Certificate, oid asn1.ObjectIdentifier) int {
+	for i, ext := range certificate.ExtraExtensions {
+		if ext.Id.Equal(oid) {
+			return i
+		}
+	}
+	return -1
+}
+
+// setExtraExtension replace extra extension with the same oid or add it
+func setExtraExtension(certificate *x509.Certificate, ext pkix.Extension) {
+	if i := extraExtensionIndex(certificate, ext.Id); i >= 0 {
+		certificate.ExtraExtensions[i] = ext
+		return
+	}
+	certificate.ExtraExtensions = append(certificate.ExtraExtensions, ext)
+}
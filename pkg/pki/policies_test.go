@@ -0,0 +1,75 @@
+package pki
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCertificatePolicies(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+	plain := asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1}
+	qualified := asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 2}
+
+	issued, err := pki.Issue(Identity{CommonName: "plain"}, CertificatePolicies(plain))
+	assert.NoError(t, err)
+	cert, err := issued.DecodeCert()
+	assert.NoError(t, err)
+	assert.Equal(t, []asn1.ObjectIdentifier{plain}, cert.PolicyIdentifiers)
+
+	issued, err = pki.Issue(Identity{CommonName: "qualified"},
+		CertificatePolicies(plain), CertificatePolicy(qualified, "https://pki.example.com/cps"))
+	assert.NoError(t, err)
+	cert, err = issued.DecodeCert()
+	assert.NoError(t, err)
+	assert.Equal(t, []asn1.ObjectIdentifier{plain, qualified}, cert.PolicyIdentifiers)
+	var policies []policyInformation
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidCertificatePolicies) {
+			_, err = asn1.Unmarshal(ext.Value, &policies)
+			assert.NoError(t, err)
+		}
+	}
+	assert.Len(t, policies, 2)
+	assert.Equal(t, "https://pki.example.com/cps", string(policies[1].Qualifiers[0].Qualifier.Bytes))
+
+	qcType, err := QCTypeStatement(OIDQCTypeESign)
+	assert.NoError(t, err)
+	issued, err = pki.Issue(Identity{CommonName: "qc"}, QCStatements(QCStatement{ID: OIDQCCompliance}, qcType))
+	assert.NoError(t, err)
+	cert, err = issued.DecodeCert()
+	assert.NoError(t, err)
+	var statements []QCStatement
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidQCStatements) {
+			_, err = asn1.Unmarshal(ext.Value, &statements)
+			assert.NoError(t, err)
+		}
+	}
+	assert.Len(t, statements, 2)
+	assert.Equal(t, OIDQCCompliance, statements[0].ID)
+	var types []asn1.ObjectIdentifier
+	_, err = asn1.Unmarshal(statements[1].Info.FullBytes, &types)
+	assert.NoError(t, err)
+	assert.Equal(t, []asn1.ObjectIdentifier{OIDQCTypeESign}, types)
+
+	bad := asn1.ObjectIdentifier{1}
+	_, err = QCTypeStatement(bad)
+	assert.Error(t, err)
+	_, err = pki.Issue(Identity{CommonName: "bad-policy"}, CertificatePolicy(bad, "https://pki.example.com/cps"))
+	assert.Error(t, err)
+	_, err = pki.Issue(Identity{CommonName: "bad-qc"}, QCStatements(QCStatement{ID: bad}))
+	assert.Error(t, err)
+	broken := Option(func(certificate *x509.Certificate) {
+		certificate.ExtraExtensions = append(certificate.ExtraExtensions,
+			pkix.Extension{Id: oidCertificatePolicies, Value: []byte{0xff}})
+	})
+	_, err = pki.Issue(Identity{CommonName: "broken-policies"}, broken, CertificatePolicies(qualified))
+	assert.Error(t, err)
+}
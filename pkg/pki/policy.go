@@ -0,0 +1,69 @@
+package pki
+
+import (
+	"crypto/x509"
+	"fmt"
+	"time"
+)
+
+// ValidityPolicy limit validity of certificates with profile, e.g. 1 year for servers and 90 days for clients.
+// Longer requests are clamped to Max or rejected. Decisions are written into audit log.
+type ValidityPolicy struct {
+	Profile Profile
+	Max     time.Duration
+	Reject  bool // reject longer requests instead of clamping NotAfter
+}
+
+// ValidityPolicyError is returned when requested validity is rejected by policy
+type ValidityPolicyError struct {
+	Profile   Profile
+	Max       time.Duration
+	Requested time.Duration
+}
+
+func (e *ValidityPolicyError) Error() string {
+	return fmt.Sprintf("requested validity %v exceeds maximum %v of %q profile", e.Requested, e.Max, string(e.Profile))
+}
+
+// certProfile return identity profile or profile inferred from extended key usages of template
+func certProfile(id Identity, template *x509.Certificate) Profile {
+	if id.Profile != ProfileNone {
+		return id.Profile
+	}
+	for _, usage := range template.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageServerAuth {
+			return ProfileServer
+		}
+	}
+	for _, usage := range template.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageClientAuth {
+			return ProfileClient
+		}
+	}
+	return ProfileNone
+}
+
+// enforceValidity clamp NotAfter of template or reject it according to policy of certificate profile.
+// It returns audit record of the decision, nil if policy wasn`t applied.
+func (p *PKI) enforceValidity(id Identity, template *x509.Certificate, now time.Time) (*AuditRecord, error) {
+	profile := certProfile(id, template)
+	policy, ok := p.validityPolicies[profile]
+	if !ok {
+		return nil, nil
+	}
+	requested := template.NotAfter.Sub(now)
+	if requested <= policy.Max {
+		return nil, nil
+	}
+	record := &AuditRecord{Name: id.Key(), Profile: profile}
+	if policy.Reject {
+		record.Action = AuditRejectValidity
+		record.Detail = fmt.Sprintf("requested not after %v, maximum validity %v", template.NotAfter.Format(time.RFC3339), policy.Max)
+		return record, &ValidityPolicyError{Profile: profile, Max: policy.Max, Requested: requested}
+	}
+	notAfter := now.Add(policy.Max).UTC()
+	record.Action = AuditClampValidity
+	record.Detail = fmt.Sprintf("not after %v clamped to %v", template.NotAfter.Format(time.RFC3339), notAfter.Format(time.RFC3339))
+	template.NotAfter = notAfter
+	return record, nil
+}
@@ -0,0 +1,56 @@
+package pki
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithValidityPolicy(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	auditPath := filepath.Join(testData, "audit.log")
+	WithAuditFile(auditPath)(pki)
+	WithValidityPolicy(
+		ValidityPolicy{Profile: ProfileServer, Max: 365 * 24 * time.Hour},
+		ValidityPolicy{Profile: ProfileClient, Max: 90 * 24 * time.Hour, Reject: true},
+	)(pki)
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+
+	server, err := pki.NewCert("server", Server())
+	assert.NoError(t, err)
+	cert, err := server.DecodeCert()
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(365*24*time.Hour), cert.NotAfter, time.Minute)
+
+	_, err = pki.NewCert("client", Client())
+	var policyErr *ValidityPolicyError
+	assert.True(t, errors.As(err, &policyErr))
+	assert.Equal(t, ProfileClient, policyErr.Profile)
+	_, err = pki.Issue(Identity{CommonName: "client", Profile: ProfileClient}, NotAfter(time.Now().Add(30*24*time.Hour)))
+	assert.NoError(t, err)
+
+	_, err = pki.NewCert("other")
+	assert.NoError(t, err)
+
+	content, err := os.ReadFile(auditPath)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	assert.Len(t, lines, 2)
+	records := make([]AuditRecord, len(lines))
+	for i, line := range lines {
+		assert.NoError(t, json.Unmarshal([]byte(line), &records[i]))
+	}
+	assert.Equal(t, AuditClampValidity, records[0].Action)
+	assert.Equal(t, "server", records[0].Name)
+	assert.Equal(t, pki.Fingerprint(cert), records[0].Fingerprint)
+	assert.Equal(t, AuditRejectValidity, records[1].Action)
+	assert.Equal(t, ProfileClient, records[1].Profile)
+}
@@ -0,0 +1,50 @@
+package pki
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithProfile(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+
+	server, err := pki.NewServerCert("server")
+	assert.NoError(t, err)
+	cert, err := server.DecodeCert()
+	assert.NoError(t, err)
+	assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}, cert.ExtKeyUsage)
+
+	WithProfile(ProfileServer, Server(), ExtKeyUsage(x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth))(pki)
+	WithProfile(ProfileClient, KeyUsage(x509.KeyUsageDigitalSignature), ExtKeyUsage(x509.ExtKeyUsageClientAuth))(pki)
+	WithProfile("code", KeyUsage(x509.KeyUsageDigitalSignature), ExtKeyUsage(x509.ExtKeyUsageCodeSigning))(pki)
+
+	server, err = pki.NewServerCert("server")
+	assert.NoError(t, err)
+	cert, err = server.DecodeCert()
+	assert.NoError(t, err)
+	assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}, cert.ExtKeyUsage)
+
+	client, err := pki.NewClientCert("client")
+	assert.NoError(t, err)
+	cert, err = client.DecodeCert()
+	assert.NoError(t, err)
+	assert.Equal(t, x509.KeyUsageDigitalSignature, cert.KeyUsage)
+	for _, ext := range cert.Extensions {
+		assert.False(t, ext.Id.Equal(asn1.ObjectIdentifier{2, 16, 840, 1, 113730, 1, 1}), "nsCertType is dropped")
+	}
+
+	signer, err := pki.Issue(Identity{CommonName: "signer", Profile: "code"})
+	assert.NoError(t, err)
+	cert, err = signer.DecodeCert()
+	assert.NoError(t, err)
+	assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning}, cert.ExtKeyUsage)
+
+	_, err = pki.Issue(Identity{CommonName: "unknown", Profile: "unknown"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,141 @@
+package pki
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// oidCRLNumber is an object identifier of crl number extension
+var oidCRLNumber = asn1.ObjectIdentifier{2, 5, 29, 20}
+
+// MirrorStatus is a result of comparing crl fetched from distribution url with local one
+type MirrorStatus struct {
+	URL        string
+	Number     *big.Int   // crl number of mirror crl, nil if it has no one
+	ThisUpdate time.Time  // issue time of mirror crl
+	Stale      bool       // mirror crl is older than local one
+	Missing    []*big.Int // serials revoked locally, but absent in mirror crl
+	Extra      []*big.Int // serials present in mirror crl only, e.g. released from hold
+	Err        error      // fetch, parse or signature error
+}
+
+// Lagging check that mirror doesn`t serve local crl yet
+func (s MirrorStatus) Lagging() bool {
+	return s.Err != nil || s.Stale || len(s.Missing) != 0 || len(s.Extra) != 0
+}
+
+// CheckCRLPropagation fetch crl from every distribution url and compare it with local crl.
+// Mirror crl must be signed by one of CAs. Client with 10 seconds timeout is used if client is nil.
+func (p *PKI) CheckCRLPropagation(urls []string, client *http.Client) ([]MirrorStatus, error) {
+	local, err := p.GetCRL()
+	if err != nil {
+		return nil, fmt.Errorf("can`t get crl: %w", err)
+	}
+	_, caCerts, err := p.validCAs()
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	res := make([]MirrorStatus, len(urls))
+	wg := sync.WaitGroup{}
+	for i, url := range urls {
+		wg.Add(1)
+		go func(i int, url string) {
+			defer wg.Done()
+			res[i] = checkMirror(client, url, local, caCerts)
+		}(i, url)
+	}
+	wg.Wait()
+	return res, nil
+}
+
+func checkMirror(client *http.Client, url string, local *pkix.CertificateList, caCerts []*x509.Certificate) MirrorStatus {
+	status := MirrorStatus{URL: url}
+	mirror, err := fetchCRL(client, url)
+	if err != nil {
+		status.Err = err
+		return status
+	}
+	if !signedByAny(mirror, caCerts) {
+		status.Err = fmt.Errorf("%v: crl isn`t signed by any ca", url)
+	}
+	status.Number = crlNumber(mirror)
+	status.ThisUpdate = mirror.TBSCertList.ThisUpdate
+	localNumber := crlNumber(local)
+	if status.Number != nil && localNumber != nil {
+		status.Stale = status.Number.Cmp(localNumber) < 0
+	} else {
+		status.Stale = status.ThisUpdate.Before(local.TBSCertList.ThisUpdate)
+	}
+	status.Missing = serialsDiff(local, mirror)
+	status.Extra = serialsDiff(mirror, local)
+	return status
+}
+
+// fetchCRL download and parse pem or der crl
+func fetchCRL(client *http.Client, url string) (*pkix.CertificateList, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("can`t fetch %v: %w", url, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("can`t fetch %v: %v", url, resp.Status)
+	}
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("can`t read %v: %w", url, err)
+	}
+	list, err := x509.ParseCRL(content)
+	if err != nil {
+		return nil, fmt.Errorf("can`t parse crl from %v: %w", url, err)
+	}
+	return list, nil
+}
+
+// crlNumber return crl number extension value or nil
+func crlNumber(list *pkix.CertificateList) *big.Int {
+	for _, ext := range list.TBSCertList.Extensions {
+		if !ext.Id.Equal(oidCRLNumber) {
+			continue
+		}
+		number := new(big.Int)
+		if _, err := asn1.Unmarshal(ext.Value, &number); err == nil {
+			return number
+		}
+	}
+	return nil
+}
+
+// serialsDiff return sorted serials revoked in a, but not in b
+func serialsDiff(a, b *pkix.CertificateList) []*big.Int {
+	inB := map[string]bool{}
+	for _, entry := range b.TBSCertList.RevokedCertificates {
+		inB[entry.SerialNumber.String()] = true
+	}
+	res := make([]*big.Int, 0)
+	seen := map[string]bool{}
+	for _, entry := range a.TBSCertList.RevokedCertificates {
+		key := entry.SerialNumber.String()
+		if !inB[key] && !seen[key] {
+			seen[key] = true
+			res = append(res, entry.SerialNumber)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Cmp(res[j]) == -1
+	})
+	return res
+}
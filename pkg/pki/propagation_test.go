@@ -0,0 +1,55 @@
+package pki
+
+import (
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPKI_CheckCRLPropagation(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+	first, err := pki.NewCert("first")
+	assert.NoError(t, err)
+	second, err := pki.NewCert("second")
+	assert.NoError(t, err)
+	assert.NoError(t, pki.RevokeOne(first.Serial))
+	oldCRL, err := pki.crlPEM()
+	assert.NoError(t, err)
+	time.Sleep(time.Second) // crl times have seconds precision
+	assert.NoError(t, pki.RevokeOne(second.Serial))
+	currentCRL, err := pki.crlPEM()
+	assert.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old.pem", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(oldCRL)
+	})
+	mux.HandleFunc("/current.pem", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(currentCRL)
+	})
+	mux.HandleFunc("/garbage.pem", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("garbage"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	statuses, err := pki.CheckCRLPropagation([]string{
+		server.URL + "/current.pem", server.URL + "/old.pem", server.URL + "/garbage.pem", server.URL + "/missing.pem",
+	}, nil)
+	assert.NoError(t, err)
+	assert.Len(t, statuses, 4)
+	assert.False(t, statuses[0].Lagging())
+	assert.True(t, statuses[1].Lagging())
+	assert.True(t, statuses[1].Stale)
+	assert.Equal(t, []*big.Int{second.Serial}, statuses[1].Missing)
+	assert.Empty(t, statuses[1].Extra)
+	assert.Error(t, statuses[2].Err)
+	assert.Error(t, statuses[3].Err)
+}
@@ -0,0 +1,93 @@
+package pki
+
+import (
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"github.com/kemsta/go-easyrsa/internal/fsStorage"
+)
+
+// Stable names of published files
+const (
+	PublishCACert = "ca.crt"    // certificate of the last CA
+	PublishCRL    = "crl.pem"   // current crl, it isn`t published until the first revocation
+	PublishChain  = "chain.pem" // all non-expired CA and intermediate certificates
+
+	PublishPreviousCRL = "previous.crl.pem" // crl signed by previous CA, it`s published only during rotation overlap
+)
+
+// NewDirPublisher return publisher writing files into dir atomically, e.g. web root of static server
+func NewDirPublisher(dir string) Publisher {
+	return fsStorage.NewDirPublisher(dir)
+}
+
+// Publish write CA certificate, crl and chain bundle to publisher with stable names
+func (p *PKI) Publish(publisher Publisher) error {
+	ca, err := p.GetLastCA()
+	if err != nil {
+		return fmt.Errorf("can`t get ca: %w", err)
+	}
+	if err := publisher.Publish(PublishCACert, ca.CertPemBytes); err != nil {
+		return fmt.Errorf("can`t publish %v: %w", PublishCACert, err)
+	}
+	chain, err := p.GetTrustBundle()
+	if err != nil {
+		return fmt.Errorf("can`t get chain: %w", err)
+	}
+	if err := publisher.Publish(PublishChain, chain); err != nil {
+		return fmt.Errorf("can`t publish %v: %w", PublishChain, err)
+	}
+	crl, err := p.crlPEM()
+	if err != nil {
+		return err
+	}
+	if crl == nil {
+		return nil
+	}
+	if err := publisher.Publish(PublishCRL, crl); err != nil {
+		return fmt.Errorf("can`t publish %v: %w", PublishCRL, err)
+	}
+	return p.publishPreviousCRL(publisher)
+}
+
+// publishPreviousCRL write crl signed by previous CA to publisher during rotation overlap
+func (p *PKI) publishPreviousCRL(publisher Publisher) error {
+	if p.previousCRL == nil {
+		return nil
+	}
+	if previous, err := p.overlappingCA(); err != nil || previous == nil {
+		return err
+	}
+	list, err := p.previousCRL.Get()
+	if err != nil {
+		return fmt.Errorf("can`t get crl of previous ca: %w", err)
+	}
+	if len(list.SignatureValue.Bytes) == 0 {
+		return nil
+	}
+	crlDER, err := asn1.Marshal(*list)
+	if err != nil {
+		return fmt.Errorf("can`t marshal crl of previous ca: %w", err)
+	}
+	content := pem.EncodeToMemory(&pem.Block{Type: PEMx509CRLBlock, Bytes: crlDER})
+	if err := publisher.Publish(PublishPreviousCRL, content); err != nil {
+		return fmt.Errorf("can`t publish %v: %w", PublishPreviousCRL, err)
+	}
+	return nil
+}
+
+// crlPEM return current crl encoded as pem or nil if crl wasn`t signed yet
+func (p *PKI) crlPEM() ([]byte, error) {
+	list, err := p.GetCRL()
+	if err != nil {
+		return nil, fmt.Errorf("can`t get crl: %w", err)
+	}
+	if len(list.SignatureValue.Bytes) == 0 {
+		return nil, nil
+	}
+	crlDER, err := asn1.Marshal(*list)
+	if err != nil {
+		return nil, fmt.Errorf("can`t marshal crl: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: PEMx509CRLBlock, Bytes: crlDER}), nil
+}
@@ -0,0 +1,76 @@
+package pki
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithPublishDir(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	webRoot := t.TempDir()
+	WithPublishDir(webRoot)(pki)
+	ca, err := pki.NewCa()
+	assert.NoError(t, err)
+	caCert, err := os.ReadFile(filepath.Join(webRoot, PublishCACert))
+	assert.NoError(t, err)
+	assert.Equal(t, ca.CertPemBytes, caCert)
+	assert.FileExists(t, filepath.Join(webRoot, PublishChain))
+	assert.NoFileExists(t, filepath.Join(webRoot, PublishCRL))
+
+	client, err := pki.NewCert("client")
+	assert.NoError(t, err)
+	assert.NoError(t, pki.RevokeOne(client.Serial))
+	crl, err := os.ReadFile(filepath.Join(webRoot, PublishCRL))
+	assert.NoError(t, err)
+	assert.Contains(t, string(crl), "BEGIN X509 CRL")
+}
+
+func TestS3Publisher(t *testing.T) {
+	var mu sync.Mutex
+	objects := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if r.Method != http.MethodPut || !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") ||
+			!strings.Contains(auth, "/eu-west-1/s3/aws4_request") || r.Header.Get("X-Amz-Security-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		objects[r.URL.Path] = string(body)
+		mu.Unlock()
+	}))
+	defer server.Close()
+	publisher := &S3Publisher{
+		Endpoint:        server.URL,
+		Region:          "eu-west-1",
+		Bucket:          "bucket",
+		Prefix:          "pki/",
+		AccessKeyID:     "AKID",
+		SecretAccessKey: "secret",
+		SessionToken:    "token",
+	}
+	assert.NoError(t, publisher.Publish(PublishCACert, []byte("cert")))
+	assert.Equal(t, map[string]string{"/bucket/pki/ca.crt": "cert"}, objects)
+
+	publisher.SessionToken = ""
+	assert.Error(t, publisher.Publish(PublishCACert, []byte("cert")))
+
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+	publisher.SessionToken = "token"
+	assert.NoError(t, pki.Publish(publisher))
+	assert.Contains(t, objects, "/bucket/pki/chain.pem")
+	assert.NotContains(t, objects, "/bucket/pki/crl.pem")
+}
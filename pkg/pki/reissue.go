@@ -0,0 +1,93 @@
+package pki
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/kemsta/go-easyrsa/pkg/pair"
+)
+
+// ReissueAll re-sign public keys of all valid leaf certificates with newCA pair, e.g. during CA rotation.
+// Subject, SANs, usages and NotAfter are preserved, private keys are kept. Leaf keys already certified by newCA
+// are skipped, so it`s safe to call it again after failure. It returns new pairs sorted by serial.
+func (p *PKI) ReissueAll(newCA *pair.X509Pair) ([]*pair.X509Pair, error) {
+	caKey, caCert, release, err := p.caSigner(newCA)
+	if err != nil {
+		return nil, fmt.Errorf("can`t parse ca pair: %w", err)
+	}
+	defer release()
+	if !caCert.IsCA {
+		return nil, fmt.Errorf("pair %v with serial %v is not a ca", newCA.CN, newCA.Serial)
+	}
+	pairs, err := p.Storage.GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("can`t get pairs: %w", err)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].Serial.Cmp(pairs[j].Serial) == -1
+	})
+	revoked := p.revokedSerials(true)
+	now := time.Now()
+	certs := make([]*x509.Certificate, len(pairs))
+	certified := map[[sha256.Size]byte]bool{}
+	for i, certPair := range pairs {
+		if certs[i], err = certPair.DecodeCert(); err != nil {
+			return nil, err
+		}
+		if !certs[i].IsCA && certs[i].CheckSignatureFrom(caCert) == nil {
+			certified[sha256.Sum256(certs[i].RawSubjectPublicKeyInfo)] = true
+		}
+	}
+	res := make([]*pair.X509Pair, 0)
+	for i, certPair := range pairs {
+		cert := certs[i]
+		if cert.IsCA || now.After(cert.NotAfter) || revoked[certPair.Serial.String()] ||
+			certified[sha256.Sum256(cert.RawSubjectPublicKeyInfo)] {
+			continue
+		}
+		serial, err := p.nextSerial()
+		if err != nil {
+			return res, err
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber:          serial,
+			Subject:               cert.Subject,
+			NotBefore:             now.Add(-10 * time.Minute).UTC(),
+			NotAfter:              cert.NotAfter,
+			KeyUsage:              cert.KeyUsage,
+			ExtKeyUsage:           cert.ExtKeyUsage,
+			BasicConstraintsValid: true,
+			DNSNames:              cert.DNSNames,
+			EmailAddresses:        cert.EmailAddresses,
+			IPAddresses:           cert.IPAddresses,
+			URIs:                  cert.URIs,
+		}
+		der, err := x509.CreateCertificate(p.random(), tmpl, caCert, cert.PublicKey, caKey)
+		if err != nil {
+			return res, fmt.Errorf("can`t reissue %v with serial %v: %w", certPair.CN, certPair.Serial, err)
+		}
+		keyPem := make([]byte, len(certPair.KeyPemBytes))
+		copy(keyPem, certPair.KeyPemBytes)
+		reissued := pair.NewX509Pair(keyPem, pem.EncodeToMemory(&pem.Block{Type: PEMCertificateBlock, Bytes: der}),
+			certPair.CN, serial)
+		if err := p.putLeaf(reissued, caCert); err != nil {
+			return res, fmt.Errorf("can`t put reissued %v with serial %v: %w", certPair.CN, serial, err)
+		}
+		certified[sha256.Sum256(cert.RawSubjectPublicKeyInfo)] = true
+		res = append(res, reissued)
+	}
+	if err := p.exportIndex(); err != nil {
+		return res, fmt.Errorf("can`t export index: %w", err)
+	}
+	var hookErr error
+	for _, reissued := range res {
+		if err := p.runHooks(EventIssue, reissued); err != nil && hookErr == nil {
+			hookErr = err
+		}
+	}
+	return res, hookErr
+}
@@ -0,0 +1,47 @@
+package pki
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPKI_ReissueAll(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+	server, err := pki.NewCert("server", Server(), DNSNames([]string{"server.example.com"}))
+	assert.NoError(t, err)
+	revoked, err := pki.NewCert("revoked", Client())
+	assert.NoError(t, err)
+	assert.NoError(t, pki.RevokeOne(revoked.Serial))
+	newCA, err := pki.NewCa()
+	assert.NoError(t, err)
+
+	got, err := pki.ReissueAll(newCA)
+	assert.NoError(t, err)
+	assert.Len(t, got, 1)
+	assert.Equal(t, "server", got[0].CN)
+	assert.Equal(t, server.KeyPemBytes, got[0].KeyPemBytes)
+	oldCert, err := server.DecodeCert()
+	assert.NoError(t, err)
+	_, newCert, err := got[0].Decode()
+	assert.NoError(t, err)
+	_, caCert, err := newCA.Decode()
+	assert.NoError(t, err)
+	assert.NoError(t, newCert.CheckSignatureFrom(caCert))
+	assert.Equal(t, oldCert.DNSNames, newCert.DNSNames)
+	assert.Equal(t, oldCert.ExtKeyUsage, newCert.ExtKeyUsage)
+	assert.Equal(t, oldCert.NotAfter, newCert.NotAfter)
+	assert.Equal(t, oldCert.RawSubjectPublicKeyInfo, newCert.RawSubjectPublicKeyInfo)
+	last, err := pki.Storage.GetLastByCn("server")
+	assert.NoError(t, err)
+	assert.Equal(t, got[0].Serial, last.Serial)
+
+	got, err = pki.ReissueAll(newCA)
+	assert.NoError(t, err)
+	assert.Empty(t, got)
+	_, err = pki.ReissueAll(server)
+	assert.Error(t, err)
+}
@@ -0,0 +1,110 @@
+package pki
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CertReloader serve the last pair with name from storage to tls servers and clients. Storage is polled,
+// so renewed certificates are picked up without restarts:
+//
+//	reloader, err := pki.NewCertReloader(p.Storage, "server", time.Minute)
+//	server := &http.Server{TLSConfig: &tls.Config{GetCertificate: reloader.GetCertificate}}
+type CertReloader struct {
+	storage KeyStorage
+	name    string
+	current atomic.Value // *tls.Certificate
+	mu      sync.Mutex
+	serial  *big.Int
+	err     error
+	done    chan struct{}
+	once    sync.Once
+}
+
+// NewCertReloader load the last pair with name and check storage for a newer one every interval until Close.
+// Zero interval disables polling, Reload can be called instead.
+func NewCertReloader(storage KeyStorage, name string, interval time.Duration) (*CertReloader, error) {
+	r := &CertReloader{storage: storage, name: name, done: make(chan struct{})}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	if interval > 0 {
+		go r.poll(interval)
+	}
+	return r, nil
+}
+
+// Reload swap served certificate if storage has a new pair with name. Current certificate is kept on error.
+func (r *CertReloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.err = r.reload()
+	return r.err
+}
+
+func (r *CertReloader) reload() error {
+	last, err := r.storage.GetLastByCn(r.name)
+	if err != nil {
+		return fmt.Errorf("can`t get %v: %w", r.name, err)
+	}
+	if r.serial != nil && r.serial.Cmp(last.Serial) == 0 {
+		return nil
+	}
+	cert, err := tls.X509KeyPair(last.CertPemBytes, last.KeyPemBytes)
+	if err != nil {
+		return fmt.Errorf("can`t load %v with serial %v: %w", r.name, last.Serial, err)
+	}
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return fmt.Errorf("can`t parse %v with serial %v: %w", r.name, last.Serial, err)
+	}
+	r.current.Store(&cert)
+	r.serial = last.Serial
+	return nil
+}
+
+func (r *CertReloader) poll(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+			_ = r.Reload()
+		}
+	}
+}
+
+// Err return error of the last reload or nil
+func (r *CertReloader) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Certificate return currently served certificate
+func (r *CertReloader) Certificate() *tls.Certificate {
+	return r.current.Load().(*tls.Certificate)
+}
+
+// GetCertificate is a tls.Config.GetCertificate for servers
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.Certificate(), nil
+}
+
+// GetClientCertificate is a tls.Config.GetClientCertificate for clients
+func (r *CertReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return r.Certificate(), nil
+}
+
+// Close stop polling storage. Last loaded certificate is still served.
+func (r *CertReloader) Close() {
+	r.once.Do(func() {
+		close(r.done)
+	})
+}
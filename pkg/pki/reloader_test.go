@@ -0,0 +1,41 @@
+package pki
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCertReloader(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+	_, err = NewCertReloader(pki.Storage, "server", 0)
+	assert.Error(t, err)
+
+	first, err := pki.NewCert("server", Server())
+	assert.NoError(t, err)
+	reloader, err := NewCertReloader(pki.Storage, "server", 10*time.Millisecond)
+	assert.NoError(t, err)
+	defer reloader.Close()
+	cert, err := reloader.GetCertificate(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, first.Serial, cert.Leaf.SerialNumber)
+
+	renewed, err := pki.NewCert("server", Server())
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		cert, _ := reloader.GetCertificate(nil)
+		return cert.Leaf.SerialNumber.Cmp(renewed.Serial) == 0
+	}, time.Second, 10*time.Millisecond)
+	assert.NoError(t, reloader.Err())
+
+	assert.NoError(t, pki.Storage.DeleteByCn("server"))
+	assert.Error(t, reloader.Reload())
+	cert, err = reloader.GetClientCertificate(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, renewed.Serial, cert.Leaf.SerialNumber)
+	reloader.Close()
+}
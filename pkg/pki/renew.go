@@ -0,0 +1,153 @@
+package pki
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/kemsta/go-easyrsa/pkg/pair"
+)
+
+// Renew issue new certificate for stored key of certificate with serial, e.g. when rolling keys of openvpn clients
+// is expensive. Subject, SANs and usages are preserved, serial and validity are new like for Issue, and options
+// are applied on top. If request of certificate is kept by CSRStore, SANs are taken from it, so default SANs
+// are applied like on first issue and not copied. Old certificate isn`t revoked. Revoked and CA certificates
+// can`t be renewed.
+func (p *PKI) Renew(serial *big.Int, opts ...CertificateOption) (*pair.X509Pair, error) {
+	certPair, err := p.Storage.GetBySerial(serial)
+	if err != nil {
+		return nil, fmt.Errorf("can`t get pair with serial %v: %w", serial, err)
+	}
+	cert, err := decodeLeaf(certPair)
+	if err != nil {
+		return nil, err
+	}
+	status, err := p.Status(serial)
+	if err != nil {
+		return nil, err
+	}
+	if status == CertStatusRevoked || status == CertStatusSuspended {
+		return nil, fmt.Errorf("pair %v with serial %v is %v", certPair.CN, serial, status)
+	}
+	id, base := sameIdentity(certPair, cert), []CertificateOption{sameAs(cert)}
+	req, err := p.storedRequest(serial)
+	if err != nil {
+		return nil, err
+	}
+	if req != nil {
+		public, ok := req.PublicKey.(interface{ Equal(crypto.PublicKey) bool })
+		if !ok || !public.Equal(cert.PublicKey) {
+			return nil, fmt.Errorf("csr of pair %v with serial %v doesn`t match its key", certPair.CN, serial)
+		}
+		id.DNSNames, id.IPAddresses = req.DNSNames, req.IPAddresses
+		base = append(base, fromRequest(encodeRequest(req)))
+	}
+	keyPEM := make([]byte, len(certPair.KeyPemBytes))
+	copy(keyPEM, certPair.KeyPemBytes)
+	return p.issue(context.Background(), id, cert.PublicKey, keyPEM, append(base, opts...))
+}
+
+// ReKey issue certificate with new key for the last certificate with cn, e.g. after suspected key compromise.
+// Subject, SANs and usages are copied like for Renew. Old certificate isn`t revoked, it`s up to caller.
+func (p *PKI) ReKey(cn string, opts ...CertificateOption) (*pair.X509Pair, error) {
+	certPair, err := p.Storage.GetLastByCn(cn)
+	if err != nil {
+		return nil, fmt.Errorf("can`t get last pair of %v: %w", cn, err)
+	}
+	cert, err := decodeLeaf(certPair)
+	if err != nil {
+		return nil, err
+	}
+	return p.issue(context.Background(), sameIdentity(certPair, cert), nil, nil,
+		append([]CertificateOption{sameAs(cert)}, opts...))
+}
+
+// RenewCa re-sign the last CA certificate with the same key and subject but new serial and validity like easy-rsa
+// renew-ca does. Certificates issued by previous CA certificate keep verifying with renewed one, so nothing has to be
+// reissued. Validity is PKI default unless options set it, other options are applied on top as well.
+func (p *PKI) RenewCa(opts ...CertificateOption) (*pair.X509Pair, error) {
+	unlock, err := p.lock(p.CAName())
+	if err != nil {
+		return nil, fmt.Errorf("can`t lock ca renewal: %w", err)
+	}
+	defer unlock()
+	caPair, err := p.GetLastCA()
+	if err != nil {
+		return nil, fmt.Errorf("can`t get ca: %w", err)
+	}
+	signer, caCert, release, err := p.caSigner(caPair)
+	if err != nil {
+		return nil, fmt.Errorf("can`t parse ca pair: %w", err)
+	}
+	defer release()
+	if !caCert.IsCA || caCert.CheckSignatureFrom(caCert) != nil {
+		return nil, fmt.Errorf("pair %v with serial %v isn`t a self signed ca", caPair.CN, caPair.Serial)
+	}
+
+	now := p.now()
+	template := *caCert
+	template.NotBefore = now.Add(-10 * time.Minute).UTC()
+	template.NotAfter = now.Add(p.defaultValidity()).UTC()
+	if _, err := newIssuance(&template, opts); err != nil {
+		return nil, err
+	}
+	serial, err := p.nextSerial()
+	if err != nil {
+		return nil, fmt.Errorf("can`t get next serial: %w", err)
+	}
+	template.SerialNumber = serial
+	certificate, err := x509.CreateCertificate(p.random(), &template, &template, caCert.PublicKey, signer)
+	if err != nil {
+		return nil, fmt.Errorf("can`t create cert: %w", err)
+	}
+
+	keyPem := make([]byte, len(caPair.KeyPemBytes))
+	copy(keyPem, caPair.KeyPemBytes)
+	res := pair.NewX509Pair(keyPem, pem.EncodeToMemory(&pem.Block{Type: PEMCertificateBlock, Bytes: certificate}),
+		caPair.CN, serial)
+	if err := p.Storage.Put(res); err != nil {
+		return nil, fmt.Errorf("can`t put renewed ca into storage: %w", err)
+	}
+	if err := p.exportIndex(); err != nil {
+		return nil, fmt.Errorf("can`t export index: %w", err)
+	}
+	return res, p.runHooks(EventIssue, res)
+}
+
+// decodeLeaf return certificate of pair, CA pairs are rejected
+func decodeLeaf(certPair *pair.X509Pair) (*x509.Certificate, error) {
+	cert, err := certPair.DecodeCert()
+	if err != nil {
+		return nil, err
+	}
+	if cert.IsCA {
+		return nil, fmt.Errorf("pair %v with serial %v is a ca", certPair.CN, certPair.Serial)
+	}
+	return cert, nil
+}
+
+// sameIdentity return identity of pair stored with the same name
+func sameIdentity(certPair *pair.X509Pair, cert *x509.Certificate) Identity {
+	return Identity{
+		Name:        certPair.CN,
+		CommonName:  cert.Subject.CommonName,
+		DNSNames:    cert.DNSNames,
+		IPAddresses: cert.IPAddresses,
+		Profile:     certProfile(Identity{}, cert),
+	}
+}
+
+// sameAs copy subject, usages and SANs which aren`t described by identity from cert
+func sameAs(cert *x509.Certificate) Option {
+	return func(certificate *x509.Certificate) {
+		certificate.Subject = cert.Subject
+		certificate.KeyUsage = cert.KeyUsage
+		certificate.ExtKeyUsage = cert.ExtKeyUsage
+		certificate.EmailAddresses = cert.EmailAddresses
+		certificate.URIs = cert.URIs
+	}
+}
@@ -0,0 +1,193 @@
+package pki
+
+import (
+	"crypto/x509"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPKI_Renew(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	ca, err := pki.NewCa()
+	assert.NoError(t, err)
+	old, err := pki.Issue(Identity{
+		Name:        "web",
+		CommonName:  "web.example.com",
+		DNSNames:    []string{"web.example.com", "www.example.com"},
+		IPAddresses: []net.IP{net.ParseIP("10.0.0.1")},
+		Profile:     ProfileServer,
+	}, NotAfter(time.Now().Add(time.Hour)))
+	assert.NoError(t, err)
+	oldCert, err := old.DecodeCert()
+	assert.NoError(t, err)
+
+	renewed, err := pki.Renew(old.Serial)
+	assert.NoError(t, err)
+	assert.Equal(t, "web", renewed.CN)
+	assert.True(t, renewed.Serial.Cmp(old.Serial) > 0)
+	assert.Equal(t, old.KeyPemBytes, renewed.KeyPemBytes)
+	cert, err := renewed.DecodeCert()
+	assert.NoError(t, err)
+	assert.Equal(t, oldCert.RawSubject, cert.RawSubject)
+	assert.Equal(t, oldCert.RawSubjectPublicKeyInfo, cert.RawSubjectPublicKeyInfo)
+	assert.Equal(t, oldCert.DNSNames, cert.DNSNames)
+	assert.True(t, oldCert.IPAddresses[0].Equal(cert.IPAddresses[0]))
+	assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}, cert.ExtKeyUsage)
+	assert.Equal(t, oldCert.KeyUsage, cert.KeyUsage)
+	assert.True(t, cert.NotAfter.After(oldCert.NotAfter.Add(24*time.Hour)))
+	assert.False(t, pki.IsRevoked(old.Serial))
+	_, _, err = renewed.Decode()
+	assert.NoError(t, err)
+
+	notAfter := time.Now().Add(48 * time.Hour).Truncate(time.Second)
+	renewed, err = pki.Renew(old.Serial, NotAfter(notAfter))
+	assert.NoError(t, err)
+	cert, err = renewed.DecodeCert()
+	assert.NoError(t, err)
+	assert.True(t, notAfter.Equal(cert.NotAfter))
+
+	_, err = pki.Renew(ca.Serial)
+	assert.Error(t, err)
+	assert.NoError(t, pki.RevokeOne(old.Serial))
+	_, err = pki.Renew(old.Serial)
+	assert.Error(t, err)
+}
+
+func TestPKI_RenewClient(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+	old, err := pki.NewCert("user", Client(), Option(func(certificate *x509.Certificate) {
+		certificate.Subject.OrganizationalUnit = []string{"vpn"}
+	}))
+	assert.NoError(t, err)
+
+	renewed, err := pki.Renew(old.Serial)
+	assert.NoError(t, err)
+	cert, err := renewed.DecodeCert()
+	assert.NoError(t, err)
+	assert.Equal(t, "user", cert.Subject.CommonName)
+	assert.Equal(t, []string{"vpn"}, cert.Subject.OrganizationalUnit)
+	assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}, cert.ExtKeyUsage)
+}
+
+func TestPKI_ReKey(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+	_, err = pki.ReKey("web")
+	assert.Error(t, err)
+	old, err := pki.NewServerCert("web", DNSNames([]string{"web.example.com"}))
+	assert.NoError(t, err)
+	oldCert, err := old.DecodeCert()
+	assert.NoError(t, err)
+	assert.NoError(t, pki.RevokeOne(old.Serial, Reason(ReasonKeyCompromise)))
+
+	rekeyed, err := pki.ReKey("web")
+	assert.NoError(t, err)
+	assert.Equal(t, "web", rekeyed.CN)
+	assert.NotEqual(t, old.KeyPemBytes, rekeyed.KeyPemBytes)
+	cert, err := rekeyed.DecodeCert()
+	assert.NoError(t, err)
+	assert.NotEqual(t, oldCert.RawSubjectPublicKeyInfo, cert.RawSubjectPublicKeyInfo)
+	assert.Equal(t, oldCert.RawSubject, cert.RawSubject)
+	assert.Equal(t, oldCert.DNSNames, cert.DNSNames)
+	assert.Equal(t, oldCert.ExtKeyUsage, cert.ExtKeyUsage)
+	_, _, err = rekeyed.Decode()
+	assert.NoError(t, err)
+	status, err := pki.Status(rekeyed.Serial)
+	assert.NoError(t, err)
+	assert.Equal(t, CertStatusValid, status)
+
+	_, err = pki.ReKey("ca")
+	assert.Error(t, err)
+}
+
+func TestPKI_RenewCa(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	_, err := pki.RenewCa()
+	assert.Error(t, err, "there is no ca")
+	ca, err := pki.NewCa(NotAfter(time.Now().Add(24*time.Hour)), MaxPathLen(1))
+	assert.NoError(t, err)
+	caCert, err := ca.DecodeCert()
+	assert.NoError(t, err)
+	before, err := pki.NewCert("client", Client())
+	assert.NoError(t, err)
+
+	renewed, err := pki.RenewCa()
+	assert.NoError(t, err)
+	assert.Equal(t, "ca", renewed.CN)
+	assert.True(t, renewed.Serial.Cmp(before.Serial) > 0)
+	assert.Equal(t, ca.KeyPemBytes, renewed.KeyPemBytes)
+	cert, err := renewed.DecodeCert()
+	assert.NoError(t, err)
+	assert.Equal(t, caCert.RawSubject, cert.RawSubject)
+	assert.Equal(t, caCert.RawSubjectPublicKeyInfo, cert.RawSubjectPublicKeyInfo)
+	assert.Equal(t, caCert.SubjectKeyId, cert.SubjectKeyId)
+	assert.Equal(t, caCert.KeyUsage, cert.KeyUsage)
+	assert.Equal(t, 1, cert.MaxPathLen)
+	assert.True(t, cert.IsCA)
+	assert.True(t, cert.NotAfter.After(caCert.NotAfter.AddDate(1, 0, 0)))
+	assert.NoError(t, cert.CheckSignatureFrom(cert))
+
+	beforeCert, err := before.DecodeCert()
+	assert.NoError(t, err)
+	assert.NoError(t, beforeCert.CheckSignatureFrom(cert), "old certs verify with renewed ca")
+	after, err := pki.NewCert("server", Server())
+	assert.NoError(t, err)
+	afterCert, err := after.DecodeCert()
+	assert.NoError(t, err)
+	assert.NoError(t, afterCert.CheckSignatureFrom(caCert), "new certs verify with previous ca")
+	assert.True(t, afterCert.NotAfter.After(caCert.NotAfter), "new certs aren`t capped by previous ca")
+
+	notAfter := time.Now().Add(10 * 24 * time.Hour).Truncate(time.Second)
+	renewed, err = pki.RenewCa(NotAfter(notAfter))
+	assert.NoError(t, err)
+	cert, err = renewed.DecodeCert()
+	assert.NoError(t, err)
+	assert.True(t, notAfter.Equal(cert.NotAfter))
+}
+
+func TestPKI_RenewStoredRequest(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	WithDefaultSANs(DNSSuffix("old.example.com"))(pki)
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+	csr, _, err := pki.NewRequest("web", 0, RequestDNSNames("web.example.com"))
+	assert.NoError(t, err)
+	signed, err := pki.SignRequest(csr, ProfileServer)
+	assert.NoError(t, err)
+	cert, err := signed.DecodeCert()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"web.example.com", "web.old.example.com"}, cert.DNSNames)
+	req, err := pki.storedRequest(signed.Serial)
+	assert.NoError(t, err)
+	if assert.NotNil(t, req) {
+		assert.Equal(t, []string{"web.example.com"}, req.DNSNames)
+	}
+
+	pki.defaultSANs = nil
+	renewed, err := pki.Renew(signed.Serial)
+	assert.NoError(t, err)
+	cert, err = renewed.DecodeCert()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"web.example.com"}, cert.DNSNames)
+	assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}, cert.ExtKeyUsage)
+	req, err = pki.storedRequest(renewed.Serial)
+	assert.NoError(t, err)
+	assert.NotNil(t, req)
+
+	local, err := pki.NewCert("local", Server())
+	assert.NoError(t, err)
+	req, err = pki.storedRequest(local.Serial)
+	assert.NoError(t, err)
+	assert.Nil(t, req)
+}
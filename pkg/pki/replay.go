@@ -0,0 +1,100 @@
+package pki
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Headers of signed enrollment requests. Signature is made by CSR key over EnrollDigest, so captured request
+// can`t be replayed with other nonce or time.
+const (
+	HeaderEnrollNonce     = "X-Enroll-Nonce"     // random nonce, unique for every request
+	HeaderEnrollTime      = "X-Enroll-Time"      // request time in RFC3339 format
+	HeaderEnrollSignature = "X-Enroll-Signature" // base64 signature of EnrollDigest by CSR key
+)
+
+// ErrReplayed is returned for enrollment requests which were already seen or are too old
+var ErrReplayed = errors.New("enrollment request is replayed")
+
+// EnrollDigest return sha256 digest of nonce, time and CSR for HeaderEnrollSignature. It`s signed with
+// PKCS#1 v1.5 by rsa keys, as ASN.1 by ecdsa keys and as message by ed25519 keys.
+func EnrollDigest(nonce, requestTime string, csr []byte) []byte {
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%s\n%s\n", nonce, requestTime)
+	_, _ = h.Write(csr)
+	return h.Sum(nil)
+}
+
+// replayGuard reject enrollment requests with nonce seen within window or time out of window
+type replayGuard struct {
+	window time.Duration
+	mu     sync.Mutex
+	seen   map[string]time.Time // nonce to time when it can be forgotten
+}
+
+func newReplayGuard(window time.Duration) *replayGuard {
+	return &replayGuard{window: window, seen: map[string]time.Time{}}
+}
+
+// check verify signed nonce and time of request with CSR body and public key of CSR
+func (g *replayGuard) check(r *http.Request, csr []byte, public crypto.PublicKey, now time.Time) error {
+	nonce := r.Header.Get(HeaderEnrollNonce)
+	requestTime := r.Header.Get(HeaderEnrollTime)
+	if nonce == "" || requestTime == "" {
+		return fmt.Errorf("%v and %v headers are required", HeaderEnrollNonce, HeaderEnrollTime)
+	}
+	signature, err := base64.StdEncoding.DecodeString(r.Header.Get(HeaderEnrollSignature))
+	if err != nil {
+		return fmt.Errorf("bad %v header: %w", HeaderEnrollSignature, err)
+	}
+	if err := verifyDigest(public, EnrollDigest(nonce, requestTime, csr), signature); err != nil {
+		return err
+	}
+	at, err := time.Parse(time.RFC3339, requestTime)
+	if err != nil {
+		return fmt.Errorf("bad %v header: %w", HeaderEnrollTime, err)
+	}
+	if at.Before(now.Add(-g.window)) || at.After(now.Add(g.window)) {
+		return fmt.Errorf("%w: time %v is out of %v window", ErrReplayed, requestTime, g.window)
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for seen, expires := range g.seen {
+		if now.After(expires) {
+			delete(g.seen, seen)
+		}
+	}
+	if _, ok := g.seen[nonce]; ok {
+		return fmt.Errorf("%w: nonce %v is already used", ErrReplayed, nonce)
+	}
+	g.seen[nonce] = at.Add(g.window)
+	return nil
+}
+
+// verifyDigest check signature of digest made like described in EnrollDigest
+func verifyDigest(public crypto.PublicKey, digest, signature []byte) error {
+	valid := false
+	switch public := public.(type) {
+	case *rsa.PublicKey:
+		valid = rsa.VerifyPKCS1v15(public, crypto.SHA256, digest, signature) == nil
+	case *ecdsa.PublicKey:
+		valid = ecdsa.VerifyASN1(public, digest, signature)
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(public, digest, signature)
+	default:
+		return fmt.Errorf("unsupported key type %T", public)
+	}
+	if !valid {
+		return fmt.Errorf("bad %v header: signature doesn`t match csr key", HeaderEnrollSignature)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package pki
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnrollHandler_Replay(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: "device"},
+	}, key)
+	assert.NoError(t, err)
+	enroll, err := NewEnrollHandler(pki, ProfileClient, OpenEnrollment(), EnrollReplayWindow(time.Minute))
+	assert.NoError(t, err)
+	var records []RequestLog
+	handler := WithRequestLog(func(record RequestLog) {
+		records = append(records, record)
+	}, enroll)
+	post := func(nonce string, at time.Time, signer crypto.Signer) int {
+		req := httptest.NewRequest(http.MethodPost, EnrollPath, bytes.NewReader(csr))
+		req.RemoteAddr = "192.0.2.1:4242"
+		requestTime := at.UTC().Format(time.RFC3339)
+		signature, err := signer.Sign(rand.Reader, EnrollDigest(nonce, requestTime, csr), crypto.SHA256)
+		assert.NoError(t, err)
+		req.Header.Set(HeaderEnrollNonce, nonce)
+		req.Header.Set(HeaderEnrollTime, requestTime)
+		req.Header.Set(HeaderEnrollSignature, base64.StdEncoding.EncodeToString(signature))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, post("nonce1", time.Now(), key))
+	assert.Equal(t, http.StatusConflict, post("nonce1", time.Now(), key))
+	assert.Equal(t, http.StatusConflict, post("nonce2", time.Now().Add(-time.Hour), key))
+	assert.Equal(t, http.StatusBadRequest, post("nonce3", time.Now(), other))
+	assert.Equal(t, http.StatusOK, post("nonce3", time.Now(), key))
+
+	req := httptest.NewRequest(http.MethodPost, EnrollPath, bytes.NewReader(csr))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	assert.Len(t, records, 6)
+	assert.Equal(t, "192.0.2.1", records[0].Remote)
+	assert.Equal(t, http.MethodPost, records[0].Method)
+	assert.Equal(t, EnrollPath, records[0].Path)
+	assert.Equal(t, "device", records[0].CN)
+	assert.NotEmpty(t, records[0].Serial)
+	assert.Equal(t, http.StatusOK, records[0].Status)
+	assert.Empty(t, records[0].Error)
+	assert.Equal(t, http.StatusConflict, records[1].Status)
+	assert.Contains(t, records[1].Error, "nonce1 is already used")
+	assert.Empty(t, records[1].Serial)
+}
+
+func Test_verifyDigest(t *testing.T) {
+	digest := sha256.Sum256([]byte("request"))
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	signature, err := key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	assert.NoError(t, err)
+	assert.NoError(t, verifyDigest(key.Public(), digest[:], signature))
+	assert.Error(t, verifyDigest(key.Public(), digest[1:], signature))
+	assert.Error(t, verifyDigest("key", digest[:], signature))
+}
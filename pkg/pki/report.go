@@ -0,0 +1,70 @@
+package pki
+
+import (
+	"fmt"
+	"sort"
+)
+
+// MonthFormat is a layout of months in Report
+const MonthFormat = "2006-01"
+
+// MonthCount is a number of certificates in month formatted with MonthFormat
+type MonthCount struct {
+	Month string `json:"month"`
+	Count int    `json:"count"`
+}
+
+// Report is an issuance and expiry summary of leaf certificates for capacity and renewal planning.
+// CA certificates are counted only in CAs.
+type Report struct {
+	CAs            int                `json:"cas"`
+	Leaves         int                `json:"leaves"`
+	Statuses       map[CertStatus]int `json:"statuses"`
+	Profiles       map[Profile]int    `json:"profiles"`       // ProfileNone is a leaf without tls usages
+	RevocationRate float64            `json:"revocationRate"` // share of revoked and suspended leaves
+	Issued         []MonthCount       `json:"issued"`         // leaves by month of NotBefore
+	Expiring       []MonthCount       `json:"expiring"`       // not revoked leaves by month of NotAfter
+}
+
+// Report summarize stored certificates, see Report
+func (p *PKI) Report() (*Report, error) {
+	infos, err := p.List()
+	if err != nil {
+		return nil, fmt.Errorf("can`t list certificates: %w", err)
+	}
+	res := &Report{Statuses: map[CertStatus]int{}, Profiles: map[Profile]int{}}
+	issued, expiring := map[string]int{}, map[string]int{}
+	revoked := 0
+	for _, info := range infos {
+		if info.CA {
+			res.CAs++
+			continue
+		}
+		res.Leaves++
+		res.Statuses[info.Status]++
+		res.Profiles[info.Profile]++
+		issued[info.NotBefore.UTC().Format(MonthFormat)]++
+		if info.Status == CertStatusRevoked || info.Status == CertStatusSuspended {
+			revoked++
+			continue
+		}
+		expiring[info.NotAfter.UTC().Format(MonthFormat)]++
+	}
+	if res.Leaves > 0 {
+		res.RevocationRate = float64(revoked) / float64(res.Leaves)
+	}
+	res.Issued, res.Expiring = monthCounts(issued), monthCounts(expiring)
+	return res, nil
+}
+
+// monthCounts return counts sorted by month
+func monthCounts(counts map[string]int) []MonthCount {
+	res := make([]MonthCount, 0, len(counts))
+	for month, count := range counts {
+		res = append(res, MonthCount{Month: month, Count: count})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Month < res[j].Month
+	})
+	return res
+}
@@ -0,0 +1,45 @@
+package pki
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPKI_Report(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+	expiry := time.Now().AddDate(0, 2, 0)
+	_, err = pki.NewCert("server", Server(), NotAfter(expiry))
+	assert.NoError(t, err)
+	_, err = pki.NewCert("client", Client(), NotAfter(expiry))
+	assert.NoError(t, err)
+	_, err = pki.NewCert("revoked", Client(), NotAfter(expiry))
+	assert.NoError(t, err)
+	assert.NoError(t, pki.RevokeAllByCN("revoked"))
+	_, err = pki.NewCert("plain")
+	assert.NoError(t, err)
+
+	report, err := pki.Report()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.CAs)
+	assert.Equal(t, 4, report.Leaves)
+	assert.Equal(t, map[CertStatus]int{CertStatusValid: 3, CertStatusRevoked: 1}, report.Statuses)
+	assert.Equal(t, map[Profile]int{ProfileServer: 1, ProfileClient: 2, ProfileNone: 1}, report.Profiles)
+	assert.Equal(t, 0.25, report.RevocationRate)
+	if assert.Len(t, report.Issued, 1) {
+		assert.Equal(t, 4, report.Issued[0].Count)
+	}
+	if assert.Len(t, report.Expiring, 2) {
+		assert.Equal(t, MonthCount{Month: expiry.UTC().Format(MonthFormat), Count: 2}, report.Expiring[0])
+		assert.Equal(t, 1, report.Expiring[1].Count)
+	}
+
+	content, err := json.Marshal(report)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), `"statuses":{"revoked":1,"valid":3}`)
+}
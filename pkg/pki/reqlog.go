@@ -0,0 +1,74 @@
+package pki
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// maxLoggedError limit size of error response body kept in RequestLog
+const maxLoggedError = 256
+
+// RequestLog is a structured record of http request to PKI server: who asked for what and with which result
+type RequestLog struct {
+	Time   time.Time `json:"time"`
+	Remote string    `json:"remote"` // client ip
+	Method string    `json:"method"`
+	Path   string    `json:"path"`
+	Roles  []Role    `json:"roles,omitempty"`  // roles of caller, see WithRoles
+	CN     string    `json:"cn,omitempty"`     // requested common name
+	Serial string    `json:"serial,omitempty"` // hex serial of issued or revoked certificate
+	Status int       `json:"status"`
+	Error  string    `json:"error,omitempty"` // error response body
+}
+
+type requestLogKey struct{}
+
+// WithRequestLog call fn with record of every request after it`s served. Roles are known if it`s wrapped
+// with WithRoles, common name and serial are filled by enroll and revoke handlers.
+func WithRequestLog(fn func(RequestLog), next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		record := &RequestLog{Time: time.Now(), Remote: r.RemoteAddr, Method: r.Method, Path: r.URL.Path}
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			record.Remote = host
+		}
+		record.Roles, _ = r.Context().Value(rolesKey{}).([]Role)
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), requestLogKey{}, record)))
+		record.Status = recorder.status
+		record.Error = string(bytes.TrimSpace(recorder.body.Bytes()))
+		fn(*record)
+	})
+}
+
+// logRequest change record of request if it`s logged with WithRequestLog
+func logRequest(r *http.Request, change func(*RequestLog)) {
+	if record, ok := r.Context().Value(requestLogKey{}).(*RequestLog); ok {
+		change(record)
+	}
+}
+
+// statusRecorder keep response status and the beginning of error response body
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(content []byte) (int, error) {
+	if r.status >= http.StatusBadRequest && r.body.Len() < maxLoggedError {
+		rest := maxLoggedError - r.body.Len()
+		if len(content) < rest {
+			rest = len(content)
+		}
+		r.body.Write(content[:rest])
+	}
+	return r.ResponseWriter.Write(content)
+}
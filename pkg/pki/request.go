@@ -0,0 +1,95 @@
+package pki
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"net"
+
+	"github.com/kemsta/go-easyrsa/pkg/pair"
+)
+
+// RequestOption change CSR created by NewRequest. It`s a CertificateOption as well, so subject and SANs are set
+// the same way for CSRs and for certificates issued with NewCert, NewServerCert or NewClientCert.
+type RequestOption func(*x509.CertificateRequest)
+
+// apply change subject, SANs and signature algorithm of certificate template like of CSR
+func (o RequestOption) apply(i *issuance) {
+	req := &x509.CertificateRequest{
+		Subject:            i.template.Subject,
+		DNSNames:           i.template.DNSNames,
+		IPAddresses:        i.template.IPAddresses,
+		EmailAddresses:     i.template.EmailAddresses,
+		URIs:               i.template.URIs,
+		SignatureAlgorithm: i.template.SignatureAlgorithm,
+	}
+	o(req)
+	i.template.Subject = req.Subject
+	i.template.DNSNames = req.DNSNames
+	i.template.IPAddresses = req.IPAddresses
+	i.template.EmailAddresses = req.EmailAddresses
+	i.template.URIs = req.URIs
+	i.template.SignatureAlgorithm = req.SignatureAlgorithm
+}
+
+// RequestSubject set subject of CSR instead of the PKI subject template, common name included
+func RequestSubject(subject pkix.Name) RequestOption {
+	return func(req *x509.CertificateRequest) {
+		req.Subject = subject
+	}
+}
+
+// RequestDNSNames set dns subject alternative names of CSR
+func RequestDNSNames(names ...string) RequestOption {
+	return func(req *x509.CertificateRequest) {
+		req.DNSNames = names
+	}
+}
+
+// RequestIPAddresses set ip subject alternative names of CSR
+func RequestIPAddresses(ips ...net.IP) RequestOption {
+	return func(req *x509.CertificateRequest) {
+		req.IPAddresses = ips
+	}
+}
+
+// RequestEmailAddresses set email subject alternative names of CSR
+func RequestEmailAddresses(emails ...string) RequestOption {
+	return func(req *x509.CertificateRequest) {
+		req.EmailAddresses = emails
+	}
+}
+
+// NewRequest generate key with algorithm of PKI and CSR for cn signed with it, e.g. at subordinate site which sends
+// requests to central CA. keySize is the default one of key algorithm if zero. Subject comes from PKI template.
+// It returns pem CSR and pair with key only, its certificate and serial are up to CA.
+func (p *PKI) NewRequest(cn string, keySize int, opts ...RequestOption) ([]byte, *pair.X509Pair, error) {
+	key, err := p.newSizedKey(context.Background(), keySize)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer pair.WipeKey(key)
+	tmpl := &x509.CertificateRequest{
+		Subject:            p.subjTemplate,
+		SignatureAlgorithm: p.signatureAlg,
+	}
+	tmpl.Subject.CommonName = cn
+	for _, opt := range opts {
+		opt(tmpl)
+	}
+	if err := checkSignatureAlgorithm(tmpl.SignatureAlgorithm, key.Public()); err != nil {
+		return nil, nil, err
+	}
+	der, err := x509.CreateCertificateRequest(p.random(), tmpl, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("can`t create csr: %w", err)
+	}
+	keyPEM, err := encodeKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	csrPEM := pem.EncodeToMemory(&pem.Block{Type: PEMCertificateRequestBlock, Bytes: der})
+	return csrPEM, pair.NewX509Pair(keyPEM, nil, cn, nil), nil
+}
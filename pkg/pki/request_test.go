@@ -0,0 +1,88 @@
+package pki
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPKI_NewRequest(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	csr, keyPair, err := pki.NewRequest("site", 2048, RequestDNSNames("site.example.com"),
+		RequestIPAddresses(net.ParseIP("10.0.0.1")))
+	assert.NoError(t, err)
+	assert.Equal(t, "site", keyPair.CN)
+	assert.Empty(t, keyPair.CertPemBytes)
+	req, err := ParseRequest(csr)
+	assert.NoError(t, err)
+	assert.Equal(t, "site", req.Subject.CommonName)
+	assert.Equal(t, []string{"site.example.com"}, req.DNSNames)
+	assert.True(t, net.ParseIP("10.0.0.1").Equal(req.IPAddresses[0]))
+	public, ok := req.PublicKey.(*rsa.PublicKey)
+	assert.True(t, ok)
+	assert.Equal(t, 2048, public.N.BitLen())
+	block, _ := pem.Decode(keyPair.KeyPemBytes)
+	assert.Equal(t, PEMRSAPrivateKeyBlock, block.Type)
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	assert.NoError(t, err)
+	assert.True(t, public.Equal(key.Public()))
+	_, _, err = pki.NewRequest("weak", 1024)
+	assert.Error(t, err)
+	assert.NoError(t, CheckName("site"))
+	assert.Error(t, CheckName("../site"))
+
+	_, err = pki.NewCa()
+	assert.NoError(t, err)
+	signed, err := pki.SignRequest(csr, ProfileServer)
+	assert.NoError(t, err)
+	cert, err := signed.DecodeCert()
+	assert.NoError(t, err)
+	assert.True(t, public.Equal(cert.PublicKey))
+
+	WithKeyAlgorithm(KeyECDSA)(pki)
+	csr, _, err = pki.NewRequest("", 384, RequestSubject(pkix.Name{CommonName: "other", OrganizationalUnit: []string{"ops"}}))
+	assert.NoError(t, err)
+	req, err = ParseRequest(csr)
+	assert.NoError(t, err)
+	assert.Equal(t, "other", req.Subject.CommonName)
+	assert.Equal(t, []string{"ops"}, req.Subject.OrganizationalUnit)
+	ecPublic, ok := req.PublicKey.(*ecdsa.PublicKey)
+	assert.True(t, ok)
+	assert.Equal(t, 384, ecPublic.Curve.Params().BitSize)
+
+	WithDefaultSignatureAlgorithm(x509.SHA256WithRSA)(pki)
+	_, _, err = pki.NewRequest("site", 0)
+	assert.Error(t, err)
+}
+
+func TestRequestOption_Certificate(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+	server, err := pki.NewServerCert("server", RequestDNSNames("server.example.com"),
+		RequestIPAddresses(net.ParseIP("10.0.0.1")), RequestEmailAddresses("ops@example.com"))
+	assert.NoError(t, err)
+	cert, err := server.DecodeCert()
+	assert.NoError(t, err)
+	assert.Equal(t, "server", cert.Subject.CommonName)
+	assert.Equal(t, []string{"server.example.com"}, cert.DNSNames)
+	assert.True(t, net.ParseIP("10.0.0.1").Equal(cert.IPAddresses[0]))
+	assert.Equal(t, []string{"ops@example.com"}, cert.EmailAddresses)
+	assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}, cert.ExtKeyUsage)
+
+	client, err := pki.Issue(Identity{CommonName: "client"}, Client(),
+		RequestSubject(pkix.Name{CommonName: "client", OrganizationalUnit: []string{"vpn"}}))
+	assert.NoError(t, err)
+	cert, err = client.DecodeCert()
+	assert.NoError(t, err)
+	assert.Equal(t, "client", cert.Subject.CommonName)
+	assert.Equal(t, []string{"vpn"}, cert.Subject.OrganizationalUnit)
+}
@@ -0,0 +1,117 @@
+package pki
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"time"
+)
+
+// RevocationReason is a CRL entry reason code from RFC 5280
+type RevocationReason int
+
+const (
+	ReasonUnspecified          RevocationReason = 0
+	ReasonKeyCompromise        RevocationReason = 1
+	ReasonCACompromise         RevocationReason = 2
+	ReasonAffiliationChanged   RevocationReason = 3
+	ReasonSuperseded           RevocationReason = 4
+	ReasonCessationOfOperation RevocationReason = 5
+	ReasonCertificateHold      RevocationReason = 6
+	ReasonRemoveFromCRL        RevocationReason = 8
+	ReasonPrivilegeWithdrawn   RevocationReason = 9
+	ReasonAACompromise         RevocationReason = 10
+)
+
+var (
+	oidExtensionReasonCode     = asn1.ObjectIdentifier{2, 5, 29, 21}
+	oidExtensionInvalidityDate = asn1.ObjectIdentifier{2, 5, 29, 24}
+)
+
+var reasonNames = map[RevocationReason]string{
+	ReasonUnspecified:          "unspecified",
+	ReasonKeyCompromise:        "keyCompromise",
+	ReasonCACompromise:         "CACompromise",
+	ReasonAffiliationChanged:   "affiliationChanged",
+	ReasonSuperseded:           "superseded",
+	ReasonCessationOfOperation: "cessationOfOperation",
+	ReasonCertificateHold:      "certificateHold",
+	ReasonRemoveFromCRL:        "removeFromCRL",
+	ReasonPrivilegeWithdrawn:   "privilegeWithdrawn",
+	ReasonAACompromise:         "AACompromise",
+}
+
+// String return openssl compatible reason name
+func (r RevocationReason) String() string {
+	if name, ok := reasonNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("reason(%d)", int(r))
+}
+
+// ParseRevocationReason parse openssl compatible reason name
+func ParseRevocationReason(name string) (RevocationReason, error) {
+	for reason, reasonName := range reasonNames {
+		if reasonName == name {
+			return reason, nil
+		}
+	}
+	return ReasonUnspecified, fmt.Errorf("unknown revocation reason %q", name)
+}
+
+// RevokeOption tune CRL entry of revoked certificate
+type RevokeOption func(*pkix.RevokedCertificate)
+
+// Reason add reason code extension into CRL entry. Unspecified reason is omitted as RFC 5280 recommends.
+func Reason(reason RevocationReason) RevokeOption {
+	return func(entry *pkix.RevokedCertificate) {
+		if reason == ReasonUnspecified {
+			return
+		}
+		val, _ := asn1.Marshal(asn1.Enumerated(reason))
+		entry.Extensions = append(entry.Extensions, pkix.Extension{Id: oidExtensionReasonCode, Value: val})
+	}
+}
+
+// InvalidityDate add invalidity date extension into CRL entry. It's the date on which it is known or suspected
+// that the private key was compromised.
+func InvalidityDate(date time.Time) RevokeOption {
+	return func(entry *pkix.RevokedCertificate) {
+		val, _ := asn1.MarshalWithParams(date.UTC(), "generalized")
+		entry.Extensions = append(entry.Extensions, pkix.Extension{Id: oidExtensionInvalidityDate, Value: val})
+	}
+}
+
+// KeyCompromised add keyCompromise reason with invalidity date into CRL entry
+func KeyCompromised(date time.Time) RevokeOption {
+	return func(entry *pkix.RevokedCertificate) {
+		Reason(ReasonKeyCompromise)(entry)
+		InvalidityDate(date)(entry)
+	}
+}
+
+// revocationReason return reason code of CRL entry, unspecified if there is no reason code extension
+func revocationReason(entry pkix.RevokedCertificate) RevocationReason {
+	for _, ext := range entry.Extensions {
+		if ext.Id.Equal(oidExtensionReasonCode) {
+			var reason asn1.Enumerated
+			if _, err := asn1.Unmarshal(ext.Value, &reason); err == nil {
+				return RevocationReason(reason)
+			}
+		}
+	}
+	return ReasonUnspecified
+}
+
+// invalidityDate return invalidity date of CRL entry if it's present
+func invalidityDate(entry pkix.RevokedCertificate) (time.Time, bool) {
+	for _, ext := range entry.Extensions {
+		if ext.Id.Equal(oidExtensionInvalidityDate) {
+			var date time.Time
+			if _, err := asn1.UnmarshalWithParams(ext.Value, &date, "generalized"); err == nil {
+				return date, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
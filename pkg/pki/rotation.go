@@ -0,0 +1,246 @@
+package pki
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/kemsta/go-easyrsa/pkg/pair"
+)
+
+// DefaultRotationOverlap is a period both CAs are trusted after RotateCA unless RotationOverlap is set
+const DefaultRotationOverlap = 30 * 24 * time.Hour
+
+// CrossName is a storage name of cross certificates of new default CAs signed by previous ones, see PKI.CrossName
+const CrossName = DefaultCAName + crossSuffix
+
+const crossSuffix = "-cross"
+
+// CrossName return storage name of cross certificates of PKI CA
+func (p *PKI) CrossName() string {
+	return p.CAName() + crossSuffix
+}
+
+// Rotation is a record of CA rotation kept in metadata of ca
+type Rotation struct {
+	Previous     *big.Int  `json:"previous"`        // serial of retiring CA
+	Current      *big.Int  `json:"current"`         // serial of new CA
+	Cross        *big.Int  `json:"cross,omitempty"` // serial of new CA cross certificate signed by previous one
+	OverlapUntil time.Time `json:"overlapUntil"`    // previous CA and cross certificate are trusted until then
+}
+
+// retires return true if CA certificate with serial is retired by rotation at now
+func (r Rotation) retires(serial *big.Int, now time.Time) bool {
+	if !now.After(r.OverlapUntil) {
+		return false
+	}
+	return serial.Cmp(r.Previous) == 0 || (r.Cross != nil && serial.Cmp(r.Cross) == 0)
+}
+
+// retired return true if CA certificate with serial is retired by one of rotations at now
+func retired(rotations []Rotation, serial *big.Int, now time.Time) bool {
+	for _, r := range rotations {
+		if r.retires(serial, now) {
+			return true
+		}
+	}
+	return false
+}
+
+// RotationOption tune RotateCA
+type RotationOption func(*rotation)
+
+type rotation struct {
+	overlap   time.Duration
+	crossSign bool
+	reissue   bool
+	caOptions []CertificateOption
+}
+
+// RotationOverlap trust previous CA for overlap after rotation instead of DefaultRotationOverlap
+func RotationOverlap(overlap time.Duration) RotationOption {
+	return func(r *rotation) {
+		r.overlap = overlap
+	}
+}
+
+// RotationCrossSign sign new CA with previous one as well and store it with PKI.CrossName,
+// so peers trusting only previous CA accept certificates of new one during overlap.
+// Previous CA should allow intermediates, see UnlimitedPathLen.
+func RotationCrossSign() RotationOption {
+	return func(r *rotation) {
+		r.crossSign = true
+	}
+}
+
+// RotationReissue re-sign valid leaf certificates with new CA, see ReissueAll
+func RotationReissue() RotationOption {
+	return func(r *rotation) {
+		r.reissue = true
+	}
+}
+
+// RotationCAOptions create new CA with options like NewCa
+func RotationCAOptions(opts ...CertificateOption) RotationOption {
+	return func(r *rotation) {
+		r.caOptions = append(r.caOptions, opts...)
+	}
+}
+
+// RotateCA replace the last CA with new one. New certificates and CRL are signed by new CA at once,
+// previous CA stays trusted by Verify, CertPool and trust bundle until overlap ends, so both CAs are accepted
+// while peers are updated. CRL is signed by previous CA as well until overlap ends, see WithPreviousCRLHolder.
+// Storage should be a MetadataStore to keep rotation records.
+func (p *PKI) RotateCA(opts ...RotationOption) (*pair.X509Pair, error) {
+	cfg := &rotation{overlap: DefaultRotationOverlap}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if _, ok := p.Storage.(MetadataStore); !ok {
+		return nil, fmt.Errorf("can`t rotate ca: %w", errNoMetadataStore)
+	}
+	previous, err := p.GetLastCA()
+	if err != nil {
+		return nil, fmt.Errorf("can`t get previous ca: %w", err)
+	}
+	if cfg.crossSign {
+		prevCert, err := previous.DecodeCert()
+		if err != nil {
+			return nil, fmt.Errorf("can`t decode previous ca: %w", err)
+		}
+		if prevCert.MaxPathLen == 0 {
+			return nil, errors.New("can`t cross sign new ca: previous ca path length forbids intermediates")
+		}
+	}
+	current, _, err := p.NewCaGeneration(cfg.caOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("can`t create new ca: %w", err)
+	}
+	record := Rotation{Previous: previous.Serial, Current: current.Serial, OverlapUntil: p.now().Add(cfg.overlap).UTC()}
+	if cfg.crossSign {
+		cross, err := p.crossSign(previous, current)
+		if err != nil {
+			return current, err
+		}
+		record.Cross = cross.Serial
+	}
+	meta, err := p.Metadata(p.CAName())
+	if err != nil {
+		return current, err
+	}
+	meta.Rotations = append(meta.Rotations, record)
+	if err := p.SetMetadata(p.CAName(), meta); err != nil {
+		return current, fmt.Errorf("can`t save rotation: %w", err)
+	}
+	if err := p.updateCRL(func(list []pkix.RevokedCertificate) ([]pkix.RevokedCertificate, error) {
+		return list, nil
+	}); err != nil {
+		return current, fmt.Errorf("can`t sign crl with new ca: %w", err)
+	}
+	if cfg.reissue {
+		if _, err := p.ReissueAll(current); err != nil {
+			return current, fmt.Errorf("can`t reissue leaves: %w", err)
+		}
+	}
+	return current, nil
+}
+
+// overlappingCA return previous CA of the last rotation until its overlap ends, nil if there is none
+func (p *PKI) overlappingCA() (*pair.X509Pair, error) {
+	rotations, err := p.Rotations()
+	if err != nil || len(rotations) == 0 {
+		return nil, err
+	}
+	last := rotations[len(rotations)-1]
+	if p.now().After(last.OverlapUntil) {
+		return nil, nil
+	}
+	previous, err := p.Storage.GetBySerial(last.Previous)
+	if err != nil {
+		return nil, fmt.Errorf("can`t get previous ca: %w", err)
+	}
+	return previous, nil
+}
+
+// updatePreviousCRL sign crl entries with previous CA during rotation overlap, see WithPreviousCRLHolder
+func (p *PKI) updatePreviousCRL(list []pkix.RevokedCertificate, number *big.Int) error {
+	if p.previousCRL == nil {
+		return nil
+	}
+	previous, err := p.overlappingCA()
+	if err != nil || previous == nil {
+		return err
+	}
+	crlPem, err := p.newCrl(previous, list, number)
+	if err != nil {
+		return fmt.Errorf("can`t sign crl with previous ca: %w", err)
+	}
+	if err := p.previousCRL.Put(crlPem); err != nil {
+		return fmt.Errorf("can`t put crl of previous ca: %w", err)
+	}
+	return nil
+}
+
+// GetPreviousCRL return crl signed by previous CA during rotation overlap, see WithPreviousCRLHolder.
+// It isn`t updated after overlap ends.
+func (p *PKI) GetPreviousCRL() (*pkix.CertificateList, error) {
+	if p.previousCRL == nil {
+		return nil, errors.New("can`t get crl of previous ca: no holder")
+	}
+	return p.previousCRL.Get()
+}
+
+// Rotations return records of CA rotations from the oldest one
+func (p *PKI) Rotations() ([]Rotation, error) {
+	meta, err := p.Metadata(p.CAName())
+	if errors.Is(err, errNoMetadataStore) {
+		return nil, nil
+	}
+	return meta.Rotations, err
+}
+
+// crossSign sign certificate of current CA with previous CA key. Cross certificate doesn`t outlive previous CA.
+func (p *PKI) crossSign(previous, current *pair.X509Pair) (*pair.X509Pair, error) {
+	signer, prevCert, release, err := p.caSigner(previous)
+	if err != nil {
+		return nil, fmt.Errorf("can`t parse previous ca pair: %w", err)
+	}
+	defer release()
+	cert, err := current.DecodeCert()
+	if err != nil {
+		return nil, err
+	}
+	serial, err := p.nextSerial()
+	if err != nil {
+		return nil, fmt.Errorf("can`t get next serial: %w", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               cert.Subject,
+		NotBefore:             cert.NotBefore,
+		NotAfter:              cert.NotAfter,
+		KeyUsage:              cert.KeyUsage,
+		ExtKeyUsage:           cert.ExtKeyUsage,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLen:            cert.MaxPathLen,
+		MaxPathLenZero:        cert.MaxPathLenZero,
+		SubjectKeyId:          cert.SubjectKeyId,
+	}
+	if tmpl.NotAfter.After(prevCert.NotAfter) {
+		tmpl.NotAfter = prevCert.NotAfter
+	}
+	der, err := x509.CreateCertificate(p.random(), tmpl, prevCert, cert.PublicKey, signer)
+	if err != nil {
+		return nil, fmt.Errorf("can`t cross sign ca: %w", err)
+	}
+	res := pair.NewX509Pair(nil, pem.EncodeToMemory(&pem.Block{Type: PEMCertificateBlock, Bytes: der}), CrossName, serial)
+	if err := p.Storage.Put(res); err != nil {
+		return nil, fmt.Errorf("can`t put cross certificate: %w", err)
+	}
+	return res, nil
+}
@@ -0,0 +1,136 @@
+package pki
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPKI_RotateCA(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	_, err := pki.RotateCA()
+	assert.Error(t, err, "there is no ca to rotate")
+	_, err = pki.NewCa()
+	assert.NoError(t, err)
+	_, err = pki.RotateCA(RotationCrossSign())
+	assert.Error(t, err, "previous ca doesn`t allow intermediates")
+	oldCA, err := pki.NewCa(UnlimitedPathLen())
+	assert.NoError(t, err)
+	oldLeaf, err := pki.NewCert("old", Client())
+	assert.NoError(t, err)
+	revokedLeaf, err := pki.NewCert("revoked", Client())
+	assert.NoError(t, err)
+
+	newCA, err := pki.RotateCA(RotationOverlap(time.Hour), RotationCrossSign(), RotationReissue())
+	assert.NoError(t, err)
+	rotations, err := pki.Rotations()
+	assert.NoError(t, err)
+	if assert.Len(t, rotations, 1) {
+		assert.Equal(t, oldCA.Serial, rotations[0].Previous)
+		assert.Equal(t, newCA.Serial, rotations[0].Current)
+		assert.NotNil(t, rotations[0].Cross)
+	}
+	newCACert, err := newCA.DecodeCert()
+	assert.NoError(t, err)
+	oldCACert, err := oldCA.DecodeCert()
+	assert.NoError(t, err)
+
+	newLeaf, err := pki.NewCert("new", Client())
+	assert.NoError(t, err)
+	newLeafCert, err := newLeaf.DecodeCert()
+	assert.NoError(t, err)
+	assert.NoError(t, newLeafCert.CheckSignatureFrom(newCACert))
+	reissued, err := pki.Storage.GetLastByCn("old")
+	assert.NoError(t, err)
+	assert.NotEqual(t, oldLeaf.Serial, reissued.Serial)
+
+	assert.NoError(t, pki.RevokeOne(revokedLeaf.Serial))
+	crl, err := pki.GetCRL()
+	assert.NoError(t, err)
+	assert.NoError(t, newCACert.CheckCRLSignature(crl), "crl is signed by new ca")
+	_, err = pki.GetPreviousCRL()
+	assert.Error(t, err, "no previous crl holder")
+
+	_, err = pki.Verify(oldLeaf.CertPemBytes)
+	assert.NoError(t, err, "previous ca is trusted during overlap")
+	_, err = pki.Verify(newLeaf.CertPemBytes)
+	assert.NoError(t, err)
+	_, err = pki.Verify(revokedLeaf.CertPemBytes)
+	assert.Error(t, err)
+
+	cross, err := pki.Storage.GetLastByCn(CrossName)
+	assert.NoError(t, err)
+	block, _ := pem.Decode(cross.CertPemBytes)
+	crossCert, err := x509.ParseCertificate(block.Bytes)
+	assert.NoError(t, err)
+	oldRoots, intermediates := x509.NewCertPool(), x509.NewCertPool()
+	oldRoots.AddCert(oldCACert)
+	intermediates.AddCert(crossCert)
+	_, err = newLeafCert.Verify(x509.VerifyOptions{Roots: oldRoots, Intermediates: intermediates,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}})
+	assert.NoError(t, err, "peers trusting previous ca accept new leaves with cross certificate")
+
+	WithClock(func() time.Time { return time.Now().Add(2 * time.Hour) })(pki)
+	_, err = pki.Verify(oldLeaf.CertPemBytes)
+	assert.Error(t, err, "previous ca is retired after overlap")
+	_, err = pki.Verify(reissued.CertPemBytes)
+	assert.NoError(t, err)
+	bundle, err := pki.GetTrustBundle()
+	assert.NoError(t, err)
+	certs, err := parseCertificates(bundle)
+	assert.NoError(t, err)
+	serials := map[string]bool{}
+	for _, cert := range certs {
+		serials[cert.SerialNumber.String()] = true
+	}
+	assert.True(t, serials[newCA.Serial.String()])
+	assert.False(t, serials[oldCA.Serial.String()])
+	assert.False(t, serials[cross.Serial.String()])
+
+	pki.Storage = NewChaosKeyStorage(pki.Storage, Faults{})
+	_, err = pki.RotateCA()
+	assert.ErrorIs(t, err, errNoMetadataStore)
+}
+
+func TestPKI_RotateCAPreviousCRL(t *testing.T) {
+	pki, err := InitPKI(t.TempDir(), nil)
+	assert.NoError(t, err)
+	oldCA, err := pki.NewCa()
+	assert.NoError(t, err)
+	leaf, err := pki.NewCert("leaf", Client())
+	assert.NoError(t, err)
+	newCA, err := pki.RotateCA(RotationOverlap(time.Hour))
+	assert.NoError(t, err)
+
+	assert.NoError(t, pki.RevokeOne(leaf.Serial))
+	oldCACert, err := oldCA.DecodeCert()
+	assert.NoError(t, err)
+	newCACert, err := newCA.DecodeCert()
+	assert.NoError(t, err)
+	crl, err := pki.GetCRL()
+	assert.NoError(t, err)
+	assert.NoError(t, newCACert.CheckCRLSignature(crl))
+	previous, err := pki.GetPreviousCRL()
+	assert.NoError(t, err)
+	assert.NoError(t, oldCACert.CheckCRLSignature(previous), "crl is signed by previous ca during overlap")
+	if assert.Len(t, previous.TBSCertList.RevokedCertificates, 1) {
+		assert.Equal(t, leaf.Serial, previous.TBSCertList.RevokedCertificates[0].SerialNumber)
+	}
+	dir := t.TempDir()
+	assert.NoError(t, pki.Publish(NewDirPublisher(dir)))
+	assert.FileExists(t, filepath.Join(dir, PublishPreviousCRL))
+
+	WithClock(func() time.Time { return time.Now().Add(2 * time.Hour) })(pki)
+	assert.NoError(t, pki.RefreshCRL())
+	stale, err := pki.GetPreviousCRL()
+	assert.NoError(t, err)
+	assert.Equal(t, previous.TBSCertList.ThisUpdate, stale.TBSCertList.ThisUpdate, "previous crl isn`t signed after overlap")
+	dir = t.TempDir()
+	assert.NoError(t, pki.Publish(NewDirPublisher(dir)))
+	assert.NoFileExists(t, filepath.Join(dir, PublishPreviousCRL))
+}
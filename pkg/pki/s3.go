@@ -0,0 +1,69 @@
+package pki
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/kemsta/go-easyrsa/internal/sigv4"
+)
+
+// S3Publisher is a Publisher putting files as objects into S3 compatible bucket. Requests are signed
+// with AWS signature version 4, objects are addressed path style: <Endpoint>/<Bucket>/<Prefix><name>.
+type S3Publisher struct {
+	Endpoint        string // e.g. http://minio:9000, https://s3.<Region>.amazonaws.com by default
+	Region          string // bucket region, us-east-1 by default
+	Bucket          string
+	Prefix          string // object key prefix, e.g. "pki/"
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string       // token of temporary credentials, optional
+	Client          *http.Client // http.DefaultClient by default
+}
+
+// Publish put object with name
+func (s *S3Publisher) Publish(name string, content []byte) error {
+	region := s.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	}
+	objectURL, err := url.Parse(strings.TrimSuffix(endpoint, "/"))
+	if err != nil {
+		return fmt.Errorf("bad s3 endpoint %q: %w", endpoint, err)
+	}
+	objectURL.Path += "/" + s.Bucket + "/" + s.Prefix + name
+	req, err := http.NewRequest(http.MethodPut, objectURL.String(), bytes.NewReader(content))
+	if err != nil {
+		return fmt.Errorf("can`t create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-pem-file")
+	sigv4.Sign(req, content, sigv4.Credentials{
+		AccessKeyID:     s.AccessKeyID,
+		SecretAccessKey: s.SecretAccessKey,
+		SessionToken:    s.SessionToken,
+	}, region, "s3", time.Now().UTC())
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("can`t put %v: %w", objectURL.Path, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("can`t put %v: %v: %s", objectURL.Path, resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
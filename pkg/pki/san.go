@@ -0,0 +1,80 @@
+package pki
+
+import (
+	"crypto/x509"
+	"net"
+	"strings"
+)
+
+// SANRule derive default subject alternative names from common name of issued certificate
+type SANRule func(cn string) (dnsNames []string, ips []net.IP)
+
+// DNSSuffix rule add "<cn>.<suffix>" dns name, e.g. DNSSuffix("internal.example.com").
+// Common names which aren`t hostnames, e.g. "John Doe" of client, get nothing.
+func DNSSuffix(suffix string) SANRule {
+	suffix = strings.Trim(suffix, ".")
+	return func(cn string) ([]string, []net.IP) {
+		if !isHostname(cn) {
+			return nil, nil
+		}
+		return []string{cn + "." + suffix}, nil
+	}
+}
+
+// applyDefaultSANs append names of PKI SAN rules to template with common name skipping already present ones.
+// Dns names of rules which aren`t valid hostnames are skipped too.
+func (p *PKI) applyDefaultSANs(template *x509.Certificate) {
+	cn := template.Subject.CommonName
+	if cn == "" {
+		return
+	}
+	for _, rule := range p.defaultSANs {
+		dnsNames, ips := rule(cn)
+		for _, name := range dnsNames {
+			if isHostname(name) && !containsString(template.DNSNames, name) {
+				template.DNSNames = append(template.DNSNames, name)
+			}
+		}
+		for _, ip := range ips {
+			if !containsIP(template.IPAddresses, ip) {
+				template.IPAddresses = append(template.IPAddresses, ip)
+			}
+		}
+	}
+}
+
+// isHostname return true if name is dns name of letters, digits and hyphens labels like RFC 1123 hostname
+func isHostname(name string) bool {
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsIP(list []net.IP, ip net.IP) bool {
+	for _, item := range list {
+		if item.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+package pki
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// SelfTestStep is a result of one step of SelfTest
+type SelfTestStep struct {
+	Name     string
+	Duration time.Duration
+	Err      error
+}
+
+// SelfTest run full lifecycle in pki created in empty dir with opts: build CA, issue server and client certs,
+// complete mutual TLS handshake between them in process, revoke client cert and make sure handshake is rejected then.
+// It`s a smoke test of build and platform, e.g. of crypto and file locking quirks. Steps are run until the first
+// failure, its error is returned as well.
+func SelfTest(ctx context.Context, dir string, opts ...PKIOption) ([]SelfTestStep, error) {
+	var pki *PKI
+	var server, client tls.Certificate
+	var clientSerial string
+	steps := []struct {
+		name string
+		run  func() error
+	}{
+		{name: "build ca", run: func() (err error) {
+			if pki, err = InitPKI(dir, nil, opts...); err != nil {
+				return err
+			}
+			_, err = pki.NewCaContext(ctx)
+			return err
+		}},
+		{name: "issue server cert", run: func() (err error) {
+			server, err = selfTestCert(ctx, pki, "localhost", Server(), DNSNames([]string{"localhost"}),
+				IPAddresses([]net.IP{net.IPv4(127, 0, 0, 1)}))
+			return err
+		}},
+		{name: "issue client cert", run: func() (err error) {
+			client, err = selfTestCert(ctx, pki, "client", Client())
+			if err == nil {
+				clientSerial = client.Leaf.SerialNumber.String()
+			}
+			return err
+		}},
+		{name: "tls handshake", run: func() error {
+			return selfTestHandshake(ctx, pki, server, client)
+		}},
+		{name: "revoke client cert", run: func() error {
+			return pki.RevokeOne(client.Leaf.SerialNumber)
+		}},
+		{name: "reject revoked client", run: func() error {
+			err := selfTestHandshake(ctx, pki, server, client)
+			if err == nil {
+				return fmt.Errorf("client cert with serial %v is accepted after revocation", clientSerial)
+			}
+			if !errors.Is(err, errSelfTestRejected) {
+				return fmt.Errorf("handshake failed for other reason: %w", err)
+			}
+			return nil
+		}},
+	}
+	res := make([]SelfTestStep, 0, len(steps))
+	for _, step := range steps {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		start := time.Now()
+		err := step.run()
+		res = append(res, SelfTestStep{Name: step.name, Duration: time.Since(start), Err: err})
+		if err != nil {
+			return res, fmt.Errorf("%v: %w", step.name, err)
+		}
+	}
+	return res, nil
+}
+
+// errSelfTestRejected is returned by server side of self test handshake when PKI rejects client certificate
+var errSelfTestRejected = errors.New("client certificate is rejected")
+
+// selfTestCert issue certificate with opts and return it as tls certificate
+func selfTestCert(ctx context.Context, pki *PKI, cn string, opts ...CertificateOption) (tls.Certificate, error) {
+	res, err := pki.NewCertContext(ctx, cn, opts...)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	cert, err := tls.X509KeyPair(res.CertPemBytes, res.KeyPemBytes)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("can`t load %v pair: %w", cn, err)
+	}
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return tls.Certificate{}, err
+	}
+	return cert, nil
+}
+
+// selfTestHandshake complete mutual TLS handshake over loopback connection. Server verifies client certificate
+// with PKI.Verify, so revocation is checked as well.
+func selfTestHandshake(ctx context.Context, pki *PKI, server, client tls.Certificate) error {
+	pool, err := pki.CertPool()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("can`t listen on loopback: %w", err)
+	}
+	defer listener.Close()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverConn, err := listener.Accept()
+		if err != nil {
+			serverErr <- err
+			return
+		}
+		defer serverConn.Close()
+		conn := tls.Server(serverConn, &tls.Config{
+			Certificates: []tls.Certificate{server},
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			ClientCAs:    pool,
+			MinVersion:   tls.VersionTLS12,
+			VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+				if _, err := pki.Verify(pem.EncodeToMemory(&pem.Block{Type: PEMCertificateBlock, Bytes: rawCerts[0]})); err != nil {
+					return fmt.Errorf("%w: %v", errSelfTestRejected, err)
+				}
+				return nil
+			},
+		})
+		serverErr <- conn.HandshakeContext(ctx)
+	}()
+	clientConn, err := (&net.Dialer{}).DialContext(ctx, "tcp", listener.Addr().String())
+	if err != nil {
+		return fmt.Errorf("can`t connect to loopback: %w", err)
+	}
+	defer clientConn.Close()
+	clientErr := tls.Client(clientConn, &tls.Config{
+		Certificates: []tls.Certificate{client},
+		RootCAs:      pool,
+		ServerName:   "localhost",
+		MinVersion:   tls.VersionTLS12,
+	}).HandshakeContext(ctx)
+	if err := <-serverErr; err != nil {
+		return fmt.Errorf("server handshake: %w", err)
+	}
+	if clientErr != nil {
+		return fmt.Errorf("client handshake: %w", clientErr)
+	}
+	return nil
+}
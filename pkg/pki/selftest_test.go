@@ -0,0 +1,31 @@
+package pki
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelfTest(t *testing.T) {
+	for _, algo := range []KeyAlgorithm{KeyRSA, KeyECDSA} {
+		t.Run(string(algo), func(t *testing.T) {
+			steps, err := SelfTest(context.Background(), filepath.Join(t.TempDir(), "pki"), WithKeyAlgorithm(algo))
+			assert.NoError(t, err)
+			names := make([]string, 0, len(steps))
+			for _, step := range steps {
+				assert.NoError(t, step.Err, step.Name)
+				names = append(names, step.Name)
+			}
+			assert.Equal(t, []string{"build ca", "issue server cert", "issue client cert", "tls handshake",
+				"revoke client cert", "reject revoked client"}, names)
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	steps, err := SelfTest(ctx, filepath.Join(t.TempDir(), "pki"))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, steps)
+}
@@ -0,0 +1,44 @@
+package pki
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+)
+
+// signatureAlgorithms are algorithms allowed for SignatureAlgorithm option by CA key type.
+// Algorithms with MD5 and SHA1 digests aren`t allowed.
+var signatureAlgorithms = map[x509.PublicKeyAlgorithm][]x509.SignatureAlgorithm{
+	x509.RSA: {
+		x509.SHA256WithRSA, x509.SHA384WithRSA, x509.SHA512WithRSA,
+		x509.SHA256WithRSAPSS, x509.SHA384WithRSAPSS, x509.SHA512WithRSAPSS,
+	},
+	x509.ECDSA:   {x509.ECDSAWithSHA256, x509.ECDSAWithSHA384, x509.ECDSAWithSHA512},
+	x509.Ed25519: {x509.PureEd25519},
+}
+
+// checkSignatureAlgorithm return error if algorithm can`t be used with CA public key.
+// Unknown algorithm means the default one of key type and is always allowed.
+func checkSignatureAlgorithm(algorithm x509.SignatureAlgorithm, public crypto.PublicKey) error {
+	if algorithm == x509.UnknownSignatureAlgorithm {
+		return nil
+	}
+	keyAlgorithm := x509.UnknownPublicKeyAlgorithm
+	switch public.(type) {
+	case *rsa.PublicKey:
+		keyAlgorithm = x509.RSA
+	case *ecdsa.PublicKey:
+		keyAlgorithm = x509.ECDSA
+	case ed25519.PublicKey:
+		keyAlgorithm = x509.Ed25519
+	}
+	for _, allowed := range signatureAlgorithms[keyAlgorithm] {
+		if algorithm == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("signature algorithm %v can`t be used with %v ca key", algorithm, keyAlgorithm)
+}
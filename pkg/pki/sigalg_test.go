@@ -0,0 +1,37 @@
+package pki
+
+import (
+	"crypto/x509"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignatureAlgorithm(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	ca, err := pki.NewCa(SignatureAlgorithm(x509.SHA512WithRSAPSS))
+	assert.NoError(t, err)
+	caCert, err := ca.DecodeCert()
+	assert.NoError(t, err)
+	assert.Equal(t, x509.SHA512WithRSAPSS, caCert.SignatureAlgorithm)
+
+	user, err := pki.NewCert("user", Client(), SignatureAlgorithm(x509.SHA384WithRSA))
+	assert.NoError(t, err)
+	cert, err := user.DecodeCert()
+	assert.NoError(t, err)
+	assert.Equal(t, x509.SHA384WithRSA, cert.SignatureAlgorithm)
+	assert.NoError(t, cert.CheckSignatureFrom(caCert))
+
+	_, err = pki.NewCert("ec", Client(), SignatureAlgorithm(x509.ECDSAWithSHA256))
+	assert.Error(t, err)
+	_, err = pki.NewCert("sha1", Client(), SignatureAlgorithm(x509.SHA1WithRSA))
+	assert.Error(t, err)
+	_, err = pki.Preview("ec", SignatureAlgorithm(x509.PureEd25519))
+	assert.Error(t, err)
+	_, err = pki.NewCa(SignatureAlgorithm(x509.ECDSAWithSHA384))
+	assert.Error(t, err)
+	cas, err := pki.Storage.GetByCN("ca")
+	assert.NoError(t, err)
+	assert.Len(t, cas, 1)
+}
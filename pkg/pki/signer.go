@@ -0,0 +1,109 @@
+package pki
+
+import (
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/kemsta/go-easyrsa/pkg/pair"
+)
+
+// ErrSignerMismatch is returned when CA certificate doesn`t match public key of signer from WithSigner
+var ErrSignerMismatch = errors.New("signer key doesn`t match ca certificate")
+
+// caSigner return signer and certificate of CA pair. It`s the signer from WithSigner if it`s set or
+// the decoded CA key otherwise. release wipes decoded key and must be called after signing.
+func (p *PKI) caSigner(caPair *pair.X509Pair) (signer crypto.Signer, cert *x509.Certificate, release func(), err error) {
+	if p.signer == nil {
+		keyPEM, encrypted, err := p.decryptKeyPEM(caPair.KeyPemBytes)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if encrypted {
+			defer pair.Wipe(keyPEM)
+		}
+		key, cert, err := pair.NewX509Pair(keyPEM, caPair.CertPemBytes, caPair.CN, caPair.Serial).DecodeSigner()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return key, cert, func() { pair.WipeKey(key) }, nil
+	}
+	cert, err = caPair.DecodeCert()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	public, ok := cert.PublicKey.(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !public.Equal(p.signer.Public()) {
+		return nil, nil, nil, fmt.Errorf("%v with serial %v: %w", caPair.CN, caPair.Serial, ErrSignerMismatch)
+	}
+	return p.signer, cert, func() {}, nil
+}
+
+// crlValidityPeriod return period between this and next update of CRL set by WithCRLValidity,
+// DefaultExpireYears by default
+func (p *PKI) crlValidityPeriod() time.Duration {
+	if p.crlValidity > 0 {
+		return p.crlValidity
+	}
+	return DefaultExpireYears * 365 * 24 * time.Hour
+}
+
+// newCrl return pem CRL with list signed by CA pair
+func (p *PKI) newCrl(caPair *pair.X509Pair, list []pkix.RevokedCertificate, number *big.Int) ([]byte, error) {
+	signer, caCert, release, err := p.caSigner(caPair)
+	if err != nil {
+		return nil, fmt.Errorf("can`t decode ca certs for signing crl: %w", err)
+	}
+	defer release()
+	now := p.now()
+	var crlBytes []byte
+	if caCert.KeyUsage&x509.KeyUsageCRLSign != 0 && len(caCert.SubjectKeyId) > 0 {
+		crlBytes, err = x509.CreateRevocationList(p.random(), &x509.RevocationList{
+			RevokedCertificates: removeDups(list),
+			Number:              number,
+			ThisUpdate:          now,
+			NextUpdate:          now.Add(p.crlValidityPeriod()),
+		}, caCert, signer)
+	} else {
+		// imported CA without crl sign usage or key id can`t sign crl with number, keep v1 crl for it
+		crlBytes, err = caCert.CreateCRL(p.random(), signer, removeDups(list), now, now.Add(p.crlValidityPeriod()))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can`t create crl: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  PEMx509CRLBlock,
+		Bytes: crlBytes,
+	}), nil
+}
+
+// nextCRLNumber return number of the next crl. It`s taken from counter set by WithCRLNumber, which is moved
+// past number of old crl first, so numbers keep growing after restore or sync. Without counter it`s number of
+// old crl plus one.
+func (p *PKI) nextCRLNumber(old *pkix.CertificateList) (*big.Int, error) {
+	var last *big.Int
+	if old != nil {
+		last = crlNumber(old)
+	}
+	if p.crlNumber == nil {
+		if last == nil {
+			return big.NewInt(1), nil
+		}
+		return new(big.Int).Add(last, big.NewInt(1)), nil
+	}
+	if advancer, ok := p.crlNumber.(SerialAdvancer); ok && last != nil {
+		if err := advancer.AdvanceTo(last); err != nil {
+			return nil, fmt.Errorf("can`t advance crl number: %w", err)
+		}
+	}
+	number, err := p.crlNumber.Next()
+	if err != nil {
+		return nil, fmt.Errorf("can`t get crl number: %w", err)
+	}
+	return number, nil
+}
@@ -0,0 +1,44 @@
+package pki
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithSigner(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	signer, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	WithSigner(signer)(pki)
+
+	ca, err := pki.NewCa()
+	assert.NoError(t, err)
+	assert.Empty(t, ca.KeyPemBytes)
+	caCert, err := ca.DecodeCert()
+	assert.NoError(t, err)
+	assert.True(t, signer.PublicKey.Equal(caCert.PublicKey))
+
+	client, err := pki.NewCert("client")
+	assert.NoError(t, err)
+	clientCert, err := client.DecodeCert()
+	assert.NoError(t, err)
+	assert.NoError(t, clientCert.CheckSignatureFrom(caCert))
+
+	assert.NoError(t, pki.RevokeOne(client.Serial))
+	crl, err := pki.GetCRL()
+	assert.NoError(t, err)
+	assert.NoError(t, caCert.CheckCRLSignature(crl))
+	assert.True(t, pki.IsRevoked(client.Serial))
+
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	WithSigner(other)(pki)
+	_, err = pki.NewCert("server")
+	assert.True(t, errors.Is(err, ErrSignerMismatch))
+}
@@ -0,0 +1,143 @@
+package pki
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	snapshotManifestName = "manifest.json"
+	snapshotObjectsDir   = "objects"
+	snapshotTimeFormat   = "20060102T150405Z"
+)
+
+// SnapshotFile is a file of snapshot. Content is stored in objects dir under its sha256 hash.
+type SnapshotFile struct {
+	Name   string `json:"name"`   // logical name, e.g. pairs/server/2.crt, crl.pem, index.txt or counters.json
+	SHA256 string `json:"sha256"` // hex encoded sha256 of content
+	Size   int    `json:"size"`   // content size
+}
+
+// SnapshotManifest describe snapshot content
+type SnapshotManifest struct {
+	Created time.Time      `json:"created"`
+	Files   []SnapshotFile `json:"files"`
+}
+
+// Snapshot write immutable hash-addressed export of all pairs, crl, index and counters into new dir inside dir.
+// Snapshot dir name contains creation time and manifest hash, so snapshot can be verified with VerifySnapshot.
+// Private keys are included, treat snapshots as sensitive as the pki itself.
+func (p *PKI) Snapshot(dir string) (string, error) {
+	files, err := p.backupFiles()
+	if err != nil {
+		return "", err
+	}
+	return writeSnapshot(dir, time.Now().UTC(), files)
+}
+
+// backupFiles return content of all pairs, crl, index and counters by logical name like pairs/server/2.crt
+func (p *PKI) backupFiles() (map[string][]byte, error) {
+	files := map[string][]byte{}
+	pairs, err := p.Storage.GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("can`t get pairs: %w", err)
+	}
+	for _, certPair := range pairs {
+		base := fmt.Sprintf("pairs/%s/%s", certPair.CN, certPair.Serial.Text(16))
+		files[base+".crt"] = certPair.CertPemBytes
+		files[base+".key"] = certPair.KeyPemBytes
+	}
+	crl, err := p.crlPEM()
+	if err != nil {
+		return nil, err
+	}
+	if crl != nil {
+		files["crl.pem"] = crl
+	}
+	index, err := p.Index()
+	if err != nil {
+		return nil, fmt.Errorf("can`t build index: %w", err)
+	}
+	var indexBuf bytes.Buffer
+	if err := index.Encode(&indexBuf); err != nil {
+		return nil, err
+	}
+	files["index.txt"] = indexBuf.Bytes()
+	counters, err := p.Counters()
+	if err != nil {
+		return nil, err
+	}
+	if files["counters.json"], err = json.Marshal(counters); err != nil {
+		return nil, fmt.Errorf("can`t marshal counters: %w", err)
+	}
+	return files, nil
+}
+
+func writeSnapshot(dir string, created time.Time, files map[string][]byte) (string, error) {
+	manifest := SnapshotManifest{Created: created.Truncate(time.Second), Files: make([]SnapshotFile, 0, len(files))}
+	for name, content := range files {
+		sum := sha256.Sum256(content)
+		manifest.Files = append(manifest.Files, SnapshotFile{Name: name, SHA256: hex.EncodeToString(sum[:]), Size: len(content)})
+	}
+	sort.Slice(manifest.Files, func(i, j int) bool {
+		return manifest.Files[i].Name < manifest.Files[j].Name
+	})
+	manifestBytes, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("can`t marshal manifest: %w", err)
+	}
+	manifestSum := sha256.Sum256(manifestBytes)
+	snapshotDir := filepath.Join(dir, fmt.Sprintf("%s-%s", created.Format(snapshotTimeFormat), hex.EncodeToString(manifestSum[:])))
+	if err := os.MkdirAll(filepath.Join(snapshotDir, snapshotObjectsDir), 0750); err != nil {
+		return "", fmt.Errorf("can`t create snapshot dir %v: %w", snapshotDir, err)
+	}
+	for _, file := range manifest.Files {
+		objectPath := filepath.Join(snapshotDir, snapshotObjectsDir, file.SHA256)
+		if _, err := os.Stat(objectPath); err == nil {
+			continue // the same content was already written
+		}
+		if err := ioutil.WriteFile(objectPath, files[file.Name], 0444); err != nil {
+			return "", fmt.Errorf("can`t write snapshot object %v: %w", objectPath, err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(snapshotDir, snapshotManifestName), manifestBytes, 0444); err != nil {
+		return "", fmt.Errorf("can`t write snapshot manifest: %w", err)
+	}
+	return snapshotDir, nil
+}
+
+// VerifySnapshot check that snapshot manifest matches snapshot dir name and every object matches its hash
+func VerifySnapshot(snapshotDir string) (*SnapshotManifest, error) {
+	manifestBytes, err := ioutil.ReadFile(filepath.Join(snapshotDir, snapshotManifestName))
+	if err != nil {
+		return nil, fmt.Errorf("can`t read snapshot manifest: %w", err)
+	}
+	manifestSum := sha256.Sum256(manifestBytes)
+	if !strings.HasSuffix(filepath.Base(filepath.Clean(snapshotDir)), "-"+hex.EncodeToString(manifestSum[:])) {
+		return nil, fmt.Errorf("manifest hash %x doesn`t match snapshot %v", manifestSum, snapshotDir)
+	}
+	manifest := &SnapshotManifest{}
+	if err := json.Unmarshal(manifestBytes, manifest); err != nil {
+		return nil, fmt.Errorf("can`t parse snapshot manifest: %w", err)
+	}
+	for _, file := range manifest.Files {
+		content, err := ioutil.ReadFile(filepath.Join(snapshotDir, snapshotObjectsDir, file.SHA256))
+		if err != nil {
+			return nil, fmt.Errorf("can`t read %v: %w", file.Name, err)
+		}
+		sum := sha256.Sum256(content)
+		if hex.EncodeToString(sum[:]) != file.SHA256 || len(content) != file.Size {
+			return nil, fmt.Errorf("%v content doesn`t match manifest", file.Name)
+		}
+	}
+	return manifest, nil
+}
@@ -0,0 +1,49 @@
+package pki
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPKI_Snapshot(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	_, _ = pki.NewCa()
+	server, _ := pki.NewCert("server", Server())
+	_ = pki.RevokeOne(server.Serial)
+	dir := t.TempDir()
+
+	snapshotDir, err := pki.Snapshot(dir)
+	assert.NoError(t, err)
+	t.Run("verify", func(t *testing.T) {
+		manifest, err := VerifySnapshot(snapshotDir)
+		assert.NoError(t, err)
+		names := make([]string, 0)
+		for _, file := range manifest.Files {
+			names = append(names, file.Name)
+		}
+		assert.Equal(t, []string{"counters.json", "crl.pem", "index.txt", "pairs/ca/1.crt", "pairs/ca/1.key", "pairs/server/2.crt", "pairs/server/2.key"}, names)
+	})
+	t.Run("tampered object", func(t *testing.T) {
+		manifest, _ := VerifySnapshot(snapshotDir)
+		objectPath := filepath.Join(snapshotDir, "objects", manifest.Files[0].SHA256)
+		_ = os.Chmod(objectPath, 0644)
+		assert.NoError(t, ioutil.WriteFile(objectPath, []byte("tampered"), 0644))
+		_, err := VerifySnapshot(snapshotDir)
+		assert.Error(t, err)
+	})
+	t.Run("tampered manifest", func(t *testing.T) {
+		manifestPath := filepath.Join(snapshotDir, "manifest.json")
+		_ = os.Chmod(manifestPath, 0644)
+		assert.NoError(t, ioutil.WriteFile(manifestPath, []byte("{}"), 0644))
+		_, err := VerifySnapshot(snapshotDir)
+		assert.Error(t, err)
+	})
+	_ = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		return os.Chmod(path, 0755)
+	})
+}
@@ -0,0 +1,67 @@
+package pki
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/kemsta/go-easyrsa/pkg/pair"
+)
+
+// ChainStapling is a way to keep issuing chain together with leaf certificates, see WithChainStapling
+type ChainStapling string
+
+const (
+	StapleNone      ChainStapling = ""          // keep leaf certificate only
+	StapleCert      ChainStapling = "cert"      // keep leaf certificate followed by its chain in certificate file
+	StapleFullChain ChainStapling = "fullchain" // keep leaf followed by its chain as separate full chain next to pair
+)
+
+// ParseChainStapling parse stapling mode from flag value, empty value and "none" mean StapleNone
+func ParseChainStapling(value string) (ChainStapling, error) {
+	switch mode := ChainStapling(value); mode {
+	case StapleNone, StapleCert, StapleFullChain:
+		return mode, nil
+	case "none":
+		return StapleNone, nil
+	default:
+		return StapleNone, fmt.Errorf("unknown chain stapling %q, expected none, cert or fullchain", value)
+	}
+}
+
+// ErrNoFullChain is returned when StapleFullChain is set but storage isn`t a FullChainPutter
+var ErrNoFullChain = errors.New("storage can`t keep full chains")
+
+// putLeaf put leaf pair signed by caCert into storage and staple its chain according to WithChainStapling
+func (p *PKI) putLeaf(res *pair.X509Pair, caCert *x509.Certificate) error {
+	if p.chainStapling == StapleNone {
+		return p.Storage.Put(res)
+	}
+	putter, ok := p.Storage.(FullChainPutter)
+	if p.chainStapling == StapleFullChain && !ok {
+		return ErrNoFullChain
+	}
+	chain, err := p.chainOf(caCert)
+	if err != nil {
+		return err
+	}
+	fullChain := bytes.NewBuffer(append([]byte{}, res.CertPemBytes...))
+	for _, cert := range chain {
+		if err := pem.Encode(fullChain, &pem.Block{Type: PEMCertificateBlock, Bytes: cert.Raw}); err != nil {
+			return fmt.Errorf("can`t encode chain of %v: %w", res.CN, err)
+		}
+	}
+	if p.chainStapling == StapleCert {
+		res.CertPemBytes = fullChain.Bytes()
+		return p.Storage.Put(res)
+	}
+	if err := p.Storage.Put(res); err != nil {
+		return err
+	}
+	if err := putter.PutFullChain(res, fullChain.Bytes()); err != nil {
+		return fmt.Errorf("can`t put full chain of %v: %w", res.CN, err)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package pki
+
+import (
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithChainStapling(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	ca, err := pki.NewCa()
+	assert.NoError(t, err)
+	caCert, err := ca.DecodeCert()
+	assert.NoError(t, err)
+
+	WithChainStapling(StapleCert)(pki)
+	stapled, err := pki.NewCert("www", Server())
+	assert.NoError(t, err)
+	stored, err := pki.Storage.GetLastByCn("www")
+	assert.NoError(t, err)
+	assert.Equal(t, stapled.CertPemBytes, stored.CertPemBytes)
+	leaf, rest := pem.Decode(stored.CertPemBytes)
+	cert, err := stored.DecodeCert()
+	assert.NoError(t, err)
+	assert.Equal(t, cert.Raw, leaf.Bytes)
+	issuer, rest := pem.Decode(rest)
+	assert.Equal(t, caCert.Raw, issuer.Bytes)
+	assert.Empty(t, rest)
+
+	WithChainStapling(StapleFullChain)(pki)
+	separate, err := pki.NewCert("api", Server())
+	assert.NoError(t, err)
+	_, rest = pem.Decode(separate.CertPemBytes)
+	assert.Empty(t, rest, "cert file keeps leaf only")
+	fullChain, err := os.ReadFile(filepath.Join(testData, "api", separate.Serial.Text(16)+".fullchain.crt"))
+	assert.NoError(t, err)
+	assert.Equal(t, append(append([]byte{}, separate.CertPemBytes...),
+		pem.EncodeToMemory(&pem.Block{Type: PEMCertificateBlock, Bytes: caCert.Raw})...), fullChain)
+
+	WithChainStapling(StapleNone)(pki)
+	plain, err := pki.NewCert("mail", Server())
+	assert.NoError(t, err)
+	_, rest = pem.Decode(plain.CertPemBytes)
+	assert.Empty(t, rest)
+	assert.NoFileExists(t, filepath.Join(testData, "mail", plain.Serial.Text(16)+".fullchain.crt"))
+}
+
+func TestParseChainStapling(t *testing.T) {
+	for value, want := range map[string]ChainStapling{"": StapleNone, "none": StapleNone, "cert": StapleCert, "fullchain": StapleFullChain} {
+		got, err := ParseChainStapling(value)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	_, err := ParseChainStapling("bundle")
+	assert.Error(t, err)
+}
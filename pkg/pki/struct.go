@@ -17,13 +17,117 @@ type KeyStorage interface {
 	GetAll() ([]*pair.X509Pair, error)                   // Get all keypair
 }
 
+// CertReader is an optional KeyStorage interface for reading certificates without touching private keys,
+// e.g. by verifiers and reports running under account which can`t read keys. Pairs are returned without keys.
+type CertReader interface {
+	GetCertOnly(cn string) (*pair.X509Pair, error)               // Get last certificate by CN.
+	GetCertOnlyBySerial(serial *big.Int) (*pair.X509Pair, error) // Get one certificate by serial.
+	GetAllCertOnly() ([]*pair.X509Pair, error)                   // Get all certificates.
+}
+
+// PatternReader is an optional KeyStorage interface for reading groups of pairs without scanning all of them
+type PatternReader interface {
+	GetByCNPattern(pattern string) ([]*pair.X509Pair, error) // Get all keypairs with CN matching path.Match glob.
+}
+
+// FullChainPutter is an optional KeyStorage interface for keeping certificate followed by its issuers next to pair,
+// see WithChainStapling
+type FullChainPutter interface {
+	PutFullChain(pair *pair.X509Pair, content []byte) error // Put full chain of pair. Overwrite if already exist.
+}
+
+// CSRStore is an optional KeyStorage interface for keeping requests of pairs signed by SignRequest next to them,
+// so Renew signs the original request again
+type CSRStore interface {
+	PutCSR(pair *pair.X509Pair, csr []byte) error // Put pem request of pair. Overwrite if already exist.
+	GetCSR(serial *big.Int) ([]byte, error)       // Get request of pair with serial, empty if there is no one
+}
+
+// FingerprintIndex is an optional KeyStorage interface for finding pairs by certificate fingerprint without scanning
+// all of them
+type FingerprintIndex interface {
+	GetByFingerprint(fingerprint string) (*pair.X509Pair, error) // Get one keypair by hex fingerprint of DER certificate.
+	RebuildFingerprints() error                                  // Rebuild index from all pairs.
+}
+
+// PendingKeyStore is an optional KeyStorage interface for keeping keys whose certificates are issued elsewhere
+// until they are imported, see NewIntermediateRequest
+type PendingKeyStore interface {
+	PutPendingKey(name string, keyPEM []byte) error // Put pem key of pairs with name. Overwrite if already exist.
+	GetPendingKey(name string) ([]byte, error)      // Get pem key of pairs with name, empty if there is no one
+	DeletePendingKey(name string) error             // Delete pem key of pairs with name
+}
+
+// Locker is an optional KeyStorage interface for exclusive operations across processes
+type Locker interface {
+	Lock(name string) (unlock func() error, err error) // Lock operation with name until unlock is called, not reentrant
+}
+
 // Serial provider interface
 type SerialProvider interface {
 	Next() (*big.Int, error) // Next return next uniq serial
 }
 
+// SerialAdvancer is an optional SerialProvider interface for adopting serials issued elsewhere
+type SerialAdvancer interface {
+	AdvanceTo(serial *big.Int) error // AdvanceTo make next serials greater than serial
+}
+
+// SerialReader is an optional SerialProvider interface for exporting counter state
+type SerialReader interface {
+	Last() (*big.Int, error) // Last return the last issued serial, zero if nothing was issued yet
+}
+
 // Certificate revocation list holder interface
 type CRLHolder interface {
 	Put([]byte) error                    // Put file content for crl
 	Get() (*pkix.CertificateList, error) // Get current revoked cert list
 }
+
+// Index holder interface. It's a destination for openssl compatible index which is regenerated after every change.
+type IndexHolder interface {
+	Put([]byte) error // Put index file content
+}
+
+// Journal interface keeps write-ahead intents of changes touching several holders
+type Journal interface {
+	Begin(intent []byte) (id string, err error) // Begin persist intent before change
+	Commit(id string) error                     // Commit drop intent when change is completed
+	Pending() (map[string][]byte, error)        // Pending return not committed intents by id
+}
+
+// TrustStore interface keeps certificates of trusted CAs without keys, e.g. partner CAs. They are never used for issuing.
+type TrustStore interface {
+	Put(name string, content []byte) error // Put pem certificate with name. Overwrite if already exist.
+	Delete(name string) error              // Delete certificate with name
+	GetAll() ([][]byte, error)             // Get all pem certificates
+}
+
+// TokenStore interface keeps single-use enrollment token records by id
+type TokenStore interface {
+	Put(id string, content []byte) error // Put token record with id. Overwrite if already exist.
+	Take(id string) ([]byte, error)      // Take remove record with id and return its content, atomically
+}
+
+// RequestStore interface keeps records of CSRs waiting for approval by id
+type RequestStore interface {
+	Put(id string, content []byte) error // Put request record with id. Overwrite if already exist.
+	Take(id string) ([]byte, error)      // Take remove record with id and return its content, atomically
+	GetAll() (map[string][]byte, error)  // Get all records by id
+}
+
+// AuditLog interface is an append-only destination of audit records
+type AuditLog interface {
+	Append(record []byte) error // Append encoded record
+}
+
+// MetadataStore is an optional KeyStorage interface for sidecar metadata of pairs with name
+type MetadataStore interface {
+	PutMetadata(name string, content []byte) error // Put metadata content. Overwrite if already exist.
+	GetMetadata(name string) ([]byte, error)       // Get metadata content, empty if there is no one
+}
+
+// Publisher interface is a destination of public PKI files like ca.crt and crl.pem served to clients
+type Publisher interface {
+	Publish(name string, content []byte) error // Publish file content with name. Overwrite if already exist.
+}
@@ -0,0 +1,100 @@
+package pki
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+
+	"github.com/kemsta/go-easyrsa/pkg/pair"
+)
+
+// SyncResult describe changes made by Sync
+type SyncResult struct {
+	Added   []*big.Int // serials of pairs missing in dst
+	Updated []*big.Int // serials of pairs with different content in dst
+	CRL     bool       // CRL of dst was replaced
+}
+
+// Changed return true if dst was changed
+func (r SyncResult) Changed() bool {
+	return len(r.Added) != 0 || len(r.Updated) != 0 || r.CRL
+}
+
+// Sync replicate src into dst, e.g. to standby location. Pairs are compared by serial and fingerprint of their
+// content, only new and changed ones are copied. CRL is copied if it differs. Pairs missing in src are kept in dst.
+// Serial provider of dst is advanced past the last copied serial if it supports SerialAdvancer.
+func Sync(src, dst *PKI) (SyncResult, error) {
+	res := SyncResult{}
+	srcPairs, err := src.Storage.GetAll()
+	if err != nil {
+		return res, fmt.Errorf("can`t get source pairs: %w", err)
+	}
+	dstPairs, err := dst.Storage.GetAll()
+	if err != nil {
+		return res, fmt.Errorf("can`t get destination pairs: %w", err)
+	}
+	fingerprints := make(map[string][32]byte, len(dstPairs))
+	for _, dstPair := range dstPairs {
+		fingerprints[dstPair.Serial.String()] = pairFingerprint(dstPair)
+	}
+	var last *big.Int
+	for _, srcPair := range srcPairs {
+		fingerprint, exists := fingerprints[srcPair.Serial.String()]
+		if exists && fingerprint == pairFingerprint(srcPair) {
+			continue
+		}
+		if err := dst.Storage.Put(srcPair); err != nil {
+			return res, fmt.Errorf("can`t put %v with serial %v: %w", srcPair.CN, srcPair.Serial, err)
+		}
+		if exists {
+			res.Updated = append(res.Updated, srcPair.Serial)
+		} else {
+			res.Added = append(res.Added, srcPair.Serial)
+		}
+		if last == nil || srcPair.Serial.Cmp(last) > 0 {
+			last = srcPair.Serial
+		}
+	}
+	if advancer, ok := dst.serialProvider.(SerialAdvancer); ok && last != nil {
+		if err := advancer.AdvanceTo(last); err != nil {
+			return res, fmt.Errorf("can`t advance serial: %w", err)
+		}
+	}
+	if res.CRL, err = syncCRL(src, dst); err != nil {
+		return res, err
+	}
+	if res.Changed() {
+		if err := dst.exportIndex(); err != nil {
+			return res, fmt.Errorf("can`t export index: %w", err)
+		}
+	}
+	return res, nil
+}
+
+// syncCRL put CRL of src into dst if it differs. There is nothing to do if src has no signed CRL yet.
+func syncCRL(src, dst *PKI) (bool, error) {
+	srcPEM, err := src.crlPEM()
+	if err != nil || srcPEM == nil {
+		return false, err
+	}
+	if dstPEM, err := dst.crlPEM(); err == nil && bytes.Equal(srcPEM, dstPEM) {
+		return false, nil
+	}
+	if err := dst.crlHolder.Put(srcPEM); err != nil {
+		return false, fmt.Errorf("can`t put crl: %w", err)
+	}
+	return true, nil
+}
+
+// pairFingerprint return sha256 of pair cn, certificate and key
+func pairFingerprint(p *pair.X509Pair) [32]byte {
+	h := sha256.New()
+	for _, part := range [][]byte{[]byte(p.CN), p.CertPemBytes, p.KeyPemBytes} {
+		_, _ = fmt.Fprintf(h, "%d:", len(part))
+		_, _ = h.Write(part)
+	}
+	var res [32]byte
+	copy(res[:], h.Sum(nil))
+	return res
+}
@@ -0,0 +1,58 @@
+package pki
+
+import (
+	"crypto/x509/pkix"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/kemsta/go-easyrsa/internal/fsStorage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSync(t *testing.T) {
+	src, cleanup := getTmpPki()
+	defer cleanup()
+	dir := t.TempDir()
+	serialProvider := fsStorage.NewFileSerialProvider(filepath.Join(dir, "serial"))
+	dst := NewPKI(fsStorage.NewDirKeyStorage(dir), serialProvider,
+		fsStorage.NewFileCRLHolder(filepath.Join(dir, "crl.pem")), pkix.Name{})
+
+	res, err := Sync(src, dst)
+	assert.NoError(t, err)
+	assert.False(t, res.Changed())
+
+	ca, err := src.NewCa()
+	assert.NoError(t, err)
+	user, err := src.NewCert("user", Client())
+	assert.NoError(t, err)
+	res, err = Sync(src, dst)
+	assert.NoError(t, err)
+	assert.Equal(t, []*big.Int{ca.Serial, user.Serial}, res.Added)
+	assert.Empty(t, res.Updated)
+	assert.False(t, res.CRL)
+	next, err := serialProvider.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), next.Int64())
+
+	assert.NoError(t, src.RevokeOne(user.Serial))
+	user.KeyPemBytes = append([]byte{}, user.KeyPemBytes...)
+	user.KeyPemBytes[len(user.KeyPemBytes)-1] = '\n'
+	user.CertPemBytes = ca.CertPemBytes
+	assert.NoError(t, src.Storage.Put(user))
+	res, err = Sync(src, dst)
+	assert.NoError(t, err)
+	assert.Empty(t, res.Added)
+	assert.Equal(t, []*big.Int{user.Serial}, res.Updated)
+	assert.True(t, res.CRL)
+	assert.True(t, dst.IsRevoked(user.Serial))
+	caCert, err := ca.DecodeCert()
+	assert.NoError(t, err)
+	list, err := dst.GetCRL()
+	assert.NoError(t, err)
+	assert.NoError(t, caCert.CheckCRLSignature(list))
+
+	res, err = Sync(src, dst)
+	assert.NoError(t, err)
+	assert.False(t, res.Changed())
+}
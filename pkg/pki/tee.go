@@ -0,0 +1,138 @@
+package pki
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/kemsta/go-easyrsa/pkg/pair"
+)
+
+// TeeKeyStorage write pairs to primary and secondary storages and read them from primary.
+// It keeps a new storage in sync with the old one during migration without downtime.
+type TeeKeyStorage struct {
+	Primary   KeyStorage
+	Secondary KeyStorage
+}
+
+// NewTeeKeyStorage TeeKeyStorage "constructor"
+func NewTeeKeyStorage(primary, secondary KeyStorage) *TeeKeyStorage {
+	return &TeeKeyStorage{Primary: primary, Secondary: secondary}
+}
+
+// Put pair to primary and then to secondary storage
+func (s *TeeKeyStorage) Put(pair *pair.X509Pair) error {
+	if err := s.Primary.Put(pair); err != nil {
+		return err
+	}
+	if err := s.Secondary.Put(pair); err != nil {
+		return fmt.Errorf("can`t put %v with serial %v to secondary storage: %w", pair.CN, pair.Serial, err)
+	}
+	return nil
+}
+
+// GetByCN return all pairs with cn from primary storage
+func (s *TeeKeyStorage) GetByCN(cn string) ([]*pair.X509Pair, error) {
+	return s.Primary.GetByCN(cn)
+}
+
+// GetLastByCn return last pair with cn from primary storage
+func (s *TeeKeyStorage) GetLastByCn(cn string) (*pair.X509Pair, error) {
+	return s.Primary.GetLastByCn(cn)
+}
+
+// GetBySerial return pair with serial from primary storage
+func (s *TeeKeyStorage) GetBySerial(serial *big.Int) (*pair.X509Pair, error) {
+	return s.Primary.GetBySerial(serial)
+}
+
+// DeleteByCn delete pairs with cn from primary and then from secondary storage
+func (s *TeeKeyStorage) DeleteByCn(cn string) error {
+	if err := s.Primary.DeleteByCn(cn); err != nil {
+		return err
+	}
+	if err := s.Secondary.DeleteByCn(cn); err != nil {
+		return fmt.Errorf("can`t delete %v from secondary storage: %w", cn, err)
+	}
+	return nil
+}
+
+// DeleteBySerial delete pair with serial from primary and then from secondary storage
+func (s *TeeKeyStorage) DeleteBySerial(serial *big.Int) error {
+	if err := s.Primary.DeleteBySerial(serial); err != nil {
+		return err
+	}
+	if err := s.Secondary.DeleteBySerial(serial); err != nil {
+		return fmt.Errorf("can`t delete serial %v from secondary storage: %w", serial, err)
+	}
+	return nil
+}
+
+// GetAll return all pairs from primary storage
+func (s *TeeKeyStorage) GetAll() ([]*pair.X509Pair, error) {
+	return s.Primary.GetAll()
+}
+
+// GetCertOnly return last certificate with cn from primary storage, without key if it`s a CertReader
+func (s *TeeKeyStorage) GetCertOnly(cn string) (*pair.X509Pair, error) {
+	if reader, ok := s.Primary.(CertReader); ok {
+		return reader.GetCertOnly(cn)
+	}
+	return s.Primary.GetLastByCn(cn)
+}
+
+// GetCertOnlyBySerial return certificate with serial from primary storage, without key if it`s a CertReader
+func (s *TeeKeyStorage) GetCertOnlyBySerial(serial *big.Int) (*pair.X509Pair, error) {
+	if reader, ok := s.Primary.(CertReader); ok {
+		return reader.GetCertOnlyBySerial(serial)
+	}
+	return s.Primary.GetBySerial(serial)
+}
+
+// GetAllCertOnly return all certificates from primary storage, without keys if it`s a CertReader
+func (s *TeeKeyStorage) GetAllCertOnly() ([]*pair.X509Pair, error) {
+	if reader, ok := s.Primary.(CertReader); ok {
+		return reader.GetAllCertOnly()
+	}
+	return s.Primary.GetAll()
+}
+
+// GetByCNPattern return pairs with cn matching pattern from primary storage, see PKI.GetByCNPattern
+func (s *TeeKeyStorage) GetByCNPattern(pattern string) ([]*pair.X509Pair, error) {
+	return getByCNPattern(s.Primary, pattern)
+}
+
+// Lock operation with name in primary storage if it`s a Locker
+func (s *TeeKeyStorage) Lock(name string) (unlock func() error, err error) {
+	if locker, ok := s.Primary.(Locker); ok {
+		return locker.Lock(name)
+	}
+	return func() error { return nil }, nil
+}
+
+// PairPaths return paths of pair files in primary storage if it`s a PairPather
+func (s *TeeKeyStorage) PairPaths(pair *pair.X509Pair) (certPath, keyPath string) {
+	if pather, ok := s.Primary.(PairPather); ok {
+		return pather.PairPaths(pair)
+	}
+	return "", ""
+}
+
+// Backfill copy pairs of primary storage which are missing in secondary one. It returns number of copied pairs.
+// Call it once after switching to TeeKeyStorage to migrate pairs issued before.
+func (s *TeeKeyStorage) Backfill() (int, error) {
+	pairs, err := s.Primary.GetAll()
+	if err != nil {
+		return 0, fmt.Errorf("can`t get pairs from primary storage: %w", err)
+	}
+	copied := 0
+	for _, p := range pairs {
+		if _, err := s.Secondary.GetBySerial(p.Serial); err == nil {
+			continue
+		}
+		if err := s.Secondary.Put(p); err != nil {
+			return copied, fmt.Errorf("can`t put %v with serial %v to secondary storage: %w", p.CN, p.Serial, err)
+		}
+		copied++
+	}
+	return copied, nil
+}
@@ -0,0 +1,51 @@
+package pki
+
+import (
+	"crypto/x509/pkix"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kemsta/go-easyrsa/internal/fsStorage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTeeKeyStorage(t *testing.T) {
+	_ = os.MkdirAll(testData, 0777)
+	defer func() {
+		_ = os.RemoveAll(testData)
+	}()
+	oldDir, newDir := filepath.Join(testData, "old"), filepath.Join(testData, "new")
+	oldStorage, newStorage := fsStorage.NewDirKeyStorage(oldDir), fsStorage.NewDirKeyStorage(newDir)
+	pki := NewPKI(oldStorage, fsStorage.NewFileSerialProvider(filepath.Join(testData, "serial")),
+		fsStorage.NewFileCRLHolder(filepath.Join(testData, "crl.pem")), pkix.Name{})
+	ca, err := pki.NewCa()
+	assert.NoError(t, err)
+
+	tee := NewTeeKeyStorage(oldStorage, newStorage)
+	pki.Storage = tee
+	cert, err := pki.NewCert("client", Client())
+	assert.NoError(t, err)
+	got, err := newStorage.GetBySerial(cert.Serial)
+	assert.NoError(t, err)
+	assert.Equal(t, cert.CertPemBytes, got.CertPemBytes)
+	_, err = newStorage.GetBySerial(ca.Serial)
+	assert.Error(t, err)
+
+	copied, err := tee.Backfill()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, copied)
+	_, err = newStorage.GetBySerial(ca.Serial)
+	assert.NoError(t, err)
+	copied, err = tee.Backfill()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, copied)
+
+	certPath, _ := tee.PairPaths(cert)
+	assert.Equal(t, filepath.Join(oldDir, "client", "2.crt"), certPath)
+	assert.NoError(t, tee.DeleteBySerial(cert.Serial))
+	_, err = newStorage.GetBySerial(cert.Serial)
+	assert.Error(t, err)
+	assert.Error(t, tee.DeleteBySerial(big.NewInt(100)))
+}
@@ -0,0 +1,143 @@
+package pki
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// ErrBadToken is returned when enrollment token is unknown, already used, expired or issued for other name or SANs
+var ErrBadToken = errors.New("bad enrollment token")
+
+// tokenRecord is a stored enrollment token. Token itself isn`t stored, only its hash is used as record id.
+type tokenRecord struct {
+	CommonName     string    `json:"cn,omitempty"` // the only common name token can enroll, any if empty, see MintAnyCNToken
+	DNSNames       []string  `json:"dns,omitempty"`
+	IPAddresses    []net.IP  `json:"ips,omitempty"`
+	EmailAddresses []string  `json:"emails,omitempty"`
+	URIs           []string  `json:"uris,omitempty"`
+	Expires        time.Time `json:"expires"`
+}
+
+// MintToken return single-use enrollment token valid for ttl bound to common name cn, see WithTokenStore.
+// CSR can have only SANs set by sans, e.g. RequestDNSNames, and no SANs without them. Names of CA pairs can`t be bound.
+func (p *PKI) MintToken(cn string, ttl time.Duration, sans ...RequestOption) (string, error) {
+	if cn == "" {
+		return "", errors.New("can`t mint token: common name is required, see MintAnyCNToken")
+	}
+	if p.reservedName(cn) {
+		return "", fmt.Errorf("can`t bind token to %v: %w", cn, ErrReservedName)
+	}
+	return p.mintToken(cn, ttl, sans)
+}
+
+// MintAnyCNToken return single-use enrollment token like MintToken, but its holder can choose any common name
+// except names of CA pairs. Prefer tokens bound to common name.
+func (p *PKI) MintAnyCNToken(ttl time.Duration, sans ...RequestOption) (string, error) {
+	return p.mintToken("", ttl, sans)
+}
+
+// mintToken store record of new token bound to cn, any common name if it`s empty
+func (p *PKI) mintToken(cn string, ttl time.Duration, sans []RequestOption) (string, error) {
+	if p.tokens == nil {
+		return "", errors.New("can`t mint token: no token store")
+	}
+	secret := make([]byte, 32)
+	if _, err := io.ReadFull(p.random(), secret); err != nil {
+		return "", fmt.Errorf("can`t generate token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+	allowed := &x509.CertificateRequest{}
+	for _, san := range sans {
+		san(allowed)
+	}
+	record := tokenRecord{
+		CommonName:     cn,
+		DNSNames:       allowed.DNSNames,
+		IPAddresses:    allowed.IPAddresses,
+		EmailAddresses: allowed.EmailAddresses,
+		Expires:        time.Now().Add(ttl).UTC(),
+	}
+	for _, uri := range allowed.URIs {
+		record.URIs = append(record.URIs, uri.String())
+	}
+	content, err := json.Marshal(record)
+	if err != nil {
+		return "", fmt.Errorf("can`t encode token: %w", err)
+	}
+	if err := p.tokens.Put(tokenID(token), content); err != nil {
+		return "", fmt.Errorf("can`t store token: %w", err)
+	}
+	return token, nil
+}
+
+// RedeemToken consume enrollment token for CSR. Token can`t be used again even if error is returned.
+func (p *PKI) RedeemToken(token string, req *x509.CertificateRequest) error {
+	if p.tokens == nil {
+		return errors.New("can`t redeem token: no token store")
+	}
+	content, err := p.tokens.Take(tokenID(token))
+	if err != nil {
+		return ErrBadToken
+	}
+	record := tokenRecord{}
+	if err := json.Unmarshal(content, &record); err != nil {
+		return fmt.Errorf("can`t decode token: %w", err)
+	}
+	if time.Now().After(record.Expires) {
+		return fmt.Errorf("%w: expired at %v", ErrBadToken, record.Expires.Format(time.RFC3339))
+	}
+	if record.CommonName != "" && record.CommonName != req.Subject.CommonName {
+		return fmt.Errorf("%w: it`s issued for other name", ErrBadToken)
+	}
+	return record.checkSANs(req)
+}
+
+// checkSANs return ErrBadToken if CSR has SAN the token isn`t issued for
+func (r tokenRecord) checkSANs(req *x509.CertificateRequest) error {
+	allowed := make(map[string]bool)
+	for _, name := range r.DNSNames {
+		allowed["dns:"+name] = true
+	}
+	for _, ip := range r.IPAddresses {
+		allowed["ip:"+ip.String()] = true
+	}
+	for _, email := range r.EmailAddresses {
+		allowed["email:"+email] = true
+	}
+	for _, uri := range r.URIs {
+		allowed["uri:"+uri] = true
+	}
+	var requested []string
+	for _, name := range req.DNSNames {
+		requested = append(requested, "dns:"+name)
+	}
+	for _, ip := range req.IPAddresses {
+		requested = append(requested, "ip:"+ip.String())
+	}
+	for _, email := range req.EmailAddresses {
+		requested = append(requested, "email:"+email)
+	}
+	for _, uri := range req.URIs {
+		requested = append(requested, "uri:"+uri.String())
+	}
+	for _, san := range requested {
+		if !allowed[san] {
+			return fmt.Errorf("%w: it`s not issued for %v", ErrBadToken, san)
+		}
+	}
+	return nil
+}
+
+// tokenID return hex sha256 of token
+func tokenID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,97 @@
+package pki
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPKI_MintToken(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	_, err := pki.MintToken("device", time.Hour)
+	assert.Error(t, err)
+	WithTokenDir(t.TempDir())(pki)
+	_, err = pki.MintToken("", time.Hour)
+	assert.Error(t, err)
+	_, err = pki.MintToken(pki.CAName(), time.Hour)
+	assert.ErrorIs(t, err, ErrReservedName)
+
+	request := func(cn string, opts ...RequestOption) *x509.CertificateRequest {
+		req := &x509.CertificateRequest{Subject: pkix.Name{CommonName: cn}}
+		for _, opt := range opts {
+			opt(req)
+		}
+		return req
+	}
+	token, err := pki.MintAnyCNToken(time.Hour)
+	assert.NoError(t, err)
+	assert.NoError(t, pki.RedeemToken(token, request("any")))
+	assert.ErrorIs(t, pki.RedeemToken(token, request("any")), ErrBadToken)
+	assert.ErrorIs(t, pki.RedeemToken("unknown", request("any")), ErrBadToken)
+
+	token, err = pki.MintToken("device", time.Hour)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, pki.RedeemToken(token, request("other")), ErrBadToken)
+	assert.ErrorIs(t, pki.RedeemToken(token, request("device")), ErrBadToken, "token is used by failed attempt")
+
+	token, err = pki.MintToken("device", -time.Minute)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, pki.RedeemToken(token, request("device")), ErrBadToken)
+
+	token, err = pki.MintToken("device", time.Hour)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, pki.RedeemToken(token, request("device", RequestDNSNames("device.example.com"))), ErrBadToken,
+		"token without SANs rejects any")
+
+	token, err = pki.MintToken("device", time.Hour,
+		RequestDNSNames("device.example.com"), RequestIPAddresses(net.ParseIP("192.0.2.1")))
+	assert.NoError(t, err)
+	assert.NoError(t, pki.RedeemToken(token, request("device",
+		RequestDNSNames("device.example.com"), RequestIPAddresses(net.ParseIP("192.0.2.1")))))
+	token, err = pki.MintToken("device", time.Hour, RequestDNSNames("device.example.com"))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, pki.RedeemToken(token, request("device",
+		RequestDNSNames("device.example.com", "bank.example.com"))), ErrBadToken)
+}
+
+func TestEnrollHandler_Token(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	WithTokenDir(t.TempDir())(pki)
+	_, err := pki.NewCa()
+	assert.NoError(t, err)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: "device"},
+	}, key)
+	assert.NoError(t, err)
+	token, err := pki.MintToken("device", time.Hour)
+	assert.NoError(t, err)
+
+	handler, err := NewEnrollHandler(pki, ProfileClient)
+	assert.NoError(t, err)
+	post := func(token string) int {
+		req := httptest.NewRequest(http.MethodPost, EnrollPath, bytes.NewReader(csr))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusUnauthorized, post(""))
+	assert.Equal(t, http.StatusOK, post(token))
+	assert.Equal(t, http.StatusUnauthorized, post(token))
+}
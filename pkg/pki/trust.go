@@ -0,0 +1,150 @@
+package pki
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// ImportTrustedCA add CA certificates from pem bundle to trust store. Certificates are stored by sha256
+// fingerprint of DER, so importing the same certificate twice is harmless. It returns fingerprints of certificates.
+func (p *PKI) ImportTrustedCA(bundle []byte) ([]string, error) {
+	if p.trustStore == nil {
+		return nil, errors.New("there is no trust store")
+	}
+	certs, err := parseCertificates(bundle)
+	if err != nil {
+		return nil, err
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("there are no certificates in bundle")
+	}
+	for _, cert := range certs {
+		if !cert.BasicConstraintsValid || !cert.IsCA {
+			return nil, fmt.Errorf("cert %v with serial %v is not a ca", cert.Subject.CommonName, cert.SerialNumber)
+		}
+	}
+	res := make([]string, 0, len(certs))
+	for _, cert := range certs {
+		fingerprint := certFingerprint(cert)
+		content := pem.EncodeToMemory(&pem.Block{Type: PEMCertificateBlock, Bytes: cert.Raw})
+		if err := p.trustStore.Put(fingerprint, content); err != nil {
+			return res, fmt.Errorf("can`t put trusted ca %v: %w", cert.Subject.CommonName, err)
+		}
+		res = append(res, fingerprint)
+	}
+	return res, nil
+}
+
+// RemoveTrustedCA remove CA certificate with sha256 fingerprint from trust store
+func (p *PKI) RemoveTrustedCA(fingerprint string) error {
+	if p.trustStore == nil {
+		return errors.New("there is no trust store")
+	}
+	return p.trustStore.Delete(fingerprint)
+}
+
+// TrustedCAs return certificates of trust store
+func (p *PKI) TrustedCAs() ([]*x509.Certificate, error) {
+	if p.trustStore == nil {
+		return []*x509.Certificate{}, nil
+	}
+	contents, err := p.trustStore.GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("can`t get trusted cas: %w", err)
+	}
+	return parseCertificates(bytes.Join(contents, nil))
+}
+
+// CertPool return pool of non-expired issuing CAs and trusted CAs, e.g. for tls.Config ClientCAs
+func (p *PKI) CertPool() (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	if _, caCerts, err := p.validCAs(); err == nil {
+		for _, cert := range caCerts {
+			pool.AddCert(cert)
+		}
+	}
+	trusted, err := p.TrustedCAs()
+	if err != nil {
+		return nil, err
+	}
+	for _, cert := range trusted {
+		pool.AddCert(cert)
+	}
+	return pool, nil
+}
+
+// Verify pem certificate of peer against issuing and trusted CAs. Certificates issued by this PKI
+// should not be revoked. Intermediates can be appended to certificate pem.
+func (p *PKI) Verify(certPEM []byte) ([][]*x509.Certificate, error) {
+	certs, err := parseCertificates(certPEM)
+	if err != nil {
+		return nil, err
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("there is no certificate to verify")
+	}
+	roots, err := p.CertPool()
+	if err != nil {
+		return nil, err
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	chains, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can`t verify %v with serial %v: %w", certs[0].Subject.CommonName, certs[0].SerialNumber, err)
+	}
+	if p.issuedHere(certs[0]) && p.IsRevoked(certs[0].SerialNumber) {
+		return nil, fmt.Errorf("%v with serial %v is revoked", certs[0].Subject.CommonName, certs[0].SerialNumber)
+	}
+	return chains, nil
+}
+
+// issuedHere return true if cert is signed by one of issuing CAs
+func (p *PKI) issuedHere(cert *x509.Certificate) bool {
+	_, caCerts, err := p.validCAs()
+	if err != nil {
+		return false
+	}
+	for _, caCert := range caCerts {
+		if cert.CheckSignatureFrom(caCert) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCertificates parse all certificate blocks of pem content
+func parseCertificates(content []byte) ([]*x509.Certificate, error) {
+	res := make([]*x509.Certificate, 0)
+	for {
+		var block *pem.Block
+		block, content = pem.Decode(content)
+		if block == nil {
+			return res, nil
+		}
+		if block.Type != PEMCertificateBlock {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("can`t parse certificate: %w", err)
+		}
+		res = append(res, cert)
+	}
+}
+
+func certFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,68 @@
+package pki
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPKI_Verify(t *testing.T) {
+	_ = os.MkdirAll(testData, 0777)
+	defer func() {
+		_ = os.RemoveAll(testData)
+	}()
+	pki, err := InitPKI(testData, nil)
+	assert.NoError(t, err)
+	_, err = pki.NewCa()
+	assert.NoError(t, err)
+	own, err := pki.NewCert("client", Client())
+	assert.NoError(t, err)
+
+	partnerKeyPEM, partnerPEM := foreignCA(t, big.NewInt(7), true)
+	partnerKey, err := parseCAKey(partnerKeyPEM)
+	assert.NoError(t, err)
+	partner, err := parseCertificates(partnerPEM)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(8),
+		Subject:      pkix.Name{CommonName: "partner client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, partner[0], partnerKey.Public(), partnerKey)
+	assert.NoError(t, err)
+	partnerLeaf := pem.EncodeToMemory(&pem.Block{Type: PEMCertificateBlock, Bytes: der})
+
+	_, err = pki.Verify(own.CertPemBytes)
+	assert.NoError(t, err)
+	_, err = pki.Verify(partnerLeaf)
+	assert.Error(t, err)
+
+	_, err = pki.ImportTrustedCA(partnerLeaf)
+	assert.Error(t, err)
+	fingerprints, err := pki.ImportTrustedCA(partnerPEM)
+	assert.NoError(t, err)
+	assert.Len(t, fingerprints, 1)
+	_, err = pki.Verify(partnerLeaf)
+	assert.NoError(t, err)
+	bundle, err := pki.GetTrustBundle()
+	assert.NoError(t, err)
+	trusted, err := parseCertificates(bundle)
+	assert.NoError(t, err)
+	assert.Len(t, trusted, 1)
+
+	assert.NoError(t, pki.RevokeOne(own.Serial))
+	_, err = pki.Verify(own.CertPemBytes)
+	assert.Error(t, err)
+	assert.NoError(t, pki.RemoveTrustedCA(fingerprints[0]))
+	_, err = pki.Verify(partnerLeaf)
+	assert.Error(t, err)
+}
@@ -0,0 +1,103 @@
+package pki
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// WindowsStore is a location of Windows certificate stores
+type WindowsStore string
+
+const (
+	WindowsCurrentUser  WindowsStore = "CurrentUser"
+	WindowsLocalMachine WindowsStore = "LocalMachine" // requires administrator rights to install
+)
+
+// windowsReadme is a README of windows bundle. Arguments are cn, serial, expiration date and store location.
+const windowsReadme = `Certificate bundle for %[1]s
+
+%[1]s.pfx - certificate, serial %[2]s, valid until %[3]s, with private key and CA chain
+ca-*.crt - certificate authorities to trust
+install.ps1 - PowerShell installer
+install.cmd - certutil installer for hosts without PowerShell
+
+Both installers put CA certificates to Root and CA stores and %[1]s.pfx to My store
+of %[4]s location and ask for the password of %[1]s.pfx. Run them from this
+directory, e.g. "powershell -ExecutionPolicy Bypass -File install.ps1".
+Nothing is written to registry directly.
+`
+
+// ExportWindows return zip with the last pair with cn as PKCS#12 file protected with password, every valid CA
+// certificate as ca-SERIAL.crt, PowerShell and certutil installers for store location and README.
+// It`s the bundle to deploy client certificates to Windows endpoints.
+func (p *PKI) ExportWindows(cn, password string, store WindowsStore) ([]byte, error) {
+	if store != WindowsCurrentUser && store != WindowsLocalMachine {
+		return nil, fmt.Errorf("unknown windows store location %q", store)
+	}
+	if password == "" {
+		return nil, errors.New("pfx password is required")
+	}
+	last, err := p.Storage.GetLastByCn(cn)
+	if err != nil {
+		return nil, fmt.Errorf("can`t get %v: %w", cn, err)
+	}
+	cert, err := last.DecodeCert()
+	if err != nil {
+		return nil, fmt.Errorf("can`t decode %v cert: %w", cn, err)
+	}
+	pfx, err := p.ExportPFX(cn, password)
+	if err != nil {
+		return nil, err
+	}
+	caPairs, caCerts, err := p.validCAs()
+	if err != nil {
+		return nil, fmt.Errorf("can`t get ca chain: %w", err)
+	}
+	var ps, cmd bytes.Buffer
+	userFlag := ""
+	if store == WindowsCurrentUser {
+		userFlag = " -user"
+	}
+	fmt.Fprintf(&ps, "# Installs %v certificate and its certificate authorities\n", cn)
+	ps.WriteString("param([SecureString]$Password)\n$ErrorActionPreference = 'Stop'\nSet-Location -LiteralPath $PSScriptRoot\n")
+	cmd.WriteString("@echo off\n")
+	fmt.Fprintf(&cmd, "rem Installs %v certificate and its certificate authorities\n", cn)
+	cmd.WriteString("cd /d \"%~dp0\"\n")
+	files := []zipFile{{name: cn + ".pfx", content: pfx}}
+	for i, caCert := range caCerts {
+		name := "ca-" + caPairs[i].Serial.Text(16) + ".crt"
+		files = append(files, zipFile{name: name, content: caPairs[i].CertPemBytes})
+		caStore := "CA"
+		if caCert.CheckSignatureFrom(caCert) == nil {
+			caStore = "Root"
+		}
+		fmt.Fprintf(&ps, "Import-Certificate -FilePath '%v' -CertStoreLocation 'Cert:\\%v\\%v' | Out-Null\n",
+			psQuote(name), store, caStore)
+		fmt.Fprintf(&cmd, "certutil -f%v -addstore %v \"%v\" || exit /b 1\n", userFlag, caStore, name)
+	}
+	fmt.Fprintf(&ps, "if (-not $Password) { $Password = Read-Host -AsSecureString -Prompt 'Password of %v.pfx' }\n",
+		psQuote(cn))
+	fmt.Fprintf(&ps, "Import-PfxCertificate -FilePath '%v.pfx' -CertStoreLocation 'Cert:\\%v\\My' -Password $Password\n",
+		psQuote(cn), store)
+	fmt.Fprintf(&cmd, "certutil -f%v -importPFX \"%v.pfx\" || exit /b 1\n", userFlag, cn)
+	readme := fmt.Sprintf(windowsReadme, cn, last.Serial.Text(16), cert.NotAfter.UTC().Format(time.RFC1123), store)
+	files = append(files,
+		zipFile{name: "install.ps1", content: crlf(ps.String())},
+		zipFile{name: "install.cmd", content: crlf(cmd.String())},
+		zipFile{name: "README.txt", content: crlf(readme)},
+	)
+	return zipFiles(files)
+}
+
+// psQuote escape s for single quoted PowerShell string
+func psQuote(s string) string {
+	return strings.ReplaceAll(s, "'", "''")
+}
+
+// crlf return text with windows line breaks
+func crlf(text string) []byte {
+	return []byte(strings.ReplaceAll(text, "\n", "\r\n"))
+}
@@ -0,0 +1,57 @@
+package pki
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPKI_ExportWindows(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	ca, err := pki.NewCa()
+	assert.NoError(t, err)
+	client, err := pki.NewCert("client", Client())
+	assert.NoError(t, err)
+
+	_, err = pki.ExportWindows("client", "secret", "Somewhere")
+	assert.Error(t, err)
+	_, err = pki.ExportWindows("client", "", WindowsCurrentUser)
+	assert.Error(t, err)
+	_, err = pki.ExportWindows("nobody", "secret", WindowsCurrentUser)
+	assert.Error(t, err)
+
+	content, err := pki.ExportWindows("client", "secret", WindowsCurrentUser)
+	assert.NoError(t, err)
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	must(t, assert.NoError(t, err))
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		assert.NoError(t, err)
+		data, _ := ioutil.ReadAll(rc)
+		_ = rc.Close()
+		files[f.Name] = string(data)
+	}
+	assert.Len(t, files, 5)
+	caFile := "ca-" + ca.Serial.Text(16) + ".crt"
+	assert.Equal(t, string(ca.CertPemBytes), files[caFile])
+	certs, _, _ := readPFX(t, []byte(files["client.pfx"]), "secret")
+	if assert.NotEmpty(t, certs) {
+		assert.Equal(t, client.Serial, certs[0].SerialNumber)
+	}
+	assert.Contains(t, files["install.ps1"],
+		"Import-Certificate -FilePath '"+caFile+"' -CertStoreLocation 'Cert:\\CurrentUser\\Root' | Out-Null\r\n")
+	assert.Contains(t, files["install.ps1"],
+		"Import-PfxCertificate -FilePath 'client.pfx' -CertStoreLocation 'Cert:\\CurrentUser\\My' -Password $Password\r\n")
+	assert.Contains(t, files["install.cmd"], "certutil -f -user -addstore Root \""+caFile+"\" || exit /b 1\r\n")
+	assert.Contains(t, files["install.cmd"], "certutil -f -user -importPFX \"client.pfx\" || exit /b 1\r\n")
+	assert.Contains(t, files["README.txt"], "CurrentUser")
+
+	content, err = pki.ExportWindows("client", "secret", WindowsLocalMachine)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, content)
+}
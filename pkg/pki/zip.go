@@ -0,0 +1,78 @@
+package pki
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"time"
+)
+
+// zipReadme is a README of client zip bundle. Arguments are cn, serial, expiration date and optional ta.key line.
+const zipReadme = `Certificate bundle for %[1]s
+
+%[1]s.crt - client certificate, serial %[2]s, valid until %[3]s
+%[1]s.key - private key, keep it secret and never send it to anyone
+ca.crt - certificate authority, use it to verify the server
+%[4]s
+For OpenVPN put the files next to the client config and refer to them with
+"ca", "cert", "key"%[5]s directives.
+`
+
+// ExportZip return zip with the last pair with cn, ca chain as ca.crt, README and OpenVPN tls-auth key
+// as ta.key if taKey isn`t empty. It`s the bundle usually handed to end users.
+func (p *PKI) ExportZip(cn string, taKey []byte) ([]byte, error) {
+	last, err := p.Storage.GetLastByCn(cn)
+	if err != nil {
+		return nil, fmt.Errorf("can`t get %v: %w", cn, err)
+	}
+	cert, err := last.DecodeCert()
+	if err != nil {
+		return nil, fmt.Errorf("can`t decode %v cert: %w", cn, err)
+	}
+	chain, err := p.GetTrustBundle()
+	if err != nil {
+		return nil, fmt.Errorf("can`t get ca chain: %w", err)
+	}
+	taLine, taDirective := "", ""
+	if len(taKey) != 0 {
+		taLine, taDirective = "ta.key - OpenVPN tls-auth key, keep it secret as well\n", " and \"tls-auth ta.key 1\""
+	}
+	readme := fmt.Sprintf(zipReadme, cn, last.Serial.Text(16), cert.NotAfter.UTC().Format(time.RFC1123), taLine, taDirective)
+	return zipFiles([]zipFile{
+		{name: cn + ".crt", content: last.CertPemBytes},
+		{name: cn + ".key", content: last.KeyPemBytes},
+		{name: "ca.crt", content: chain},
+		{name: "ta.key", content: taKey},
+		{name: "README.txt", content: []byte(readme)},
+	})
+}
+
+type zipFile struct {
+	name    string
+	content []byte
+}
+
+// zipFiles return zip with files readable only by owner, empty files are skipped
+func zipFiles(files []zipFile) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	now := time.Now()
+	for _, file := range files {
+		if len(file.content) == 0 {
+			continue
+		}
+		header := &zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: now}
+		header.SetMode(0600)
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			return nil, fmt.Errorf("can`t add %v to zip: %w", file.name, err)
+		}
+		if _, err := w.Write(file.content); err != nil {
+			return nil, fmt.Errorf("can`t add %v to zip: %w", file.name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("can`t close zip: %w", err)
+	}
+	return buf.Bytes(), nil
+}
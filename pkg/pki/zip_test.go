@@ -0,0 +1,51 @@
+package pki
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPKI_ExportZip(t *testing.T) {
+	pki, cleanup := getTmpPki()
+	defer cleanup()
+	ca, err := pki.NewCa()
+	assert.NoError(t, err)
+	client, err := pki.NewCert("client")
+	assert.NoError(t, err)
+
+	readZip := func(content []byte) map[string]string {
+		zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+		assert.NoError(t, err)
+		res := map[string]string{}
+		for _, f := range zr.File {
+			rc, err := f.Open()
+			assert.NoError(t, err)
+			data, _ := ioutil.ReadAll(rc)
+			_ = rc.Close()
+			res[f.Name] = string(data)
+		}
+		return res
+	}
+
+	content, err := pki.ExportZip("client", nil)
+	assert.NoError(t, err)
+	files := readZip(content)
+	assert.Len(t, files, 4)
+	assert.Equal(t, string(client.CertPemBytes), files["client.crt"])
+	assert.Equal(t, string(client.KeyPemBytes), files["client.key"])
+	assert.Equal(t, string(ca.CertPemBytes), files["ca.crt"])
+	assert.NotContains(t, files["README.txt"], "ta.key")
+
+	content, err = pki.ExportZip("client", []byte("ta"))
+	assert.NoError(t, err)
+	files = readZip(content)
+	assert.Equal(t, "ta", files["ta.key"])
+	assert.Contains(t, files["README.txt"], "tls-auth ta.key 1")
+
+	_, err = pki.ExportZip("nobody", nil)
+	assert.Error(t, err)
+}
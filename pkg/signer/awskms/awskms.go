@@ -0,0 +1,180 @@
+// Package awskms provide crypto.Signer for asymmetric AWS KMS keys, so CA private key never leaves KMS.
+// Requests are signed with AWS signature version 4, no AWS SDK is required.
+//
+// Signer can be passed to pki.WithSigner together with CA certificate imported by PKI.ImportCaCert:
+//
+//	signer, err := awskms.New("alias/pki-ca")
+//	pki, err := pki.InitPKI(dir, nil, pki.WithSigner(signer))
+//	_, err = pki.ImportCaCert(caPEM)
+package awskms
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kemsta/go-easyrsa/internal/sigv4"
+)
+
+// Signer sign digests with asymmetric KMS key
+type Signer struct {
+	KeyID           string // key id, arn or alias
+	Endpoint        string // e.g. http://localstack:4566, https://kms.<Region>.amazonaws.com by default
+	Region          string // key region, us-east-1 by default
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string       // token of temporary credentials, optional
+	Client          *http.Client // http.DefaultClient by default
+	public          crypto.PublicKey
+}
+
+// New return signer of key with keyID and read its public key. Region, endpoint and credentials are taken
+// from AWS_REGION, AWS_DEFAULT_REGION, AWS_ENDPOINT_URL, AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN environment variables.
+func New(keyID string) (*Signer, error) {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	s := &Signer{
+		KeyID:           keyID,
+		Endpoint:        os.Getenv("AWS_ENDPOINT_URL"),
+		Region:          region,
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if err := s.Init(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Init read public key of KeyID. It must be called before signing if Signer isn`t created by New.
+// Only RSA and ECDSA keys are supported.
+func (s *Signer) Init() error {
+	var resp struct {
+		PublicKey []byte
+	}
+	if err := s.call("GetPublicKey", map[string]string{"KeyId": s.KeyID}, &resp); err != nil {
+		return fmt.Errorf("can`t get public key %v: %w", s.KeyID, err)
+	}
+	public, err := x509.ParsePKIXPublicKey(resp.PublicKey)
+	if err != nil {
+		return fmt.Errorf("can`t parse public key %v: %w", s.KeyID, err)
+	}
+	switch public.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+	default:
+		return fmt.Errorf("unsupported key type %T", public)
+	}
+	s.public = public
+	return nil
+}
+
+// Public return public key of KMS key
+func (s *Signer) Public() crypto.PublicKey {
+	return s.public
+}
+
+// Sign digest with KMS key. RSA keys sign with PKCS#1 v1.5 or PSS with salt of hash length,
+// ECDSA signatures are ASN.1 encoded.
+func (s *Signer) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	algorithm, err := s.algorithm(opts)
+	if err != nil {
+		return nil, err
+	}
+	req := map[string]interface{}{
+		"KeyId":            s.KeyID,
+		"Message":          digest,
+		"MessageType":      "DIGEST",
+		"SigningAlgorithm": algorithm,
+	}
+	var resp struct {
+		Signature []byte
+	}
+	if err := s.call("Sign", req, &resp); err != nil {
+		return nil, fmt.Errorf("can`t sign with %v: %w", s.KeyID, err)
+	}
+	return resp.Signature, nil
+}
+
+// algorithm return KMS signing algorithm for key type and opts
+func (s *Signer) algorithm(opts crypto.SignerOpts) (string, error) {
+	hashes := map[crypto.Hash]string{crypto.SHA256: "SHA_256", crypto.SHA384: "SHA_384", crypto.SHA512: "SHA_512"}
+	hash, ok := hashes[opts.HashFunc()]
+	if !ok {
+		return "", fmt.Errorf("unsupported hash %v", opts.HashFunc())
+	}
+	switch s.public.(type) {
+	case *rsa.PublicKey:
+		pss, ok := opts.(*rsa.PSSOptions)
+		if !ok {
+			return "RSASSA_PKCS1_V1_5_" + hash, nil
+		}
+		if pss.SaltLength != rsa.PSSSaltLengthEqualsHash && pss.SaltLength != opts.HashFunc().Size() {
+			return "", errors.New("only pss salt of hash length is supported")
+		}
+		return "RSASSA_PSS_" + hash, nil
+	case *ecdsa.PublicKey:
+		return "ECDSA_" + hash, nil
+	}
+	return "", fmt.Errorf("unsupported key type %T", s.public)
+}
+
+// call KMS action with json request and decode json response into resp
+func (s *Signer) call(action string, req interface{}, resp interface{}) error {
+	region := s.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://kms.%s.amazonaws.com", region)
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(endpoint, "/")+"/", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("can`t create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	httpReq.Header.Set("X-Amz-Target", "TrentService."+action)
+	sigv4.Sign(httpReq, body, sigv4.Credentials{
+		AccessKeyID:     s.AccessKeyID,
+		SecretAccessKey: s.SecretAccessKey,
+		SessionToken:    s.SessionToken,
+	}, region, "kms", time.Now().UTC())
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	httpResp, err := client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = httpResp.Body.Close()
+	}()
+	respBody, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return err
+	}
+	if httpResp.StatusCode/100 != 2 {
+		return fmt.Errorf("%v: %s", httpResp.Status, bytes.TrimSpace(respBody))
+	}
+	return json.Unmarshal(respBody, resp)
+}
@@ -0,0 +1,72 @@
+package awskms
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeKMS serve GetPublicKey and Sign actions for key
+func fakeKMS(t *testing.T, key *ecdsa.PrivateKey) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=id/"))
+		assert.Contains(t, r.Header.Get("Authorization"), "/eu-west-1/kms/aws4_request")
+		var req struct {
+			KeyId            string
+			Message          []byte
+			MessageType      string
+			SigningAlgorithm string
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if req.KeyId != "alias/ca" {
+			http.Error(w, `{"__type":"NotFoundException"}`, http.StatusBadRequest)
+			return
+		}
+		switch r.Header.Get("X-Amz-Target") {
+		case "TrentService.GetPublicKey":
+			der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"PublicKey": der})
+		case "TrentService.Sign":
+			assert.Equal(t, "DIGEST", req.MessageType)
+			assert.Equal(t, "ECDSA_SHA_256", req.SigningAlgorithm)
+			signature, _ := ecdsa.SignASN1(rand.Reader, key, req.Message)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"Signature": signature})
+		default:
+			http.Error(w, "unknown action", http.StatusBadRequest)
+		}
+	}))
+}
+
+func TestSigner(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	server := fakeKMS(t, key)
+	defer server.Close()
+	t.Setenv("AWS_ENDPOINT_URL", server.URL)
+	t.Setenv("AWS_REGION", "eu-west-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "id")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	signer, err := New("alias/ca")
+	assert.NoError(t, err)
+	assert.True(t, key.PublicKey.Equal(signer.Public()))
+	digest := sha256.Sum256([]byte("tbs"))
+	signature, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	assert.NoError(t, err)
+	assert.True(t, ecdsa.VerifyASN1(&key.PublicKey, digest[:], signature))
+	_, err = signer.Sign(rand.Reader, digest[:], crypto.SHA1)
+	assert.Error(t, err)
+
+	_, err = New("alias/other")
+	assert.Error(t, err)
+}
@@ -0,0 +1,233 @@
+// Package azurekv provide crypto.Signer for Azure Key Vault keys, so CA private key never leaves Key Vault
+// or Managed HSM. REST API is used, no Azure SDK is required.
+//
+// Signer can be passed to pki.WithSigner together with CA certificate imported by PKI.ImportCaCert:
+//
+//	signer, err := azurekv.New("https://pki.vault.azure.net/keys/ca")
+//	pki, err := pki.InitPKI(dir, nil, pki.WithSigner(signer))
+package azurekv
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/kemsta/go-easyrsa/pkg/pair"
+)
+
+// APIVersion is Key Vault REST API version
+const APIVersion = "7.4"
+
+// Resource is OAuth2 resource of Key Vault tokens
+const Resource = "https://vault.azure.net"
+
+// imdsTimeout limit wait of instance metadata service, it`s unreachable outside of Azure
+const imdsTimeout = 2 * time.Second
+
+// imdsTokenURL is managed identity token endpoint of instance metadata service
+var imdsTokenURL = "http://169.254.169.254/metadata/identity/oauth2/token"
+
+// TokenSource return OAuth2 access token for Key Vault requests
+type TokenSource func() (string, error)
+
+// Signer sign digests with Key Vault key
+type Signer struct {
+	KeyID  string       // key identifier, e.g. https://<vault>.vault.azure.net/keys/<name>[/<version>]
+	Token  TokenSource  // DefaultTokenSource by default
+	Client *http.Client // http.DefaultClient by default
+	kid    string       // versioned key identifier
+	public crypto.PublicKey
+}
+
+// New return signer of key with identifier and read its public key with DefaultTokenSource.
+// The current version is used if identifier has no version.
+func New(keyID string) (*Signer, error) {
+	s := &Signer{KeyID: keyID}
+	if err := s.Init(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// DefaultTokenSource return token from AZURE_ACCESS_TOKEN environment variable, from managed identity
+// if instance metadata service is available or from az account get-access-token otherwise
+func DefaultTokenSource() (string, error) {
+	if token := os.Getenv("AZURE_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+	if token, err := imdsToken(); err == nil {
+		return token, nil
+	}
+	out, err := exec.Command("az", "account", "get-access-token", "--resource", Resource,
+		"--query", "accessToken", "--output", "tsv").Output()
+	if err != nil {
+		return "", fmt.Errorf("can`t get access token with az: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func imdsToken() (string, error) {
+	query := url.Values{"api-version": {"2018-02-01"}, "resource": {Resource}}
+	req, err := http.NewRequest(http.MethodGet, imdsTokenURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata", "true")
+	resp, err := (&http.Client{Timeout: imdsTimeout}).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("instance metadata service: %v", resp.Status)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
+
+// Init read public key and version of KeyID. It must be called before signing if Signer isn`t created by New.
+// Only RSA and EC keys on P-256, P-384 and P-521 curves are supported.
+func (s *Signer) Init() error {
+	var resp struct {
+		Key pair.JWK `json:"key"`
+	}
+	if err := s.call(http.MethodGet, strings.TrimSuffix(s.KeyID, "/"), nil, &resp); err != nil {
+		return fmt.Errorf("can`t get key %v: %w", s.KeyID, err)
+	}
+	resp.Key.Kty = strings.TrimSuffix(resp.Key.Kty, "-HSM")
+	public, err := resp.Key.PublicKey()
+	if err != nil {
+		return fmt.Errorf("can`t parse key %v: %w", s.KeyID, err)
+	}
+	switch public.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+	default:
+		return fmt.Errorf("unsupported key type %T", public)
+	}
+	s.public, s.kid = public, resp.Key.Kid
+	return nil
+}
+
+// Public return public key of Key Vault key
+func (s *Signer) Public() crypto.PublicKey {
+	return s.public
+}
+
+// Sign digest with Key Vault key. RSA keys sign with PKCS#1 v1.5 or PSS with salt of hash length,
+// ECDSA signatures are ASN.1 encoded.
+func (s *Signer) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	algorithm, err := s.algorithm(opts)
+	if err != nil {
+		return nil, err
+	}
+	b64 := base64.RawURLEncoding
+	req := map[string]string{"alg": algorithm, "value": b64.EncodeToString(digest)}
+	var resp struct {
+		Value string `json:"value"`
+	}
+	if err := s.call(http.MethodPost, s.kid+"/sign", req, &resp); err != nil {
+		return nil, fmt.Errorf("can`t sign with %v: %w", s.kid, err)
+	}
+	signature, err := b64.DecodeString(resp.Value)
+	if err != nil {
+		return nil, fmt.Errorf("bad signature of %v: %w", s.kid, err)
+	}
+	if _, ok := s.public.(*ecdsa.PublicKey); ok {
+		// Key Vault returns JWS signature r || s
+		half := len(signature) / 2
+		return asn1.Marshal(struct{ R, S *big.Int }{
+			new(big.Int).SetBytes(signature[:half]),
+			new(big.Int).SetBytes(signature[half:]),
+		})
+	}
+	return signature, nil
+}
+
+// algorithm return JWA signing algorithm for key type and opts
+func (s *Signer) algorithm(opts crypto.SignerOpts) (string, error) {
+	sizes := map[crypto.Hash]string{crypto.SHA256: "256", crypto.SHA384: "384", crypto.SHA512: "512"}
+	size, ok := sizes[opts.HashFunc()]
+	if !ok {
+		return "", fmt.Errorf("unsupported hash %v", opts.HashFunc())
+	}
+	switch s.public.(type) {
+	case *rsa.PublicKey:
+		pss, ok := opts.(*rsa.PSSOptions)
+		if !ok {
+			return "RS" + size, nil
+		}
+		if pss.SaltLength != rsa.PSSSaltLengthEqualsHash && pss.SaltLength != opts.HashFunc().Size() {
+			return "", fmt.Errorf("only pss salt of hash length is supported")
+		}
+		return "PS" + size, nil
+	case *ecdsa.PublicKey:
+		return "ES" + size, nil
+	}
+	return "", fmt.Errorf("unsupported key type %T", s.public)
+}
+
+// call Key Vault url with json request and decode json response into resp
+func (s *Signer) call(method, keyURL string, req interface{}, resp interface{}) error {
+	tokenSource := s.Token
+	if tokenSource == nil {
+		tokenSource = DefaultTokenSource
+	}
+	token, err := tokenSource()
+	if err != nil {
+		return err
+	}
+	var body io.Reader
+	if req != nil {
+		content, err := json.Marshal(req)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(content)
+	}
+	httpReq, err := http.NewRequest(method, keyURL+"?api-version="+APIVersion, body)
+	if err != nil {
+		return fmt.Errorf("can`t create request: %w", err)
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+token)
+	httpReq.Header.Set("Content-Type", "application/json")
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	httpResp, err := client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = httpResp.Body.Close()
+	}()
+	respBody, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return err
+	}
+	if httpResp.StatusCode/100 != 2 {
+		return fmt.Errorf("%v: %s", httpResp.Status, bytes.TrimSpace(respBody))
+	}
+	return json.Unmarshal(respBody, resp)
+}
@@ -0,0 +1,70 @@
+package azurekv
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeVault serve get key and sign operations of ca key version 1
+func fakeVault(t *testing.T, key *ecdsa.PrivateKey) *httptest.Server {
+	b64 := base64.RawURLEncoding
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		assert.Equal(t, APIVersion, r.URL.Query().Get("api-version"))
+		switch {
+		case r.Method == http.MethodGet && (r.URL.Path == "/keys/ca" || r.URL.Path == "/keys/ca/1"):
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"key": map[string]string{
+				"kid": server.URL + "/keys/ca/1",
+				"kty": "EC-HSM",
+				"crv": "P-256",
+				"x":   b64.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+				"y":   b64.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+			}})
+		case r.Method == http.MethodPost && r.URL.Path == "/keys/ca/1/sign":
+			var req map[string]string
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			assert.Equal(t, "ES256", req["alg"])
+			digest, err := b64.DecodeString(req["value"])
+			assert.NoError(t, err)
+			sr, ss, err := ecdsa.Sign(rand.Reader, key, digest)
+			assert.NoError(t, err)
+			signature := append(sr.FillBytes(make([]byte, 32)), ss.FillBytes(make([]byte, 32))...)
+			_ = json.NewEncoder(w).Encode(map[string]string{"kid": req["kid"], "value": b64.EncodeToString(signature)})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return server
+}
+
+func TestSigner(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	server := fakeVault(t, key)
+	defer server.Close()
+	t.Setenv("AZURE_ACCESS_TOKEN", "token")
+
+	signer, err := New(server.URL + "/keys/ca")
+	assert.NoError(t, err)
+	assert.True(t, key.PublicKey.Equal(signer.Public()))
+	digest := sha256.Sum256([]byte("tbs"))
+	signature, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	assert.NoError(t, err)
+	assert.True(t, ecdsa.VerifyASN1(&key.PublicKey, digest[:], signature))
+	_, err = signer.Sign(rand.Reader, digest[:], crypto.SHA1)
+	assert.Error(t, err)
+
+	_, err = New(server.URL + "/keys/other")
+	assert.Error(t, err)
+}
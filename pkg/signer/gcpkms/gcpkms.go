@@ -0,0 +1,204 @@
+// Package gcpkms provide crypto.Signer for asymmetric Google Cloud KMS key versions, so CA private key
+// never leaves Cloud KMS. REST API is used, no Google Cloud SDK is required.
+//
+// Signer can be passed to pki.WithSigner together with CA certificate imported by PKI.ImportCaCert:
+//
+//	signer, err := gcpkms.New("projects/p/locations/global/keyRings/pki/cryptoKeys/ca/cryptoKeyVersions/1")
+//	pki, err := pki.InitPKI(dir, nil, pki.WithSigner(signer))
+package gcpkms
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// DefaultEndpoint is Cloud KMS REST endpoint
+const DefaultEndpoint = "https://cloudkms.googleapis.com"
+
+// metadataTimeout limit wait of metadata server, it`s unreachable outside of Google Cloud
+const metadataTimeout = 2 * time.Second
+
+// metadataTokenURL is token endpoint of GCE metadata server for default service account
+var metadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// TokenSource return OAuth2 access token for Cloud KMS requests
+type TokenSource func() (string, error)
+
+// Signer sign digests with Cloud KMS asymmetric key version
+type Signer struct {
+	Name      string       // key version resource name
+	Endpoint  string       // DefaultEndpoint by default
+	Token     TokenSource  // DefaultTokenSource by default
+	Client    *http.Client // http.DefaultClient by default
+	algorithm string
+	public    crypto.PublicKey
+}
+
+// New return signer of key version with resource name and read its public key with DefaultTokenSource.
+// Endpoint can be changed with CLOUDKMS_ENDPOINT environment variable, e.g. for emulator.
+func New(name string) (*Signer, error) {
+	s := &Signer{Name: name, Endpoint: os.Getenv("CLOUDKMS_ENDPOINT")}
+	if err := s.Init(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// DefaultTokenSource return token from GOOGLE_OAUTH_ACCESS_TOKEN environment variable,
+// from GCE metadata server if it`s available or from gcloud auth print-access-token otherwise
+func DefaultTokenSource() (string, error) {
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+	if token, err := metadataToken(); err == nil {
+		return token, nil
+	}
+	out, err := exec.Command("gcloud", "auth", "print-access-token").Output()
+	if err != nil {
+		return "", fmt.Errorf("can`t get access token with gcloud: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func metadataToken() (string, error) {
+	req, err := http.NewRequest(http.MethodGet, metadataTokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := (&http.Client{Timeout: metadataTimeout}).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata server: %v", resp.Status)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
+
+// Init read public key and algorithm of key version. It must be called before signing if Signer isn`t created
+// by New. Only RSA and EC signing keys are supported.
+func (s *Signer) Init() error {
+	var resp struct {
+		Pem       string `json:"pem"`
+		Algorithm string `json:"algorithm"`
+	}
+	if err := s.call(http.MethodGet, "/publicKey", nil, &resp); err != nil {
+		return fmt.Errorf("can`t get public key %v: %w", s.Name, err)
+	}
+	block, _ := pem.Decode([]byte(resp.Pem))
+	if block == nil {
+		return fmt.Errorf("can`t parse public key %v: no pem block", s.Name)
+	}
+	public, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("can`t parse public key %v: %w", s.Name, err)
+	}
+	switch public.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+	default:
+		return fmt.Errorf("unsupported key type %T", public)
+	}
+	s.public, s.algorithm = public, resp.Algorithm
+	return nil
+}
+
+// Public return public key of key version
+func (s *Signer) Public() crypto.PublicKey {
+	return s.public
+}
+
+// Sign digest with key version. Algorithm is fixed by key version, so opts must match it:
+// PKCS#1 v1.5 or PSS padding for RSA keys and the same hash.
+func (s *Signer) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	hashes := map[crypto.Hash]string{crypto.SHA256: "sha256", crypto.SHA384: "sha384", crypto.SHA512: "sha512"}
+	hash, ok := hashes[opts.HashFunc()]
+	if !ok || !strings.HasSuffix(s.algorithm, strings.ToUpper(hash)) {
+		return nil, fmt.Errorf("hash %v doesn`t match key algorithm %v", opts.HashFunc(), s.algorithm)
+	}
+	if strings.HasPrefix(s.algorithm, "RSA_") {
+		_, pss := opts.(*rsa.PSSOptions)
+		if pss != strings.HasPrefix(s.algorithm, "RSA_SIGN_PSS_") {
+			return nil, fmt.Errorf("rsa padding doesn`t match key algorithm %v", s.algorithm)
+		}
+	}
+	req := map[string]interface{}{"digest": map[string][]byte{hash: digest}}
+	var resp struct {
+		Signature []byte `json:"signature"`
+	}
+	if err := s.call(http.MethodPost, ":asymmetricSign", req, &resp); err != nil {
+		return nil, fmt.Errorf("can`t sign with %v: %w", s.Name, err)
+	}
+	return resp.Signature, nil
+}
+
+// call key version method with json request and decode json response into resp
+func (s *Signer) call(method, suffix string, req interface{}, resp interface{}) error {
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+	tokenSource := s.Token
+	if tokenSource == nil {
+		tokenSource = DefaultTokenSource
+	}
+	token, err := tokenSource()
+	if err != nil {
+		return err
+	}
+	var body io.Reader
+	if req != nil {
+		content, err := json.Marshal(req)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(content)
+	}
+	httpReq, err := http.NewRequest(method, strings.TrimSuffix(endpoint, "/")+"/v1/"+s.Name+suffix, body)
+	if err != nil {
+		return fmt.Errorf("can`t create request: %w", err)
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+token)
+	httpReq.Header.Set("Content-Type", "application/json")
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	httpResp, err := client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = httpResp.Body.Close()
+	}()
+	respBody, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return err
+	}
+	if httpResp.StatusCode/100 != 2 {
+		return fmt.Errorf("%v: %s", httpResp.Status, bytes.TrimSpace(respBody))
+	}
+	return json.Unmarshal(respBody, resp)
+}
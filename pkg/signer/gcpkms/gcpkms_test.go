@@ -0,0 +1,65 @@
+package gcpkms
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const keyName = "projects/p/locations/global/keyRings/pki/cryptoKeys/ca/cryptoKeyVersions/1"
+
+// fakeKMS serve publicKey and asymmetricSign methods of key version
+func fakeKMS(t *testing.T, key *ecdsa.PrivateKey) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/"+keyName+"/publicKey":
+			der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+			_ = json.NewEncoder(w).Encode(map[string]string{
+				"pem":       string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+				"algorithm": "EC_SIGN_P256_SHA256",
+			})
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/"+keyName+":asymmetricSign":
+			var req struct {
+				Digest map[string][]byte `json:"digest"`
+			}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			signature, _ := ecdsa.SignASN1(rand.Reader, key, req.Digest["sha256"])
+			_ = json.NewEncoder(w).Encode(map[string][]byte{"signature": signature})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestSigner(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	server := fakeKMS(t, key)
+	defer server.Close()
+	t.Setenv("CLOUDKMS_ENDPOINT", server.URL)
+	t.Setenv("GOOGLE_OAUTH_ACCESS_TOKEN", "token")
+
+	signer, err := New(keyName)
+	assert.NoError(t, err)
+	assert.True(t, key.PublicKey.Equal(signer.Public()))
+	digest := sha256.Sum256([]byte("tbs"))
+	signature, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	assert.NoError(t, err)
+	assert.True(t, ecdsa.VerifyASN1(&key.PublicKey, digest[:], signature))
+	_, err = signer.Sign(rand.Reader, digest[:], crypto.SHA384)
+	assert.Error(t, err)
+
+	_, err = New(keyName + "0")
+	assert.Error(t, err)
+}
@@ -0,0 +1,48 @@
+// Package piv provide crypto.Signer for keys kept in PIV slots of YubiKey and other PIV smart cards.
+// Token is accessed through ykcs11 PKCS#11 module with pkcs11-tool, so no cgo is required.
+//
+// Root CA key can be generated in slot 9c with ykman and used to sign certificates:
+//
+//	signer, err := piv.New(piv.SlotSignature, pin)
+//	pki, err := pki.InitPKI(dir, nil, pki.WithSigner(signer))
+package piv
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kemsta/go-easyrsa/pkg/signer/pkcs11"
+)
+
+// Module is PKCS#11 module of PIV tokens. Module from yubico-piv-tool is found by library search path.
+var Module = "libykcs11.so"
+
+// PIV key slots
+const (
+	SlotAuthentication = "9a"
+	SlotSignature      = "9c" // digital signature slot, the usual place of CA keys
+	SlotKeyManagement  = "9d"
+	SlotCardAuth       = "9e"
+)
+
+// keyIDs are ids of slot key objects in ykcs11
+var keyIDs = map[string]string{
+	SlotAuthentication: "01",
+	SlotSignature:      "02",
+	SlotKeyManagement:  "03",
+	SlotCardAuth:       "04",
+}
+
+// New return signer of key in PIV slot of the first token. pin is verified by token on every signature,
+// so slots with pin policy "always" work too.
+func New(slot, pin string) (*pkcs11.Signer, error) {
+	keyID, ok := keyIDs[strings.ToLower(slot)]
+	if !ok {
+		return nil, fmt.Errorf("unknown piv slot %q, one of 9a, 9c, 9d and 9e is expected", slot)
+	}
+	signer, err := pkcs11.New(Module, "", keyID, pin)
+	if err != nil {
+		return nil, fmt.Errorf("can`t open piv slot %v: %w", slot, err)
+	}
+	return signer, nil
+}
@@ -0,0 +1,76 @@
+package piv
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/kemsta/go-easyrsa/pkg/signer/pkcs11"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeTool write pkcs11-tool replacement which keeps key of slot 9c in dir and signs with openssl
+func fakeTool(t *testing.T, key *ecdsa.PrivateKey) string {
+	if _, err := exec.LookPath("openssl"); err != nil {
+		t.Skip("openssl is required")
+	}
+	dir := t.TempDir()
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "key.pem"), keyPem, 0600))
+	pubDer, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "pub.der"), pubDer, 0600))
+	script := `#!/bin/sh
+while [ $# -gt 0 ]; do
+  case "$1" in
+    --module) [ "$2" = "libykcs11.so" ] || exit 1; shift ;;
+    --id) [ "$2" = "02" ] || exit 1; shift ;;
+    --read-object) op=read ;;
+    --sign) op=sign ;;
+    --pin) [ "$2" = "env:PKCS11_PIN" ] && [ "$PKCS11_PIN" = "123456" ] || exit 1; shift ;;
+    --input-file) in="$2"; shift ;;
+    --output-file) out="$2"; shift ;;
+  esac
+  shift
+done
+case "$op" in
+  read) cp "` + dir + `/pub.der" "$out" ;;
+  sign) openssl pkeyutl -sign -inkey "` + dir + `/key.pem" -in "$in" -out "$out" ;;
+esac
+`
+	tool := filepath.Join(dir, "pkcs11-tool")
+	assert.NoError(t, ioutil.WriteFile(tool, []byte(script), 0700))
+	return tool
+}
+
+func TestNew(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	defer func(tool string) {
+		pkcs11.Tool = tool
+	}(pkcs11.Tool)
+	pkcs11.Tool = fakeTool(t, key)
+
+	signer, err := New("9C", "123456")
+	assert.NoError(t, err)
+	assert.True(t, key.PublicKey.Equal(signer.Public()))
+	digest := sha256.Sum256([]byte("tbs"))
+	signature, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	assert.NoError(t, err)
+	assert.True(t, ecdsa.VerifyASN1(&key.PublicKey, digest[:], signature))
+
+	_, err = New(SlotAuthentication, "123456")
+	assert.Error(t, err)
+	_, err = New("82", "123456")
+	assert.Error(t, err)
+}
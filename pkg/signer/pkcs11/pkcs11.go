@@ -0,0 +1,135 @@
+// Package pkcs11 provide crypto.Signer for keys kept in PKCS#11 tokens like HSMs, smart cards or SoftHSM.
+// Token is accessed with pkcs11-tool from OpenSC, so no cgo is required. Pin is passed with --pin env:NAME, so
+// pkcs11-tool of OpenSC release supporting it is required.
+//
+// Signer can be passed to pki.WithSigner, so CA certificates and CRLs are signed by the token:
+//
+//	signer, err := pkcs11.New("/usr/lib/softhsm/libsofthsm2.so", "0", "01", pin)
+//	pki, err := pki.InitPKI(dir, nil, pki.WithSigner(signer))
+package pkcs11
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// Tool is pkcs11-tool binary used for token operations
+var Tool = "pkcs11-tool"
+
+// pinEnv is environment variable passing pin to Tool with --pin env:NAME, so pin isn`t visible in process list
+const pinEnv = "PKCS11_PIN"
+
+// digestInfoPrefixes are DER prefixes of DigestInfo for RSA PKCS#1 v1.5 signatures
+var digestInfoPrefixes = map[crypto.Hash][]byte{
+	crypto.SHA1:   {0x30, 0x21, 0x30, 0x09, 0x06, 0x05, 0x2b, 0x0e, 0x03, 0x02, 0x1a, 0x05, 0x00, 0x04, 0x14},
+	crypto.SHA256: {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+	crypto.SHA384: {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+	crypto.SHA512: {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+}
+
+// Signer sign with private key object of token slot. Key never leaves the token.
+type Signer struct {
+	Module string // path of PKCS#11 module
+	Slot   string // slot id, the first slot with token is used if it`s empty
+	KeyID  string // hex id of key object
+	PIN    string // user pin
+	public crypto.PublicKey
+}
+
+// New return signer of key with keyID in token slot. Public key is read from the token,
+// only RSA and ECDSA keys are supported.
+func New(module, slot, keyID, pin string) (*Signer, error) {
+	s := &Signer{Module: module, Slot: slot, KeyID: keyID, PIN: pin}
+	der, err := s.run(nil, "--read-object", "--type", "pubkey", "--id", keyID)
+	if err != nil {
+		return nil, fmt.Errorf("can`t read public key %v: %w", keyID, err)
+	}
+	public, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		rsaPublic, rsaErr := x509.ParsePKCS1PublicKey(der)
+		if rsaErr != nil {
+			return nil, fmt.Errorf("can`t parse public key %v: %w", keyID, err)
+		}
+		public = rsaPublic
+	}
+	switch public.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", public)
+	}
+	s.public = public
+	return s, nil
+}
+
+// Public return public key of token key
+func (s *Signer) Public() crypto.PublicKey {
+	return s.public
+}
+
+// Sign digest with token key. RSA keys sign with PKCS#1 v1.5 padding, PSS isn`t supported.
+// ECDSA signatures are ASN.1 encoded like ecdsa.SignASN1 does.
+func (s *Signer) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	switch s.public.(type) {
+	case *rsa.PublicKey:
+		if _, ok := opts.(*rsa.PSSOptions); ok {
+			return nil, errors.New("rsa pss signatures aren`t supported")
+		}
+		prefix, ok := digestInfoPrefixes[opts.HashFunc()]
+		if !ok {
+			return nil, fmt.Errorf("unsupported hash %v", opts.HashFunc())
+		}
+		return s.run(append(append([]byte{}, prefix...), digest...), "--sign", "--mechanism", "RSA-PKCS", "--id", s.KeyID)
+	case *ecdsa.PublicKey:
+		return s.run(digest, "--sign", "--mechanism", "ECDSA", "--signature-format", "openssl", "--id", s.KeyID)
+	}
+	return nil, fmt.Errorf("unsupported key type %T", s.public)
+}
+
+// run Tool with module, slot, pin and args. Pin is passed in environment, input is passed with --input-file
+// if it isn`t nil, content of --output-file is returned.
+func (s *Signer) run(input []byte, args ...string) ([]byte, error) {
+	dir, err := ioutil.TempDir("", "pkcs11")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	full := []string{"--module", s.Module}
+	if s.Slot != "" {
+		full = append(full, "--slot", s.Slot)
+	}
+	if s.PIN != "" {
+		full = append(full, "--login", "--pin", "env:"+pinEnv)
+	}
+	full = append(full, args...)
+	if input != nil {
+		in := filepath.Join(dir, "in")
+		if err := ioutil.WriteFile(in, input, 0600); err != nil {
+			return nil, err
+		}
+		full = append(full, "--input-file", in)
+	}
+	out := filepath.Join(dir, "out")
+	full = append(full, "--output-file", out)
+	cmd := exec.Command(Tool, full...)
+	if s.PIN != "" {
+		cmd.Env = append(os.Environ(), pinEnv+"="+s.PIN)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%v: %w: %s", Tool, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return ioutil.ReadFile(out)
+}
@@ -0,0 +1,73 @@
+package pkcs11
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeTool write pkcs11-tool replacement which keeps key in dir and signs with openssl
+func fakeTool(t *testing.T, key *rsa.PrivateKey) string {
+	if _, err := exec.LookPath("openssl"); err != nil {
+		t.Skip("openssl is required")
+	}
+	dir := t.TempDir()
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "key.pem"), keyPem, 0600))
+	pubDer, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "pub.der"), pubDer, 0600))
+	script := `#!/bin/sh
+while [ $# -gt 0 ]; do
+  case "$1" in
+    --read-object) op=read ;;
+    --sign) op=sign ;;
+    --pin) [ "$2" = "env:PKCS11_PIN" ] && [ "$PKCS11_PIN" = "1234" ] || exit 1; shift ;;
+    --input-file) in="$2"; shift ;;
+    --output-file) out="$2"; shift ;;
+  esac
+  shift
+done
+case "$op" in
+  read) cp "` + dir + `/pub.der" "$out" ;;
+  sign) openssl pkeyutl -sign -inkey "` + dir + `/key.pem" -in "$in" -out "$out" ;;
+esac
+`
+	tool := filepath.Join(dir, "pkcs11-tool")
+	assert.NoError(t, ioutil.WriteFile(tool, []byte(script), 0700))
+	return tool
+}
+
+func TestSigner(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	defer func(tool string) {
+		Tool = tool
+	}(Tool)
+	Tool = fakeTool(t, key)
+
+	signer, err := New("module.so", "0", "01", "1234")
+	assert.NoError(t, err)
+	assert.True(t, key.PublicKey.Equal(signer.Public()))
+
+	digest := sha256.Sum256([]byte("tbs"))
+	signature, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	assert.NoError(t, err)
+	assert.NoError(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature))
+
+	_, err = signer.Sign(rand.Reader, digest[:], &rsa.PSSOptions{Hash: crypto.SHA256})
+	assert.Error(t, err)
+
+	signer.PIN = "0000"
+	_, err = signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	assert.Error(t, err)
+}